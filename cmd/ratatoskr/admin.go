@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"ratatoskr/internal/config"
+	"ratatoskr/internal/db"
+	"strings"
+)
+
+type subcommand struct {
+	name        string
+	description string
+	run         command
+}
+
+// subcommands groups admin commands such as "tags export" under one
+// command, which runs the subcommand named by its first argument.
+func subcommands(name string, list ...subcommand) command {
+	return func(
+		ctx context.Context,
+		args []string,
+		getenv func(string) string,
+		open openDB,
+		stdout io.Writer,
+		stderr io.Writer,
+	) error {
+		lines := []string{fmt.Sprintf("usage: ratatoskr %s <command> [flags]\n\ncommands:", name)}
+		for _, s := range list {
+			lines = append(lines, fmt.Sprintf("  %-10s %s", s.name, s.description))
+		}
+		usage := strings.Join(lines, "\n") + "\n"
+		if len(args) == 0 {
+			fmt.Fprint(stderr, usage)
+			return errUsage
+		}
+		switch args[0] {
+		case "help", "-h", "--help":
+			fmt.Fprint(stdout, usage)
+			return nil
+		}
+		for _, s := range list {
+			if s.name == args[0] {
+				return s.run(ctx, args[1:], getenv, open, stdout, stderr)
+			}
+		}
+		fmt.Fprintf(stderr, "unknown command \"%s %s\"\n\n%s", name, args[0], usage)
+		return errUsage
+	}
+}
+
+// connect opens the database with the settings from getenv. Admin commands
+// report errors on stderr instead of logging them.
+func connect(
+	ctx context.Context,
+	getenv func(string) string,
+	open openDB,
+	stderr io.Writer,
+) (db.DB, error) {
+	c, err := config.GetDBConfig(getenv)
+	if err != nil {
+		return nil, report(stderr, err)
+	}
+	database, err := open(ctx, c.MongoURI, c.MongoDBName)
+	if err != nil {
+		return nil, report(stderr, fmt.Errorf("failed to connect to database: %w", err))
+	}
+	return database, nil
+}
+
+func report(stderr io.Writer, err error) error {
+	fmt.Fprintf(stderr, "%s\n", err)
+	return err
+}
+
+// readInput reads the file named by path, or stdin when path is empty or "-".
+func readInput(path string) ([]byte, error) {
+	if path == "" || path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// writeOutput calls write with the file named by path, or with stdout when
+// path is empty or "-". A file is only kept when write succeeds.
+func writeOutput(path string, stdout io.Writer, write func(io.Writer) error) (err error) {
+	if path == "" || path == "-" {
+		return write(stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, f.Close())
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+	return write(f)
+}
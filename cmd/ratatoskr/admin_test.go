@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/models"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// adminDBMock records what the admin commands write.
+type adminDBMock struct {
+	dbMock
+	saved   *[]models.Group
+	indexes bool
+	pingErr error
+}
+
+func (m *adminDBMock) UpdateTags(_ context.Context, groups *[]models.Group) error {
+	m.saved = groups
+	return nil
+}
+
+func (m *adminDBMock) Ping(context.Context) error {
+	return m.pingErr
+}
+
+func (m *adminDBMock) EnsureIndexes(context.Context) error {
+	m.indexes = true
+	return nil
+}
+
+func (m *adminDBMock) StreamAnalytics(
+	_ context.Context,
+	_ time.Time,
+	_ time.Time,
+	fn func(models.Analytics) error,
+) error {
+	return fn(models.Analytics{UserID: 1234, Tag: "#tag1", Group: "group1"})
+}
+
+func TestAdminCommands(t *testing.T) {
+	dir := t.TempDir()
+	textFile := filepath.Join(dir, "tags.txt")
+	os.WriteFile(textFile, []byte("• Group 1:\n#tag1 = one\n\n• Group 2:\n#tag2\n"), 0o600)
+	jsonFile := filepath.Join(dir, "tags.json")
+	os.WriteFile(jsonFile, []byte(`{"groups":[{"name":"Group 1","tags":[{"name":"#tag1"}]}]}`), 0o600)
+	invalidFile := filepath.Join(dir, "invalid.txt")
+	os.WriteFile(invalidFile, []byte("• Group 1\ntag1\n"), 0o600)
+
+	type tc struct {
+		name        string
+		args        []string
+		database    *adminDBMock
+		shouldError bool
+		expected    error
+		stdout      []string
+		stderr      []string
+		check       func(*adminDBMock) error
+	}
+
+	table := []tc{
+		{
+			name:   "should export tags as text",
+			args:   []string{"tags", "export"},
+			stdout: []string{"• group1:\n#tag1\n#tag2\n#tag3\n\n• group2:\n#tag4"},
+		},
+
+		{
+			name:   "should export tags as json",
+			args:   []string{"tags", "export", "-format", "json"},
+			stdout: []string{`"groups": [`, `"name": "#tag4"`},
+		},
+
+		{
+			name:   "should import tags",
+			args:   []string{"tags", "import", textFile},
+			stdout: []string{"imported 2 groups with 2 tags"},
+			check: func(m *adminDBMock) error {
+				expected := &[]models.Group{
+					{Name: "Group 1", Tags: []models.Tag{{Name: "#tag1", Aliases: []string{"one"}}}},
+					{Name: "Group 2", OriginalIndex: 1, Tags: []models.Tag{{Name: "#tag2"}}},
+				}
+				if !reflect.DeepEqual(expected, m.saved) {
+					return errors.New("wrong tags saved")
+				}
+				return nil
+			},
+		},
+
+		{
+			name:   "should import json by file name",
+			args:   []string{"tags", "import", jsonFile},
+			stdout: []string{"imported 1 groups with 1 tags"},
+		},
+
+		{
+			name:        "should not import invalid tags",
+			args:        []string{"tags", "import", invalidFile},
+			shouldError: true,
+			stderr:      []string{"line 1: group name must end with a colon", "line 2: tag must start with #"},
+			check: func(m *adminDBMock) error {
+				if m.saved != nil {
+					return errors.New("invalid tags were saved")
+				}
+				return nil
+			},
+		},
+
+		{
+			name:   "should validate tags",
+			args:   []string{"tags", "validate", "-format", "text", textFile},
+			stdout: []string{"valid: 2 groups with 2 tags"},
+		},
+
+		{
+			name:        "should reject unknown subcommand",
+			args:        []string{"tags", "delete"},
+			shouldError: true,
+			expected:    errUsage,
+			stderr:      []string{`unknown command "tags delete"`, "usage: ratatoskr tags <command>"},
+		},
+
+		{
+			name:   "should export analytics",
+			args:   []string{"analytics", "export", "-from", "2024-01-01", "-to", "2024-01-31"},
+			stdout: []string{"1234", "#tag1"},
+		},
+
+		{
+			name:        "should reject invalid dates",
+			args:        []string{"analytics", "export", "-from", "01.01.2024"},
+			shouldError: true,
+			stderr:      []string{`invalid from date "01.01.2024"`},
+		},
+
+		{
+			name:   "should ping database",
+			args:   []string{"db", "ping"},
+			stdout: []string{"ok"},
+		},
+
+		{
+			name:        "should report failed ping",
+			args:        []string{"db", "ping"},
+			database:    &adminDBMock{pingErr: errors.New("no reachable servers")},
+			shouldError: true,
+			stderr:      []string{"failed to ping database: no reachable servers"},
+		},
+
+		{
+			name:   "should ensure indexes",
+			args:   []string{"db", "indexes", "ensure"},
+			stdout: []string{"indexes ensured"},
+			check: func(m *adminDBMock) error {
+				if !m.indexes {
+					return errors.New("indexes were not ensured")
+				}
+				return nil
+			},
+		},
+
+		{
+			name:   "should check config with secrets redacted",
+			args:   []string{"config", "check", "webapp"},
+			stdout: []string{"# webapp", "TOKEN=[redacted]", "PORT=8088"},
+		},
+
+		{
+			name:        "should report config problems",
+			args:        []string{"config", "check", "bot"},
+			shouldError: true,
+			stderr:      []string{"required WEBAPP_URL was not provided"},
+		},
+	}
+
+	for _, test := range table {
+		if test.database == nil {
+			test.database = &adminDBMock{}
+		}
+		var stdout, stderr strings.Builder
+		err := run(
+			context.Background(),
+			test.args,
+			getEnv,
+			func(context.Context, string, string) (db.DB, error) { return test.database, nil },
+			&stdout,
+			&stderr,
+		)
+		if (err != nil) != test.shouldError {
+			t.Errorf("%s - unexpected error: %v\n%s", test.name, err, stderr.String())
+		}
+		if test.expected != nil && !errors.Is(err, test.expected) {
+			t.Errorf("%s - wrong error\nexpected: %v\nactual:   %v", test.name, test.expected, err)
+		}
+		for _, expected := range test.stdout {
+			if !strings.Contains(stdout.String(), expected) {
+				t.Errorf("%s - expected %q in stdout:\n%s", test.name, expected, stdout.String())
+			}
+		}
+		for _, expected := range test.stderr {
+			if !strings.Contains(stderr.String(), expected) {
+				t.Errorf("%s - expected %q in stderr:\n%s", test.name, expected, stderr.String())
+			}
+		}
+		if test.check != nil {
+			if err := test.check(test.database); err != nil {
+				t.Errorf("%s - %v", test.name, err)
+			}
+		}
+	}
+}
+
+func TestTagsExportToFile(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "tags.json")
+	var stdout, stderr strings.Builder
+	err := run(
+		context.Background(),
+		[]string{"tags", "export", "-output", output},
+		getEnv,
+		func(context.Context, string, string) (db.DB, error) { return dbMock{}, nil },
+		&stdout,
+		&stderr,
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, stderr.String())
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read export: %v", err)
+	}
+	var validate strings.Builder
+	err = run(
+		context.Background(),
+		[]string{"tags", "validate", output},
+		getEnv,
+		nil,
+		&validate,
+		&stderr,
+	)
+	if err != nil {
+		t.Errorf("exported tags are not valid: %v\n%s\n%s", err, data, stderr.String())
+	}
+	if !strings.Contains(validate.String(), "valid: 2 groups with 6 tags") {
+		t.Errorf("wrong validation output: %s", validate.String())
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"ratatoskr/internal/analytics"
+	"time"
+)
+
+var runAnalytics = subcommands(
+	"analytics",
+	subcommand{"export", "write raw analytics to stdout or a file", runAnalyticsExport},
+)
+
+func runAnalyticsExport(
+	ctx context.Context,
+	args []string,
+	getenv func(string) string,
+	open openDB,
+	stdout io.Writer,
+	stderr io.Writer,
+) error {
+	flags := newCommandFlags(
+		"analytics export",
+		"Writes the raw analytics of a date range, like the webapp export. Raw\nevents are only kept for ANALYTICS_RETENTION_DAYS.",
+		stderr,
+	)
+	from := flags.set.String("from", "", "first `date` to export, YYYY-MM-DD (default the first record)")
+	to := flags.set.String("to", "", "last `date` to export, YYYY-MM-DD (default today)")
+	format := flags.set.String("format", analytics.FormatCSV, "`format` of the export, csv or json")
+	output := flags.set.String("output", "", "`file` to write to instead of stdout")
+	getenv, err := flags.parse(args, getenv)
+	if err != nil {
+		return err
+	}
+	*format, err = analytics.ParseFormat(*format)
+	if err != nil {
+		return report(stderr, err)
+	}
+	start, end, err := analytics.ParseRange(*from, *to, time.Now())
+	if err != nil {
+		return report(stderr, err)
+	}
+	database, err := connect(ctx, getenv, open, stderr)
+	if err != nil {
+		return err
+	}
+	err = writeOutput(*output, stdout, func(w io.Writer) error {
+		return analytics.Export(ctx, database, w, *format, start, end)
+	})
+	if err != nil {
+		return report(stderr, fmt.Errorf("failed to export analytics: %w", err))
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"ratatoskr/internal/analytics"
+	"ratatoskr/internal/bot"
+	"ratatoskr/internal/config"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/events"
+	"ratatoskr/internal/logger"
+	"ratatoskr/internal/sessions"
+	"ratatoskr/internal/settings"
+	"time"
+)
+
+func runBot(
+	ctx context.Context,
+	args []string,
+	getenv func(string) string,
+	open openDB,
+	stdout io.Writer,
+	stderr io.Writer,
+) error {
+	flags := newCommandFlags("bot", "Runs the Telegram bot.", stderr)
+	flags.setting("health-addr", "HEALTH_ADDR", "`address` of the health and metrics listener")
+	getenv, err := flags.parse(args, getenv)
+	if err != nil {
+		return err
+	}
+	c, err := config.GetBotConfig(getenv)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return err
+	}
+	l := logger.NewLogger("Telegram bot", stdout, c.Log)
+	l.Info("configuration loaded", "config", c)
+	db, err := open(ctx, c.MongoURI, c.MongoDBName)
+	if err != nil {
+		return l.Error("failed to connect to database", "error", err)
+	}
+	err = ensureIndexes(ctx, db, l)
+	if err != nil {
+		return err
+	}
+	analyticsOutbox, closeOutbox, err := startOutbox(ctx, db, l, c.AnalyticsOutboxPath)
+	if err != nil {
+		return err
+	}
+	defer closeOutbox()
+	return startBot(ctx, c, analyticsOutbox, l, nil, nil, nil)
+}
+
+// startBot runs the bot and its background jobs until ctx is done. db
+// should write analytics through an outbox.
+func startBot(
+	ctx context.Context,
+	c *config.BotConfig,
+	db db.DB,
+	l *logger.Logger,
+	runtime *settings.Store,
+	bus *events.Bus,
+	store *sessions.Store,
+) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go analytics.NewRollupJob(db, l, time.Hour, c.AnalyticsRetention, time.Now).Run(ctx)
+
+	return bot.Run(ctx, db, l, c, runtime, bus, store)
+}
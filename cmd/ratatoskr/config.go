@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"ratatoskr/internal/config"
+)
+
+var runConfig = subcommands(
+	"config",
+	subcommand{"check", "validate the configuration and print it with secrets redacted", runConfigCheck},
+)
+
+func runConfigCheck(
+	ctx context.Context,
+	args []string,
+	getenv func(string) string,
+	open openDB,
+	stdout io.Writer,
+	stderr io.Writer,
+) error {
+	flags := newCommandFlags(
+		"config check",
+		"Validates the configuration of a command, serve (the default) checks\nboth the bot and the webapp, db only the database settings.",
+		stderr,
+	)
+	flags.args("[bot|webapp|serve|db]", 1)
+	getenv, err := flags.parse(args, getenv)
+	if err != nil {
+		return err
+	}
+	// configs are printed as "# name" followed by NAME=value lines
+	type checked struct {
+		name   string
+		config fmt.Stringer
+	}
+	var configs []checked
+	switch target := flags.set.Arg(0); target {
+	case "bot":
+		c, err := config.GetBotConfig(getenv)
+		if err != nil {
+			return report(stderr, err)
+		}
+		configs = append(configs, checked{"bot", c})
+	case "webapp":
+		c, err := config.GetWebAppConfig(getenv)
+		if err != nil {
+			return report(stderr, err)
+		}
+		configs = append(configs, checked{"webapp", c})
+	case "", "serve":
+		bot, webApp, err := config.GetServeConfig(getenv)
+		if err != nil {
+			return report(stderr, err)
+		}
+		configs = append(configs, checked{"bot", bot}, checked{"webapp", webApp})
+	case "db":
+		c, err := config.GetDBConfig(getenv)
+		if err != nil {
+			return report(stderr, err)
+		}
+		configs = append(configs, checked{"db", c})
+	default:
+		fmt.Fprintf(stderr, "unknown command %q to check\n", target)
+		flags.set.Usage()
+		return errUsage
+	}
+	for i, c := range configs {
+		if i != 0 {
+			fmt.Fprintln(stdout)
+		}
+		fmt.Fprintf(stdout, "# %s\n%s\n", c.name, c.config)
+	}
+	return nil
+}
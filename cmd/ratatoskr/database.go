@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/logger"
+	"time"
+)
+
+const dbTimeout = 30 * time.Second
+
+var runDB = subcommands(
+	"db",
+	subcommand{"ping", "check that the database can be reached", runDBPing},
+	subcommand{"indexes", "manage the indexes, see \"db indexes help\"", runDBIndexes},
+)
+
+func runDBPing(
+	ctx context.Context,
+	args []string,
+	getenv func(string) string,
+	open openDB,
+	stdout io.Writer,
+	stderr io.Writer,
+) error {
+	flags := newCommandFlags("db ping", "Checks that the database can be reached.", stderr)
+	getenv, err := flags.parse(args, getenv)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+	database, err := connect(ctx, getenv, open, stderr)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	err = database.Ping(ctx)
+	if err != nil {
+		return report(stderr, fmt.Errorf("failed to ping database: %w", err))
+	}
+	fmt.Fprintf(stdout, "ok (%s)\n", time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// ensureIndexes creates the indexes once on start, writes do not create
+// them.
+func ensureIndexes(ctx context.Context, database db.DB, l *logger.Logger) error {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+	err := database.EnsureIndexes(ctx)
+	if err != nil {
+		return l.Error("failed to ensure indexes", "error", err)
+	}
+	return nil
+}
+
+var runDBIndexes = subcommands(
+	"db indexes",
+	subcommand{"ensure", "create the indexes that do not exist yet", runDBIndexesEnsure},
+)
+
+func runDBIndexesEnsure(
+	ctx context.Context,
+	args []string,
+	getenv func(string) string,
+	open openDB,
+	stdout io.Writer,
+	stderr io.Writer,
+) error {
+	flags := newCommandFlags(
+		"db indexes ensure",
+		"Creates the indexes the bot and the webapp create on start, for example\nafter restoring a backup.",
+		stderr,
+	)
+	getenv, err := flags.parse(args, getenv)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+	database, err := connect(ctx, getenv, open, stderr)
+	if err != nil {
+		return err
+	}
+	err = database.EnsureIndexes(ctx)
+	if err != nil {
+		return report(stderr, fmt.Errorf("failed to ensure indexes: %w", err))
+	}
+	fmt.Fprintln(stdout, "indexes ensured")
+	return nil
+}
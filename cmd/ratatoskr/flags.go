@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+)
+
+// commandFlags are the flags of a command. A flag overrides the environment
+// variable of its setting, everything else still comes from the environment
+// and CONFIG_FILE.
+type commandFlags struct {
+	set       *flag.FlagSet
+	overrides map[string]*string
+	// arguments describes the positional arguments, a command without it
+	// takes none
+	arguments string
+	maxArgs   int
+}
+
+func newCommandFlags(name string, description string, stderr io.Writer) *commandFlags {
+	set := flag.NewFlagSet("ratatoskr "+name, flag.ContinueOnError)
+	set.SetOutput(stderr)
+	f := &commandFlags{set: set, overrides: map[string]*string{}}
+	set.Usage = func() {
+		fmt.Fprintf(set.Output(), "usage: ratatoskr %s [flags]%s\n\n%s\n\nflags:\n", name, f.arguments, description)
+		set.PrintDefaults()
+	}
+	f.setting("config", "CONFIG_FILE", "YAML `file` with the settings")
+	return f
+}
+
+func (f *commandFlags) setting(name string, env string, usage string) {
+	f.overrides[env] = f.set.String(name, "", fmt.Sprintf("%s (overrides %s)", usage, env))
+}
+
+// args allows up to max positional arguments, shown as usage in the help.
+func (f *commandFlags) args(usage string, max int) {
+	f.arguments = " " + usage
+	f.maxArgs = max
+}
+
+// parse returns getenv with the flags that were set applied. It returns
+// flag.ErrHelp when help was asked for and errUsage for invalid flags.
+func (f *commandFlags) parse(args []string, getenv func(string) string) (func(string) string, error) {
+	err := f.set.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, errUsage
+	}
+	if f.set.NArg() > f.maxArgs {
+		fmt.Fprintf(f.set.Output(), "unexpected arguments %q\n", f.set.Args())
+		f.set.Usage()
+		return nil, errUsage
+	}
+	return func(name string) string {
+		if value := f.overrides[name]; value != nil && *value != "" {
+			return *value
+		}
+		return getenv(name)
+	}, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/mongo_db"
+	"syscall"
+	"time"
+)
+
+const usage = `usage: ratatoskr <command> [flags]
+
+commands:
+  bot        run the Telegram bot
+  webapp     run the webapp server
+  serve      run the bot and the webapp in one process
+
+admin commands:
+  tags       export, import or validate the tag menu
+  analytics  export raw analytics
+  db         check the database and create its indexes
+  config     validate the configuration
+
+Run "ratatoskr <command> --help" for the flags of a command and
+"ratatoskr <command> help" for the subcommands of an admin command.
+`
+
+var errUsage = errors.New("invalid usage")
+
+type openDB func(ctx context.Context, URI string, database string) (db.DB, error)
+
+type command func(
+	ctx context.Context,
+	args []string,
+	getenv func(string) string,
+	open openDB,
+	stdout io.Writer,
+	stderr io.Writer,
+) error
+
+var commands = map[string]command{
+	"bot":       runBot,
+	"webapp":    runWebApp,
+	"serve":     runServe,
+	"tags":      runTags,
+	"analytics": runAnalytics,
+	"db":        runDB,
+	"config":    runConfig,
+}
+
+func run(
+	ctx context.Context,
+	args []string,
+	getenv func(string) string,
+	open openDB,
+	stdout io.Writer,
+	stderr io.Writer,
+) error {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return errUsage
+	}
+	name, args := args[0], args[1:]
+	switch name {
+	case "help", "-h", "--help":
+		fmt.Fprint(stdout, usage)
+		return nil
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", name, usage)
+		return errUsage
+	}
+	err := cmd(ctx, args, getenv, open, stdout, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return err
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	opened := []*mongo_db.MongoDB{}
+	err := run(
+		ctx,
+		os.Args[1:],
+		os.Getenv,
+		func(ctx context.Context, URI string, database string) (db.DB, error) {
+			m, err := mongo_db.NewMongoDB(ctx, URI, database)
+			if err != nil {
+				return nil, err
+			}
+			opened = append(opened, m)
+			return m, nil
+		},
+		os.Stdout,
+		os.Stderr,
+	)
+	stop()
+	closeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	for _, m := range opened {
+		if closeErr := m.Close(closeCtx); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "failed to disconnect from database: %s\n", closeErr)
+		}
+	}
+	cancel()
+	if errors.Is(err, errUsage) {
+		os.Exit(2)
+	}
+	// errors are already logged by run
+	if err != nil {
+		os.Exit(1)
+	}
+}
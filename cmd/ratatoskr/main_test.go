@@ -0,0 +1,432 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"ratatoskr/internal/config"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/models"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGet(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+	outboxPath := filepath.Join(t.TempDir(), "outbox.jsonl")
+	go run(
+		ctx,
+		[]string{"webapp"},
+		func(key string) string {
+			if key == "ANALYTICS_OUTBOX_PATH" {
+				return outboxPath
+			}
+			return getEnv(key)
+		},
+		func(context.Context, string, string) (db.DB, error) { return dbMock{}, nil },
+		os.Stdout,
+		os.Stderr,
+	)
+	err := waitForReady(ctx, time.Second, "http://127.0.0.1:8088/ping")
+	if err != nil {
+		t.Errorf("error upon waiting for server: %v", err)
+	}
+
+	res, err := http.Get("http://127.0.0.1:8088/")
+	if err != nil {
+		t.Errorf("error upon waiting for server: %v", err)
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Errorf("error upon waiting for server: %v", err)
+		return
+	}
+	if res.StatusCode != http.StatusForbidden || len(data) > 0 {
+		t.Error("did not block request without token")
+	}
+
+	res, err = http.Get("http://127.0.0.1:8088/ping")
+	if err != nil {
+		t.Errorf("error upon waiting for server: %v", err)
+	}
+	data, err = io.ReadAll(res.Body)
+	if err != nil {
+		t.Errorf("error upon waiting for server: %v", err)
+		return
+	}
+	if string(data) != "pong" {
+		t.Error("failed to ping-pong")
+	}
+
+	res, err = http.Get("http://127.0.0.1:8088/TOKEN")
+	if err != nil {
+		t.Errorf("error upon waiting for server: %v", err)
+	}
+	data, err = io.ReadAll(res.Body)
+	if err != nil {
+		t.Errorf("error upon waiting for server: %v", err)
+		return
+	}
+	html := string(data)
+	if !strings.Contains(html, "https://telegram.org/js/telegram-web-app.js") {
+		t.Errorf("html does not contain telegram web app")
+	}
+	groups := []string{"group1", "group2"}
+	if !containsMultipleSubstrings(html, groups) {
+		t.Errorf("html does not contain some of groups: %v\n%v", groups, html)
+	}
+	tags := []string{"#tag1", "#tag2", "#tag3"}
+	if !containsMultipleSubstrings(html, tags) {
+		t.Errorf("html does not contain some of tags: %v\n%v", tags, html)
+	}
+	if !strings.Contains(
+		html,
+		fmt.Sprintf("<meta name=\"version\" content=\"%s\">", config.WebAppVersion),
+	) {
+		t.Errorf("html does not contain  version info")
+	}
+}
+
+func TestRun(t *testing.T) {
+	type tc struct {
+		name        string
+		args        []string
+		shouldError bool
+		expected    error
+		stdout      []string
+		stderr      []string
+	}
+
+	table := []tc{
+		{
+			name:        "should print usage without command",
+			args:        []string{},
+			shouldError: true,
+			expected:    errUsage,
+			stderr:      []string{"usage: ratatoskr <command> [flags]"},
+		},
+
+		{
+			name:   "should print usage on help",
+			args:   []string{"help"},
+			stdout: []string{"usage: ratatoskr <command> [flags]", "serve"},
+		},
+
+		{
+			name:        "should reject unknown command",
+			args:        []string{"worker"},
+			shouldError: true,
+			expected:    errUsage,
+			stderr:      []string{`unknown command "worker"`},
+		},
+
+		{
+			name:   "should print bot flags",
+			args:   []string{"bot", "--help"},
+			stderr: []string{"usage: ratatoskr bot [flags]", "-config", "-health-addr"},
+		},
+
+		{
+			name:   "should print serve flags",
+			args:   []string{"serve", "-h"},
+			stderr: []string{"usage: ratatoskr serve [flags]", "-health-addr", "-ip", "-port"},
+		},
+
+		{
+			name:        "should reject unknown flag",
+			args:        []string{"webapp", "--health-addr", ":8081"},
+			shouldError: true,
+			expected:    errUsage,
+			stderr:      []string{"flag provided but not defined: -health-addr"},
+		},
+
+		{
+			name:        "should reject arguments",
+			args:        []string{"webapp", "now"},
+			shouldError: true,
+			expected:    errUsage,
+			stderr:      []string{`unexpected arguments ["now"]`},
+		},
+
+		{
+			name:        "should report settings of both processes",
+			args:        []string{"serve"},
+			shouldError: true,
+			stderr:      []string{"required WEBAPP_URL was not provided", "required PORT was not provided"},
+		},
+	}
+
+	for _, test := range table {
+		var stdout, stderr strings.Builder
+		err := run(
+			context.Background(),
+			test.args,
+			func(string) string { return "" },
+			func(context.Context, string, string) (db.DB, error) { return dbMock{}, nil },
+			&stdout,
+			&stderr,
+		)
+		if (err != nil) != test.shouldError {
+			t.Errorf("%s - unexpected error: %v", test.name, err)
+		}
+		if test.expected != nil && !errors.Is(err, test.expected) {
+			t.Errorf("%s - wrong error\nexpected: %v\nactual:   %v", test.name, test.expected, err)
+		}
+		for _, expected := range test.stdout {
+			if !strings.Contains(stdout.String(), expected) {
+				t.Errorf("%s - expected %q in stdout:\n%s", test.name, expected, stdout.String())
+			}
+		}
+		for _, expected := range test.stderr {
+			if !strings.Contains(stderr.String(), expected) {
+				t.Errorf("%s - expected %q in stderr:\n%s", test.name, expected, stderr.String())
+			}
+		}
+	}
+}
+
+func TestCommandFlags(t *testing.T) {
+	flags := newCommandFlags("webapp", "", io.Discard)
+	addListenFlags(flags)
+	getenv, err := flags.parse([]string{"-port", "9090"}, func(name string) string {
+		return map[string]string{"PORT": "8080", "IP": "127.0.0.1"}[name]
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	actual := []string{getenv("PORT"), getenv("IP"), getenv("CONFIG_FILE")}
+	expected := []string{"9090", "127.0.0.1", ""}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected: %+v\nactual:   %+v", expected, actual)
+	}
+}
+
+func containsMultipleSubstrings(string string, substrings []string) bool {
+	containst := true
+	for _, substring := range substrings {
+		if !strings.Contains(string, substring) {
+			containst = false
+			break
+		}
+	}
+	return containst
+}
+
+func waitForReady(
+	ctx context.Context,
+	timeout time.Duration,
+	endpoint string,
+) error {
+	client := http.Client{}
+	startTime := time.Now()
+	for {
+		req, err := http.NewRequestWithContext(
+			ctx,
+			http.MethodGet,
+			endpoint,
+			nil,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			fmt.Printf("Error making request: %s\n", err.Error())
+			continue
+		}
+		if resp.StatusCode == http.StatusOK {
+			fmt.Println("Endpoint is ready!")
+			resp.Body.Close()
+			return nil
+		}
+		resp.Body.Close()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			if time.Since(startTime) >= timeout {
+				return fmt.Errorf("timeout reached while waiting for endpoint")
+			}
+			time.Sleep(250 * time.Millisecond)
+		}
+	}
+}
+
+func getEnv(s string) string {
+	switch s {
+	case "ADMIN_IDS":
+		return "1234,7890"
+	case "IP":
+		return "127.0.0.1"
+	case "PORT":
+		return "8088"
+	case "MONGO_URI":
+		return "mongo://<name>:<pass>"
+	case "MONGO_DB_NAME":
+		return "database name"
+	case "TOKEN":
+		return "TOKEN"
+	case "RECEIVER_ID":
+		return "4567"
+	default:
+		return ""
+	}
+}
+
+type dbMock struct{}
+
+func (_ dbMock) GetAllGroupsWithTags(context.Context) (*[]models.Group, error) {
+	return &[]models.Group{
+		{Name: "group1", Tags: []models.Tag{
+			{Name: "#tag1"},
+			{Name: "#tag2"},
+			{Name: "#tag3"},
+		}},
+		{Name: "group2", Tags: []models.Tag{
+			{Name: "#tag4"},
+			{Name: "#tag5"},
+			{Name: "#tag6"},
+		}},
+	}, nil
+}
+
+func (_ dbMock) UpdateTags(context.Context, *[]models.Group) error {
+	return nil
+}
+
+func (_ dbMock) InsertAnalytics(context.Context, *[]models.Analytics) error {
+	return nil
+}
+
+func (_ dbMock) GetAdminAnalytics(context.Context, time.Time, time.Time) (*[]models.AdminAnalytics, error) {
+	return &[]models.AdminAnalytics{}, nil
+}
+
+func (_ dbMock) AddTag(context.Context, string, models.Tag) error {
+	return nil
+}
+
+func (m dbMock) GetTagMenu(ctx context.Context) (*models.TagMenu, error) {
+	groups, err := m.GetAllGroupsWithTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &models.TagMenu{Groups: *groups}, nil
+}
+
+func (_ dbMock) Ping(context.Context) error {
+	return nil
+}
+
+func (_ dbMock) GetTagMenuVersion(context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (_ dbMock) ReplaceTagMenu(context.Context, int64, *[]models.Group) (int64, error) {
+	return 1, nil
+}
+
+func (_ dbMock) GetFavoriteTags(context.Context, int64) (*[]string, error) {
+	return &[]string{}, nil
+}
+
+func (_ dbMock) SetFavoriteTag(context.Context, int64, string, bool) error {
+	return nil
+}
+
+func (_ dbMock) GetRecentTags(context.Context, int64, int) (*[]models.TagUsage, error) {
+	return &[]models.TagUsage{}, nil
+}
+
+func (_ dbMock) GetDailyPosts(context.Context, time.Time, time.Time) (*[]models.PostRollup, error) {
+	return &[]models.PostRollup{}, nil
+}
+
+func (_ dbMock) GetRecentPosts(context.Context, time.Time, int) (*[]models.Post, error) {
+	return &[]models.Post{}, nil
+}
+
+func (_ dbMock) RegisterPendingMedia(context.Context, *[]models.PendingMedia) error {
+	return nil
+}
+
+func (_ dbMock) InsertTaggingSession(context.Context, *models.TaggingSession) error {
+	return nil
+}
+
+func (_ dbMock) GetTaggingSession(context.Context, string) (*models.TaggingSession, error) {
+	return nil, db.ErrSessionNotFound
+}
+
+func (_ dbMock) ClaimTaggingSession(context.Context, string) (*models.TaggingSession, error) {
+	return nil, db.ErrSessionNotFound
+}
+
+func (_ dbMock) DeleteTaggingSession(context.Context, string) error {
+	return nil
+}
+
+func (_ dbMock) GetDraft(context.Context, string, int64) (*models.Draft, error) {
+	return nil, db.ErrDraftNotFound
+}
+
+func (_ dbMock) SaveDraft(context.Context, *models.Draft) error {
+	return nil
+}
+
+func (_ dbMock) DeleteDrafts(context.Context, string) error {
+	return nil
+}
+
+func (_ dbMock) GetPendingMedia(context.Context, int64, []int64) (*[]models.PendingMedia, error) {
+	return &[]models.PendingMedia{}, nil
+}
+
+func (_ dbMock) GetTagCooccurrence(
+	context.Context,
+	[]string,
+	time.Time,
+) (*[]models.TagUsage, error) {
+	return &[]models.TagUsage{}, nil
+}
+
+func (_ dbMock) StreamAnalytics(
+	context.Context,
+	time.Time,
+	time.Time,
+	func(models.Analytics) error,
+) error {
+	return nil
+}
+
+func (_ dbMock) RollupAnalytics(context.Context, time.Time) error {
+	return nil
+}
+
+func (_ dbMock) EnsureAnalyticsRetention(context.Context, time.Duration) error {
+	return nil
+}
+
+func (_ dbMock) GetSettings(context.Context) (*models.Settings, error) {
+	return nil, db.ErrSettingsNotFound
+}
+
+func (_ dbMock) SaveSettings(context.Context, int64, *models.Settings, *models.SettingChange) (int64, error) {
+	return 0, nil
+}
+
+func (_ dbMock) GetSettingChanges(context.Context, int) (*[]models.SettingChange, error) {
+	return &[]models.SettingChange{}, nil
+}
+
+func (_ dbMock) EnsureIndexes(context.Context) error {
+	return nil
+}
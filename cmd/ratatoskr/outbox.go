@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/logger"
+	"ratatoskr/internal/outbox"
+	"time"
+)
+
+// startOutbox wraps database in an analytics outbox that is flushed in the
+// background. The returned function stops flushing, writes what is left and
+// closes the outbox.
+func startOutbox(
+	ctx context.Context,
+	database db.DB,
+	l *logger.Logger,
+	path string,
+) (*outbox.AnalyticsOutbox, func(), error) {
+	analyticsOutbox, err := outbox.NewAnalyticsOutbox(database, l, path)
+	if err != nil {
+		return nil, nil, l.Error("failed to open analytics outbox", "path", path, "error", err)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		analyticsOutbox.Run(ctx)
+	}()
+	return analyticsOutbox, func() {
+		cancel()
+		<-done
+		closeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := analyticsOutbox.Close(closeCtx); err != nil {
+			l.Error("failed to close analytics outbox", "error", err)
+		}
+	}, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"ratatoskr/internal/config"
+	"ratatoskr/internal/events"
+	"ratatoskr/internal/logger"
+	"ratatoskr/internal/sessions"
+	"ratatoskr/internal/settings"
+	"time"
+)
+
+const serveDescription = `Runs the bot and the webapp in one process. They share the MongoDB
+client, the logger, the runtime settings and the tagging sessions, and the webapp reloads the tag
+menu as soon as the bot changes it.`
+
+func runServe(
+	ctx context.Context,
+	args []string,
+	getenv func(string) string,
+	open openDB,
+	stdout io.Writer,
+	stderr io.Writer,
+) error {
+	flags := newCommandFlags("serve", serveDescription, stderr)
+	flags.setting("health-addr", "HEALTH_ADDR", "`address` of the bot health and metrics listener")
+	addListenFlags(flags)
+	getenv, err := flags.parse(args, getenv)
+	if err != nil {
+		return err
+	}
+	botConfig, webAppConfig, err := config.GetServeConfig(getenv)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return err
+	}
+	l := logger.NewLogger("ratatoskr", stdout, botConfig.Log)
+	l.Info("configuration loaded", "bot", botConfig, "webapp", webAppConfig)
+
+	db, err := open(ctx, botConfig.MongoURI, botConfig.MongoDBName)
+	if err != nil {
+		return l.Error("failed to connect to database", "error", err)
+	}
+	err = ensureIndexes(ctx, db, l)
+	if err != nil {
+		return err
+	}
+	runtime := settings.NewStore(
+		db,
+		settings.Defaults(botConfig.ReceiverID),
+		settings.CheckInterval,
+		time.Now,
+	)
+	bus := events.NewBus()
+	store := sessions.NewStore(db, sessions.DefaultTTL, time.Now)
+
+	analyticsOutbox, closeOutbox, err := startOutbox(ctx, db, l, botConfig.AnalyticsOutboxPath)
+	if err != nil {
+		return err
+	}
+	defer closeOutbox()
+
+	ctx, cancel := context.WithCancel(ctx)
+	wait, err := startWebApp(ctx, webAppConfig, analyticsOutbox, l.With("component", "webapp"), runtime, bus, store)
+	if err != nil {
+		cancel()
+		return err
+	}
+	err = startBot(ctx, botConfig, analyticsOutbox, l.With("component", "bot"), runtime, bus, store)
+	cancel()
+	wait()
+	return err
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"ratatoskr/internal/models"
+	"ratatoskr/internal/tags_parser"
+	"strings"
+)
+
+const (
+	tagsFormatText = "text"
+	tagsFormatJSON = "json"
+)
+
+var runTags = subcommands(
+	"tags",
+	subcommand{"export", "write the tag menu to stdout or a file", runTagsExport},
+	subcommand{"import", "replace the tag menu with a file", runTagsImport},
+	subcommand{"validate", "check a tag menu file without saving it", runTagsValidate},
+)
+
+// tagsFormat returns the format flag, or guesses it from the file name.
+func tagsFormat(format string, path string) (string, error) {
+	switch strings.ToLower(format) {
+	case "":
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			return tagsFormatJSON, nil
+		}
+		return tagsFormatText, nil
+	case tagsFormatText:
+		return tagsFormatText, nil
+	case tagsFormatJSON:
+		return tagsFormatJSON, nil
+	default:
+		return "", fmt.Errorf("unknown tags format %q, expected text or json", format)
+	}
+}
+
+func parseTags(format string, data []byte) ([]models.Group, error) {
+	if format == tagsFormatJSON {
+		return tags_parser.ParseJSON(data)
+	}
+	return tags_parser.Parse(string(data))
+}
+
+func runTagsExport(
+	ctx context.Context,
+	args []string,
+	getenv func(string) string,
+	open openDB,
+	stdout io.Writer,
+	stderr io.Writer,
+) error {
+	flags := newCommandFlags("tags export", "Writes the tag menu in the format of the bot message or as JSON.", stderr)
+	format := flags.set.String("format", "", "`format` of the menu, text or json (default from the output file name)")
+	output := flags.set.String("output", "", "`file` to write to instead of stdout")
+	getenv, err := flags.parse(args, getenv)
+	if err != nil {
+		return err
+	}
+	*format, err = tagsFormat(*format, *output)
+	if err != nil {
+		return report(stderr, err)
+	}
+	database, err := connect(ctx, getenv, open, stderr)
+	if err != nil {
+		return err
+	}
+	groups, err := database.GetAllGroupsWithTags(ctx)
+	if err != nil {
+		return report(stderr, fmt.Errorf("failed to get tags: %w", err))
+	}
+	err = writeOutput(*output, stdout, func(w io.Writer) error {
+		if *format == tagsFormatJSON {
+			data, err := tags_parser.FormatJSON(*groups)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(w, "%s\n", data)
+			return err
+		}
+		_, err := fmt.Fprintf(w, "%s\n", tags_parser.Format(*groups))
+		return err
+	})
+	if err != nil {
+		return report(stderr, fmt.Errorf("failed to write tags: %w", err))
+	}
+	return nil
+}
+
+func runTagsImport(
+	ctx context.Context,
+	args []string,
+	getenv func(string) string,
+	open openDB,
+	stdout io.Writer,
+	stderr io.Writer,
+) error {
+	flags := newCommandFlags(
+		"tags import",
+		"Replaces the tag menu with the groups from file, or stdin without it.\nNothing is saved when the file has problems.",
+		stderr,
+	)
+	format := flags.set.String("format", "", "`format` of the menu, text or json (default from the file name)")
+	flags.args("[file]", 1)
+	getenv, err := flags.parse(args, getenv)
+	if err != nil {
+		return err
+	}
+	groups, err := readTags(*format, flags.set.Arg(0))
+	if err != nil {
+		return report(stderr, err)
+	}
+	database, err := connect(ctx, getenv, open, stderr)
+	if err != nil {
+		return err
+	}
+	err = database.UpdateTags(ctx, &groups)
+	if err != nil {
+		return report(stderr, fmt.Errorf("failed to save tags: %w", err))
+	}
+	fmt.Fprintf(stdout, "imported %d groups with %d tags\n", len(groups), countTags(groups))
+	return nil
+}
+
+func runTagsValidate(
+	ctx context.Context,
+	args []string,
+	getenv func(string) string,
+	open openDB,
+	stdout io.Writer,
+	stderr io.Writer,
+) error {
+	flags := newCommandFlags("tags validate", "Checks a tag menu file, or stdin without it.", stderr)
+	format := flags.set.String("format", "", "`format` of the menu, text or json (default from the file name)")
+	flags.args("[file]", 1)
+	_, err := flags.parse(args, getenv)
+	if err != nil {
+		return err
+	}
+	groups, err := readTags(*format, flags.set.Arg(0))
+	if err != nil {
+		return report(stderr, err)
+	}
+	fmt.Fprintf(stdout, "valid: %d groups with %d tags\n", len(groups), countTags(groups))
+	return nil
+}
+
+func readTags(format string, path string) ([]models.Group, error) {
+	format, err := tagsFormat(format, path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := readInput(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tags: %w", err)
+	}
+	return parseTags(format, data)
+}
+
+func countTags(groups []models.Group) int {
+	count := 0
+	for _, g := range groups {
+		count += len(g.Tags)
+	}
+	return count
+}
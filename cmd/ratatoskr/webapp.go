@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"ratatoskr/internal/config"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/events"
+	"ratatoskr/internal/logger"
+	"ratatoskr/internal/sessions"
+	"ratatoskr/internal/settings"
+	"ratatoskr/internal/webapp"
+	"time"
+)
+
+func runWebApp(
+	ctx context.Context,
+	args []string,
+	getenv func(string) string,
+	open openDB,
+	stdout io.Writer,
+	stderr io.Writer,
+) error {
+	flags := newCommandFlags("webapp", "Runs the webapp server.", stderr)
+	addListenFlags(flags)
+	getenv, err := flags.parse(args, getenv)
+	if err != nil {
+		return err
+	}
+	c, err := config.GetWebAppConfig(getenv)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return err
+	}
+	l := logger.NewLogger("WebApp", stdout, c.Log)
+	l.Info("configuration loaded", "config", c)
+
+	db, err := open(ctx, c.MongoURI, c.MongoDBName)
+	if err != nil {
+		return l.Error("failed to connect to database", "error", err)
+	}
+	err = ensureIndexes(ctx, db, l)
+	if err != nil {
+		return err
+	}
+	analyticsOutbox, closeOutbox, err := startOutbox(ctx, db, l, c.AnalyticsOutboxPath)
+	if err != nil {
+		return err
+	}
+	defer closeOutbox()
+	wait, err := startWebApp(ctx, c, analyticsOutbox, l, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	wait()
+	return nil
+}
+
+func addListenFlags(flags *commandFlags) {
+	flags.setting("ip", "IP", "`address` the webapp listens on")
+	flags.setting("port", "PORT", "`port` the webapp listens on")
+}
+
+// startWebApp serves the webapp until ctx is done. The returned function
+// waits for the server to shut down. db should write analytics through an
+// outbox.
+func startWebApp(
+	ctx context.Context,
+	c *config.WepAppConfig,
+	db db.DB,
+	l *logger.Logger,
+	runtime *settings.Store,
+	bus *events.Bus,
+	store *sessions.Store,
+) (func(), error) {
+	svr, err := webapp.NewServer(c, db, l, runtime, bus, store)
+	if err != nil {
+		return nil, l.Error("failed to create server", "error", err)
+	}
+
+	httpServer := &http.Server{
+		Handler: svr,
+		Addr:    fmt.Sprintf("[%s]:%s", c.IP, c.Port),
+	}
+
+	go func() {
+		l.Info("starting server", "addr", httpServer.Addr)
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			l.Error("failed to listen and serve", "addr", httpServer.Addr, "error", err)
+		}
+	}()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			l.Error("failed to shut down http server", "error", err)
+		}
+	}()
+	return func() { <-done }, nil
+}
@@ -0,0 +1,126 @@
+package analytics
+
+import (
+	"cmp"
+	"context"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/models"
+	"slices"
+	"time"
+)
+
+type DayPosts struct {
+	Day   time.Time
+	Posts int
+}
+
+type GroupShare struct {
+	Group string
+	Count int
+}
+
+type Dashboard struct {
+	From        time.Time
+	To          time.Time
+	UnusedSince time.Time
+	TotalPosts  int
+	TotalTags   int
+	Days        []DayPosts
+	TopTags     []models.TagUsage
+	Groups      []GroupShare
+	Unused      []models.PostTag
+}
+
+// BuildDashboard summarizes rolled up analytics for [from, to). Unused lists
+// menu tags that were not used at all since unusedSince.
+func BuildDashboard(
+	ctx context.Context,
+	database db.DB,
+	from time.Time,
+	to time.Time,
+	unusedSince time.Time,
+	now time.Time,
+	topTags int,
+) (*Dashboard, error) {
+	d := &Dashboard{
+		From:        from,
+		To:          to,
+		UnusedSince: unusedSince,
+		Days:        []DayPosts{},
+		TopTags:     []models.TagUsage{},
+		Groups:      []GroupShare{},
+		Unused:      []models.PostTag{},
+	}
+
+	daily, err := database.GetDailyPosts(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	posts := map[time.Time]int{}
+	for _, r := range *daily {
+		posts[r.Start.UTC()] += r.Posts
+	}
+	for day := startOfDay(from); day.Before(to); day = day.AddDate(0, 0, 1) {
+		d.Days = append(d.Days, DayPosts{Day: day, Posts: posts[day]})
+		d.TotalPosts += posts[day]
+	}
+
+	admins, err := database.GetAdminAnalytics(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	tags := map[string]models.TagUsage{}
+	groups := map[string]int{}
+	for _, a := range *admins {
+		for _, t := range a.Tags {
+			usage := tags[t.Tag]
+			usage.Tag = t.Tag
+			usage.Group = t.Group
+			usage.Count += t.Count
+			tags[t.Tag] = usage
+			groups[t.Group] += t.Count
+			d.TotalTags += t.Count
+		}
+	}
+	for _, t := range tags {
+		d.TopTags = append(d.TopTags, t)
+	}
+	slices.SortFunc(d.TopTags, func(a, b models.TagUsage) int {
+		return cmp.Or(b.Count-a.Count, cmp.Compare(a.Tag, b.Tag))
+	})
+	d.TopTags = d.TopTags[:min(topTags, len(d.TopTags))]
+	for group, count := range groups {
+		d.Groups = append(d.Groups, GroupShare{Group: group, Count: count})
+	}
+	slices.SortFunc(d.Groups, func(a, b GroupShare) int {
+		return cmp.Or(b.Count-a.Count, cmp.Compare(a.Group, b.Group))
+	})
+
+	recent, err := database.GetAdminAnalytics(ctx, unusedSince, now)
+	if err != nil {
+		return nil, err
+	}
+	used := map[string]bool{}
+	for _, a := range *recent {
+		for _, t := range a.Tags {
+			used[t.Tag] = true
+		}
+	}
+	menu, err := database.GetAllGroupsWithTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range *menu {
+		for _, t := range g.Tags {
+			if !used[t.Name] {
+				d.Unused = append(d.Unused, models.PostTag{Tag: t.Name, Group: g.Name})
+			}
+		}
+	}
+	return d, nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,97 @@
+package analytics
+
+import (
+	"context"
+	"ratatoskr/internal/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type dashboardMock struct {
+	dbMock
+}
+
+func (_ *dashboardMock) GetDailyPosts(context.Context, time.Time, time.Time) (*[]models.PostRollup, error) {
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	return &[]models.PostRollup{
+		{Start: day, UserID: 1, Posts: 2},
+		{Start: day, UserID: 2, Posts: 1},
+		{Start: day.AddDate(0, 0, 1), UserID: 1, Posts: 4},
+	}, nil
+}
+
+func (_ *dashboardMock) GetAdminAnalytics(
+	_ context.Context,
+	from time.Time,
+	_ time.Time,
+) (*[]models.AdminAnalytics, error) {
+	if from.Equal(time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC)) {
+		return &[]models.AdminAnalytics{
+			{UserID: 1, Tags: []models.TagUsage{{Tag: "#cat", Group: "Animals", Count: 1}}},
+		}, nil
+	}
+	return &[]models.AdminAnalytics{
+		{UserID: 1, Tags: []models.TagUsage{
+			{Tag: "#cat", Group: "Animals", Count: 3},
+			{Tag: "#sea", Group: "Places", Count: 2},
+		}},
+		{UserID: 2, Tags: []models.TagUsage{
+			{Tag: "#dog", Group: "Animals", Count: 2},
+			{Tag: "#cat", Group: "Animals", Count: 1},
+		}},
+	}, nil
+}
+
+func (_ *dashboardMock) GetAllGroupsWithTags(context.Context) (*[]models.Group, error) {
+	return &[]models.Group{
+		{Name: "Animals", Tags: []models.Tag{{Name: "#cat"}, {Name: "#dog"}}},
+		{Name: "Places", Tags: []models.Tag{{Name: "#sea"}}},
+	}, nil
+}
+
+func TestBuildDashboard(t *testing.T) {
+	date := func(day int) time.Time {
+		return time.Date(2024, 1, day, 0, 0, 0, 0, time.UTC)
+	}
+	actual, err := BuildDashboard(
+		context.Background(),
+		&dashboardMock{},
+		date(1),
+		date(5),
+		date(20),
+		date(30),
+		2,
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := &Dashboard{
+		From:        date(1),
+		To:          date(5),
+		UnusedSince: date(20),
+		TotalPosts:  7,
+		TotalTags:   8,
+		Days: []DayPosts{
+			{Day: date(1)},
+			{Day: date(2), Posts: 3},
+			{Day: date(3), Posts: 4},
+			{Day: date(4)},
+		},
+		TopTags: []models.TagUsage{
+			{Tag: "#cat", Group: "Animals", Count: 4},
+			{Tag: "#dog", Group: "Animals", Count: 2},
+		},
+		Groups: []GroupShare{
+			{Group: "Animals", Count: 6},
+			{Group: "Places", Count: 2},
+		},
+		Unused: []models.PostTag{
+			{Tag: "#dog", Group: "Animals"},
+			{Tag: "#sea", Group: "Places"},
+		},
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("wrong dashboard\nexpected: %+v\nactual:   %+v", expected, actual)
+	}
+}
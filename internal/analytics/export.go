@@ -0,0 +1,206 @@
+package analytics
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+const DateLayout = "2006-01-02"
+
+var csvHeader = []string{
+	"id",
+	"date",
+	"tag",
+	"group",
+	"userId",
+	"mediaKind",
+	"itemCount",
+	"destinationChatId",
+	"channelMessageIds",
+	"postingMode",
+	"correlationId",
+}
+
+type record struct {
+	ID                string    `json:"id"`
+	Date              time.Time `json:"date"`
+	Tag               string    `json:"tag"`
+	Group             string    `json:"group"`
+	UserID            int64     `json:"userId,omitempty"`
+	MediaKind         string    `json:"mediaKind,omitempty"`
+	ItemCount         int       `json:"itemCount,omitempty"`
+	DestinationChatID int64     `json:"destinationChatId,omitempty"`
+	ChannelMessageIDs []int64   `json:"channelMessageIds,omitempty"`
+	PostingMode       string    `json:"postingMode,omitempty"`
+	CorrelationID     string    `json:"correlationId,omitempty"`
+}
+
+func newRecord(a models.Analytics) record {
+	id := ""
+	if !a.ID.IsZero() {
+		id = a.ID.Hex()
+	}
+	return record{
+		ID:                id,
+		Date:              a.Date.UTC(),
+		Tag:               a.Tag,
+		Group:             a.Group,
+		UserID:            a.UserID,
+		MediaKind:         a.MediaKind,
+		ItemCount:         a.ItemCount,
+		DestinationChatID: a.DestinationChatID,
+		ChannelMessageIDs: a.ChannelMessageIDs,
+		PostingMode:       a.PostingMode,
+		CorrelationID:     a.CorrelationID,
+	}
+}
+
+func (r record) csv() []string {
+	ids := make([]string, len(r.ChannelMessageIDs))
+	for i, id := range r.ChannelMessageIDs {
+		ids[i] = strconv.FormatInt(id, 10)
+	}
+	return []string{
+		r.ID,
+		r.Date.Format(time.RFC3339),
+		r.Tag,
+		r.Group,
+		formatOptionalInt(r.UserID),
+		r.MediaKind,
+		formatOptionalInt(int64(r.ItemCount)),
+		formatOptionalInt(r.DestinationChatID),
+		strings.Join(ids, " "),
+		r.PostingMode,
+		r.CorrelationID,
+	}
+}
+
+func formatOptionalInt(i int64) string {
+	if i == 0 {
+		return ""
+	}
+	return strconv.FormatInt(i, 10)
+}
+
+func ParseFormat(s string) (string, error) {
+	switch strings.ToLower(s) {
+	case "", FormatCSV:
+		return FormatCSV, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("unknown export format %q, expected csv or json", s)
+	}
+}
+
+func ContentType(format string) string {
+	if format == FormatJSON {
+		return "application/json"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// ParseRange reads inclusive YYYY-MM-DD dates. Missing from exports
+// everything up to the end date, missing to exports up to now.
+func ParseRange(from string, to string, now time.Time) (time.Time, time.Time, error) {
+	start := time.Time{}
+	end := now
+	var err error
+	if from != "" {
+		start, err = time.Parse(DateLayout, from)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from date %q, expected YYYY-MM-DD", from)
+		}
+	}
+	if to != "" {
+		end, err = time.Parse(DateLayout, to)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to date %q, expected YYYY-MM-DD", to)
+		}
+		end = end.AddDate(0, 0, 1)
+	}
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from date must be before to date")
+	}
+	return start, end, nil
+}
+
+func FileName(format string, from time.Time, to time.Time) string {
+	name := "analytics"
+	if !from.IsZero() {
+		name += "-" + from.Format(DateLayout)
+	}
+	return fmt.Sprintf("%s-%s.%s", name, to.Format(DateLayout), format)
+}
+
+// Export writes raw analytics for [from, to) to w while reading them from
+// the database cursor, so the whole range never sits in memory.
+func Export(
+	ctx context.Context,
+	database db.DB,
+	w io.Writer,
+	format string,
+	from time.Time,
+	to time.Time,
+) error {
+	switch format {
+	case FormatCSV:
+		return exportCSV(ctx, database, w, from, to)
+	case FormatJSON:
+		return exportJSON(ctx, database, w, from, to)
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+func exportCSV(ctx context.Context, database db.DB, w io.Writer, from time.Time, to time.Time) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	err := database.StreamAnalytics(ctx, from, to, func(a models.Analytics) error {
+		return writer.Write(newRecord(a).csv())
+	})
+	if err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func exportJSON(ctx context.Context, database db.DB, w io.Writer, from time.Time, to time.Time) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	separator := "\n"
+	err := database.StreamAnalytics(ctx, from, to, func(a models.Analytics) error {
+		line, err := json.Marshal(newRecord(a))
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, separator); err != nil {
+			return err
+		}
+		separator = ",\n"
+		_, err = w.Write(line)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n]\n")
+	return err
+}
@@ -0,0 +1,162 @@
+package analytics
+
+import (
+	"context"
+	"ratatoskr/internal/models"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type streamMock struct {
+	dbMock
+	analytics []models.Analytics
+	from      time.Time
+	to        time.Time
+}
+
+func (m *streamMock) StreamAnalytics(
+	_ context.Context,
+	from time.Time,
+	to time.Time,
+	fn func(models.Analytics) error,
+) error {
+	m.from = from
+	m.to = to
+	for _, a := range m.analytics {
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestExport(t *testing.T) {
+	id, _ := primitive.ObjectIDFromHex("65a1b2c3d4e5f60718293a4b")
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	database := &streamMock{analytics: []models.Analytics{
+		{ID: id, Tag: "#tag1", Group: "Group, 1", Date: date},
+		{
+			Tag:               "#tag2",
+			Group:             "Group 2",
+			Date:              date,
+			UserID:            1234,
+			MediaKind:         models.MediaKindAlbum,
+			ItemCount:         2,
+			DestinationChatID: -100,
+			ChannelMessageIDs: []int64{7, 8},
+			PostingMode:       models.PostingModeImmediate,
+			CorrelationID:     "abc123",
+		},
+	}}
+
+	type tc struct {
+		name     string
+		format   string
+		expected string
+	}
+
+	table := []tc{
+		{
+			name:   "should export csv",
+			format: FormatCSV,
+			expected: "id,date,tag,group,userId,mediaKind,itemCount,destinationChatId,channelMessageIds,postingMode,correlationId\n" +
+				"65a1b2c3d4e5f60718293a4b,2024-01-02T03:04:05Z,#tag1,\"Group, 1\",,,,,,,\n" +
+				",2024-01-02T03:04:05Z,#tag2,Group 2,1234,album,2,-100,7 8,immediate,abc123\n",
+		},
+
+		{
+			name:   "should export json",
+			format: FormatJSON,
+			expected: "[\n" +
+				`{"id":"65a1b2c3d4e5f60718293a4b","date":"2024-01-02T03:04:05Z","tag":"#tag1","group":"Group, 1"},` + "\n" +
+				`{"id":"","date":"2024-01-02T03:04:05Z","tag":"#tag2","group":"Group 2","userId":1234,"mediaKind":"album","itemCount":2,"destinationChatId":-100,"channelMessageIds":[7,8],"postingMode":"immediate","correlationId":"abc123"}` +
+				"\n]\n",
+		},
+	}
+
+	for _, test := range table {
+		var out strings.Builder
+		err := Export(context.Background(), database, &out, test.format, date, date.AddDate(0, 0, 1))
+		if err != nil {
+			t.Errorf("%s - unexpected error: %v", test.name, err)
+		}
+		if out.String() != test.expected {
+			t.Errorf("%s - wrong output\nexpected: %s\nactual:   %s", test.name, test.expected, out.String())
+		}
+	}
+
+	var out strings.Builder
+	database.analytics = nil
+	Export(context.Background(), database, &out, FormatJSON, date, date)
+	if out.String() != "[\n]\n" {
+		t.Errorf("wrong empty json export: %q", out.String())
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	type tc struct {
+		name        string
+		from        string
+		to          string
+		shouldError bool
+		start       time.Time
+		end         time.Time
+	}
+
+	table := []tc{
+		{
+			name:  "should default to everything until now",
+			start: time.Time{},
+			end:   now,
+		},
+
+		{
+			name:  "should include the whole to day",
+			from:  "2024-01-01",
+			to:    "2024-01-31",
+			start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			end:   time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		},
+
+		{
+			name:        "should fail on malformed date",
+			from:        "01.01.2024",
+			shouldError: true,
+		},
+
+		{
+			name:        "should fail on reversed range",
+			from:        "2024-02-01",
+			to:          "2024-01-01",
+			shouldError: true,
+		},
+	}
+
+	for _, test := range table {
+		start, end, err := ParseRange(test.from, test.to, now)
+		if test.shouldError {
+			if err == nil {
+				t.Errorf("%s - did not fail", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s - unexpected error: %v", test.name, err)
+		}
+		if !start.Equal(test.start) || !end.Equal(test.end) {
+			t.Errorf(
+				"%s - wrong range\nexpected: %v - %v\nactual:   %v - %v",
+				test.name,
+				test.start,
+				test.end,
+				start,
+				end,
+			)
+		}
+	}
+}
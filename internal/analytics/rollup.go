@@ -0,0 +1,79 @@
+package analytics
+
+import (
+	"context"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/logger"
+	"time"
+)
+
+const rollupTimeout = time.Minute * 5
+
+type RollupJob struct {
+	db        db.DB
+	logger    *logger.Logger
+	interval  time.Duration
+	retention time.Duration
+	now       func() time.Time
+}
+
+func NewRollupJob(
+	db db.DB,
+	logger *logger.Logger,
+	interval time.Duration,
+	retention time.Duration,
+	now func() time.Time,
+) *RollupJob {
+	return &RollupJob{
+		db:        db,
+		logger:    logger,
+		interval:  interval,
+		retention: retention,
+		now:       now,
+	}
+}
+
+// Run rolls raw analytics up right away and then on every interval until ctx
+// is cancelled. The retention index is only applied after the first rollup,
+// so events are never expired before they were aggregated.
+func (j *RollupJob) Run(ctx context.Context) {
+	retentionApplied := false
+	for {
+		err := j.RunOnce(ctx)
+		if err != nil {
+			j.logger.Error("failed to roll up analytics", "error", err)
+		}
+		if err == nil && !retentionApplied {
+			retentionApplied = j.applyRetention(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(j.interval):
+		}
+	}
+}
+
+func (j *RollupJob) RunOnce(ctx context.Context) error {
+	c, cancel := context.WithTimeout(ctx, rollupTimeout)
+	defer cancel()
+	start := j.now()
+	err := j.db.RollupAnalytics(c, start)
+	if err != nil {
+		return err
+	}
+	j.logger.Info("analytics rolled up", "duration", j.now().Sub(start))
+	return nil
+}
+
+func (j *RollupJob) applyRetention(ctx context.Context) bool {
+	c, cancel := context.WithTimeout(ctx, rollupTimeout)
+	defer cancel()
+	err := j.db.EnsureAnalyticsRetention(c, j.retention)
+	if err != nil {
+		j.logger.Error("failed to apply analytics retention", "error", err)
+		return false
+	}
+	j.logger.Info("analytics retention set", "retention", j.retention)
+	return true
+}
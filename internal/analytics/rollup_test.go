@@ -0,0 +1,77 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/logger"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type dbMock struct {
+	db.DB
+	mu          sync.Mutex
+	calls       []string
+	failRollups int
+}
+
+func (m *dbMock) RollupAnalytics(context.Context, time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, "rollup")
+	if m.failRollups > 0 {
+		m.failRollups--
+		return fmt.Errorf("database is down")
+	}
+	return nil
+}
+
+func (m *dbMock) EnsureAnalyticsRetention(_ context.Context, retention time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, fmt.Sprintf("retention %v", retention))
+	return nil
+}
+
+func TestRollupJob(t *testing.T) {
+	database := &dbMock{failRollups: 1}
+	job := NewRollupJob(
+		database,
+		logger.NewLogger("test logger", &strings.Builder{}, logger.Options{}),
+		time.Millisecond*10,
+		time.Hour,
+		time.Now,
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		job.Run(ctx)
+		close(done)
+	}()
+	time.Sleep(time.Millisecond * 45)
+	cancel()
+	<-done
+
+	database.mu.Lock()
+	defer database.mu.Unlock()
+	if len(database.calls) < 4 {
+		t.Fatalf("job did not keep running: %v", database.calls)
+	}
+	expected := []string{"rollup", "rollup", "retention 1h0m0s", "rollup"}
+	if !reflect.DeepEqual(expected, database.calls[:4]) {
+		t.Errorf(
+			"retention must be applied once after a successful rollup\nexpected: %v\nactual:   %v",
+			expected,
+			database.calls,
+		)
+	}
+	for _, call := range database.calls[4:] {
+		if call != "rollup" {
+			t.Errorf("retention was applied more than once: %v", database.calls)
+		}
+	}
+}
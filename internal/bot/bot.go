@@ -1,37 +1,75 @@
 package bot
 
 import (
-	"fmt"
+	"context"
+	"net/http"
 	"ratatoskr/internal/config"
 	"ratatoskr/internal/db"
+	"ratatoskr/internal/events"
 	"ratatoskr/internal/logger"
+	"ratatoskr/internal/metrics"
+	"ratatoskr/internal/sessions"
+	"ratatoskr/internal/settings"
 	"time"
 
 	"github.com/PaulSonOfLars/gotgbot/v2"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext"
 )
 
+// apiURL is the Bot API the bot talks to, tests point it to a fake server.
+var apiURL = gotgbot.DefaultAPIURL
+
+// Run polls for updates until ctx is done. runtime, bus and store are shared
+// with the webapp when both run in one process, a nil runtime or store makes
+// the bot keep its own.
 func Run(
+	ctx context.Context,
 	db db.DB,
-	logger *logger.Logger, config *config.BotConfig) error {
+	logger *logger.Logger,
+	config *config.BotConfig,
+	runtime *settings.Store,
+	bus *events.Bus,
+	store *sessions.Store,
+) error {
 	logger.Info("initializing bot...")
-	bot, err := gotgbot.NewBot(config.Token, nil)
+	bot, err := gotgbot.NewBot(config.Token, &gotgbot.BotOpts{
+		BotClient: metrics.TelegramClient{
+			BotClient: &gotgbot.BaseBotClient{
+				DefaultRequestOpts: &gotgbot.RequestOpts{APIURL: apiURL},
+			},
+			Logger: logger,
+		},
+	})
 	if err != nil {
-		logger.Error(fmt.Sprintf("failed to initialize new bot, error: %v", err))
-		return err
+		return logger.Error("failed to initialize new bot", "error", err)
 	}
 	logger.Info("bot initialized")
 
+	state := newHealth(time.Now)
 	dispatcher := ext.NewDispatcher(&ext.DispatcherOpts{
 		Error: func(b *gotgbot.Bot, ctx *ext.Context, err error) ext.DispatcherAction {
 			return ext.DispatcherActionNoop
 		},
+		Processor:   state,
 		MaxRoutines: ext.DefaultMaxRoutines,
 	})
 
-	updater := ext.NewUpdater(dispatcher, nil)
+	updater := ext.NewUpdater(dispatcher, &ext.UpdaterOpts{
+		UnhandledErrFunc: state.pollFailed(logger),
+	})
 
-	addHandlers(db, dispatcher, logger, config)
+	handler := addHandlers(db, dispatcher, logger, config, runtime, bus, store)
+	var server *http.Server
+	if config.HealthAddr != "" {
+		server = healthServer(config.HealthAddr, config.MetricsToken, db, logger, state, handler.mediaGroupMap)
+		go func() {
+			logger.Info("health listener started", "addr", config.HealthAddr)
+			err := server.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				logger.Error("health listener stopped", "addr", config.HealthAddr, "error", err)
+			}
+		}()
+	}
 
 	logger.Info("staring polling...")
 	err = updater.StartPolling(bot, &ext.PollingOpts{
@@ -44,12 +82,29 @@ func Run(
 		},
 	})
 	if err != nil {
-		logger.Error(fmt.Sprintf("failed to start polling, error: %v", err))
-		return err
+		return logger.Error("failed to start polling", "error", err)
+	}
+	state.startedPolling()
+	logger.Info(
+		"polling started",
+		"bot", bot.Username,
+		"webapp_url", config.WebAppUrl,
+		"version", config.Version,
+	)
+	<-ctx.Done()
+	logger.Info("stopping polling...")
+	err = updater.Stop()
+	if err != nil {
+		return logger.Error("failed to stop polling", "error", err)
+	}
+	if server != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err = server.Shutdown(shutdownCtx)
+		if err != nil {
+			return logger.Error("failed to shut down health listener", "error", err)
+		}
 	}
-	logger.Info("polling started")
-	logger.Info(fmt.Sprintf("WebApp url - %s", config.WebAppUrl))
-	logger.Info(fmt.Sprintf("%s is live", bot.FirstName))
-	updater.Idle()
+	logger.Info("polling stopped")
 	return nil
 }
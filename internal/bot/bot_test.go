@@ -0,0 +1,65 @@
+package bot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"ratatoskr/internal/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunStopsWithContext(t *testing.T) {
+	polled := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/getMe"):
+			w.Write([]byte(`{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"bot","username":"bot"}}`))
+		case strings.HasSuffix(r.URL.Path, "/getUpdates"):
+			select {
+			case polled <- struct{}{}:
+			default:
+			}
+			time.Sleep(time.Millisecond * 10)
+			w.Write([]byte(`{"ok":true,"result":[]}`))
+		default:
+			w.Write([]byte(`{"ok":true,"result":true}`))
+		}
+	}))
+	defer server.Close()
+	originalAPIURL := apiURL
+	defer func() { apiURL = originalAPIURL }()
+	apiURL = server.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Run(
+			ctx,
+			&dbMock{},
+			fakeLogger(),
+			&config.BotConfig{Token: "TOKEN", WebAppUrl: webAppUrl},
+			nil,
+			nil,
+			nil,
+		)
+	}()
+	select {
+	case <-polled:
+	case err := <-done:
+		t.Fatalf("bot stopped before polling: %v", err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("bot did not start polling")
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Error("bot did not stop after the context was cancelled")
+	}
+}
@@ -0,0 +1,47 @@
+package bot
+
+import (
+	"context"
+	"ratatoskr/internal/correlation"
+	"ratatoskr/internal/logger"
+	"ratatoskr/internal/metrics"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+)
+
+// correlationKey is where the processor keeps the correlation ID of an
+// update in ext.Context.Data.
+const correlationKey = "correlation_id"
+
+// withCorrelationID gives the update a new correlation ID and returns the
+// bot to handle it with, whose Bot API requests carry the same ID.
+func withCorrelationID(b *gotgbot.Bot, ctx *ext.Context) *gotgbot.Bot {
+	id := correlation.New()
+	if ctx.Data == nil {
+		ctx.Data = map[string]any{}
+	}
+	ctx.Data[correlationKey] = id
+	return metrics.WithCorrelationID(b, id)
+}
+
+// updateContext carries the correlation ID of the update.
+func updateContext(ctx *ext.Context) context.Context {
+	id, _ := ctx.Data[correlationKey].(string)
+	return correlation.With(context.Background(), id)
+}
+
+// updateLogger adds the correlation ID and the message of the update to
+// every record.
+func (h handler) updateLogger(ctx *ext.Context) *logger.Logger {
+	return h.logger.WithContext(updateContext(ctx)).With(messageFields(ctx.EffectiveMessage)...)
+}
+
+// messageFields are the log fields identifying a message.
+func messageFields(m *gotgbot.Message) []any {
+	fields := []any{"chat_id", m.Chat.Id, "message_id", m.MessageId}
+	if m.MediaGroupId != "" {
+		fields = append(fields, "media_group_id", m.MediaGroupId)
+	}
+	return fields
+}
@@ -0,0 +1,31 @@
+package bot
+
+import (
+	"ratatoskr/internal/correlation"
+	"ratatoskr/internal/metrics"
+	"testing"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+)
+
+func TestWithCorrelationID(t *testing.T) {
+	b := &gotgbot.Bot{BotClient: metrics.TelegramClient{BotClient: &gotgbot.BaseBotClient{}}}
+	ctx := &ext.Context{}
+
+	if id := correlation.ID(updateContext(ctx)); id != "" {
+		t.Errorf("expected no correlation ID before processing, actual %q", id)
+	}
+	updateBot := withCorrelationID(b, ctx)
+	id := correlation.ID(updateContext(ctx))
+	if !correlation.Valid(id) {
+		t.Fatalf("invalid correlation ID %q", id)
+	}
+	client := updateBot.BotClient.(metrics.TelegramClient)
+	if client.CorrelationID != id {
+		t.Errorf("wrong bot correlation ID\nexpected: %q\nactual:   %q", id, client.CorrelationID)
+	}
+	if withCorrelationID(b, &ext.Context{}) == updateBot {
+		t.Errorf("expected a bot per update")
+	}
+}
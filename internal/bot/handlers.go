@@ -2,15 +2,20 @@ package bot
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"io"
+	"ratatoskr/internal/analytics"
 	"ratatoskr/internal/config"
+	"ratatoskr/internal/correlation"
 	"ratatoskr/internal/db"
+	"ratatoskr/internal/events"
 	"ratatoskr/internal/logger"
+	"ratatoskr/internal/metrics"
 	"ratatoskr/internal/models"
-	"ratatoskr/internal/utils"
+	"ratatoskr/internal/sessions"
+	"ratatoskr/internal/settings"
+	"ratatoskr/internal/tags_parser"
 	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
@@ -25,6 +30,9 @@ type handler struct {
 	mediaGroupMap *mediaGroupMap
 	config        *config.BotConfig
 	db            db.DB
+	sessions      *sessions.Store
+	settings      *settings.Store
+	bus           *events.Bus
 }
 
 func newHandler(
@@ -37,6 +45,13 @@ func newHandler(
 		logger:        logger,
 		mediaGroupMap: newMediaGroupMap(),
 		db:            db,
+		sessions:      sessions.NewStore(db, sessions.DefaultTTL, time.Now),
+		settings: settings.NewStore(
+			db,
+			settings.Defaults(config.ReceiverID),
+			settings.CheckInterval,
+			time.Now,
+		),
 	}
 }
 
@@ -45,8 +60,18 @@ func addHandlers(
 	dispatcher *ext.Dispatcher,
 	logger *logger.Logger,
 	config *config.BotConfig,
-) {
+	runtime *settings.Store,
+	bus *events.Bus,
+	store *sessions.Store,
+) *handler {
 	handler := newHandler(db, logger, config)
+	if runtime != nil {
+		handler.settings = runtime
+	}
+	if store != nil {
+		handler.sessions = store
+	}
+	handler.bus = bus
 	middleware := newMidlleware(logger, config)
 
 	dispatcher.AddHandler(
@@ -57,24 +82,50 @@ func addHandlers(
 	)
 
 	dispatcher.AddHandler(
-		handlers.NewMessage(isTagsMessage, middleware.adminOnly(handler.handleUpdateTags())),
+		handlers.NewCommand("editor",
+			middleware.adminOnly(
+				handler.handleEditor()),
+		),
 	)
 
 	dispatcher.AddHandler(
-		handlers.NewMessage(func(msg *gotgbot.Message) bool {
-			return msg.WebAppData != nil
-		},
+		handlers.NewCommand("dashboard",
 			middleware.adminOnly(
-				handler.handleWebAppData(time.Now)),
+				handler.handleDashboard()),
 		),
 	)
 
+	dispatcher.AddHandler(
+		handlers.NewCommand("export",
+			middleware.adminOnly(
+				handler.handleExport(time.Now)),
+		),
+	)
+
+	dispatcher.AddHandler(
+		handlers.NewCommand("settings",
+			middleware.adminOnly(
+				handler.handleSettings()),
+		),
+	)
+
+	dispatcher.AddHandler(
+		handlers.NewCallback(
+			isSettingsCallback,
+			middleware.adminOnly(handler.handleSettingsCallback()),
+		),
+	)
+
+	dispatcher.AddHandler(
+		handlers.NewMessage(isTagsMessage, middleware.adminOnly(handler.handleUpdateTags())),
+	)
+
 	dispatcher.AddHandler(
 		handlers.NewMessage(
 			message.MediaGroup,
 			middleware.adminOnly(
 				handler.receiveGroup(
-					time.Millisecond*500,
+					handler.mediaGroupInterval,
 					handler.respondWithMediaGroup(
 						handler.removeEffectiveMediaGroup(),
 					),
@@ -116,11 +167,13 @@ func addHandlers(
 		),
 	)
 
+	return handler
 }
 
 func (h handler) handlePhoto(next handlers.Response) handlers.Response {
 	return func(b *gotgbot.Bot, ctx *ext.Context) error {
-		h.logger.Info(fmt.Sprintf("received photo %d", ctx.EffectiveMessage.MessageId))
+		log := h.updateLogger(ctx)
+		log.Info("received photo")
 		m, err := sendPhoto(
 			b,
 			ctx.EffectiveChat.Id,
@@ -128,26 +181,29 @@ func (h handler) handlePhoto(next handlers.Response) handlers.Response {
 			&gotgbot.SendPhotoOpts{},
 		)
 		if err != nil {
-			return h.logger.Error(
-				fmt.Sprintf("failed to reply with photo, error: %v", err),
-			)
+			return log.Error("failed to reply with photo", "error", err)
 		}
-		err = h.sendWebAppMarkup(b, ctx.EffectiveChat.Id, []int64{m.MessageId})
+		h.registerMedia(updateContext(ctx), ctx.EffectiveChat.Id, *m)
+		err = h.sendWebAppMarkup(
+			updateContext(ctx),
+			b,
+			ctx.EffectiveChat.Id,
+			[]int64{ctx.EffectiveMessage.MessageId},
+			[]int64{m.MessageId},
+			models.MediaKindPhoto,
+		)
 		if err != nil {
-			return h.logger.Error(
-				fmt.Sprintf("failed to reply with webapp, error: %v", err),
-			)
+			return log.Error("failed to reply with webapp", "error", err)
 		}
-		h.logger.Info(
-			fmt.Sprintf("photo message reply success %d", m.MessageId),
-		)
+		log.Info("photo message reply success", "reply_id", m.MessageId)
 		return next(b, ctx)
 	}
 }
 
 func (h handler) handleVideo(next handlers.Response) handlers.Response {
 	return func(b *gotgbot.Bot, ctx *ext.Context) error {
-		h.logger.Info(fmt.Sprintf("received video %d", ctx.EffectiveMessage.MessageId))
+		log := h.updateLogger(ctx)
+		log.Info("received video")
 		m, err := sendVideo(
 			b,
 			ctx.EffectiveChat.Id,
@@ -155,26 +211,29 @@ func (h handler) handleVideo(next handlers.Response) handlers.Response {
 			&gotgbot.SendVideoOpts{},
 		)
 		if err != nil {
-			return h.logger.Error(
-				fmt.Sprintf("failed to reply with video, error: %v", err),
-			)
+			return log.Error("failed to reply with video", "error", err)
 		}
-		err = h.sendWebAppMarkup(b, ctx.EffectiveChat.Id, []int64{m.MessageId})
-		h.logger.Info(
-			fmt.Sprintf("video message reply success %d", m.MessageId),
+		h.registerMedia(updateContext(ctx), ctx.EffectiveChat.Id, *m)
+		err = h.sendWebAppMarkup(
+			updateContext(ctx),
+			b,
+			ctx.EffectiveChat.Id,
+			[]int64{ctx.EffectiveMessage.MessageId},
+			[]int64{m.MessageId},
+			models.MediaKindVideo,
 		)
 		if err != nil {
-			return h.logger.Error(
-				fmt.Sprintf("failed to reply with video, error: %v", err),
-			)
+			return log.Error("failed to reply with webapp", "error", err)
 		}
+		log.Info("video message reply success", "reply_id", m.MessageId)
 		return next(b, ctx)
 	}
 }
 
 func (h handler) handleAnimation(next handlers.Response) handlers.Response {
 	return func(b *gotgbot.Bot, ctx *ext.Context) error {
-		h.logger.Info(fmt.Sprintf("received animation %d", ctx.EffectiveMessage.MessageId))
+		log := h.updateLogger(ctx)
+		log.Info("received animation")
 		m, err := sendAnimation(
 			b,
 			ctx.EffectiveChat.Id,
@@ -182,43 +241,56 @@ func (h handler) handleAnimation(next handlers.Response) handlers.Response {
 			&gotgbot.SendAnimationOpts{},
 		)
 		if err != nil {
-			return h.logger.Error(
-				fmt.Sprintf("failed to reply with animation, error: %v", err),
-			)
+			return log.Error("failed to reply with animation", "error", err)
 		}
-		err = h.sendWebAppMarkup(b, ctx.EffectiveChat.Id, []int64{m.MessageId})
+		h.registerMedia(updateContext(ctx), ctx.EffectiveChat.Id, *m)
+		err = h.sendWebAppMarkup(
+			updateContext(ctx),
+			b,
+			ctx.EffectiveChat.Id,
+			[]int64{ctx.EffectiveMessage.MessageId},
+			[]int64{m.MessageId},
+			models.MediaKindAnimation,
+		)
 		if err != nil {
-			return h.logger.Error(
-				fmt.Sprintf("failed to reply with animation, error: %v", err),
-			)
+			return log.Error("failed to reply with webapp", "error", err)
 		}
-		h.logger.Info(fmt.Sprintf("animation message reply success %d", m.MessageId))
+		log.Info("animation message reply success", "reply_id", m.MessageId)
 		return next(b, ctx)
 	}
 }
 
 func (h handler) removeOneEffectiveMessage() handlers.Response {
 	return func(b *gotgbot.Bot, ctx *ext.Context) error {
-		h.logger.Info(fmt.Sprintf("removing message %d", ctx.EffectiveMessage.MessageId))
+		log := h.updateLogger(ctx)
+		log.Info("removing message")
 		ok, err := deleteMessage(
 			b,
 			ctx.EffectiveMessage.GetSender().Id(),
 			ctx.EffectiveMessage.MessageId,
 		)
 		if ok {
-			h.logger.Info(
-				fmt.Sprintf("message successfully removed %d", ctx.EffectiveMessage.MessageId),
-			)
+			log.Info("message successfully removed")
 		}
 		if err != nil {
-			h.logger.Warning(fmt.Sprintf("failed to delete reply message, error: %v", err))
+			log.Warning("failed to delete reply message", "error", err)
 		}
 		return nil
 	}
 }
 
+// mediaGroupInterval is how long the items of a media group are collected
+// before the group is sent back.
+func (h handler) mediaGroupInterval(ctx context.Context) time.Duration {
+	current, err := h.settings.Get(ctx)
+	if err != nil {
+		h.logger.WithContext(ctx).Warning("failed to read settings, using last known", "error", err)
+	}
+	return current.MediaGroupInterval
+}
+
 func (h *handler) receiveGroup(
-	interval time.Duration,
+	interval func(context.Context) time.Duration,
 	next handlers.Response,
 ) handlers.Response {
 	return func(b *gotgbot.Bot, ctx *ext.Context) error {
@@ -231,34 +303,25 @@ func (h *handler) receiveGroup(
 			mediaFileID = ctx.EffectiveMessage.Photo[0].FileId
 			mediaType = "photo"
 		}
-		h.logger.Info(
-			fmt.Sprintf(
-				"receiving group %s, current file %s",
-				ctx.EffectiveMessage.MediaGroupId,
-				mediaFileID,
-			),
-		)
+		log := h.updateLogger(ctx)
+		log.Info("receiving media group item", "file_id", mediaFileID, "media_type", mediaType)
 		h.mediaGroupMap.add(ctx.EffectiveMessage.MediaGroupId, item{
 			fileID:    mediaFileID,
 			mediaType: mediaType,
 			messageID: ctx.EffectiveMessage.MessageId,
 		})
+		wait := interval(updateContext(ctx))
 		go func() {
-			time.Sleep(interval)
+			time.Sleep(wait)
 			related := h.mediaGroupMap.get(ctx.EffectiveMessage.MediaGroupId)
 			if len(related) == 0 {
-				h.logger.Error("map is empty for " + ctx.EffectiveMessage.MediaGroupId)
+				log.Error("media group is empty")
 				return
 			}
 			if related[0].messageID != ctx.EffectiveMessage.MessageId {
 				return
 			}
-			h.logger.Info(
-				fmt.Sprintf(
-					"processing group %s",
-					ctx.EffectiveMessage.MediaGroupId,
-				),
-			)
+			log.Info("processing media group", "items", len(related))
 			next(b, ctx)
 		}()
 		return nil
@@ -267,12 +330,8 @@ func (h *handler) receiveGroup(
 
 func (h handler) respondWithMediaGroup(next handlers.Response) handlers.Response {
 	return func(b *gotgbot.Bot, ctx *ext.Context) error {
-		h.logger.Info(
-			fmt.Sprintf(
-				"responding with media group %d",
-				ctx.EffectiveMessage.MessageId,
-			),
-		)
+		log := h.updateLogger(ctx)
+		log.Info("responding with media group")
 		group := []gotgbot.InputMedia{}
 		for _, item := range h.mediaGroupMap.get(ctx.EffectiveMessage.MediaGroupId) {
 			switch item.mediaType {
@@ -281,7 +340,12 @@ func (h handler) respondWithMediaGroup(next handlers.Response) handlers.Response
 			case "video":
 				group = append(group, gotgbot.InputMediaVideo{Media: item.fileID})
 			default:
-				h.logger.Error(fmt.Sprintf("unhandler media type in %+v", item))
+				log.Error(
+					"unhandled media type",
+					"media_type", item.mediaType,
+					"file_id", item.fileID,
+					"item_message_id", item.messageID,
+				)
 			}
 		}
 		messages, err := sendMediaGroup(
@@ -291,29 +355,28 @@ func (h handler) respondWithMediaGroup(next handlers.Response) handlers.Response
 			&gotgbot.SendMediaGroupOpts{},
 		)
 		if err != nil {
-			return h.logger.Error(
-				fmt.Sprintf("failed to reply with animation, error: %v", err),
-			)
+			return log.Error("failed to reply with media group", "error", err)
 		}
 		messageIDs := []int64{}
 		for _, message := range messages {
 			messageIDs = append(messageIDs, message.MessageId)
 		}
-		h.logger.Info(
-			fmt.Sprintf(
-				"media group files send %d",
-				ctx.EffectiveMessage.MessageId,
-			),
+		log.Info("media group files sent", "reply_ids", messageIDs)
+		sourceIDs := []int64{}
+		for _, item := range h.mediaGroupMap.get(ctx.EffectiveMessage.MediaGroupId) {
+			sourceIDs = append(sourceIDs, item.messageID)
+		}
+		h.registerMedia(updateContext(ctx), ctx.EffectiveChat.Id, messages...)
+		err = h.sendWebAppMarkup(
+			updateContext(ctx),
+			b,
+			ctx.EffectiveChat.Id,
+			sourceIDs,
+			messageIDs,
+			models.MediaKindAlbum,
 		)
-		err = h.sendWebAppMarkup(b, ctx.EffectiveChat.Id, messageIDs)
 		if err != nil {
-			return h.logger.Error(
-				fmt.Sprintf(
-					"failed to send web app markup  %d - %v",
-					ctx.EffectiveMessage.MessageId,
-					err,
-				),
-			)
+			return log.Error("failed to send web app markup", "error", err)
 		}
 		return next(b, ctx)
 	}
@@ -321,179 +384,244 @@ func (h handler) respondWithMediaGroup(next handlers.Response) handlers.Response
 
 func (h handler) removeEffectiveMediaGroup() handlers.Response {
 	return func(b *gotgbot.Bot, ctx *ext.Context) error {
-		h.logger.Info(
-			fmt.Sprintf(
-				"removing effective media group - %s",
-				ctx.EffectiveMessage.MediaGroupId,
-			),
-		)
+		log := h.updateLogger(ctx)
+		log.Info("removing media group")
 		toDelete := []int64{}
 		for _, v := range h.mediaGroupMap.get(ctx.EffectiveMessage.MediaGroupId) {
 			toDelete = append(toDelete, v.messageID)
 		}
 		_, err := deleteMessages(b, ctx.EffectiveChat.Id, toDelete)
 		if err != nil {
-			h.logger.Error(fmt.Sprintf("did not remove group media, reason: %+v", err))
+			log.Error("failed to remove media group", "error", err)
 		}
 		h.mediaGroupMap.remove(ctx.EffectiveMessage.MediaGroupId)
-		h.logger.Info(
-			fmt.Sprintf(
-				"successfully removed media group id - %s",
-				ctx.EffectiveMessage.MediaGroupId,
-			),
-		)
+		log.Info("media group successfully removed")
 		return nil
 	}
 }
 
-func (h handler) sendWebAppMarkup(b bot, chatID int64, messageID []int64) error {
-	h.logger.Info(
-		fmt.Sprintf(
-			"sending web app markup %d",
-			messageID,
-		),
-	)
-	str := []string{}
-	for _, id := range messageID {
-		str = append(str, fmt.Sprint(id))
+// previewSide is the smallest photo side that still looks sharp in the
+// webapp preview strip.
+const previewSide = 320
+
+// registerMedia counts the sent media and stores their preview file IDs, so
+// the webapp can show what is being tagged. Previews are optional, failures
+// are only logged.
+func (h handler) registerMedia(ctx context.Context, chatID int64, messages ...gotgbot.Message) {
+	media := []models.PendingMedia{}
+	for _, m := range messages {
+		metrics.MediaProcessed.Inc(mediaKind(m))
+		kind, fileID := previewFile(m)
+		if fileID == "" {
+			continue
+		}
+		media = append(media, models.PendingMedia{
+			ChatID:    chatID,
+			MessageID: m.MessageId,
+			Kind:      kind,
+			FileID:    fileID,
+		})
 	}
-	m, err := sendMessage(b, chatID, "* * *", &gotgbot.SendMessageOpts{})
+	c, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+	err := h.db.RegisterPendingMedia(c, &media)
 	if err != nil {
-		return h.logger.Error(err.Error())
+		h.logger.WithContext(ctx).Warning("failed to register media previews", "chat_id", chatID, "error", err)
 	}
-	_, err = sendMessage(b, chatID, "* * *", &gotgbot.SendMessageOpts{
-		ReplyMarkup: gotgbot.ReplyKeyboardMarkup{
-			ResizeKeyboard: true,
-			IsPersistent:   true,
-			Keyboard: [][]gotgbot.KeyboardButton{
-				{{
-					Text: "#tag",
-					WebApp: &gotgbot.WebAppInfo{Url: fmt.Sprintf(
-						"%s/%s?message-id=%v&media-id=%v",
-						h.config.WebAppUrl,
-						h.config.Token,
-						m.MessageId+1,
-						strings.Join(str, ","),
-					)},
-				}},
-			},
-		},
+}
+
+func mediaKind(m gotgbot.Message) string {
+	switch {
+	case len(m.Photo) != 0:
+		return models.MediaKindPhoto
+	case m.Animation != nil:
+		return models.MediaKindAnimation
+	case m.Video != nil:
+		return models.MediaKindVideo
+	}
+	return "other"
+}
+
+// previewFile picks the smallest file that is good enough for a preview.
+func previewFile(m gotgbot.Message) (string, string) {
+	switch {
+	case len(m.Photo) != 0:
+		for _, p := range m.Photo {
+			if max(p.Width, p.Height) >= previewSide {
+				return models.MediaKindPhoto, p.FileId
+			}
+		}
+		return models.MediaKindPhoto, m.Photo[len(m.Photo)-1].FileId
+	case m.Animation != nil && m.Animation.Thumbnail != nil:
+		return models.MediaKindAnimation, m.Animation.Thumbnail.FileId
+	case m.Video != nil && m.Video.Thumbnail != nil:
+		return models.MediaKindVideo, m.Video.Thumbnail.FileId
+	}
+	return "", ""
+}
+
+// sendWebAppMarkup attaches an inline webapp button to the echoed media, so
+// every pending item can be tagged on its own. Media groups can not carry
+// reply markup, their button goes to a reply to the first item instead. The
+// button only carries the id of a tagging session that remembers the media.
+func (h handler) sendWebAppMarkup(
+	ctx context.Context,
+	b bot,
+	chatID int64,
+	sourceIDs []int64,
+	messageIDs []int64,
+	mediaKind string,
+) error {
+	log := h.logger.WithContext(ctx).With("chat_id", chatID, "message_ids", messageIDs)
+	log.Info("sending web app markup")
+	buttonMessageID := messageIDs[0]
+	if len(messageIDs) > 1 {
+		m, err := sendMessage(b, chatID, "* * *", &gotgbot.SendMessageOpts{
+			ReplyParameters: &gotgbot.ReplyParameters{MessageId: messageIDs[0]},
+		})
+		if err != nil {
+			return log.Error("failed to send web app button message", "error", err)
+		}
+		buttonMessageID = m.MessageId
+	}
+	c, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+	session, err := h.sessions.Create(c, models.TaggingSession{
+		ChatID:           chatID,
+		SourceMessageIDs: sourceIDs,
+		MessageIDs:       messageIDs,
+		ButtonMessageID:  buttonMessageID,
+		MediaKind:        mediaKind,
+		CorrelationID:    correlation.ID(ctx),
 	})
 	if err != nil {
-		return h.logger.Error(err.Error())
+		return log.Error("failed to create tagging session", "error", err)
 	}
-	_, err = deleteMessage(b, chatID, m.MessageId)
+	_, _, err = editMessageReplyMarkup(b, &gotgbot.EditMessageReplyMarkupOpts{
+		ChatId:    chatID,
+		MessageId: buttonMessageID,
+		ReplyMarkup: gotgbot.InlineKeyboardMarkup{
+			InlineKeyboard: [][]gotgbot.InlineKeyboardButton{{{
+				Text: "#tag",
+				WebApp: &gotgbot.WebAppInfo{Url: fmt.Sprintf(
+					"%s/%s?session=%s",
+					h.config.WebAppUrl,
+					h.config.Token,
+					session.ID,
+				)},
+			}}},
+		},
+	})
 	if err != nil && err != gotgbot.ErrNilBotClient {
-		return h.logger.Error(err.Error())
+		return log.Error("failed to attach web app markup", "session_id", session.ID, "error", err)
 	}
-	h.logger.Info(
-		fmt.Sprintf("webapp markup send - %d", messageID),
-	)
+	log.Info("web app markup sent", "session_id", session.ID)
 	return nil
 }
 
-func (h handler) handleWebAppData(now func() time.Time) handlers.Response {
-	type data struct {
-		MediaIDs  string     `json:"mediaIds,required"`
-		MessageID string     `json:"messageId,required"`
-		Data      [][]string `json:"data,required"`
-	}
+func (h handler) handlePing() handlers.Response {
 	return func(b *gotgbot.Bot, ctx *ext.Context) error {
-		h.logger.Info(
-			fmt.Sprintf(
-				"received webapp markup %d %+v",
-				ctx.EffectiveMessage.MessageId,
-				ctx.EffectiveMessage.WebAppData.Data,
-			),
-		)
-		var d data
-		err := json.Unmarshal([]byte(ctx.EffectiveMessage.WebAppData.Data), &d)
-		if err != nil {
-			return h.logger.Error(err.Error())
-		}
-		mediaIDs, err := utils.StringToIntSlice(d.MediaIDs)
-		if err != nil {
-			return h.logger.Error(err.Error())
-		}
-		tags := []string{}
-		analytics := []models.Analytics{}
-		for _, v := range d.Data {
-			if len(v) != 2 {
-				return h.logger.Error(
-					fmt.Sprintf("Failed to parse data from web app, wrong format: %+v", v),
-				)
-			}
-			group := v[0]
-			tag := v[1]
-			tags = append(tags, tag)
-			analytics = append(
-				analytics,
-				models.Analytics{
-					Group: group,
-					Tag:   tag,
-					Date:  now(),
-				},
-			)
-		}
-		_, _, err = editMessageCaption(b, &gotgbot.EditMessageCaptionOpts{
-			ChatId:    ctx.EffectiveChat.Id,
-			MessageId: mediaIDs[0],
-			Caption:   strings.Join(tags, "\n"),
-		})
-		if err != nil &&
-			!strings.Contains(err.Error(), "are exactly the same as a current content") {
-			return h.logger.Error(err.Error())
-		}
-		_, err = copyMessages(b, h.config.ReceiverID, ctx.EffectiveChat.Id, mediaIDs, nil)
-		if err != nil {
-			return h.logger.Error(err.Error())
-		}
-		messageId, err := strconv.Atoi(d.MessageID)
-		if err != nil {
-			return h.logger.Error(err.Error())
-		}
-		_, err = deleteMessages(
+		log := h.updateLogger(ctx)
+		log.Info("received ping command")
+		_, err := sendMessage(
 			b,
 			ctx.EffectiveChat.Id,
-			[]int64{int64(messageId), ctx.EffectiveMessage.MessageId},
+			fmt.Sprintf("pong (v%s)", h.config.Version),
+			nil,
 		)
 		if err != nil {
-			return h.logger.Error(err.Error())
+			return log.Error("failed to send pong", "error", err)
 		}
-		c, cancel := context.WithTimeout(context.TODO(), time.Second*5)
-		defer cancel()
-		err = h.db.InsertAnalytics(c, &analytics)
+		log.Info("pong sent")
+		return nil
+	}
+}
+
+func (h handler) handleEditor() handlers.Response {
+	return h.handleWebAppPage("editor", "Edit tags menu", "Open editor")
+}
+
+func (h handler) handleDashboard() handlers.Response {
+	return h.handleWebAppPage("dashboard", "Analytics dashboard", "Open dashboard")
+}
+
+// handleWebAppPage answers a command with a button that opens the webapp
+// page of the same name, which checks the init data Telegram passes to it.
+func (h handler) handleWebAppPage(page string, text string, button string) handlers.Response {
+	return func(b *gotgbot.Bot, ctx *ext.Context) error {
+		log := h.updateLogger(ctx)
+		log.Info("received " + page + " command")
+		_, err := sendMessage(b, ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+			ReplyMarkup: gotgbot.InlineKeyboardMarkup{
+				InlineKeyboard: [][]gotgbot.InlineKeyboardButton{{{
+					Text:   button,
+					WebApp: &gotgbot.WebAppInfo{Url: h.config.WebAppUrl + "/" + page},
+				}}},
+			},
+		})
 		if err != nil {
-			h.logger.Error(err.Error())
+			return log.Error("failed to send "+page+" button", "error", err)
 		}
-		h.logger.Info(
-			fmt.Sprintf(
-				"webapp data processed succussfully %d",
-				ctx.EffectiveMessage.MessageId,
-			),
-		)
 		return nil
 	}
 }
 
-func (h handler) handlePing() handlers.Response {
+const exportUsage = "usage: /export analytics [from YYYY-MM-DD] [to YYYY-MM-DD] [csv|json]"
+
+func (h handler) handleExport(now func() time.Time) handlers.Response {
 	return func(b *gotgbot.Bot, ctx *ext.Context) error {
-		h.logger.Info(fmt.Sprintf("received ping command %d", ctx.EffectiveMessage.MessageId))
-		_, err := sendMessage(
+		log := h.updateLogger(ctx)
+		log.Info("received export command")
+		args := strings.Fields(ctx.EffectiveMessage.Text)[1:]
+		if len(args) == 0 || args[0] != "analytics" {
+			sendMessage(b, ctx.EffectiveChat.Id, exportUsage, nil)
+			return log.Error("unknown export", "args", args)
+		}
+		dates := []string{}
+		format := analytics.FormatCSV
+		for _, arg := range args[1:] {
+			f, err := analytics.ParseFormat(arg)
+			if err == nil {
+				format = f
+				continue
+			}
+			dates = append(dates, arg)
+		}
+		if len(dates) > 2 {
+			sendMessage(b, ctx.EffectiveChat.Id, exportUsage, nil)
+			return log.Error("too many export arguments", "args", args)
+		}
+		dates = append(dates, "", "")
+		from, to, err := analytics.ParseRange(dates[0], dates[1], now())
+		if err != nil {
+			sendMessage(b, ctx.EffectiveChat.Id, fmt.Sprintf("%s\n%s", err, exportUsage), nil)
+			return log.Error("invalid export range", "error", err)
+		}
+		c, cancel := context.WithTimeout(updateContext(ctx), time.Minute*5)
+		defer cancel()
+		r, w := io.Pipe()
+		go func() {
+			w.CloseWithError(analytics.Export(c, h.db, w, format, from, to))
+		}()
+		_, err = sendDocument(
 			b,
 			ctx.EffectiveChat.Id,
-			fmt.Sprintf("pong (v%s)", h.config.Version),
-			nil,
+			gotgbot.NamedFile{File: r, FileName: analytics.FileName(format, from, to)},
+			&gotgbot.SendDocumentOpts{
+				RequestOpts: &gotgbot.RequestOpts{Timeout: time.Minute * 5},
+			},
 		)
+		r.Close()
 		if err != nil {
-			return h.logger.Error(err.Error())
+			sendMessage(b, ctx.EffectiveChat.Id, "error", nil)
+			return log.Error("failed to export analytics", "error", err)
 		}
-		h.logger.Info(fmt.Sprintf("successfully send pong %d", ctx.EffectiveMessage.MessageId))
+		log.Info("analytics exported", "format", format, "from", from, "to", to)
 		return nil
 	}
 }
 
-var tagsRegexp = regexp.MustCompile(`(•.*:\n((#.*)(\n?))*)`)
+var tagsRegexp = regexp.MustCompile(`(?m)^\s*•`)
 
 func isTagsMessage(msg *gotgbot.Message) bool {
 	return tagsRegexp.Match([]byte(msg.Text))
@@ -501,42 +629,26 @@ func isTagsMessage(msg *gotgbot.Message) bool {
 
 func (h handler) handleUpdateTags() handlers.Response {
 	return func(b *gotgbot.Bot, ctx *ext.Context) error {
-		h.logger.Info(
-			fmt.Sprintf("received update tags request %d", ctx.EffectiveMessage.MessageId),
-		)
-		all := ctx.EffectiveMessage.Text[strings.IndexRune(ctx.EffectiveMessage.Text, '•'):]
-		groupStrings := strings.Split(all, "\n\n")
-		g := []models.Group{}
-		ok := true
-		for i, group := range groupStrings {
-			data := strings.Split(group, "\n")
-			r := regexp.MustCompile("• (.*):")
-			matched := r.FindStringSubmatch(data[0])
-			name := matched[1]
-			tags := data[1:]
-			if len(tags) == 0 || len(matched) != 2 {
-				ok = false
-				break
-			}
-			t := []models.Tag{}
-			for _, v := range tags {
-				t = append(t, models.Tag{Name: v})
-			}
-			g = append(g, models.Group{Name: name, Tags: t, OriginalIndex: i})
-		}
-		if !ok {
-			sendMessage(b, ctx.EffectiveChat.Id, "error", nil)
-			return h.logger.Error("failed to parse tags")
+		log := h.updateLogger(ctx)
+		log.Info("received update tags request")
+		g, err := tags_parser.Parse(ctx.EffectiveMessage.Text)
+		if err != nil {
+			sendMessage(
+				b,
+				ctx.EffectiveChat.Id,
+				fmt.Sprintf("failed to parse tags:\n%s", err.Error()),
+				nil,
+			)
+			return log.Error("failed to parse tags", "error", err)
 		}
-		err := h.db.UpdateTags(context.Background(), &g)
+		err = h.db.UpdateTags(updateContext(ctx), &g)
 		if err != nil {
 			sendMessage(b, ctx.EffectiveChat.Id, "error", nil)
-			return h.logger.Error(err.Error())
+			return log.Error("failed to update tags", "error", err)
 		}
+		h.bus.Publish(events.TagsChanged)
 		sendMessage(b, ctx.EffectiveChat.Id, "👍", nil)
-		h.logger.Info(
-			fmt.Sprintf("updated tags %d", ctx.EffectiveMessage.MessageId),
-		)
+		log.Info("updated tags", "groups", len(g))
 		return err
 	}
 }
@@ -3,23 +3,30 @@ package bot
 import (
 	"context"
 	"fmt"
+	"io"
 	"ratatoskr/internal/config"
+	"ratatoskr/internal/correlation"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/events"
 	"ratatoskr/internal/logger"
 	"ratatoskr/internal/models"
+	"ratatoskr/internal/sessions"
 	"reflect"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/PaulSonOfLars/gotgbot/v2"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers"
 )
 
 func fakeLogger() *logger.Logger {
 	return logger.NewLogger(
 		"test logger",
 		&strings.Builder{},
-		&strings.Builder{},
+		logger.Options{},
 	)
 }
 
@@ -32,7 +39,7 @@ func TestReceiveGroupMedia(t *testing.T) {
 
 	calls := 0
 	res := fakeHandler.receiveGroup(
-		time.Millisecond*500,
+		func(context.Context) time.Duration { return time.Millisecond * 500 },
 		func(b *gotgbot.Bot, ctx *ext.Context) error {
 			calls++
 			return nil
@@ -166,17 +173,9 @@ func TestSendPhoto(t *testing.T) {
 			MessageId: int64(createdMessageID),
 		}, nil
 	}
-	sendMessage = func(
-		b bot,
-		chatId int64,
-		message string,
-		opts *gotgbot.SendMessageOpts,
-	) (*gotgbot.Message, error) {
-		createdMessageID++
-		if opts.ReplyMarkup != nil {
-			sendWebAppUrl = opts.ReplyMarkup.(gotgbot.ReplyKeyboardMarkup).Keyboard[0][0].WebApp.Url
-		}
-		return &gotgbot.Message{MessageId: int64(createdMessageID)}, nil
+	editMessageReplyMarkup = func(b bot, opts *gotgbot.EditMessageReplyMarkupOpts) (*gotgbot.Message, bool, error) {
+		sendWebAppUrl = opts.ReplyMarkup.InlineKeyboard[0][0].WebApp.Url
+		return nil, true, nil
 	}
 
 	mockNext := func(b *gotgbot.Bot, ctx *ext.Context) error {
@@ -202,9 +201,10 @@ func TestSendPhoto(t *testing.T) {
 		t.Errorf("Next was not called after handlePhoto")
 	}
 	expectedWebAppUrl := fmt.Sprintf(
-		"%s/%s?message-id=3&media-id=1",
+		"%s/%s?session=%s",
 		webAppUrl,
 		fakeHandler.config.Token,
+		onlySession(t, fakeHandler.db).ID,
 	)
 	if sendWebAppUrl != expectedWebAppUrl {
 		t.Errorf(
@@ -251,17 +251,9 @@ func TestSendVideo(t *testing.T) {
 			MessageId: int64(createdMessageID),
 		}, nil
 	}
-	sendMessage = func(
-		b bot,
-		chatId int64,
-		message string,
-		opts *gotgbot.SendMessageOpts,
-	) (*gotgbot.Message, error) {
-		createdMessageID++
-		if opts.ReplyMarkup != nil {
-			sendWebAppUrl = opts.ReplyMarkup.(gotgbot.ReplyKeyboardMarkup).Keyboard[0][0].WebApp.Url
-		}
-		return &gotgbot.Message{MessageId: int64(createdMessageID)}, nil
+	editMessageReplyMarkup = func(b bot, opts *gotgbot.EditMessageReplyMarkupOpts) (*gotgbot.Message, bool, error) {
+		sendWebAppUrl = opts.ReplyMarkup.InlineKeyboard[0][0].WebApp.Url
+		return nil, true, nil
 	}
 
 	mockNext := func(b *gotgbot.Bot, ctx *ext.Context) error {
@@ -287,9 +279,10 @@ func TestSendVideo(t *testing.T) {
 		t.Errorf("Next was not called after handleVideo")
 	}
 	expectedWebAppUrl := fmt.Sprintf(
-		"%s/%s?message-id=3&media-id=1",
+		"%s/%s?session=%s",
 		webAppUrl,
 		fakeHandler.config.Token,
+		onlySession(t, fakeHandler.db).ID,
 	)
 	if sendWebAppUrl != expectedWebAppUrl {
 		t.Errorf(
@@ -335,18 +328,6 @@ func TestSendAnimation(t *testing.T) {
 		return &gotgbot.Message{MessageId: int64(createdMessageID)}, nil
 	}
 
-	sendMessage = func(
-		b bot,
-		chatId int64,
-		message string,
-		opts *gotgbot.SendMessageOpts,
-	) (*gotgbot.Message, error) {
-		createdMessageID++
-		if opts.ReplyMarkup != nil {
-			sendWebAppUrl = opts.ReplyMarkup.(gotgbot.ReplyKeyboardMarkup).Keyboard[0][0].WebApp.Url
-		}
-		return &gotgbot.Message{MessageId: int64(createdMessageID)}, nil
-	}
 	editMessageReplyMarkup = func(b bot, opts *gotgbot.EditMessageReplyMarkupOpts) (*gotgbot.Message, bool, error) {
 		sendWebAppUrl = opts.ReplyMarkup.InlineKeyboard[0][0].WebApp.Url
 		return nil, true, nil
@@ -374,7 +355,7 @@ func TestSendAnimation(t *testing.T) {
 	if !nextCalled {
 		t.Errorf("Next was not called after handleAnimation")
 	}
-	expectedWebAppUrl := fmt.Sprintf("%s/?message-id=3&media-id=1", webAppUrl)
+	expectedWebAppUrl := fmt.Sprintf("%s/?session=%s", webAppUrl, onlySession(t, fakeHandler.db).ID)
 	if sendWebAppUrl != expectedWebAppUrl {
 		t.Errorf(
 			"Did not send correct webApp message-id query params\nexpected: %v\nactual:   %v",
@@ -394,9 +375,10 @@ func TestRespondWithMediaGroup(t *testing.T) {
 	}
 	var send arg
 	var sendWebAppUrl string
-	sendMessageID := 0
+	sendMessageID := 3
+	database := &dbMock{}
 	fakeHandler := newHandler(
-		&dbMock{},
+		database,
 		fakeLogger(),
 		&config.BotConfig{Token: "TOKEN", WebAppUrl: webAppUrl},
 	)
@@ -440,9 +422,9 @@ func TestRespondWithMediaGroup(t *testing.T) {
 			inputMedia: inputMedia,
 		}
 		return []gotgbot.Message{
-			{MessageId: 1},
-			{MessageId: 2},
-			{MessageId: 3},
+			{MessageId: 1, Photo: []gotgbot.PhotoSize{{FileId: "thumb 1", Width: 90, Height: 60}}},
+			{MessageId: 2, Photo: []gotgbot.PhotoSize{{FileId: "thumb 2", Width: 90, Height: 60}}},
+			{MessageId: 3, Video: &gotgbot.Video{Thumbnail: &gotgbot.PhotoSize{FileId: "thumb 3"}}},
 		}, nil
 	}
 	sendMessage = func(
@@ -452,12 +434,12 @@ func TestRespondWithMediaGroup(t *testing.T) {
 		opts *gotgbot.SendMessageOpts,
 	) (*gotgbot.Message, error) {
 		sendMessageID++
-		if opts.ReplyMarkup != nil {
-			sendWebAppUrl = opts.ReplyMarkup.(gotgbot.ReplyKeyboardMarkup).Keyboard[0][0].WebApp.Url
-		}
-
 		return &gotgbot.Message{MessageId: int64(sendMessageID)}, nil
 	}
+	editMessageReplyMarkup = func(b bot, opts *gotgbot.EditMessageReplyMarkupOpts) (*gotgbot.Message, bool, error) {
+		sendWebAppUrl = opts.ReplyMarkup.InlineKeyboard[0][0].WebApp.Url
+		return nil, true, nil
+	}
 
 	err := fakeHandler.respondWithMediaGroup(func(b *gotgbot.Bot, ctx *ext.Context) error {
 		nextCalled = true
@@ -489,9 +471,10 @@ func TestRespondWithMediaGroup(t *testing.T) {
 		t.Errorf("Did not send correct media group:\nexpected: %+v\nactual:   %+v", expected, send)
 	}
 	expectedWebAppUrl := fmt.Sprintf(
-		"%s/%s?message-id=2&media-id=1,2,3",
+		"%s/%s?session=%s",
 		webAppUrl,
 		fakeHandler.config.Token,
+		onlySession(t, fakeHandler.db).ID,
 	)
 	if sendWebAppUrl != expectedWebAppUrl {
 		t.Errorf(
@@ -500,11 +483,93 @@ func TestRespondWithMediaGroup(t *testing.T) {
 			sendWebAppUrl,
 		)
 	}
+	expectedMedia := []models.PendingMedia{
+		{ChatID: 1, MessageID: 1, Kind: "photo", FileID: "thumb 1"},
+		{ChatID: 1, MessageID: 2, Kind: "photo", FileID: "thumb 2"},
+		{ChatID: 1, MessageID: 3, Kind: "video", FileID: "thumb 3"},
+	}
+	if !reflect.DeepEqual(database.media, expectedMedia) {
+		t.Errorf(
+			"Did not register media previews\nexpected: %+v\nactual:   %+v",
+			expectedMedia,
+			database.media,
+		)
+	}
 	if !nextCalled {
 		t.Error("did not call next after clearing messages")
 	}
 }
 
+func TestPreviewFile(t *testing.T) {
+	type tc struct {
+		name    string
+		message gotgbot.Message
+		kind    string
+		fileID  string
+	}
+
+	table := []tc{
+		{
+			name: "should pick smallest photo size big enough for preview",
+			message: gotgbot.Message{Photo: []gotgbot.PhotoSize{
+				{FileId: "s", Width: 90, Height: 67},
+				{FileId: "m", Width: 320, Height: 240},
+				{FileId: "x", Width: 1280, Height: 960},
+			}},
+			kind:   "photo",
+			fileID: "m",
+		},
+
+		{
+			name: "should pick largest photo size when all are small",
+			message: gotgbot.Message{Photo: []gotgbot.PhotoSize{
+				{FileId: "s", Width: 90, Height: 67},
+				{FileId: "m", Width: 200, Height: 150},
+			}},
+			kind:   "photo",
+			fileID: "m",
+		},
+
+		{
+			name: "should use video thumbnail",
+			message: gotgbot.Message{
+				Video: &gotgbot.Video{FileId: "video", Thumbnail: &gotgbot.PhotoSize{FileId: "thumb"}},
+			},
+			kind:   "video",
+			fileID: "thumb",
+		},
+
+		{
+			name: "should use animation thumbnail",
+			message: gotgbot.Message{
+				Animation: &gotgbot.Animation{Thumbnail: &gotgbot.PhotoSize{FileId: "thumb"}},
+				Document:  &gotgbot.Document{FileId: "document"},
+			},
+			kind:   "animation",
+			fileID: "thumb",
+		},
+
+		{
+			name:    "should skip video without thumbnail",
+			message: gotgbot.Message{Video: &gotgbot.Video{FileId: "video"}},
+		},
+	}
+
+	for _, test := range table {
+		kind, fileID := previewFile(test.message)
+		if kind != test.kind || fileID != test.fileID {
+			t.Errorf(
+				"%s\nexpected: %s %s\nactual:   %s %s",
+				test.name,
+				test.kind,
+				test.fileID,
+				kind,
+				fileID,
+			)
+		}
+	}
+}
+
 var webAppUrl = "https://webapp.url"
 
 func TestRemoveEffectiveMediaGroup(t *testing.T) {
@@ -571,392 +636,174 @@ func TestRemoveEffectiveMediaGroup(t *testing.T) {
 	}
 }
 
+// onlySession returns the tagging session a handler created in dbMock.
+func onlySession(t *testing.T, database db.DB) models.TaggingSession {
+	t.Helper()
+	sessions := database.(*dbMock).sessions
+	if len(sessions) != 1 {
+		t.Fatalf("expected one tagging session, actual: %+v", sessions)
+	}
+	return sessions[0]
+}
+
 func TestSendWebAppMarkup(t *testing.T) {
+	type markup struct {
+		chatID    int64
+		messageID int64
+		url       string
+	}
 	type tc struct {
-		name     string
-		expected string
-		run      func() string
+		name       string
+		chatID     int64
+		sourceIDs  []int64
+		messageIDs []int64
+		mediaKind  string
+		replyTo    []int64
+		markup     markup
+		session    models.TaggingSession
 	}
 
 	originalSendMessage := sendMessage
-
+	originalEditMessageReplyMarkup := editMessageReplyMarkup
 	defer func() {
 		sendMessage = originalSendMessage
+		editMessageReplyMarkup = originalEditMessageReplyMarkup
 	}()
 
 	table := []tc{
 		{
-			name: "should create webapp with url for one photo",
-			run: func() string {
-				var url string
-				sendMessageCalls := 0
-				sendMessage = func(b bot, chatId int64, message string, opts *gotgbot.SendMessageOpts) (*gotgbot.Message, error) {
-					sendMessageCalls++
-					if opts.ReplyMarkup != nil {
-						url = opts.ReplyMarkup.(gotgbot.ReplyKeyboardMarkup).Keyboard[0][0].WebApp.Url
-					}
-					return &gotgbot.Message{MessageId: int64(sendMessageCalls)}, nil
-				}
-				fakeHandler := newHandler(
-					&dbMock{},
-					fakeLogger(),
-					&config.BotConfig{Token: "TOKEN", WebAppUrl: webAppUrl},
-				)
-				fakeHandler.sendWebAppMarkup(&gotgbot.Bot{}, int64(sendMessageCalls), []int64{1234})
-				return url
+			name:       "should attach button to photo",
+			chatID:     1,
+			sourceIDs:  []int64{1233},
+			messageIDs: []int64{1234},
+			mediaKind:  models.MediaKindPhoto,
+			replyTo:    []int64{},
+			markup:     markup{chatID: 1, messageID: 1234},
+			session: models.TaggingSession{
+				ChatID:           1,
+				SourceMessageIDs: []int64{1233},
+				MessageIDs:       []int64{1234},
+				ButtonMessageID:  1234,
+				MediaKind:        models.MediaKindPhoto,
+				CorrelationID:    "abc123",
 			},
-			expected: webAppUrl + "/TOKEN?message-id=2&media-id=1234",
 		},
 
 		{
-			name: "should create webapp with url for one animation",
-			run: func() string {
-				var url string
-				sendMessageCalls := 0
-				sendMessage = func(b bot, chatId int64, message string, opts *gotgbot.SendMessageOpts) (*gotgbot.Message, error) {
-					sendMessageCalls++
-					if opts.ReplyMarkup != nil {
-						url = opts.ReplyMarkup.(gotgbot.ReplyKeyboardMarkup).Keyboard[0][0].WebApp.Url
-					}
-					return &gotgbot.Message{MessageId: int64(sendMessageCalls)}, nil
-				}
-				fakeHandler := newHandler(
-					&dbMock{},
-					fakeLogger(),
-					&config.BotConfig{Token: "TOKEN", WebAppUrl: webAppUrl},
-				)
-				fakeHandler.sendWebAppMarkup(&gotgbot.Bot{}, 1, []int64{1234})
-				return url
+			name:       "should attach button to animation",
+			chatID:     1,
+			sourceIDs:  []int64{1233},
+			messageIDs: []int64{1234},
+			mediaKind:  models.MediaKindAnimation,
+			replyTo:    []int64{},
+			markup:     markup{chatID: 1, messageID: 1234},
+			session: models.TaggingSession{
+				ChatID:           1,
+				SourceMessageIDs: []int64{1233},
+				MessageIDs:       []int64{1234},
+				ButtonMessageID:  1234,
+				MediaKind:        models.MediaKindAnimation,
+				CorrelationID:    "abc123",
 			},
-			expected: webAppUrl + "/TOKEN?message-id=2&media-id=1234",
 		},
 
 		{
-			name: "should create webapp with url for one video",
-			run: func() string {
-				var url string
-				sendMessageCalls := 0
-				sendMessage = func(b bot, chatId int64, message string, opts *gotgbot.SendMessageOpts) (*gotgbot.Message, error) {
-					sendMessageCalls++
-					if opts.ReplyMarkup != nil {
-						url = opts.ReplyMarkup.(gotgbot.ReplyKeyboardMarkup).Keyboard[0][0].WebApp.Url
-					}
-					return &gotgbot.Message{MessageId: int64(sendMessageCalls)}, nil
-				}
-				fakeHandler := newHandler(
-					&dbMock{},
-					fakeLogger(),
-					&config.BotConfig{Token: "TOKEN", WebAppUrl: webAppUrl},
-				)
-				fakeHandler.sendWebAppMarkup(&gotgbot.Bot{}, 1, []int64{1234})
-				return url
+			name:       "should attach button to video",
+			chatID:     1,
+			sourceIDs:  []int64{1233},
+			messageIDs: []int64{1234},
+			mediaKind:  models.MediaKindVideo,
+			replyTo:    []int64{},
+			markup:     markup{chatID: 1, messageID: 1234},
+			session: models.TaggingSession{
+				ChatID:           1,
+				SourceMessageIDs: []int64{1233},
+				MessageIDs:       []int64{1234},
+				ButtonMessageID:  1234,
+				MediaKind:        models.MediaKindVideo,
+				CorrelationID:    "abc123",
 			},
-			expected: webAppUrl + "/TOKEN?message-id=2&media-id=1234",
 		},
 
 		{
-			name: "should create webapp with url for group",
-			run: func() string {
-				var url string
-
-				sendMessage = func(b bot, chatId int64, message string, opts *gotgbot.SendMessageOpts) (*gotgbot.Message, error) {
-					if opts.ReplyMarkup != nil {
-						url = opts.ReplyMarkup.(gotgbot.ReplyKeyboardMarkup).Keyboard[0][0].WebApp.Url
-					}
-					return &gotgbot.Message{MessageId: 1}, nil
-				}
-
-				fakeHandler := newHandler(
-					&dbMock{},
-					fakeLogger(),
-					&config.BotConfig{Token: "TOKEN", WebAppUrl: webAppUrl},
-				)
-				fakeHandler.sendWebAppMarkup(&gotgbot.Bot{}, 1, []int64{1234, 1235, 1236})
-				return url
+			name:       "should reply to group with button",
+			chatID:     1,
+			sourceIDs:  []int64{1230, 1231, 1232},
+			messageIDs: []int64{1234, 1235, 1236},
+			mediaKind:  models.MediaKindAlbum,
+			replyTo:    []int64{1234},
+			markup:     markup{chatID: 1, messageID: 1237},
+			session: models.TaggingSession{
+				ChatID:           1,
+				SourceMessageIDs: []int64{1230, 1231, 1232},
+				MessageIDs:       []int64{1234, 1235, 1236},
+				ButtonMessageID:  1237,
+				MediaKind:        models.MediaKindAlbum,
+				CorrelationID:    "abc123",
 			},
-			expected: webAppUrl + "/TOKEN?message-id=2&media-id=1234,1235,1236",
 		},
 	}
 
 	for _, test := range table {
-		actual := test.run()
-		if test.expected != actual {
-			t.Errorf(
-				"%s - unexpected webapp url\nexpected: %s\nactual:   %s",
-				test.name,
-				test.expected,
-				actual,
-			)
+		replyTo := []int64{}
+		var actual markup
+		sendMessage = func(b bot, chatId int64, message string, opts *gotgbot.SendMessageOpts) (*gotgbot.Message, error) {
+			replyTo = append(replyTo, opts.ReplyParameters.MessageId)
+			return &gotgbot.Message{MessageId: 1237}, nil
 		}
-	}
-}
-
-func TestHandleWebAppData(t *testing.T) {
-	type editCaptionsResult struct {
-		chatID    int64
-		messageID int64
-		caption   string
-	}
-	type copyMessagesResult struct {
-		from       int64
-		receiver   int64
-		messageIDs []int64
-	}
-	type deleteMessagesResult struct {
-		chatID     int64
-		massageIDs []int64
-	}
-	type result struct {
-		editCaption    editCaptionsResult
-		copyMessages   copyMessagesResult
-		deleteMessages deleteMessagesResult
-		analytics      []models.Analytics
-	}
-	type tc struct {
-		name     string
-		ctx      *ext.Context
-		call     func(ctx *ext.Context) result
-		expected result
-	}
-
-	originalEditMessageCaptionOpts := editMessageCaption
-	originalDeleteMessages := deleteMessages
-	originalCopyMessages := copyMessages
-	defer func() {
-		editMessageCaption = originalEditMessageCaptionOpts
-		deleteMessages = originalDeleteMessages
-		copyMessages = originalCopyMessages
-	}()
-	var res result
-	deleteMessages = func(b bot, chatId int64, messageIds []int64) (bool, error) {
-		res.deleteMessages.chatID = chatId
-		res.deleteMessages.massageIDs = messageIds
-		return true, nil
-	}
-	copyMessages = func(b bot, chatId, fromChatId int64, messageIds []int64, opts *gotgbot.CopyMessagesOpts) ([]gotgbot.MessageId, error) {
-		res.copyMessages.from = fromChatId
-		res.copyMessages.receiver = chatId
-		res.copyMessages.messageIDs = messageIds
-		return []gotgbot.MessageId{}, nil
-	}
-	editMessageCaption = func(b bot, opts *gotgbot.EditMessageCaptionOpts) (*gotgbot.Message, bool, error) {
-		res.editCaption.chatID = opts.ChatId
-		res.editCaption.messageID = opts.MessageId
-		res.editCaption.caption = opts.Caption
-		return nil, true, nil
-	}
-	database := dbMock{}
-	fh := newHandler(&database, fakeLogger(), &config.BotConfig{
-		ReceiverID: 7890,
-	})
-
-	now := time.Now()
-
-	table := []tc{
-		{
-			name: "edit one caption and copy one message",
-			ctx: &ext.Context{
-				EffectiveChat: &gotgbot.Chat{
-					Id: 1,
-				},
-				EffectiveMessage: &gotgbot.Message{
-					MessageId: 3,
-					WebAppData: &gotgbot.WebAppData{
-						Data: `{
-                            "data": [["Group 1", "#tag1"],["Group 1", "#tag2"],["Group 1", "#tag3"]],
-                            "mediaIds": "1",
-                            "messageId": "2"
-                        }`,
-					}},
-			},
-			call: func(ctx *ext.Context) result {
-				res = result{}
-				fh.handleWebAppData(func() time.Time { return now })(&gotgbot.Bot{}, ctx)
-				res.analytics = *database.analytics
-				return res
-			},
-			expected: result{
-				editCaption: editCaptionsResult{
-					chatID:    1,
-					messageID: 1,
-					caption:   "#tag1\n#tag2\n#tag3",
-				},
-				copyMessages: copyMessagesResult{
-					from:       1,
-					receiver:   7890,
-					messageIDs: []int64{1},
-				},
-				deleteMessages: deleteMessagesResult{
-					chatID:     1,
-					massageIDs: []int64{2, 3},
-				},
-				analytics: []models.Analytics{
-					{
-						Group: "Group 1",
-						Tag:   "#tag1",
-						Date:  now,
-					},
-					{
-						Group: "Group 1",
-						Tag:   "#tag2",
-						Date:  now,
-					},
-					{
-						Group: "Group 1",
-						Tag:   "#tag3",
-						Date:  now,
-					},
-				},
-			},
-		},
-
-		{
-			name: "edit one caption and copy multiple messages",
-			ctx: &ext.Context{
-				EffectiveChat: &gotgbot.Chat{
-					Id: 1,
-				},
-				EffectiveMessage: &gotgbot.Message{
-					MessageId: 3,
-					WebAppData: &gotgbot.WebAppData{
-						Data: `{
-                            "data": [["Group 1", "#tag1"],["Group 1", "#tag2"],["Group 1", "#tag3"]],
-                            "mediaIds": "1,2,3",
-                            "messageId": "2"
-                        }`,
-					}},
-			},
-			call: func(ctx *ext.Context) result {
-				res = result{}
-				fh.handleWebAppData(func() time.Time { return now })(&gotgbot.Bot{}, ctx)
-				res.analytics = *database.analytics
-				return res
-			},
-			expected: result{
-				editCaption: editCaptionsResult{
-					chatID:    1,
-					messageID: 1,
-					caption:   "#tag1\n#tag2\n#tag3",
-				},
-				copyMessages: copyMessagesResult{
-					from:       1,
-					receiver:   7890,
-					messageIDs: []int64{1, 2, 3},
-				},
-				deleteMessages: deleteMessagesResult{
-					chatID:     1,
-					massageIDs: []int64{2, 3},
-				},
-				analytics: []models.Analytics{
-					{
-						Group: "Group 1",
-						Tag:   "#tag1",
-						Date:  now,
-					},
-					{
-						Group: "Group 1",
-						Tag:   "#tag2",
-						Date:  now,
-					},
-					{
-						Group: "Group 1",
-						Tag:   "#tag3",
-						Date:  now,
-					},
-				},
-			},
-		},
-
-		{
-			name: "should remain order of elements",
-			ctx: &ext.Context{
-				EffectiveChat: &gotgbot.Chat{
-					Id: 1,
-				},
-				EffectiveMessage: &gotgbot.Message{
-					MessageId: 3,
-					WebAppData: &gotgbot.WebAppData{
-						Data: `{
-                            "data": [["Group 2", "#tag2"],["Group 1", "#tag3"],["Group 1", "#tag1"]],
-                            "mediaIds": "1,2,3",
-                            "messageId": "2"
-                        }`,
-					}},
-			},
-			call: func(ctx *ext.Context) result {
-				res = result{}
-				fh.handleWebAppData(func() time.Time { return now })(&gotgbot.Bot{}, ctx)
-				res.analytics = *database.analytics
-				return res
-			},
-			expected: result{
-				editCaption: editCaptionsResult{
-					chatID:    1,
-					messageID: 1,
-					caption:   "#tag2\n#tag3\n#tag1",
-				},
-				copyMessages: copyMessagesResult{
-					from:       1,
-					receiver:   7890,
-					messageIDs: []int64{1, 2, 3},
-				},
-				deleteMessages: deleteMessagesResult{
-					chatID:     1,
-					massageIDs: []int64{2, 3},
-				},
-				analytics: []models.Analytics{
-					{
-						Group: "Group 2",
-						Tag:   "#tag2",
-						Date:  now,
-					},
-					{
-						Group: "Group 1",
-						Tag:   "#tag3",
-						Date:  now,
-					},
-					{
-						Group: "Group 1",
-						Tag:   "#tag1",
-						Date:  now,
-					},
-				},
-			},
-		},
-	}
-
-	for _, test := range table {
-		actual := test.call(test.ctx)
-		if !reflect.DeepEqual(test.expected.editCaption, actual.editCaption) {
-			t.Errorf(
-				"Unexpected edit caption result - %s\nexpected: %+v\nactual:   %+v",
-				test.name,
-				test.expected.editCaption,
-				actual.editCaption,
-			)
+		editMessageReplyMarkup = func(b bot, opts *gotgbot.EditMessageReplyMarkupOpts) (*gotgbot.Message, bool, error) {
+			actual = markup{
+				chatID:    opts.ChatId,
+				messageID: opts.MessageId,
+				url:       opts.ReplyMarkup.InlineKeyboard[0][0].WebApp.Url,
+			}
+			return nil, true, nil
+		}
+		database := &dbMock{}
+		fakeHandler := newHandler(
+			database,
+			fakeLogger(),
+			&config.BotConfig{Token: "TOKEN", WebAppUrl: webAppUrl},
+		)
+		err := fakeHandler.sendWebAppMarkup(
+			correlation.With(context.Background(), "abc123"),
+			&gotgbot.Bot{},
+			test.chatID,
+			test.sourceIDs,
+			test.messageIDs,
+			test.mediaKind,
+		)
+		if err != nil {
+			t.Errorf("%s - unexpected error: %v", test.name, err)
+			continue
 		}
-		if !reflect.DeepEqual(test.expected.analytics, actual.analytics) {
+		if !reflect.DeepEqual(test.replyTo, replyTo) {
 			t.Errorf(
-				"Unexpected analytics result - %s\nexpected: %+v\nactual:   %+v",
+				"%s - unexpected replies\nexpected: %+v\nactual:   %+v",
 				test.name,
-				test.expected.analytics,
-				actual.analytics,
+				test.replyTo,
+				replyTo,
 			)
 		}
-		if !reflect.DeepEqual(test.expected.copyMessages, actual.copyMessages) {
+		session := onlySession(t, database)
+		test.markup.url = webAppUrl + "/TOKEN?session=" + session.ID
+		if test.markup != actual {
 			t.Errorf(
-				"Unexpected copy messgaes result - %s\nexpected: %+v\nactual:   %+v",
+				"%s - unexpected webapp markup\nexpected: %+v\nactual:   %+v",
 				test.name,
-				test.expected.copyMessages,
-				actual.copyMessages,
+				test.markup,
+				actual,
 			)
 		}
-		if !reflect.DeepEqual(test.expected.deleteMessages, actual.deleteMessages) {
+		test.session.ID = session.ID
+		test.session.CreatedAt = session.CreatedAt
+		test.session.ExpiresAt = session.CreatedAt.Add(sessions.DefaultTTL)
+		if !reflect.DeepEqual(test.session, session) {
 			t.Errorf(
-				"Unexpected deleteMessages result - %s\nexpected: %+v\nactual:   %+v",
+				"%s - unexpected session\nexpected: %+v\nactual:   %+v",
 				test.name,
-				test.expected.deleteMessages,
-				actual.deleteMessages,
+				test.session,
+				session,
 			)
 		}
 	}
@@ -992,6 +839,118 @@ func TestPingHandler(t *testing.T) {
 	}
 }
 
+func TestHandleWebAppPage(t *testing.T) {
+	originalSendMessage := sendMessage
+	defer func() { sendMessage = originalSendMessage }()
+	url := ""
+	sendMessage = func(b bot, chatId int64, message string, opts *gotgbot.SendMessageOpts) (*gotgbot.Message, error) {
+		markup := opts.ReplyMarkup.(gotgbot.InlineKeyboardMarkup)
+		url = markup.InlineKeyboard[0][0].WebApp.Url
+		return &gotgbot.Message{}, nil
+	}
+	fakeHandler := newHandler(
+		&dbMock{},
+		fakeLogger(),
+		&config.BotConfig{Token: "TOKEN", WebAppUrl: webAppUrl},
+	)
+
+	type tc struct {
+		name     string
+		command  string
+		response handlers.Response
+		url      string
+	}
+
+	table := []tc{
+		{
+			name:     "should open editor",
+			command:  "/editor",
+			response: fakeHandler.handleEditor(),
+			url:      webAppUrl + "/editor",
+		},
+
+		{
+			name:     "should open dashboard",
+			command:  "/dashboard",
+			response: fakeHandler.handleDashboard(),
+			url:      webAppUrl + "/dashboard",
+		},
+	}
+
+	for _, test := range table {
+		url = ""
+		err := test.response(&gotgbot.Bot{}, &ext.Context{
+			EffectiveChat:    &gotgbot.Chat{Id: 1},
+			EffectiveMessage: &gotgbot.Message{Text: test.command},
+		})
+		if err != nil {
+			t.Errorf("%s - unexpected error: %v", test.name, err)
+		}
+		if url != test.url {
+			t.Errorf("%s - wrong url\nexpected: %+v\nactual:   %+v", test.name, test.url, url)
+		}
+	}
+}
+
+func TestHandleExport(t *testing.T) {
+	originalSendDocument := sendDocument
+	originalSendMessage := sendMessage
+	defer func() {
+		sendDocument = originalSendDocument
+		sendMessage = originalSendMessage
+	}()
+	type document struct {
+		chatID  int64
+		name    string
+		content string
+	}
+	var sent document
+	reply := ""
+	sendDocument = func(b bot, chatId int64, file gotgbot.InputFile, opts *gotgbot.SendDocumentOpts) (*gotgbot.Message, error) {
+		named := file.(gotgbot.NamedReader)
+		content, err := io.ReadAll(named)
+		if err != nil {
+			return nil, err
+		}
+		sent = document{chatID: chatId, name: named.Name(), content: string(content)}
+		return &gotgbot.Message{}, nil
+	}
+	sendMessage = func(b bot, chatId int64, message string, opts *gotgbot.SendMessageOpts) (*gotgbot.Message, error) {
+		reply = message
+		return nil, nil
+	}
+	fakeHandler := newHandler(
+		&dbMock{},
+		fakeLogger(),
+		&config.BotConfig{Token: "TOKEN", WebAppUrl: webAppUrl},
+	)
+	now := func() time.Time { return time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC) }
+
+	err := fakeHandler.handleExport(now)(&gotgbot.Bot{}, &ext.Context{
+		EffectiveChat:    &gotgbot.Chat{Id: 1},
+		EffectiveMessage: &gotgbot.Message{Text: "/export analytics 2024-01-01 2024-01-31 json"},
+	})
+	if err != nil {
+		t.Errorf("unexpected export error: %v", err)
+	}
+	expected := document{chatID: 1, name: "analytics-2024-01-01-2024-02-01.json", content: "[\n]\n"}
+	if !reflect.DeepEqual(expected, sent) {
+		t.Errorf("did not send export\nexpected: %+v\nactual:   %+v", expected, sent)
+	}
+
+	sent = document{}
+	err = fakeHandler.handleExport(now)(&gotgbot.Bot{}, &ext.Context{
+		EffectiveChat:    &gotgbot.Chat{Id: 1},
+		EffectiveMessage: &gotgbot.Message{Text: "/export analytics 2024-13-01"},
+	})
+	if err == nil || sent.name != "" {
+		t.Errorf("exported with malformed date")
+	}
+	if !strings.Contains(reply, exportUsage) {
+		t.Errorf("did not reply with usage: %q", reply)
+	}
+}
+
 func TestHandleUpdateTags(t *testing.T) {
 	database := dbMock{}
 	originalSendMessage := sendMessage
@@ -1015,6 +974,9 @@ func TestHandleUpdateTags(t *testing.T) {
 		fakeLogger(),
 		&config.BotConfig{Token: "TOKEN", WebAppUrl: webAppUrl},
 	)
+	fakeHandler.bus = events.NewBus()
+	published := 0
+	fakeHandler.bus.Subscribe(events.TagsChanged, func() { published++ })
 	fakeHandler.handleUpdateTags()(&gotgbot.Bot{}, &ext.Context{
 		EffectiveChat: &gotgbot.Chat{
 			Id: 1,
@@ -1063,6 +1025,51 @@ func TestHandleUpdateTags(t *testing.T) {
 			m,
 		)
 	}
+	if published != 1 {
+		t.Errorf("tags change was not published\nexpected: %+v\nactual:   %+v", 1, published)
+	}
+}
+
+func TestHandleUpdateTagsParseErrors(t *testing.T) {
+	database := dbMock{}
+	originalSendMessage := sendMessage
+	defer func() {
+		sendMessage = originalSendMessage
+	}()
+	reply := ""
+	sendMessage = func(b bot, chatId int64, message string, opts *gotgbot.SendMessageOpts) (*gotgbot.Message, error) {
+		reply = message
+		return nil, nil
+	}
+	fakeHandler := newHandler(
+		&database,
+		fakeLogger(),
+		&config.BotConfig{Token: "TOKEN", WebAppUrl: webAppUrl},
+	)
+	err := fakeHandler.handleUpdateTags()(&gotgbot.Bot{}, &ext.Context{
+		EffectiveChat: &gotgbot.Chat{
+			Id: 1,
+		},
+		EffectiveMessage: &gotgbot.Message{
+			Text: `Tags list
+
+• Group 1
+#tag1
+tag2`,
+		},
+	})
+	if err == nil {
+		t.Error("did not fail on malformed tags")
+	}
+	if database.groups != nil {
+		t.Errorf("updated tags despite parse errors: %+v", *database.groups)
+	}
+	expected := "failed to parse tags:\n" +
+		"line 3: group name must end with a colon (\"• Group 1\")\n" +
+		"line 5: tag must start with # (\"tag2\")"
+	if reply != expected {
+		t.Errorf("did not reply with parse errors\nexpected: %q\nactual:   %q", expected, reply)
+	}
 }
 
 func TestIsTagsMessageFilter(t *testing.T) {
@@ -1083,11 +1090,59 @@ func TestIsTagsMessageFilter(t *testing.T) {
 	if isTagsMessage(&gotgbot.Message{Text: "some random message"}) {
 		t.Error("passed invalid string")
 	}
+	if !isTagsMessage(&gotgbot.Message{Text: "Tags list\n\n• Group 1\n#tag1"}) {
+		t.Error("did not pass malformed tags, errors will not be reported")
+	}
 }
 
 type dbMock struct {
 	groups    *[]models.Group
 	analytics *[]models.Analytics
+	media     []models.PendingMedia
+	sessions  []models.TaggingSession
+	settings  *models.Settings
+	changes   []models.SettingChange
+}
+
+func (m *dbMock) InsertTaggingSession(_ context.Context, s *models.TaggingSession) error {
+	m.sessions = append(m.sessions, *s)
+	return nil
+}
+
+func (m *dbMock) GetTaggingSession(_ context.Context, id string) (*models.TaggingSession, error) {
+	for _, s := range m.sessions {
+		if s.ID == id {
+			return &s, nil
+		}
+	}
+	return nil, db.ErrSessionNotFound
+}
+
+func (m *dbMock) DeleteTaggingSession(_ context.Context, id string) error {
+	m.sessions = slices.DeleteFunc(m.sessions, func(s models.TaggingSession) bool {
+		return s.ID == id
+	})
+	return nil
+}
+
+func (m *dbMock) ClaimTaggingSession(ctx context.Context, id string) (*models.TaggingSession, error) {
+	s, err := m.GetTaggingSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s, m.DeleteTaggingSession(ctx, id)
+}
+
+func (_ dbMock) GetDraft(context.Context, string, int64) (*models.Draft, error) {
+	return nil, db.ErrDraftNotFound
+}
+
+func (_ dbMock) SaveDraft(context.Context, *models.Draft) error {
+	return nil
+}
+
+func (_ dbMock) DeleteDrafts(context.Context, string) error {
+	return nil
 }
 
 func (_ dbMock) GetAllGroupsWithTags(context.Context) (*[]models.Group, error) {
@@ -1110,7 +1165,118 @@ func (m *dbMock) UpdateTags(_ context.Context, g *[]models.Group) error {
 	return nil
 }
 
+func (m *dbMock) RegisterPendingMedia(_ context.Context, media *[]models.PendingMedia) error {
+	m.media = append(m.media, *media...)
+	return nil
+}
+
 func (m *dbMock) InsertAnalytics(_ context.Context, a *[]models.Analytics) error {
 	m.analytics = a
 	return nil
 }
+
+func (_ dbMock) GetAdminAnalytics(context.Context, time.Time, time.Time) (*[]models.AdminAnalytics, error) {
+	return &[]models.AdminAnalytics{}, nil
+}
+
+func (_ dbMock) AddTag(context.Context, string, models.Tag) error {
+	return nil
+}
+
+func (_ dbMock) GetTagMenu(context.Context) (*models.TagMenu, error) {
+	return &models.TagMenu{Groups: []models.Group{}}, nil
+}
+
+func (_ dbMock) Ping(context.Context) error {
+	return nil
+}
+
+func (_ dbMock) GetTagMenuVersion(context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (_ dbMock) ReplaceTagMenu(context.Context, int64, *[]models.Group) (int64, error) {
+	return 1, nil
+}
+
+func (_ dbMock) GetFavoriteTags(context.Context, int64) (*[]string, error) {
+	return &[]string{}, nil
+}
+
+func (_ dbMock) SetFavoriteTag(context.Context, int64, string, bool) error {
+	return nil
+}
+
+func (_ dbMock) GetRecentTags(context.Context, int64, int) (*[]models.TagUsage, error) {
+	return &[]models.TagUsage{}, nil
+}
+
+func (_ dbMock) GetDailyPosts(context.Context, time.Time, time.Time) (*[]models.PostRollup, error) {
+	return &[]models.PostRollup{}, nil
+}
+
+func (_ dbMock) GetRecentPosts(context.Context, time.Time, int) (*[]models.Post, error) {
+	return &[]models.Post{}, nil
+}
+
+func (_ dbMock) GetPendingMedia(context.Context, int64, []int64) (*[]models.PendingMedia, error) {
+	return &[]models.PendingMedia{}, nil
+}
+
+func (_ dbMock) GetTagCooccurrence(
+	context.Context,
+	[]string,
+	time.Time,
+) (*[]models.TagUsage, error) {
+	return &[]models.TagUsage{}, nil
+}
+
+func (_ dbMock) StreamAnalytics(
+	context.Context,
+	time.Time,
+	time.Time,
+	func(models.Analytics) error,
+) error {
+	return nil
+}
+
+func (_ dbMock) RollupAnalytics(context.Context, time.Time) error {
+	return nil
+}
+
+func (_ dbMock) EnsureAnalyticsRetention(context.Context, time.Duration) error {
+	return nil
+}
+
+func (m *dbMock) GetSettings(context.Context) (*models.Settings, error) {
+	if m.settings == nil {
+		return nil, db.ErrSettingsNotFound
+	}
+	s := *m.settings
+	return &s, nil
+}
+
+func (m *dbMock) SaveSettings(
+	_ context.Context,
+	version int64,
+	s *models.Settings,
+	change *models.SettingChange,
+) (int64, error) {
+	if m.settings != nil && m.settings.Version != version {
+		return 0, db.ErrSettingsConflict
+	}
+	next := *s
+	next.Version = version + 1
+	m.settings = &next
+	m.changes = append([]models.SettingChange{*change}, m.changes...)
+	return next.Version, nil
+}
+
+func (m *dbMock) GetSettingChanges(_ context.Context, limit int) (*[]models.SettingChange, error) {
+	changes := m.changes[:min(limit, len(m.changes))]
+	return &changes, nil
+}
+
+func (m *dbMock) EnsureIndexes(context.Context) error {
+	return nil
+}
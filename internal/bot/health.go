@@ -0,0 +1,168 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/logger"
+	"ratatoskr/internal/metrics"
+	"sync"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+)
+
+const (
+	// pollFailureWindow is how long a failed getUpdates call marks polling
+	// as failing, the updater retries every second while it keeps failing.
+	pollFailureWindow = time.Second * 30
+	healthTimeout     = time.Second * 3
+)
+
+// health is the state reported by the health listener.
+type health struct {
+	now func() time.Time
+
+	mu            sync.Mutex
+	polling       bool
+	lastUpdate    time.Time
+	lastPollError time.Time
+}
+
+func newHealth(now func() time.Time) *health {
+	return &health{now: now}
+}
+
+func (h *health) startedPolling() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.polling = true
+}
+
+func (h *health) receivedUpdate() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastUpdate = h.now()
+}
+
+// pollFailed is used as the updater error func, so it sleeps like the
+// updater does without one.
+func (h *health) pollFailed(logger *logger.Logger) ext.ErrorFunc {
+	return func(err error) {
+		h.mu.Lock()
+		h.lastPollError = h.now()
+		h.mu.Unlock()
+		logger.Error("failed to get updates", "error", err)
+		time.Sleep(time.Second)
+	}
+}
+
+// ProcessUpdate records when the last update was received, counts it and
+// gives it a correlation ID before handing it to the dispatcher.
+func (h *health) ProcessUpdate(d *ext.Dispatcher, b *gotgbot.Bot, ctx *ext.Context) error {
+	h.receivedUpdate()
+	metrics.UpdatesReceived.Inc(updateType(ctx.Update))
+	return ext.BaseProcessor{}.ProcessUpdate(d, withCorrelationID(b, ctx), ctx)
+}
+
+func updateType(u *gotgbot.Update) string {
+	switch {
+	case u == nil:
+		return "unknown"
+	case u.Message != nil && u.Message.WebAppData != nil:
+		return "web_app_data"
+	case u.Message != nil:
+		return "message"
+	case u.EditedMessage != nil:
+		return "edited_message"
+	case u.CallbackQuery != nil:
+		return "callback_query"
+	case u.InlineQuery != nil:
+		return "inline_query"
+	case u.MyChatMember != nil:
+		return "my_chat_member"
+	}
+	return "other"
+}
+
+type healthReport struct {
+	Status              string     `json:"status"`
+	Polling             bool       `json:"polling"`
+	LastUpdate          *time.Time `json:"lastUpdate"`
+	LastPollError       *time.Time `json:"lastPollError"`
+	Database            string     `json:"database"`
+	BufferedMediaGroups int        `json:"bufferedMediaGroups"`
+}
+
+func (h *health) report(ctx context.Context, db db.DB, groups *mediaGroupMap) (healthReport, error) {
+	h.mu.Lock()
+	res := healthReport{
+		Status:              "ok",
+		Polling:             h.polling && h.now().Sub(h.lastPollError) >= pollFailureWindow,
+		Database:            "ok",
+		BufferedMediaGroups: groups.len(),
+	}
+	if !h.lastUpdate.IsZero() {
+		lastUpdate := h.lastUpdate
+		res.LastUpdate = &lastUpdate
+	}
+	if !h.lastPollError.IsZero() {
+		lastPollError := h.lastPollError
+		res.LastPollError = &lastPollError
+	}
+	h.mu.Unlock()
+	err := db.Ping(ctx)
+	if err != nil {
+		res.Database = "unavailable"
+	}
+	if !res.Polling || err != nil {
+		res.Status = "unavailable"
+	}
+	return res, err
+}
+
+// healthServer serves /healthz for liveness, /readyz with the polling state,
+// database reachability and media groups still being collected, and
+// /metrics when a scrape token is set.
+func healthServer(
+	addr string,
+	metricsToken string,
+	db db.DB,
+	logger *logger.Logger,
+	state *health,
+	groups *mediaGroupMap,
+) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		fmt.Fprintf(w, "ok")
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), healthTimeout)
+		defer cancel()
+		res, err := state.report(ctx, db, groups)
+		if err != nil {
+			logger.Error("health check failed to reach database", "error", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if res.Status != "ok" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		err = json.NewEncoder(w).Encode(res)
+		if err != nil {
+			logger.Error("failed to write health report", "error", err)
+		}
+	})
+	if metricsToken != "" {
+		mux.Handle("GET /metrics", metrics.Default.TokenHandler(metricsToken))
+	}
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: time.Second * 5,
+	}
+}
@@ -0,0 +1,167 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"ratatoskr/internal/db"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+type pingMock struct {
+	db.DB
+	err error
+}
+
+func (m pingMock) Ping(context.Context) error {
+	return m.err
+}
+
+func TestHealthServer(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	type tc struct {
+		name     string
+		path     string
+		prepare  func(*health, *mediaGroupMap)
+		pingErr  error
+		status   int
+		response string
+	}
+
+	table := []tc{
+		{
+			name:     "should report live process before polling",
+			path:     "/healthz",
+			prepare:  func(*health, *mediaGroupMap) {},
+			status:   http.StatusOK,
+			response: "ok",
+		},
+
+		{
+			name:     "should not be ready before polling",
+			path:     "/readyz",
+			prepare:  func(*health, *mediaGroupMap) {},
+			status:   http.StatusServiceUnavailable,
+			response: `{"status":"unavailable","polling":false,"lastUpdate":null,"lastPollError":null,"database":"ok","bufferedMediaGroups":0}`,
+		},
+
+		{
+			name: "should report received updates and buffered media groups",
+			path: "/readyz",
+			prepare: func(h *health, groups *mediaGroupMap) {
+				h.startedPolling()
+				h.receivedUpdate()
+				groups.add("group", item{messageID: 1})
+			},
+			status:   http.StatusOK,
+			response: `{"status":"ok","polling":true,"lastUpdate":"2024-01-02T03:04:05Z","lastPollError":null,"database":"ok","bufferedMediaGroups":1}`,
+		},
+
+		{
+			name: "should report failing polling",
+			path: "/readyz",
+			prepare: func(h *health, groups *mediaGroupMap) {
+				h.startedPolling()
+				h.lastPollError = now.Add(-pollFailureWindow / 2)
+			},
+			status:   http.StatusServiceUnavailable,
+			response: `{"status":"unavailable","polling":false,"lastUpdate":null,"lastPollError":"2024-01-02T03:03:50Z","database":"ok","bufferedMediaGroups":0}`,
+		},
+
+		{
+			name: "should recover from old polling failure",
+			path: "/readyz",
+			prepare: func(h *health, groups *mediaGroupMap) {
+				h.startedPolling()
+				h.lastPollError = now.Add(-pollFailureWindow)
+			},
+			status:   http.StatusOK,
+			response: `{"status":"ok","polling":true,"lastUpdate":null,"lastPollError":"2024-01-02T03:03:35Z","database":"ok","bufferedMediaGroups":0}`,
+		},
+
+		{
+			name:     "should report unreachable database",
+			path:     "/readyz",
+			prepare:  func(h *health, groups *mediaGroupMap) { h.startedPolling() },
+			pingErr:  errors.New("server selection timeout"),
+			status:   http.StatusServiceUnavailable,
+			response: `{"status":"unavailable","polling":true,"lastUpdate":null,"lastPollError":null,"database":"unavailable","bufferedMediaGroups":0}`,
+		},
+	}
+
+	for _, test := range table {
+		state := newHealth(func() time.Time { return now })
+		groups := newMediaGroupMap()
+		test.prepare(state, groups)
+		server := healthServer(":0", "", pingMock{err: test.pingErr}, fakeLogger(), state, groups)
+		rec := httptest.NewRecorder()
+		server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if rec.Code != test.status {
+			t.Errorf("%s - wrong status, expected %d, actual %d", test.name, test.status, rec.Code)
+		}
+		if actual := strings.TrimSpace(rec.Body.String()); actual != test.response {
+			t.Errorf("%s\nexpected: %s\nactual:   %s", test.name, test.response, actual)
+		}
+	}
+
+	state := newHealth(func() time.Time { return now })
+	server := healthServer(":0", "SCRAPE", pingMock{}, fakeLogger(), state, newMediaGroupMap())
+	for token, status := range map[string]int{"": http.StatusForbidden, "SCRAPE": http.StatusOK} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		server.Handler.ServeHTTP(rec, req)
+		if rec.Code != status {
+			t.Errorf("metrics with token %q - wrong status, expected %d, actual %d", token, status, rec.Code)
+		}
+	}
+}
+
+func TestUpdateType(t *testing.T) {
+	type tc struct {
+		name     string
+		update   *gotgbot.Update
+		expected string
+	}
+
+	table := []tc{
+		{
+			name:     "should count media as message",
+			update:   &gotgbot.Update{Message: &gotgbot.Message{Photo: []gotgbot.PhotoSize{{}}}},
+			expected: "message",
+		},
+
+		{
+			name: "should count webapp data separately",
+			update: &gotgbot.Update{Message: &gotgbot.Message{
+				WebAppData: &gotgbot.WebAppData{Data: "{}"},
+			}},
+			expected: "web_app_data",
+		},
+
+		{
+			name:     "should count callback query",
+			update:   &gotgbot.Update{CallbackQuery: &gotgbot.CallbackQuery{}},
+			expected: "callback_query",
+		},
+
+		{
+			name:     "should count unknown update as other",
+			update:   &gotgbot.Update{ChatJoinRequest: &gotgbot.ChatJoinRequest{}},
+			expected: "other",
+		},
+	}
+
+	for _, test := range table {
+		actual := updateType(test.update)
+		if actual != test.expected {
+			t.Errorf("%s\nexpected: %s\nactual:   %s", test.name, test.expected, actual)
+		}
+	}
+}
@@ -8,6 +8,7 @@ type bot interface {
 	SendPhoto(int64, gotgbot.InputFile, *gotgbot.SendPhotoOpts) (*gotgbot.Message, error)
 	SendVideo(int64, gotgbot.InputFile, *gotgbot.SendVideoOpts) (*gotgbot.Message, error)
 	SendAnimation(int64, gotgbot.InputFile, *gotgbot.SendAnimationOpts) (*gotgbot.Message, error)
+	SendDocument(int64, gotgbot.InputFile, *gotgbot.SendDocumentOpts) (*gotgbot.Message, error)
 	SendMediaGroup(
 		int64,
 		[]gotgbot.InputMedia,
@@ -15,13 +16,8 @@ type bot interface {
 	) ([]gotgbot.Message, error)
 	SendMessage(int64, string, *gotgbot.SendMessageOpts) (*gotgbot.Message, error)
 	EditMessageReplyMarkup(*gotgbot.EditMessageReplyMarkupOpts) (*gotgbot.Message, bool, error)
-	EditMessageCaption(opts *gotgbot.EditMessageCaptionOpts) (*gotgbot.Message, bool, error)
-	CopyMessages(
-		chatId int64,
-		fromChatId int64,
-		messageIds []int64,
-		opts *gotgbot.CopyMessagesOpts,
-	) ([]gotgbot.MessageId, error)
+	EditMessageText(string, *gotgbot.EditMessageTextOpts) (*gotgbot.Message, bool, error)
+	AnswerCallbackQuery(string, *gotgbot.AnswerCallbackQueryOpts) (bool, error)
 }
 
 var (
@@ -30,11 +26,12 @@ var (
 	sendPhoto              = botSendPhoto
 	sendVideo              = botSendVideo
 	sendAnimation          = botSendAnimation
+	sendDocument           = botSendDocument
 	sendMediaGroup         = botSendMediaGroup
 	sendMessage            = botSendMessage
 	editMessageReplyMarkup = botEditMessageReplyMarkup
-	editMessageCaption     = botEditMessageCaption
-	copyMessages           = botCopyMessages
+	editMessageText        = botEditMessageText
+	answerCallbackQuery    = botAnswerCallbackQuery
 )
 
 func botSendPhoto(
@@ -76,6 +73,19 @@ func botSendAnimation(
 	)
 }
 
+func botSendDocument(
+	b bot,
+	chatId int64,
+	document gotgbot.InputFile,
+	opts *gotgbot.SendDocumentOpts,
+) (*gotgbot.Message, error) {
+	return b.SendDocument(
+		chatId,
+		document,
+		opts,
+	)
+}
+
 func botSendMediaGroup(
 	b bot,
 	chatId int64,
@@ -117,19 +127,18 @@ func botEditMessageReplyMarkup(
 	return b.EditMessageReplyMarkup(opts)
 }
 
-func botEditMessageCaption(
+func botEditMessageText(
 	b bot,
-	opts *gotgbot.EditMessageCaptionOpts,
+	text string,
+	opts *gotgbot.EditMessageTextOpts,
 ) (*gotgbot.Message, bool, error) {
-	return b.EditMessageCaption(opts)
+	return b.EditMessageText(text, opts)
 }
 
-func botCopyMessages(
+func botAnswerCallbackQuery(
 	b bot,
-	chatId int64,
-	fromChatId int64,
-	messageIds []int64,
-	opts *gotgbot.CopyMessagesOpts,
-) ([]gotgbot.MessageId, error) {
-	return b.CopyMessages(chatId, fromChatId, messageIds, opts)
+	callbackQueryID string,
+	opts *gotgbot.AnswerCallbackQueryOpts,
+) (bool, error) {
+	return b.AnswerCallbackQuery(callbackQueryID, opts)
 }
@@ -49,3 +49,9 @@ func (mgm *mediaGroupMap) get(key string) []item {
 	defer mgm.mu.Unlock()
 	return mgm.hashMap[key]
 }
+
+func (mgm *mediaGroupMap) len() int {
+	mgm.mu.Lock()
+	defer mgm.mu.Unlock()
+	return len(mgm.hashMap)
+}
@@ -1,7 +1,6 @@
 package bot
 
 import (
-	"fmt"
 	"ratatoskr/internal/config"
 	"ratatoskr/internal/logger"
 	"slices"
@@ -28,7 +27,11 @@ func (m middleware) adminOnly(
 ) handlers.Response {
 	return func(b *gotgbot.Bot, ctx *ext.Context) error {
 		if slices.Index(m.config.AdminIDs, ctx.EffectiveSender.User.Id) == -1 {
-			return m.logger.Error(fmt.Sprintf("unauthorized sender %+v", ctx.EffectiveSender))
+			return m.logger.WithContext(updateContext(ctx)).Error(
+				"unauthorized sender",
+				"user_id", ctx.EffectiveSender.Id(),
+				"username", ctx.EffectiveSender.Username(),
+			)
 		}
 		return next(b, ctx)
 	}
@@ -18,7 +18,7 @@ func TestAdminOnly(t *testing.T) {
 	}
 
 	middleware := newMidlleware(
-		logger.NewLogger("test", &strings.Builder{}, &strings.Builder{}),
+		logger.NewLogger("test", &strings.Builder{}, logger.Options{}),
 		&config.BotConfig{AdminIDs: []int64{1234}},
 	)
 
@@ -0,0 +1,207 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/models"
+	"ratatoskr/internal/settings"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers"
+)
+
+// settingsCallbackPrefix starts the callback data of the settings menu
+// buttons, followed by the setting and the value, "settings:mode:silent".
+const settingsCallbackPrefix = "settings:"
+
+const (
+	settingsHistory      = "history"
+	settingsHistoryLimit = 10
+)
+
+const settingsUsage = "usage: /settings [history | <setting> <value>]\n" +
+	"settings: receiver, interval, caption, mode"
+
+func isSettingsCallback(cq *gotgbot.CallbackQuery) bool {
+	return cq.Message != nil && strings.HasPrefix(cq.Data, settingsCallbackPrefix)
+}
+
+// handleSettings shows the settings menu. Values without a button, like the
+// receiver chat ID, are set with "/settings <setting> <value>".
+func (h handler) handleSettings() handlers.Response {
+	return func(b *gotgbot.Bot, ctx *ext.Context) error {
+		log := h.updateLogger(ctx)
+		log.Info("received settings command")
+		c, cancel := context.WithTimeout(updateContext(ctx), time.Second*5)
+		defer cancel()
+		args := strings.Fields(ctx.EffectiveMessage.Text)[1:]
+		var current models.Settings
+		var err error
+		switch {
+		case len(args) == 0:
+			current, err = h.settings.Get(c)
+			if err != nil {
+				log.Warning("failed to read settings, showing last known", "error", err)
+			}
+		case len(args) == 1 && args[0] == settingsHistory:
+			return h.sendSettingsHistory(c, b, ctx.EffectiveChat.Id)
+		case len(args) == 2:
+			current, err = h.settings.Set(c, args[0], args[1], ctx.EffectiveSender.Id())
+			if err != nil {
+				sendMessage(b, ctx.EffectiveChat.Id, fmt.Sprintf("%s\n%s", err, settingsUsage), nil)
+				return log.Error("failed to change setting", "setting", args[0], "error", err)
+			}
+			log.Info("setting changed", "setting", args[0], "value", args[1])
+		default:
+			sendMessage(b, ctx.EffectiveChat.Id, settingsUsage, nil)
+			return log.Error("unknown settings arguments", "args", args)
+		}
+		text, markup := settingsMenu(current)
+		_, err = sendMessage(b, ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+			ReplyMarkup: markup,
+		})
+		if err != nil {
+			return log.Error("failed to send settings menu", "error", err)
+		}
+		return nil
+	}
+}
+
+// handleSettingsCallback saves the setting of a pressed menu button and
+// updates the menu in place.
+func (h handler) handleSettingsCallback() handlers.Response {
+	return func(b *gotgbot.Bot, ctx *ext.Context) error {
+		log := h.updateLogger(ctx)
+		query := ctx.CallbackQuery
+		name, value, _ := strings.Cut(strings.TrimPrefix(query.Data, settingsCallbackPrefix), ":")
+		log = log.With("setting", name, "value", value)
+		log.Info("received settings button")
+		c, cancel := context.WithTimeout(updateContext(ctx), time.Second*5)
+		defer cancel()
+		if name == settingsHistory {
+			answerCallbackQuery(b, query.Id, nil)
+			return h.sendSettingsHistory(c, b, ctx.EffectiveChat.Id)
+		}
+		if value == "" {
+			answerCallbackQuery(b, query.Id, &gotgbot.AnswerCallbackQueryOpts{
+				Text:      fmt.Sprintf("send /settings %s <value>", name),
+				ShowAlert: true,
+			})
+			return nil
+		}
+		notice := "saved"
+		current, err := h.settings.Set(c, name, value, ctx.EffectiveSender.Id())
+		if errors.Is(err, db.ErrSettingsConflict) {
+			notice = "settings were changed by someone else, check them and try again"
+			current, err = h.settings.Get(c)
+		}
+		if err != nil {
+			answerCallbackQuery(b, query.Id, &gotgbot.AnswerCallbackQueryOpts{Text: err.Error()})
+			return log.Error("failed to change setting", "error", err)
+		}
+		text, markup := settingsMenu(current)
+		_, _, err = editMessageText(b, text, &gotgbot.EditMessageTextOpts{
+			ChatId:      ctx.EffectiveChat.Id,
+			MessageId:   ctx.EffectiveMessage.MessageId,
+			ReplyMarkup: markup,
+		})
+		if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+			log.Warning("failed to update settings menu", "error", err)
+		}
+		_, err = answerCallbackQuery(b, query.Id, &gotgbot.AnswerCallbackQueryOpts{Text: notice})
+		if err != nil {
+			log.Warning("failed to answer settings button", "error", err)
+		}
+		log.Info("setting changed", "version", current.Version)
+		return nil
+	}
+}
+
+func (h handler) sendSettingsHistory(ctx context.Context, b bot, chatID int64) error {
+	log := h.logger.WithContext(ctx).With("chat_id", chatID)
+	changes, err := h.db.GetSettingChanges(ctx, settingsHistoryLimit)
+	if err != nil {
+		sendMessage(b, chatID, "error", nil)
+		return log.Error("failed to read settings history", "error", err)
+	}
+	_, err = sendMessage(b, chatID, formatSettingsHistory(*changes), nil)
+	if err != nil {
+		return log.Error("failed to send settings history", "error", err)
+	}
+	return nil
+}
+
+func formatSettingsHistory(changes []models.SettingChange) string {
+	if len(changes) == 0 {
+		return "settings were never changed"
+	}
+	lines := []string{}
+	for _, c := range changes {
+		lines = append(lines, fmt.Sprintf(
+			"%s %d %s: %s → %s",
+			c.Date.UTC().Format("2006-01-02 15:04"),
+			c.UserID,
+			c.Setting,
+			c.From,
+			c.To,
+		))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func settingsMenu(current models.Settings) (string, gotgbot.InlineKeyboardMarkup) {
+	text := fmt.Sprintf(
+		"Settings\nreceiver: %s\ninterval: %s\ncaption: %s\nmode: %s",
+		settings.Value(current, settings.Receiver),
+		settings.Value(current, settings.MediaGroupInterval),
+		settings.Value(current, settings.CaptionFormat),
+		settings.Value(current, settings.PostingMode),
+	)
+	if !current.UpdatedAt.IsZero() {
+		text += fmt.Sprintf(
+			"\nchanged by %d at %s",
+			current.UpdatedBy,
+			current.UpdatedAt.UTC().Format("2006-01-02 15:04"),
+		)
+	}
+	intervals := []string{}
+	for _, d := range settings.IntervalOptions {
+		intervals = append(intervals, d.String())
+	}
+	return text, gotgbot.InlineKeyboardMarkup{
+		InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+			{{
+				Text:         "receiver " + settings.Value(current, settings.Receiver),
+				CallbackData: settingsCallbackPrefix + settings.Receiver,
+			}},
+			settingsRow(current, settings.MediaGroupInterval, intervals),
+			settingsRow(current, settings.CaptionFormat, settings.CaptionFormats),
+			settingsRow(current, settings.PostingMode, settings.PostingModes),
+			{{
+				Text:         settingsHistory,
+				CallbackData: settingsCallbackPrefix + settingsHistory,
+			}},
+		},
+	}
+}
+
+// settingsRow has a button per option, the current one is checked.
+func settingsRow(current models.Settings, name string, options []string) []gotgbot.InlineKeyboardButton {
+	row := []gotgbot.InlineKeyboardButton{}
+	for _, option := range options {
+		text := option
+		if settings.Value(current, name) == option {
+			text = "✓ " + option
+		}
+		row = append(row, gotgbot.InlineKeyboardButton{
+			Text:         text,
+			CallbackData: settingsCallbackPrefix + name + ":" + option,
+		})
+	}
+	return row
+}
@@ -0,0 +1,262 @@
+package bot
+
+import (
+	"ratatoskr/internal/config"
+	"ratatoskr/internal/models"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+)
+
+func TestHandleSettings(t *testing.T) {
+	originalSendMessage := sendMessage
+	defer func() { sendMessage = originalSendMessage }()
+	replies := []string{}
+	sendMessage = func(b bot, chatId int64, message string, opts *gotgbot.SendMessageOpts) (*gotgbot.Message, error) {
+		replies = append(replies, message)
+		return &gotgbot.Message{}, nil
+	}
+
+	type tc struct {
+		name        string
+		text        string
+		shouldError bool
+		reply       string
+		receiverID  int64
+		changes     int
+	}
+
+	table := []tc{
+		{
+			name:       "should show menu with defaults",
+			text:       "/settings",
+			reply:      "Settings\nreceiver: -100\ninterval: 500ms\ncaption: lines\nmode: immediate",
+			receiverID: -100,
+		},
+
+		{
+			name:       "should set receiver",
+			text:       "/settings receiver -200",
+			reply:      "Settings\nreceiver: -200\ninterval: 500ms\ncaption: lines\nmode: immediate\nchanged by 1234",
+			receiverID: -200,
+			changes:    1,
+		},
+
+		{
+			name:       "should list changes",
+			text:       "/settings history",
+			reply:      "1234 receiver: -100 → -200",
+			receiverID: -200,
+			changes:    1,
+		},
+
+		{
+			name:        "should reject invalid value",
+			text:        "/settings receiver @channel",
+			shouldError: true,
+			reply:       settingsUsage,
+			receiverID:  -200,
+			changes:     1,
+		},
+
+		{
+			name:        "should reject unknown arguments",
+			text:        "/settings receiver",
+			shouldError: true,
+			reply:       settingsUsage,
+			receiverID:  -200,
+			changes:     1,
+		},
+	}
+
+	database := &dbMock{}
+	fakeHandler := newHandler(database, fakeLogger(), &config.BotConfig{ReceiverID: -100})
+	for _, test := range table {
+		replies = []string{}
+		err := fakeHandler.handleSettings()(&gotgbot.Bot{}, &ext.Context{
+			EffectiveChat:    &gotgbot.Chat{Id: 1},
+			EffectiveSender:  &gotgbot.Sender{User: &gotgbot.User{Id: 1234}},
+			EffectiveMessage: &gotgbot.Message{Text: test.text},
+		})
+		if (err != nil) != test.shouldError {
+			t.Errorf("%s - unexpected error: %v", test.name, err)
+		}
+		if len(replies) != 1 || !strings.Contains(replies[0], test.reply) {
+			t.Errorf("%s - wrong reply\nexpected: %+v\nactual:   %+v", test.name, test.reply, replies)
+		}
+		current, _ := fakeHandler.settings.Get(updateContext(&ext.Context{}))
+		if current.ReceiverID != test.receiverID {
+			t.Errorf("%s - wrong receiver\nexpected: %+v\nactual:   %+v", test.name, test.receiverID, current.ReceiverID)
+		}
+		if len(database.changes) != test.changes {
+			t.Errorf("%s - wrong changes\nexpected: %+v\nactual:   %+v", test.name, test.changes, database.changes)
+		}
+	}
+}
+
+func TestHandleSettingsCallback(t *testing.T) {
+	originalEditMessageText := editMessageText
+	originalAnswerCallbackQuery := answerCallbackQuery
+	defer func() {
+		editMessageText = originalEditMessageText
+		answerCallbackQuery = originalAnswerCallbackQuery
+	}()
+	type result struct {
+		menu   string
+		answer string
+		alert  bool
+	}
+	var res result
+	editMessageText = func(b bot, text string, opts *gotgbot.EditMessageTextOpts) (*gotgbot.Message, bool, error) {
+		res.menu = text
+		return nil, true, nil
+	}
+	answerCallbackQuery = func(b bot, id string, opts *gotgbot.AnswerCallbackQueryOpts) (bool, error) {
+		res.answer = opts.Text
+		res.alert = opts.ShowAlert
+		return true, nil
+	}
+
+	type tc struct {
+		name        string
+		data        string
+		shouldError bool
+		expected    result
+		settings    models.Settings
+	}
+
+	now := time.Now()
+	table := []tc{
+		{
+			name: "should switch posting mode",
+			data: "settings:mode:silent",
+			expected: result{
+				menu:   "Settings\nreceiver: -100\ninterval: 500ms\ncaption: lines\nmode: silent",
+				answer: "saved",
+			},
+			settings: models.Settings{
+				ReceiverID:         -100,
+				MediaGroupInterval: time.Millisecond * 500,
+				CaptionFormat:      models.CaptionFormatLines,
+				PostingMode:        models.PostingModeSilent,
+				Version:            1,
+				UpdatedBy:          1234,
+			},
+		},
+
+		{
+			name: "should change media group interval",
+			data: "settings:interval:2s",
+			expected: result{
+				menu:   "Settings\nreceiver: -100\ninterval: 2s\ncaption: lines\nmode: silent",
+				answer: "saved",
+			},
+			settings: models.Settings{
+				ReceiverID:         -100,
+				MediaGroupInterval: time.Second * 2,
+				CaptionFormat:      models.CaptionFormatLines,
+				PostingMode:        models.PostingModeSilent,
+				Version:            2,
+				UpdatedBy:          1234,
+			},
+		},
+
+		{
+			name: "should explain how to set receiver",
+			data: "settings:receiver",
+			expected: result{
+				answer: "send /settings receiver <value>",
+				alert:  true,
+			},
+			settings: models.Settings{
+				ReceiverID:         -100,
+				MediaGroupInterval: time.Second * 2,
+				CaptionFormat:      models.CaptionFormatLines,
+				PostingMode:        models.PostingModeSilent,
+				Version:            2,
+				UpdatedBy:          1234,
+			},
+		},
+
+		{
+			name:        "should reject unknown value",
+			data:        "settings:caption:bold",
+			shouldError: true,
+			expected: result{
+				answer: "invalid setting value: caption format must be one of [lines inline none]",
+			},
+			settings: models.Settings{
+				ReceiverID:         -100,
+				MediaGroupInterval: time.Second * 2,
+				CaptionFormat:      models.CaptionFormatLines,
+				PostingMode:        models.PostingModeSilent,
+				Version:            2,
+				UpdatedBy:          1234,
+			},
+		},
+	}
+
+	database := &dbMock{}
+	fakeHandler := newHandler(database, fakeLogger(), &config.BotConfig{ReceiverID: -100})
+	for _, test := range table {
+		res = result{}
+		err := fakeHandler.handleSettingsCallback()(&gotgbot.Bot{}, &ext.Context{
+			Update: &gotgbot.Update{
+				CallbackQuery: &gotgbot.CallbackQuery{Id: "QUERY", Data: test.data},
+			},
+			EffectiveChat:    &gotgbot.Chat{Id: 1},
+			EffectiveSender:  &gotgbot.Sender{User: &gotgbot.User{Id: 1234}},
+			EffectiveMessage: &gotgbot.Message{MessageId: 5, Chat: gotgbot.Chat{Id: 1}},
+		})
+		if (err != nil) != test.shouldError {
+			t.Errorf("%s - unexpected error: %v", test.name, err)
+		}
+		// the menu ends with who changed the settings and when
+		res.menu, _, _ = strings.Cut(res.menu, "\nchanged by")
+		if !reflect.DeepEqual(test.expected, res) {
+			t.Errorf("%s\nexpected: %+v\nactual:   %+v", test.name, test.expected, res)
+		}
+		actual := *database.settings
+		if actual.UpdatedAt.Before(now) {
+			t.Errorf("%s - change time was not recorded: %v", test.name, actual.UpdatedAt)
+		}
+		actual.UpdatedAt = time.Time{}
+		if !reflect.DeepEqual(test.settings, actual) {
+			t.Errorf("%s - wrong settings\nexpected: %+v\nactual:   %+v", test.name, test.settings, actual)
+		}
+	}
+}
+
+func TestSettingsMenu(t *testing.T) {
+	_, markup := settingsMenu(models.Settings{
+		ReceiverID:         -100,
+		MediaGroupInterval: time.Second,
+		CaptionFormat:      models.CaptionFormatNone,
+		PostingMode:        models.PostingModeImmediate,
+	})
+	expected := [][]string{
+		{"receiver -100"},
+		{"250ms", "500ms", "✓ 1s", "2s"},
+		{"lines", "inline", "✓ none"},
+		{"✓ immediate", "silent"},
+		{"history"},
+	}
+	actual := [][]string{}
+	for _, row := range markup.InlineKeyboard {
+		texts := []string{}
+		for _, button := range row {
+			texts = append(texts, button.Text)
+			if len(button.CallbackData) > 64 || !strings.HasPrefix(button.CallbackData, settingsCallbackPrefix) {
+				t.Errorf("invalid callback data %q", button.CallbackData)
+			}
+		}
+		actual = append(actual, texts)
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected: %+v\nactual:   %+v", expected, actual)
+	}
+}
@@ -1,9 +1,10 @@
 package config
 
 import (
-	"fmt"
-	"ratatoskr/internal/utils"
+	"log/slog"
+	"ratatoskr/internal/logger"
 	"strconv"
+	"time"
 )
 
 type BotConfig struct {
@@ -14,49 +15,92 @@ type BotConfig struct {
 	ReceiverID  int64
 	MongoURI    string
 	MongoDBName string
+
+	AnalyticsOutboxPath string
+	AnalyticsRetention  time.Duration
+
+	// HealthAddr is where the health listener is served, disabled if empty
+	HealthAddr string
+	// MetricsToken is the bearer token of the scraper, /metrics is not
+	// served if empty
+	MetricsToken string
+
+	Log logger.Options
 }
 
 const BotVersion = "1.0.2"
 
+const defaultAnalyticsOutboxPath = "analytics_outbox.jsonl"
+
+const (
+	defaultAnalyticsRetentionDays = 180
+	minAnalyticsRetentionDays     = 7
+)
+
+// GetBotConfig reads the bot settings, see source for where they come from.
 func GetBotConfig(getenv func(string) string) (*BotConfig, error) {
-	token := getenv("TOKEN")
-	if token == "" {
-		return nil, fmt.Errorf("bot token not provided")
-	}
-	adminIDs, err := utils.StringToIntSlice(getenv("ADMIN_IDS"))
+	s := newSource(getenv)
+	c := s.botConfig()
+	err := s.err()
 	if err != nil {
-		return nil, fmt.Errorf("error upon receiving admin IDs")
-	}
-	if len(adminIDs) == 0 {
-		return nil, fmt.Errorf("admin IDs must be set")
-	}
-	webAppUrl := getenv("WEBAPP_URL")
-	if webAppUrl == "" {
-		return nil, fmt.Errorf("required WEBAPP_URL was not provided")
+		return nil, err
 	}
-	receiverString := getenv("RECEIVER_ID")
-	if receiverString == "" {
-		return nil, fmt.Errorf("required RECEIVER_ID was not provided")
+	return c, nil
+}
+
+func (s *source) botConfig() *BotConfig {
+	c := &BotConfig{
+		Version:     BotVersion,
+		Token:       s.required("TOKEN"),
+		AdminIDs:    s.requiredIDs("ADMIN_IDS"),
+		WebAppUrl:   s.required("WEBAPP_URL"),
+		ReceiverID:  s.requiredInt("RECEIVER_ID"),
+		MongoURI:    s.required("MONGO_URI"),
+		MongoDBName: s.required("MONGO_DB_NAME"),
+
+		AnalyticsOutboxPath: s.string("ANALYTICS_OUTBOX_PATH"),
+
+		HealthAddr:   s.string("HEALTH_ADDR"),
+		MetricsToken: s.string("METRICS_TOKEN"),
+		Log:          s.logOptions(),
 	}
-	receiverID, err := strconv.Atoi(receiverString)
-	if err != nil {
-		return nil, fmt.Errorf("required RECEIVER_ID could not be parsed to int")
+	if c.AnalyticsOutboxPath == "" {
+		c.AnalyticsOutboxPath = defaultAnalyticsOutboxPath
 	}
-	mongoURI := getenv("MONGO_URI")
-	if mongoURI == "" {
-		return nil, fmt.Errorf("required PORT was not provided")
+	retentionDays := s.optionalInt("ANALYTICS_RETENTION_DAYS", defaultAnalyticsRetentionDays)
+	if retentionDays < 0 || (retentionDays > 0 && retentionDays < minAnalyticsRetentionDays) {
+		s.problem(
+			"ANALYTICS_RETENTION_DAYS must be 0 (keep forever) or at least %d",
+			minAnalyticsRetentionDays,
+		)
 	}
-	mongoDBName := getenv("MONGO_DB_NAME")
-	if mongoDBName == "" {
-		return nil, fmt.Errorf("required MONGO_DB_NAME was not provided")
+	c.AnalyticsRetention = time.Duration(retentionDays) * time.Hour * 24
+	return c
+}
+
+// String lists the effective settings with secrets redacted.
+func (c BotConfig) String() string {
+	return formatSettings(c.settings())
+}
+
+func (c BotConfig) LogValue() slog.Value {
+	return settingsValue(c.settings())
+}
+
+func (c BotConfig) settings() []setting {
+	return []setting{
+		{"VERSION", c.Version},
+		{"TOKEN", redacted},
+		{"ADMIN_IDS", formatIDs(c.AdminIDs)},
+		{"WEBAPP_URL", c.WebAppUrl},
+		{"RECEIVER_ID", strconv.FormatInt(c.ReceiverID, 10)},
+		{"MONGO_URI", redactURI(c.MongoURI)},
+		{"MONGO_DB_NAME", c.MongoDBName},
+		{"ANALYTICS_OUTBOX_PATH", c.AnalyticsOutboxPath},
+		{"ANALYTICS_RETENTION_DAYS", strconv.Itoa(int(c.AnalyticsRetention.Hours() / 24))},
+		{"HEALTH_ADDR", c.HealthAddr},
+		{"METRICS_TOKEN", redactSecret(c.MetricsToken)},
+		{"LOG_LEVEL", c.Log.Level.String()},
+		{"LOG_FORMAT", string(c.Log.Format)},
 	}
-	return &BotConfig{
-		Version:     BotVersion,
-		Token:       token,
-		AdminIDs:    adminIDs,
-		WebAppUrl:   webAppUrl,
-		ReceiverID:  int64(receiverID),
-		MongoURI:    mongoURI,
-		MongoDBName: mongoDBName,
-	}, nil
 }
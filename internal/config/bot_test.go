@@ -1,8 +1,10 @@
 package config
 
 import (
+	"ratatoskr/internal/logger"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestGetBotConfig(t *testing.T) {
@@ -187,8 +189,85 @@ func TestGetBotConfig(t *testing.T) {
 				ReceiverID:  1234,
 				MongoURI:    "mongo://<name>:<pass>",
 				MongoDBName: "database name",
+
+				AnalyticsOutboxPath: defaultAnalyticsOutboxPath,
+				AnalyticsRetention:  time.Hour * 24 * defaultAnalyticsRetentionDays,
+				Log:                 logger.Options{Format: logger.FormatText},
 			},
 		},
+
+		{
+			name:        "should use provided optional settings",
+			shouldError: false,
+			getenv: func(s string) string {
+				switch s {
+				case "TOKEN":
+					return "TOKEN"
+				case "ADMIN_IDS":
+					return "1,2"
+				case "WEBAPP_URL":
+					return "https:// link is required"
+				case "RECEIVER_ID":
+					return "1234"
+				case "MONGO_URI":
+					return "mongo://<name>:<pass>"
+				case "MONGO_DB_NAME":
+					return "database name"
+				case "ANALYTICS_OUTBOX_PATH":
+					return "/var/lib/ratatoskr/outbox.jsonl"
+				case "ANALYTICS_RETENTION_DAYS":
+					return "30"
+				case "HEALTH_ADDR":
+					return ":8081"
+				case "METRICS_TOKEN":
+					return "SCRAPE"
+				default:
+					return ""
+				}
+			},
+			expected: &BotConfig{
+				Version:     BotVersion,
+				Token:       "TOKEN",
+				AdminIDs:    []int64{1, 2},
+				WebAppUrl:   "https:// link is required",
+				ReceiverID:  1234,
+				MongoURI:    "mongo://<name>:<pass>",
+				MongoDBName: "database name",
+
+				AnalyticsOutboxPath: "/var/lib/ratatoskr/outbox.jsonl",
+				AnalyticsRetention:  time.Hour * 24 * 30,
+
+				HealthAddr:   ":8081",
+				MetricsToken: "SCRAPE",
+				Log:          logger.Options{Format: logger.FormatText},
+			},
+		},
+
+		{
+			name:        "should fail if retention is shorter than rollup lookback",
+			shouldError: true,
+			getenv: func(s string) string {
+				switch s {
+				case "TOKEN":
+					return "TOKEN"
+				case "ADMIN_IDS":
+					return "1,2"
+				case "WEBAPP_URL":
+					return "https:// link is required"
+				case "RECEIVER_ID":
+					return "1234"
+				case "MONGO_URI":
+					return "mongo://<name>:<pass>"
+				case "MONGO_DB_NAME":
+					return "database name"
+				case "ANALYTICS_RETENTION_DAYS":
+					return "2"
+				default:
+					return ""
+				}
+			},
+			expected: nil,
+		},
 	}
 
 	for _, test := range table {
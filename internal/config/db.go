@@ -0,0 +1,49 @@
+package config
+
+import (
+	"log/slog"
+	"ratatoskr/internal/logger"
+)
+
+// DBConfig is what the admin commands need to reach the database.
+type DBConfig struct {
+	MongoURI    string
+	MongoDBName string
+	Log         logger.Options
+}
+
+// GetDBConfig reads the database settings. The config file of the bot or
+// the webapp can be used as is, its other keys are not reported.
+func GetDBConfig(getenv func(string) string) (*DBConfig, error) {
+	s := newSource(getenv)
+	c := &DBConfig{
+		MongoURI:    s.required("MONGO_URI"),
+		MongoDBName: s.required("MONGO_DB_NAME"),
+		Log:         s.logOptions(),
+	}
+	for key := range s.file {
+		s.used = append(s.used, key)
+	}
+	err := s.err()
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c DBConfig) String() string {
+	return formatSettings(c.settings())
+}
+
+func (c DBConfig) LogValue() slog.Value {
+	return settingsValue(c.settings())
+}
+
+func (c DBConfig) settings() []setting {
+	return []setting{
+		{"MONGO_URI", redactURI(c.MongoURI)},
+		{"MONGO_DB_NAME", c.MongoDBName},
+		{"LOG_LEVEL", c.Log.Level.String()},
+		{"LOG_FORMAT", string(c.Log.Format)},
+	}
+}
@@ -0,0 +1,17 @@
+package config
+
+import (
+	"ratatoskr/internal/logger"
+)
+
+// logOptions reads LOG_LEVEL and LOG_FORMAT, shared by the bot and the
+// webapp.
+func (s *source) logOptions() logger.Options {
+	level := s.string("LOG_LEVEL")
+	format := s.string("LOG_FORMAT")
+	options, err := logger.ParseOptions(level, format)
+	if err != nil {
+		s.problem("LOG_LEVEL or LOG_FORMAT is invalid: %v", err)
+	}
+	return options
+}
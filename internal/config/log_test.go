@@ -0,0 +1,59 @@
+package config
+
+import (
+	"log/slog"
+	"ratatoskr/internal/logger"
+	"reflect"
+	"testing"
+)
+
+func TestLogOptions(t *testing.T) {
+	type tc struct {
+		name        string
+		shouldError bool
+		env         map[string]string
+		expected    logger.Options
+	}
+
+	table := []tc{
+		{
+			name:        "should default to info text",
+			shouldError: false,
+			env:         map[string]string{},
+			expected:    logger.Options{Level: slog.LevelInfo, Format: logger.FormatText},
+		},
+
+		{
+			name:        "should use provided level and format",
+			shouldError: false,
+			env:         map[string]string{"LOG_LEVEL": "debug", "LOG_FORMAT": "json"},
+			expected:    logger.Options{Level: slog.LevelDebug, Format: logger.FormatJSON},
+		},
+
+		{
+			name:        "should fail on unknown level",
+			shouldError: true,
+			env:         map[string]string{"LOG_LEVEL": "loud"},
+			expected:    logger.Options{},
+		},
+
+		{
+			name:        "should fail on unknown format",
+			shouldError: true,
+			env:         map[string]string{"LOG_FORMAT": "yaml"},
+			expected:    logger.Options{},
+		},
+	}
+
+	for _, test := range table {
+		s := newSource(func(s string) string { return test.env[s] })
+		actual := s.logOptions()
+		err := s.err()
+		if (err != nil) != test.shouldError {
+			t.Errorf("%s - unexpected error: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf("%s\nexpected: %+v\nactual:   %+v", test.name, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const redacted = "[redacted]"
+
+type setting struct {
+	name  string
+	value string
+}
+
+func formatSettings(settings []setting) string {
+	lines := []string{}
+	for _, s := range settings {
+		lines = append(lines, fmt.Sprintf("%s=%s", s.name, s.value))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func settingsValue(settings []setting) slog.Value {
+	attrs := []slog.Attr{}
+	for _, s := range settings {
+		attrs = append(attrs, slog.String(strings.ToLower(s.name), s.value))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func formatIDs(ids []int64) string {
+	res := []string{}
+	for _, id := range ids {
+		res = append(res, strconv.FormatInt(id, 10))
+	}
+	return strings.Join(res, ",")
+}
+
+// redactSecret hides a secret, but shows whether it is set.
+func redactSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
+
+// redactURI hides the password of a connection string, or all of it if it
+// can not be parsed.
+func redactURI(uri string) string {
+	if uri == "" {
+		return ""
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return redacted
+	}
+	return u.Redacted()
+}
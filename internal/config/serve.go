@@ -0,0 +1,16 @@
+package config
+
+// GetServeConfig reads the settings of the bot and the webapp from the same
+// source, so they can run in one process with one config file.
+func GetServeConfig(getenv func(string) string) (*BotConfig, *WepAppConfig, error) {
+	s := newSource(getenv)
+	bot := s.botConfig()
+	webApp := s.webAppConfig()
+	// both write analytics through the outbox of the bot
+	webApp.AnalyticsOutboxPath = bot.AnalyticsOutboxPath
+	err := s.err()
+	if err != nil {
+		return nil, nil, err
+	}
+	return bot, webApp, nil
+}
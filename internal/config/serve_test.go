@@ -0,0 +1,42 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetServeConfig(t *testing.T) {
+	configFile := writeFile(t, "ratatoskr.yaml", `
+token: TOKEN
+admin_ids: [1, 2]
+webapp_url: https://example.com
+receiver_id: 1234
+mongo_uri: mongodb://file
+mongo_db_name: ratatoskr
+ip: 127.0.0.1
+port: 8080
+`)
+
+	bot, webApp, err := GetServeConfig(func(s string) string {
+		return map[string]string{"CONFIG_FILE": configFile}[s]
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bot.Token != "TOKEN" || webApp.Token != "TOKEN" || webApp.Port != "8080" || bot.WebAppUrl != "https://example.com" {
+		t.Errorf("wrong configs\nbot:    %+v\nwebapp: %+v", bot, webApp)
+	}
+
+	_, _, err = GetServeConfig(func(s string) string { return "" })
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if count := strings.Count(err.Error(), "required TOKEN was not provided"); count != 1 {
+		t.Errorf("shared setting reported %d times:\n%s", count, err)
+	}
+	for _, problem := range []string{"required WEBAPP_URL", "required PORT"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("missing problem %q in:\n%s", problem, err)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"ratatoskr/internal/utils"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// source looks settings up by their environment variable name. A variable
+// overrides NAME_FILE, which holds the value in a file (Docker secrets), and
+// both override the key name in lower case from the CONFIG_FILE YAML file.
+// Problems are collected, so every one of them is reported at once.
+type source struct {
+	getenv   func(string) string
+	file     map[string]string
+	used     []string
+	problems []error
+	// unreadable are settings whose _FILE failed, they are not reported
+	// as missing again
+	unreadable []string
+}
+
+func newSource(getenv func(string) string) *source {
+	s := &source{getenv: getenv, file: map[string]string{}}
+	path := getenv("CONFIG_FILE")
+	if path == "" {
+		return s
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		s.problem("CONFIG_FILE could not be read: %v", err)
+		return s
+	}
+	values := map[string]any{}
+	err = yaml.Unmarshal(data, &values)
+	if err != nil {
+		s.problem("CONFIG_FILE %s is not valid YAML: %v", path, err)
+		return s
+	}
+	for key, value := range values {
+		switch v := value.(type) {
+		case nil:
+		case []any:
+			items := []string{}
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			s.file[key] = strings.Join(items, ",")
+		case map[string]any:
+			s.problem("CONFIG_FILE key %s must be a value or a list", key)
+		default:
+			s.file[key] = fmt.Sprint(v)
+		}
+	}
+	return s
+}
+
+// problem records a problem once, settings shared by the bot and the webapp
+// are read twice when both run in one process.
+func (s *source) problem(format string, args ...any) {
+	err := fmt.Errorf(format, args...)
+	for _, p := range s.problems {
+		if p.Error() == err.Error() {
+			return
+		}
+	}
+	s.problems = append(s.problems, err)
+}
+
+func (s *source) string(name string) string {
+	s.used = append(s.used, strings.ToLower(name))
+	value := s.getenv(name)
+	if path := s.getenv(name + "_FILE"); path != "" {
+		if value != "" {
+			s.problem("only one of %s and %s_FILE can be set", name, name)
+			return value
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			s.problem("%s_FILE could not be read: %v", name, err)
+			s.unreadable = append(s.unreadable, name)
+			return ""
+		}
+		return strings.TrimRight(string(data), "\r\n")
+	}
+	if value != "" {
+		return value
+	}
+	return s.file[strings.ToLower(name)]
+}
+
+func (s *source) required(name string) string {
+	value := s.string(name)
+	if value == "" && !slices.Contains(s.unreadable, name) {
+		s.problem("required %s was not provided", name)
+	}
+	return value
+}
+
+func (s *source) requiredInt(name string) int64 {
+	value := s.required(name)
+	if value == "" {
+		return 0
+	}
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		s.problem("%s could not be parsed to int", name)
+	}
+	return i
+}
+
+func (s *source) optionalInt(name string, fallback int) int {
+	value := s.string(name)
+	if value == "" {
+		return fallback
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		s.problem("%s could not be parsed to int", name)
+		return fallback
+	}
+	return i
+}
+
+func (s *source) requiredIDs(name string) []int64 {
+	value := s.required(name)
+	if value == "" {
+		return nil
+	}
+	ids, err := utils.StringToIntSlice(value)
+	if err != nil {
+		s.problem("%s could not be parsed to a list of ints", name)
+	} else if len(ids) == 0 {
+		s.problem("%s must contain at least one ID", name)
+	}
+	return ids
+}
+
+// err reports every problem found, including file keys that are not
+// settings, which are most likely typos.
+func (s *source) err() error {
+	problems := slices.Clone(s.problems)
+	unknown := []string{}
+	for key := range s.file {
+		if !slices.Contains(s.used, key) {
+			unknown = append(unknown, key)
+		}
+	}
+	slices.Sort(unknown)
+	for _, key := range unknown {
+		problems = append(problems, fmt.Errorf("CONFIG_FILE key %s is not a setting", key))
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration:\n%w", errors.Join(problems...))
+}
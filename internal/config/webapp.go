@@ -1,8 +1,10 @@
 package config
 
 import (
-	"fmt"
-	"ratatoskr/internal/utils"
+	"log/slog"
+	"ratatoskr/internal/logger"
+	"strconv"
+	"strings"
 )
 
 type WepAppConfig struct {
@@ -13,46 +15,83 @@ type WepAppConfig struct {
 	MongoURI    string
 	MongoDBName string
 	Token       string
+	ReceiverID  int64
+	BotAPIURL   string
+	// AnalyticsOutboxPath must not be the file of the bot, unless both run
+	// in one process
+	AnalyticsOutboxPath string
+	// MetricsToken is the bearer token of the scraper, /metrics is not
+	// served if empty
+	MetricsToken string
+	Log          logger.Options
 }
 
 const WebAppVersion = "1.1.5"
 
+const defaultBotAPIURL = "https://api.telegram.org"
+
+const defaultWebAppOutboxPath = "webapp_analytics_outbox.jsonl"
+
+// GetWebAppConfig reads the webapp settings, see source for where they come
+// from.
 func GetWebAppConfig(getenv func(string) string) (*WepAppConfig, error) {
-	stringAdminIDs := getenv("ADMIN_IDS")
-	if stringAdminIDs == "" {
-		return nil, fmt.Errorf("required ADMIN_IDS was not provided")
-	}
-	adminIDs, err := utils.StringToIntSlice(stringAdminIDs)
+	s := newSource(getenv)
+	c := s.webAppConfig()
+	err := s.err()
 	if err != nil {
 		return nil, err
 	}
-	ip := getenv("IP")
-	if ip == "" {
-		return nil, fmt.Errorf("required IP was not provided")
-	}
-	port := getenv("PORT")
-	if port == "" {
-		return nil, fmt.Errorf("required PORT was not provided")
+	return c, nil
+}
+
+func (s *source) webAppConfig() *WepAppConfig {
+	c := &WepAppConfig{
+		Version:     WebAppVersion,
+		AdminIDs:    s.requiredIDs("ADMIN_IDS"),
+		IP:          s.required("IP"),
+		Port:        s.required("PORT"),
+		MongoURI:    s.required("MONGO_URI"),
+		MongoDBName: s.required("MONGO_DB_NAME"),
+		Token:       s.required("TOKEN"),
+		ReceiverID:  s.requiredInt("RECEIVER_ID"),
+		BotAPIURL:   strings.TrimSuffix(s.string("BOT_API_URL"), "/"),
+
+		AnalyticsOutboxPath: s.string("ANALYTICS_OUTBOX_PATH"),
+		MetricsToken:        s.string("METRICS_TOKEN"),
+		Log:                 s.logOptions(),
 	}
-	mongoURI := getenv("MONGO_URI")
-	if mongoURI == "" {
-		return nil, fmt.Errorf("required PORT was not provided")
+	if c.BotAPIURL == "" {
+		c.BotAPIURL = defaultBotAPIURL
 	}
-	mongoDBName := getenv("MONGO_DB_NAME")
-	if mongoDBName == "" {
-		return nil, fmt.Errorf("required MONGO_DB_NAME was not provided")
+	if c.AnalyticsOutboxPath == "" {
+		c.AnalyticsOutboxPath = defaultWebAppOutboxPath
 	}
-	token := getenv("TOKEN")
-	if token == "" {
-		return nil, fmt.Errorf("bot token not provided")
+	return c
+}
+
+// String lists the effective settings with secrets redacted.
+func (c WepAppConfig) String() string {
+	return formatSettings(c.settings())
+}
+
+func (c WepAppConfig) LogValue() slog.Value {
+	return settingsValue(c.settings())
+}
+
+func (c WepAppConfig) settings() []setting {
+	return []setting{
+		{"VERSION", c.Version},
+		{"ADMIN_IDS", formatIDs(c.AdminIDs)},
+		{"IP", c.IP},
+		{"PORT", c.Port},
+		{"MONGO_URI", redactURI(c.MongoURI)},
+		{"MONGO_DB_NAME", c.MongoDBName},
+		{"TOKEN", redacted},
+		{"RECEIVER_ID", strconv.FormatInt(c.ReceiverID, 10)},
+		{"BOT_API_URL", c.BotAPIURL},
+		{"ANALYTICS_OUTBOX_PATH", c.AnalyticsOutboxPath},
+		{"METRICS_TOKEN", redactSecret(c.MetricsToken)},
+		{"LOG_LEVEL", c.Log.Level.String()},
+		{"LOG_FORMAT", string(c.Log.Format)},
 	}
-	return &WepAppConfig{
-		Version:     WebAppVersion,
-		AdminIDs:    adminIDs,
-		IP:          ip,
-		Port:        port,
-		MongoURI:    mongoURI,
-		MongoDBName: mongoDBName,
-		Token:       token,
-	}, nil
 }
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"ratatoskr/internal/logger"
 	"reflect"
 	"testing"
 )
@@ -30,6 +31,8 @@ func TestGetWebAppConfig(t *testing.T) {
 					return "database name"
 				case "TOKEN":
 					return "TOKEN"
+				case "RECEIVER_ID":
+					return "-100123"
 				default:
 					return ""
 				}
@@ -54,6 +57,8 @@ func TestGetWebAppConfig(t *testing.T) {
 					return "database name"
 				case "TOKEN":
 					return "TOKEN"
+				case "RECEIVER_ID":
+					return "-100123"
 				default:
 					return ""
 				}
@@ -78,6 +83,8 @@ func TestGetWebAppConfig(t *testing.T) {
 					return "database name"
 				case "TOKEN":
 					return "TOKEN"
+				case "RECEIVER_ID":
+					return "-100123"
 				default:
 					return ""
 				}
@@ -102,6 +109,8 @@ func TestGetWebAppConfig(t *testing.T) {
 					return ""
 				case "TOKEN":
 					return "TOKEN"
+				case "RECEIVER_ID":
+					return "-100123"
 				default:
 					return ""
 				}
@@ -126,6 +135,8 @@ func TestGetWebAppConfig(t *testing.T) {
 					return "database name"
 				case "TOKEN":
 					return "TOKEN"
+				case "RECEIVER_ID":
+					return "-100123"
 				default:
 					return ""
 				}
@@ -174,6 +185,8 @@ func TestGetWebAppConfig(t *testing.T) {
 					return "database name"
 				case "TOKEN":
 					return "TOKEN"
+				case "RECEIVER_ID":
+					return "-100123"
 				default:
 					return ""
 				}
@@ -187,6 +200,76 @@ func TestGetWebAppConfig(t *testing.T) {
 				MongoURI:    "mongo://<name>:<pass>",
 				MongoDBName: "database name",
 				Token:       "TOKEN",
+				ReceiverID:  -100123,
+				BotAPIURL:   "https://api.telegram.org",
+
+				AnalyticsOutboxPath: defaultWebAppOutboxPath,
+				Log:                 logger.Options{Format: logger.FormatText},
+			},
+		},
+
+		{
+			name: "should error if RECEIVER_ID was not provided",
+			getenv: func(s string) string {
+				switch s {
+				case "ADMIN_IDS":
+					return "1234,7890"
+				case "IP":
+					return "127.0.0.1"
+				case "PORT":
+					return "8080"
+				case "MONGO_URI":
+					return "mongo://<name>:<pass>"
+				case "MONGO_DB_NAME":
+					return "database name"
+				case "TOKEN":
+					return "TOKEN"
+				default:
+					return ""
+				}
+			},
+			shouldError: true,
+			expected:    nil,
+		},
+
+		{
+			name: "should use custom bot api url",
+			getenv: func(s string) string {
+				switch s {
+				case "ADMIN_IDS":
+					return "1234,7890"
+				case "IP":
+					return "127.0.0.1"
+				case "PORT":
+					return "8080"
+				case "MONGO_URI":
+					return "mongo://<name>:<pass>"
+				case "MONGO_DB_NAME":
+					return "database name"
+				case "TOKEN":
+					return "TOKEN"
+				case "RECEIVER_ID":
+					return "-100123"
+				case "BOT_API_URL":
+					return "http://localhost:8081/"
+				default:
+					return ""
+				}
+			},
+			shouldError: false,
+			expected: &WepAppConfig{
+				Version:     WebAppVersion,
+				AdminIDs:    []int64{1234, 7890},
+				IP:          "127.0.0.1",
+				Port:        "8080",
+				MongoURI:    "mongo://<name>:<pass>",
+				MongoDBName: "database name",
+				Token:       "TOKEN",
+				ReceiverID:  -100123,
+				BotAPIURL:   "http://localhost:8081",
+
+				AnalyticsOutboxPath: defaultWebAppOutboxPath,
+				Log:                 logger.Options{Format: logger.FormatText},
 			},
 		},
 	}
@@ -0,0 +1,71 @@
+package correlation
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestWith(t *testing.T) {
+	ctx := context.Background()
+	if actual := ID(ctx); actual != "" {
+		t.Errorf("expected no ID, actual %q", actual)
+	}
+	if actual := ID(With(ctx, "")); actual != "" {
+		t.Errorf("expected empty ID to be skipped, actual %q", actual)
+	}
+	id := New()
+	if actual := ID(With(ctx, id)); actual != id {
+		t.Errorf("expected: %q\nactual:   %q", id, actual)
+	}
+	if New() == id {
+		t.Errorf("expected new IDs to differ")
+	}
+}
+
+func TestValid(t *testing.T) {
+	type tc struct {
+		name     string
+		id       string
+		expected bool
+	}
+
+	table := []tc{
+		{
+			name:     "should accept generated ID",
+			id:       New(),
+			expected: true,
+		},
+
+		{
+			name:     "should accept uuid",
+			id:       "0f8fad5b-d9cb-469f-a165-70867728950e",
+			expected: true,
+		},
+
+		{
+			name:     "should reject empty ID",
+			id:       "",
+			expected: false,
+		},
+
+		{
+			name:     "should reject too long ID",
+			id:       strings.Repeat("a", maxLength+1),
+			expected: false,
+		},
+
+		{
+			name:     "should reject ID with spaces or quotes",
+			id:       `abc "def"`,
+			expected: false,
+		},
+	}
+
+	for _, test := range table {
+		actual := Valid(test.id)
+		if actual != test.expected {
+			t.Errorf("%s\nexpected: %+v\nactual:   %+v", test.name, test.expected, actual)
+		}
+	}
+}
@@ -2,11 +2,63 @@ package db
 
 import (
 	"context"
+	"errors"
 	"ratatoskr/internal/models"
+	"time"
+)
+
+var (
+	ErrGroupNotFound = errors.New("group not found")
+	ErrTagExists     = errors.New("tag already exists")
+	ErrMenuConflict  = errors.New("tag menu was changed by someone else")
+
+	ErrSessionNotFound = errors.New("tagging session not found")
+	ErrDraftNotFound   = errors.New("draft not found")
+
+	ErrSettingsNotFound = errors.New("settings were never saved")
+	ErrSettingsConflict = errors.New("settings were changed by someone else")
 )
 
 type DB interface {
+	Ping(context.Context) error
 	GetAllGroupsWithTags(context.Context) (*[]models.Group, error)
 	UpdateTags(context.Context, *[]models.Group) error
+	AddTag(ctx context.Context, group string, tag models.Tag) error
+	GetTagMenu(ctx context.Context) (*models.TagMenu, error)
+	GetTagMenuVersion(ctx context.Context) (int64, error)
+	ReplaceTagMenu(ctx context.Context, version int64, groups *[]models.Group) (int64, error)
+	GetFavoriteTags(ctx context.Context, userID int64) (*[]string, error)
+	SetFavoriteTag(ctx context.Context, userID int64, tag string, favorite bool) error
 	InsertAnalytics(context.Context, *[]models.Analytics) error
+	GetAdminAnalytics(ctx context.Context, from time.Time, to time.Time) (*[]models.AdminAnalytics, error)
+	StreamAnalytics(
+		ctx context.Context,
+		from time.Time,
+		to time.Time,
+		fn func(models.Analytics) error,
+	) error
+	GetRecentTags(ctx context.Context, userID int64, limit int) (*[]models.TagUsage, error)
+	GetTagCooccurrence(ctx context.Context, tags []string, since time.Time) (*[]models.TagUsage, error)
+	GetDailyPosts(ctx context.Context, from time.Time, to time.Time) (*[]models.PostRollup, error)
+	GetRecentPosts(ctx context.Context, since time.Time, limit int) (*[]models.Post, error)
+	RegisterPendingMedia(ctx context.Context, media *[]models.PendingMedia) error
+	GetPendingMedia(ctx context.Context, chatID int64, messageIDs []int64) (*[]models.PendingMedia, error)
+	InsertTaggingSession(ctx context.Context, session *models.TaggingSession) error
+	GetTaggingSession(ctx context.Context, id string) (*models.TaggingSession, error)
+	DeleteTaggingSession(ctx context.Context, id string) error
+	ClaimTaggingSession(ctx context.Context, id string) (*models.TaggingSession, error)
+	GetDraft(ctx context.Context, sessionID string, userID int64) (*models.Draft, error)
+	SaveDraft(ctx context.Context, draft *models.Draft) error
+	DeleteDrafts(ctx context.Context, sessionID string) error
+	RollupAnalytics(ctx context.Context, now time.Time) error
+	EnsureAnalyticsRetention(ctx context.Context, retention time.Duration) error
+	GetSettings(ctx context.Context) (*models.Settings, error)
+	SaveSettings(
+		ctx context.Context,
+		version int64,
+		settings *models.Settings,
+		change *models.SettingChange,
+	) (int64, error)
+	GetSettingChanges(ctx context.Context, limit int) (*[]models.SettingChange, error)
+	EnsureIndexes(ctx context.Context) error
 }
@@ -0,0 +1,42 @@
+package events
+
+import "sync"
+
+type Event string
+
+// TagsChanged is published after the tag menu was replaced.
+const TagsChanged Event = "tags_changed"
+
+// Bus passes events between the bot and the webapp when `ratatoskr serve`
+// runs both in one process. A nil Bus drops every event, which is what the
+// bot and the webapp get when they run on their own.
+type Bus struct {
+	mu          sync.Mutex
+	subscribers map[Event][]func()
+}
+
+func NewBus() *Bus {
+	return &Bus{subscribers: map[Event][]func(){}}
+}
+
+func (b *Bus) Subscribe(event Event, fn func()) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[event] = append(b.subscribers[event], fn)
+}
+
+// Publish calls the subscribers of event in the calling goroutine.
+func (b *Bus) Publish(event Event) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	subscribers := b.subscribers[event]
+	b.mu.Unlock()
+	for _, fn := range subscribers {
+		fn()
+	}
+}
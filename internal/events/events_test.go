@@ -0,0 +1,20 @@
+package events
+
+import "testing"
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+	calls := 0
+	bus.Subscribe(TagsChanged, func() { calls++ })
+	bus.Subscribe(TagsChanged, func() { calls++ })
+	bus.Subscribe("other", func() { t.Error("called subscriber of another event") })
+
+	bus.Publish(TagsChanged)
+	if calls != 2 {
+		t.Errorf("expected: %+v\nactual:   %+v", 2, calls)
+	}
+
+	var nilBus *Bus
+	nilBus.Subscribe(TagsChanged, func() { t.Error("nil bus called subscriber") })
+	nilBus.Publish(TagsChanged)
+}
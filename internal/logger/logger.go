@@ -1,55 +1,117 @@
 package logger
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"path/filepath"
+	"ratatoskr/internal/correlation"
+	"runtime"
+	"strings"
+	"time"
 )
 
+type Format string
+
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+)
+
+// Options choose the minimum level and the output format. The zero value
+// logs info and above as text.
+type Options struct {
+	Level  slog.Level
+	Format Format
+}
+
+// ParseOptions reads a level (debug, info, warn or error) and a format
+// (text or json), empty values keep the defaults.
+func ParseOptions(level string, format string) (Options, error) {
+	options := Options{Level: slog.LevelInfo, Format: FormatText}
+	if level != "" {
+		err := options.Level.UnmarshalText([]byte(level))
+		if err != nil {
+			return Options{}, fmt.Errorf("unknown log level %q", level)
+		}
+	}
+	switch Format(strings.ToLower(format)) {
+	case "", FormatText:
+	case FormatJSON:
+		options.Format = FormatJSON
+	default:
+		return Options{}, fmt.Errorf("unknown log format %q", format)
+	}
+	return options, nil
+}
+
+// Logger writes leveled records with key/value fields into one stream.
 type Logger struct {
-	infoLogger *log.Logger
-	errLogger  *log.Logger
-}
-
-const delimeterStart = "{{ "
-const delimeterEnd = " }}"
-
-var infoFlags = (log.Ldate | log.Ltime | log.Lmicroseconds)
-var errFlags = (log.Ldate | log.Ltime | log.Lmicroseconds | log.Lshortfile)
-
-func NewLogger(
-	name string,
-	stdout io.Writer,
-	stderr io.Writer,
-) *Logger {
-	combined := io.MultiWriter(stdout, stderr)
-	return &Logger{
-		infoLogger: log.New(
-			stdout,
-			fmt.Sprintf("%s: ", name),
-			infoFlags,
-		),
-		errLogger: log.New(
-			combined,
-			fmt.Sprintf("%s: ", name),
-			errFlags,
-		),
+	slog *slog.Logger
+}
+
+func NewLogger(name string, w io.Writer, options Options) *Logger {
+	handlerOptions := &slog.HandlerOptions{Level: options.Level}
+	var handler slog.Handler = slog.NewTextHandler(w, handlerOptions)
+	if options.Format == FormatJSON {
+		handler = slog.NewJSONHandler(w, handlerOptions)
 	}
+	return &Logger{slog: slog.New(handler).With("logger", name)}
 }
 
-func (l Logger) Info(s string) {
-	l.infoLogger.Printf(format("INFO", s))
+// With returns a logger adding the fields to every record.
+func (l Logger) With(args ...any) *Logger {
+	return &Logger{slog: l.slog.With(args...)}
 }
 
-func (l Logger) Warning(s string) {
-	l.infoLogger.Printf(format("WARNING", s))
+// WithContext returns a logger adding the correlation ID carried by ctx.
+func (l Logger) WithContext(ctx context.Context) *Logger {
+	id := correlation.ID(ctx)
+	if id == "" {
+		return &l
+	}
+	return l.With("correlation_id", id)
+}
+
+func (l Logger) Debug(msg string, args ...any) {
+	l.log(slog.LevelDebug, msg, args...)
+}
+
+func (l Logger) Info(msg string, args ...any) {
+	l.log(slog.LevelInfo, msg, args...)
 }
 
-func (l Logger) Error(s string) error {
-	l.errLogger.Printf(format("ERROR", s))
-	return fmt.Errorf(s)
+func (l Logger) Warning(msg string, args ...any) {
+	l.log(slog.LevelWarn, msg, args...)
 }
 
-func format(level string, message string) string {
-	return fmt.Sprintf("[%s] -- %s%s%s\n", level, delimeterStart, message, delimeterEnd)
+// Error logs msg with the calling line and returns it as an error wrapping
+// the first error among args.
+func (l Logger) Error(msg string, args ...any) error {
+	l.log(slog.LevelError, msg, args...)
+	for _, arg := range args {
+		if err, ok := arg.(error); ok {
+			return fmt.Errorf("%s: %w", msg, err)
+		}
+	}
+	return errors.New(msg)
+}
+
+func (l Logger) log(level slog.Level, msg string, args ...any) {
+	ctx := context.Background()
+	if !l.slog.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	// skip runtime.Callers, log and the exported method
+	runtime.Callers(3, pcs[:])
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	r.Add(args...)
+	if level >= slog.LevelError {
+		frame, _ := runtime.CallersFrames(pcs[:]).Next()
+		r.AddAttrs(slog.String("source", fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line)))
+	}
+	l.slog.Handler().Handle(ctx, r)
 }
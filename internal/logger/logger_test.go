@@ -1,95 +1,192 @@
 package logger
 
 import (
-	"fmt"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"ratatoskr/internal/correlation"
 	"reflect"
 	"strings"
 	"testing"
 )
 
 func TestLogger(t *testing.T) {
-	var stdout strings.Builder
-	var stderr strings.Builder
-	infoFlags = 0
-	errFlags = 0
-	reset := func() {
-		stdout.Reset()
-		stderr.Reset()
-	}
-	logger := NewLogger("TestLogger", &stdout, &stderr)
+	var out strings.Builder
+	logger := NewLogger("TestLogger", &out, Options{Level: slog.LevelInfo, Format: FormatJSON})
+	failure := errors.New("connection refused")
 
 	type tc struct {
 		name     string
-		log      func(string) error
-		value    string
-		stdout   string
-		stderr   string
+		log      func() error
+		records  []map[string]any
 		expected error
 	}
 
 	table := []tc{
 		{
-			name:  "log level only into stdout",
-			value: "info level",
-			log: func(s string) error {
-				logger.Info(s)
+			name: "should skip records below level",
+			log: func() error {
+				logger.Debug("debug level", "chat_id", 1)
 				return nil
 			},
-			stdout:   "TestLogger: [INFO] -- {{ info level }}\n",
-			stderr:   "",
+			records:  []map[string]any{},
 			expected: nil,
 		},
 
 		{
-			name:  "warning level only into stdout",
-			value: "warning level",
-			log: func(s string) error {
-				logger.Warning(s)
+			name: "should log info with fields",
+			log: func() error {
+				logger.Info("received photo", "chat_id", 1, "message_id", 2)
 				return nil
 			},
-			stdout:   "TestLogger: [WARNING] -- {{ warning level }}\n",
-			stderr:   "",
+			records: []map[string]any{
+				{
+					"level":      "INFO",
+					"msg":        "received photo",
+					"logger":     "TestLogger",
+					"chat_id":    float64(1),
+					"message_id": float64(2),
+				},
+			},
 			expected: nil,
 		},
 
 		{
-			name:  "error level into stdout and stderr",
-			value: "error level",
-			log: func(s string) error {
-				return logger.Error(s)
+			name: "should log warning with logger fields",
+			log: func() error {
+				logger.With("media_group_id", "abc").Warning("failed to register media")
+				return nil
+			},
+			records: []map[string]any{
+				{
+					"level":          "WARN",
+					"msg":            "failed to register media",
+					"logger":         "TestLogger",
+					"media_group_id": "abc",
+				},
+			},
+			expected: nil,
+		},
+
+		{
+			name: "should log error once with source and return it",
+			log: func() error {
+				return logger.Error("failed to connect", "error", failure)
+			},
+			records: []map[string]any{
+				{
+					"level":  "ERROR",
+					"msg":    "failed to connect",
+					"logger": "TestLogger",
+					"error":  "connection refused",
+					"source": "logger_test.go",
+				},
+			},
+			expected: failure,
+		},
+
+		{
+			name: "should add correlation id from context",
+			log: func() error {
+				ctx := correlation.With(context.Background(), "abc123")
+				logger.WithContext(ctx).Info("processing media group")
+				logger.WithContext(context.Background()).Info("no correlation")
+				return nil
+			},
+			records: []map[string]any{
+				{
+					"level":          "INFO",
+					"msg":            "processing media group",
+					"logger":         "TestLogger",
+					"correlation_id": "abc123",
+				},
+				{
+					"level":  "INFO",
+					"msg":    "no correlation",
+					"logger": "TestLogger",
+				},
 			},
-			stdout:   "TestLogger: [ERROR] -- {{ error level }}\n",
-			stderr:   "TestLogger: [ERROR] -- {{ error level }}\n",
-			expected: fmt.Errorf("error level"),
+			expected: nil,
 		},
 	}
 
 	for _, test := range table {
-		reset()
-		err := test.log(test.value)
-		if test.stdout != stdout.String() {
-			t.Errorf(
-				"Failed: %s - stdout\nexpected: %s\nactual:   %s",
-				test.name,
-				test.stdout,
-				stdout.String(),
-			)
+		out.Reset()
+		err := test.log()
+		records := []map[string]any{}
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			record := map[string]any{}
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatalf("%s - invalid record %q: %v", test.name, line, err)
+			}
+			delete(record, "time")
+			if source, ok := record["source"].(string); ok {
+				// line numbers move with the test, the file is enough
+				record["source"], _, _ = strings.Cut(source, ":")
+			}
+			records = append(records, record)
 		}
-		if test.stderr != stderr.String() {
-			t.Errorf(
-				"Failed: %s - stderr\nexpected: %s\nactual:   %s",
-				test.name,
-				test.stderr,
-				stderr.String(),
-			)
+		if !reflect.DeepEqual(test.records, records) {
+			t.Errorf("%s\nexpected: %+v\nactual:   %+v", test.name, test.records, records)
+		}
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%s - wrong error\nexpected: %v\nactual:   %v", test.name, test.expected, err)
+		}
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	type tc struct {
+		name        string
+		level       string
+		format      string
+		shouldError bool
+		expected    Options
+	}
+
+	table := []tc{
+		{
+			name:     "should default to info text",
+			expected: Options{Level: slog.LevelInfo, Format: FormatText},
+		},
+
+		{
+			name:     "should parse debug json",
+			level:    "debug",
+			format:   "JSON",
+			expected: Options{Level: slog.LevelDebug, Format: FormatJSON},
+		},
+
+		{
+			name:     "should parse warn",
+			level:    "WARN",
+			expected: Options{Level: slog.LevelWarn, Format: FormatText},
+		},
+
+		{
+			name:        "should fail on unknown level",
+			level:       "verbose",
+			shouldError: true,
+		},
+
+		{
+			name:        "should fail on unknown format",
+			format:      "xml",
+			shouldError: true,
+		},
+	}
+
+	for _, test := range table {
+		actual, err := ParseOptions(test.level, test.format)
+		if (err != nil) != test.shouldError {
+			t.Errorf("%s - unexpected error: %v", test.name, err)
 		}
-		if !reflect.DeepEqual(test.expected, err) {
-			t.Errorf(
-				"Failed error output creating %s\nexpected: %v\nactual:   %v",
-				test.name,
-				test.expected,
-				err,
-			)
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf("%s\nexpected: %+v\nactual:   %+v", test.name, test.expected, actual)
 		}
 	}
 }
@@ -0,0 +1,357 @@
+// Package metrics keeps counters, gauges and histograms in memory and exposes them in
+// the Prometheus text format.
+package metrics
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are histogram buckets in seconds, from 5ms to 10s.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// PollBuckets are histogram buckets in seconds for long polls, from 100ms to
+// 30s.
+var PollBuckets = []float64{.1, .5, 1, 2.5, 5, 8, 9, 10, 12, 15, 30}
+
+type collector interface {
+	write(w io.Writer) error
+}
+
+// Registry holds metrics in the order they were created.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// Write writes all metrics in the Prometheus text format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	collectors := slices.Clone(r.collectors)
+	r.mu.Unlock()
+	for _, c := range collectors {
+		err := c.write(w)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler serves the registry for Prometheus to scrape.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		r.Write(w)
+	})
+}
+
+// TokenHandler serves the registry only to scrapers that send token as a
+// bearer token.
+func (r *Registry) TokenHandler(token string) http.Handler {
+	next := r.Handler()
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		bearer, _ := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (d desc) header(w io.Writer, kind string) error {
+	_, err := fmt.Fprintf(
+		w,
+		"# HELP %s %s\n# TYPE %s %s\n",
+		d.name,
+		helpEscaper.Replace(d.help),
+		d.name,
+		kind,
+	)
+	return err
+}
+
+// series formats the labels of one series, extra is appended as is.
+func (d desc) series(name string, values []string, extra string) string {
+	pairs := []string{}
+	for i, l := range d.labels {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, l, labelEscaper.Replace(values[i])))
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+	if len(pairs) == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s{%s}", name, strings.Join(pairs, ","))
+}
+
+// key joins the label values of a series. A wrong number of values is a bug
+// at the call site, the observation is dropped and counted in
+// DroppedObservations instead of failing the request it measures.
+func (d desc) key(values []string) (string, bool) {
+	if len(values) != len(d.labels) {
+		if d.name != DroppedObservations.name {
+			DroppedObservations.Inc(d.name)
+		}
+		return "", false
+	}
+	return strings.Join(values, "\xff"), true
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// CounterVec is a counter partitioned by label values.
+type CounterVec struct {
+	desc
+	kind string
+
+	mu     sync.Mutex
+	values map[string]*counterSeries
+}
+
+type counterSeries struct {
+	labels []string
+	value  float64
+}
+
+func (r *Registry) NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		desc:   desc{name: name, help: help, labels: labels},
+		kind:   "counter",
+		values: map[string]*counterSeries{},
+	}
+	r.register(c)
+	return c
+}
+
+func (c *CounterVec) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+func (c *CounterVec) Add(v float64, labels ...string) {
+	key, ok := c.key(labels)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.values[key]
+	if !ok {
+		s = &counterSeries{labels: slices.Clone(labels)}
+		c.values[key] = s
+	}
+	s.value += v
+}
+
+// Value returns the current count of the series.
+func (c *CounterVec) Value(labels ...string) float64 {
+	key, ok := c.key(labels)
+	if !ok {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.values[key]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (c *CounterVec) write(w io.Writer) error {
+	err := c.header(w, c.kind)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.values) {
+		s := c.values[key]
+		_, err := fmt.Fprintf(w, "%s %s\n", c.series(c.name, s.labels, ""), formatFloat(s.value))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GaugeVec is a value that can go up and down, partitioned by label values.
+type GaugeVec struct {
+	CounterVec
+}
+
+func (r *Registry) NewGaugeVec(name string, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{CounterVec{
+		desc:   desc{name: name, help: help, labels: labels},
+		kind:   "gauge",
+		values: map[string]*counterSeries{},
+	}}
+	r.register(g)
+	return g
+}
+
+func (g *GaugeVec) Set(v float64, labels ...string) {
+	key, ok := g.key(labels)
+	if !ok {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	s, ok := g.values[key]
+	if !ok {
+		s = &counterSeries{labels: slices.Clone(labels)}
+		g.values[key] = s
+	}
+	s.value = v
+}
+
+// HistogramVec counts observations into cumulative buckets, partitioned by
+// label values.
+type HistogramVec struct {
+	desc
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labels []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (r *Registry) NewHistogramVec(
+	name string,
+	help string,
+	buckets []float64,
+	labels ...string,
+) *HistogramVec {
+	h := &HistogramVec{
+		desc:    desc{name: name, help: help, labels: labels},
+		buckets: slices.Sorted(slices.Values(buckets)),
+		values:  map[string]*histogramSeries{},
+	}
+	r.register(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(v float64, labels ...string) {
+	key, ok := h.key(labels)
+	if !ok {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.values[key]
+	if !ok {
+		s = &histogramSeries{labels: slices.Clone(labels), counts: make([]uint64, len(h.buckets))}
+		h.values[key] = s
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+// Count returns how many values the series observed.
+func (h *HistogramVec) Count(labels ...string) uint64 {
+	key, ok := h.key(labels)
+	if !ok {
+		return 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.values[key]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) write(w io.Writer) error {
+	err := h.header(w, "histogram")
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.values) {
+		s := h.values[key]
+		for i, upper := range h.buckets {
+			_, err := fmt.Fprintf(
+				w,
+				"%s %d\n",
+				h.series(h.name+"_bucket", s.labels, fmt.Sprintf(`le="%s"`, formatFloat(upper))),
+				s.counts[i],
+			)
+			if err != nil {
+				return err
+			}
+		}
+		_, err := fmt.Fprintf(
+			w,
+			"%s %d\n%s %s\n%s %d\n",
+			h.series(h.name+"_bucket", s.labels, `le="+Inf"`),
+			s.count,
+			h.series(h.name+"_sum", s.labels, ""),
+			formatFloat(s.sum),
+			h.series(h.name+"_count", s.labels, ""),
+			s.count,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	type tc struct {
+		name     string
+		record   func(r *Registry)
+		expected string
+	}
+
+	table := []tc{
+		{
+			name: "should write metric without series",
+			record: func(r *Registry) {
+				r.NewCounterVec("posts_total", "Posts.", "destination")
+			},
+			expected: "# HELP posts_total Posts.\n" +
+				"# TYPE posts_total counter\n",
+		},
+
+		{
+			name: "should write counters sorted by labels",
+			record: func(r *Registry) {
+				c := r.NewCounterVec("updates_total", "Updates\nby type.", "type")
+				c.Inc("message")
+				c.Add(2, "callback_query")
+				c.Inc("message")
+			},
+			expected: "# HELP updates_total Updates\\nby type.\n" +
+				"# TYPE updates_total counter\n" +
+				"updates_total{type=\"callback_query\"} 2\n" +
+				"updates_total{type=\"message\"} 2\n",
+		},
+
+		{
+			name: "should escape label values",
+			record: func(r *Registry) {
+				r.NewCounterVec("errors_total", "Errors.", "reason").Inc("say \"hi\"\\\n")
+			},
+			expected: "# HELP errors_total Errors.\n" +
+				"# TYPE errors_total counter\n" +
+				"errors_total{reason=\"say \\\"hi\\\"\\\\\\n\"} 1\n",
+		},
+
+		{
+			name: "should write cumulative histogram buckets",
+			record: func(r *Registry) {
+				h := r.NewHistogramVec("latency_seconds", "Latency.", []float64{1, 0.1}, "method")
+				h.Observe(0.05, "getUpdates")
+				h.Observe(0.5, "getUpdates")
+				h.Observe(3, "getUpdates")
+			},
+			expected: "# HELP latency_seconds Latency.\n" +
+				"# TYPE latency_seconds histogram\n" +
+				"latency_seconds_bucket{method=\"getUpdates\",le=\"0.1\"} 1\n" +
+				"latency_seconds_bucket{method=\"getUpdates\",le=\"1\"} 2\n" +
+				"latency_seconds_bucket{method=\"getUpdates\",le=\"+Inf\"} 3\n" +
+				"latency_seconds_sum{method=\"getUpdates\"} 3.55\n" +
+				"latency_seconds_count{method=\"getUpdates\"} 3\n",
+		},
+
+		{
+			name: "should write last value of gauge",
+			record: func(r *Registry) {
+				g := r.NewGaugeVec("pending", "Pending.")
+				g.Set(3)
+				g.Add(-1)
+				g.Set(5)
+			},
+			expected: "# HELP pending Pending.\n" +
+				"# TYPE pending gauge\n" +
+				"pending 5\n",
+		},
+
+		{
+			name: "should drop observations with wrong labels",
+			record: func(r *Registry) {
+				c := r.NewCounterVec("sent_total", "Sent.", "chat")
+				c.Inc()
+				c.Inc("1", "2")
+				r.NewHistogramVec("wait_seconds", "Wait.", []float64{1}).Observe(0.5, "getUpdates")
+				r.NewGaugeVec("queued", "Queued.", "kind").Set(1)
+			},
+			expected: "# HELP sent_total Sent.\n" +
+				"# TYPE sent_total counter\n" +
+				"# HELP wait_seconds Wait.\n" +
+				"# TYPE wait_seconds histogram\n" +
+				"# HELP queued Queued.\n" +
+				"# TYPE queued gauge\n",
+		},
+
+		{
+			name: "should write series without labels",
+			record: func(r *Registry) {
+				r.NewCounterVec("ticks_total", "Ticks.").Inc()
+			},
+			expected: "# HELP ticks_total Ticks.\n" +
+				"# TYPE ticks_total counter\n" +
+				"ticks_total 1\n",
+		},
+	}
+
+	for _, test := range table {
+		r := NewRegistry()
+		test.record(r)
+		var actual strings.Builder
+		err := r.Write(&actual)
+		if err != nil {
+			t.Fatalf("%s - %v", test.name, err)
+		}
+		if actual.String() != test.expected {
+			t.Errorf("%s\nexpected: %q\nactual:   %q", test.name, test.expected, actual.String())
+		}
+	}
+}
+
+func TestDroppedObservations(t *testing.T) {
+	h := NewRegistry().NewHistogramVec("dropped_test_seconds", "Dropped.", DefBuckets, "method")
+	before := DroppedObservations.Value("dropped_test_seconds")
+	h.Observe(1)
+	h.Observe(1, "getMe", "extra")
+	actual := DroppedObservations.Value("dropped_test_seconds")
+	if actual != before+2 {
+		t.Errorf("wrong dropped count\nexpected: %v\nactual:   %v", before+2, actual)
+	}
+	if count := h.Count("getMe"); count != 0 {
+		t.Errorf("dropped observation was recorded: %d", count)
+	}
+}
@@ -0,0 +1,75 @@
+package metrics
+
+// Default is the registry served on /metrics by the bot and the webapp, to
+// scrapers with METRICS_TOKEN.
+var Default = NewRegistry()
+
+var (
+	UpdatesReceived = Default.NewCounterVec(
+		"ratatoskr_updates_received_total",
+		"Telegram updates received by the bot, by update type.",
+		"type",
+	)
+	MediaProcessed = Default.NewCounterVec(
+		"ratatoskr_media_processed_total",
+		"Media items received for tagging, by kind.",
+		"kind",
+	)
+	PostsPublished = Default.NewCounterVec(
+		"ratatoskr_posts_published_total",
+		"Posts copied to a destination chat.",
+		"destination",
+	)
+	TelegramRequestDuration = Default.NewHistogramVec(
+		"ratatoskr_telegram_request_duration_seconds",
+		"Bot API request latency, by method. getUpdates long polls are kept apart.",
+		DefBuckets,
+		"method",
+	)
+	TelegramPollDuration = Default.NewHistogramVec(
+		"ratatoskr_telegram_poll_duration_seconds",
+		"Duration of getUpdates long polls, which wait for updates up to the poll timeout.",
+		PollBuckets,
+	)
+	TelegramRequestErrors = Default.NewCounterVec(
+		"ratatoskr_telegram_request_errors_total",
+		"Failed Bot API requests, by method.",
+		"method",
+	)
+	DBOperationDuration = Default.NewHistogramVec(
+		"ratatoskr_db_operation_duration_seconds",
+		"MongoDB command latency, by command and outcome.",
+		DefBuckets,
+		"command",
+		"status",
+	)
+	AnalyticsEnqueued = Default.NewCounterVec(
+		"ratatoskr_analytics_outbox_enqueued_total",
+		"Analytics events appended to the outbox.",
+	)
+	AnalyticsFlushed = Default.NewCounterVec(
+		"ratatoskr_analytics_outbox_flushed_total",
+		"Analytics events written from the outbox to MongoDB.",
+	)
+	AnalyticsFlushFailures = Default.NewCounterVec(
+		"ratatoskr_analytics_outbox_flush_failures_total",
+		"Outbox flushes that failed and will be retried.",
+	)
+	AnalyticsPending = Default.NewGaugeVec(
+		"ratatoskr_analytics_outbox_pending",
+		"Analytics events in the outbox waiting to be written.",
+	)
+	DroppedObservations = Default.NewCounterVec(
+		"ratatoskr_metrics_dropped_observations_total",
+		"Observations dropped because their label values did not match the metric, by metric.",
+		"metric",
+	)
+	HTTPRequestDuration = Default.NewHistogramVec(
+		"ratatoskr_http_request_duration_seconds",
+		"Webapp request latency, by route and status.",
+		DefBuckets,
+		"route",
+		"method",
+		"status",
+	)
+)
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"ratatoskr/internal/correlation"
+	"ratatoskr/internal/logger"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+// TelegramClient records latency and failures of every Bot API request
+// made through the wrapped client. getUpdates waits for updates up to the
+// long poll timeout, so its duration goes to TelegramPollDuration.
+type TelegramClient struct {
+	gotgbot.BotClient
+	// Logger, if set, logs every request at debug level.
+	Logger *logger.Logger
+	// CorrelationID is added to the context of every request, the generated
+	// Bot methods do not take one.
+	CorrelationID string
+}
+
+func (c TelegramClient) TimeoutContext(opts *gotgbot.RequestOpts) (context.Context, context.CancelFunc) {
+	ctx, cancel := c.BotClient.TimeoutContext(opts)
+	return correlation.With(ctx, c.CorrelationID), cancel
+}
+
+func (c TelegramClient) RequestWithContext(
+	ctx context.Context,
+	token string,
+	method string,
+	params map[string]string,
+	data map[string]gotgbot.NamedReader,
+	opts *gotgbot.RequestOpts,
+) (json.RawMessage, error) {
+	start := time.Now()
+	res, err := c.BotClient.RequestWithContext(ctx, token, method, params, data, opts)
+	duration := time.Since(start)
+	if method == "getUpdates" {
+		TelegramPollDuration.Observe(duration.Seconds())
+	} else {
+		TelegramRequestDuration.Observe(duration.Seconds(), method)
+	}
+	if err != nil {
+		TelegramRequestErrors.Inc(method)
+	}
+	if c.Logger != nil {
+		args := []any{"method", method, "duration", duration}
+		if err != nil {
+			args = append(args, "error", err)
+		}
+		c.Logger.WithContext(ctx).Debug("telegram request", args...)
+	}
+	return res, err
+}
+
+// WithCorrelationID returns a copy of b whose requests carry id, bots that
+// do not use a TelegramClient are returned as they are.
+func WithCorrelationID(b *gotgbot.Bot, id string) *gotgbot.Bot {
+	client, ok := b.BotClient.(TelegramClient)
+	if !ok {
+		return b
+	}
+	client.CorrelationID = id
+	res := *b
+	res.BotClient = client
+	return &res
+}
@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"ratatoskr/internal/correlation"
+	"ratatoskr/internal/logger"
+	"strings"
+	"testing"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+type botClientMock struct {
+	gotgbot.BotClient
+	err error
+	// correlationID receives the correlation ID of the last request
+	correlationID *string
+}
+
+func (c botClientMock) TimeoutContext(*gotgbot.RequestOpts) (context.Context, context.CancelFunc) {
+	return context.WithCancel(context.Background())
+}
+
+func (c botClientMock) RequestWithContext(
+	ctx context.Context,
+	_ string,
+	_ string,
+	_ map[string]string,
+	_ map[string]gotgbot.NamedReader,
+	_ *gotgbot.RequestOpts,
+) (json.RawMessage, error) {
+	if c.correlationID != nil {
+		*c.correlationID = correlation.ID(ctx)
+	}
+	return json.RawMessage(`true`), c.err
+}
+
+func TestTelegramClient(t *testing.T) {
+	type tc struct {
+		name   string
+		method string
+		err    error
+		errors float64
+	}
+
+	table := []tc{
+		{name: "should time successful request", method: "testSendMessage", errors: 0},
+		{name: "should count failed request", method: "testCopyMessages", err: errors.New("Bad Request"), errors: 1},
+	}
+
+	for _, test := range table {
+		client := TelegramClient{BotClient: botClientMock{err: test.err}}
+		_, err := client.RequestWithContext(context.Background(), "TOKEN", test.method, nil, nil, nil)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s - wrong error\nexpected: %v\nactual:   %v", test.name, test.err, err)
+		}
+		if actual := TelegramRequestDuration.Count(test.method); actual != 1 {
+			t.Errorf("%s - wrong request count\nexpected: 1\nactual:   %d", test.name, actual)
+		}
+		if actual := TelegramRequestErrors.Value(test.method); actual != test.errors {
+			t.Errorf("%s - wrong error count\nexpected: %v\nactual:   %v", test.name, test.errors, actual)
+		}
+	}
+}
+
+func TestTelegramClientPoll(t *testing.T) {
+	before := TelegramPollDuration.Count()
+	client := TelegramClient{BotClient: botClientMock{}}
+	_, err := client.RequestWithContext(context.Background(), "TOKEN", "getUpdates", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if actual := TelegramPollDuration.Count(); actual != before+1 {
+		t.Errorf("wrong poll count\nexpected: %d\nactual:   %d", before+1, actual)
+	}
+	if actual := TelegramRequestDuration.Count("getUpdates"); actual != 0 {
+		t.Errorf("long poll was recorded as request latency: %d", actual)
+	}
+}
+
+func TestWithCorrelationID(t *testing.T) {
+	var out strings.Builder
+	var received string
+	b := &gotgbot.Bot{
+		Token: "TOKEN",
+		BotClient: TelegramClient{
+			BotClient: botClientMock{correlationID: &received},
+			Logger:    logger.NewLogger("test logger", &out, logger.Options{Level: slog.LevelDebug}),
+		},
+	}
+
+	_, err := WithCorrelationID(b, "abc123").Request("testGetMe", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if received != "abc123" {
+		t.Errorf("wrong request correlation ID\nexpected: %q\nactual:   %q", "abc123", received)
+	}
+	if !strings.Contains(out.String(), "correlation_id=abc123") {
+		t.Errorf("request was not logged with correlation ID: %s", out.String())
+	}
+	if client := b.BotClient.(TelegramClient); client.CorrelationID != "" {
+		t.Errorf("original bot was changed: %q", client.CorrelationID)
+	}
+
+	_, err = b.Request("testGetMe", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if received != "" {
+		t.Errorf("expected no correlation ID, actual %q", received)
+	}
+}
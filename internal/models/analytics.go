@@ -0,0 +1,81 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	MediaKindPhoto     = "photo"
+	MediaKindVideo     = "video"
+	MediaKindAnimation = "animation"
+	MediaKindAlbum     = "album"
+)
+
+const (
+	PostingModeImmediate = "immediate"
+	PostingModeSilent    = "silent"
+)
+
+type Analytics struct {
+	ID    primitive.ObjectID `bson:"_id,omitempty"`
+	Tag   string             `bson:"tag"`
+	Group string             `bson:"group"`
+	Date  time.Time          `bson:"dateUsed"`
+
+	UserID            int64   `bson:"userId,omitempty"`
+	MediaKind         string  `bson:"mediaKind,omitempty"`
+	ItemCount         int     `bson:"itemCount,omitempty"`
+	DestinationChatID int64   `bson:"destinationChatId,omitempty"`
+	ChannelMessageIDs []int64 `bson:"channelMessageIds,omitempty"`
+	PostingMode       string  `bson:"postingMode,omitempty"`
+	CorrelationID     string  `bson:"correlationId,omitempty"`
+}
+
+type TagUsage struct {
+	Tag   string `bson:"tag"`
+	Group string `bson:"group"`
+	Count int    `bson:"count"`
+}
+
+type AdminAnalytics struct {
+	UserID   int64      `bson:"_id"`
+	Total    int        `bson:"total"`
+	Posts    int        `bson:"posts"`
+	LastUsed time.Time  `bson:"lastUsed"`
+	Tags     []TagUsage `bson:"tags"`
+}
+
+type TagRollup struct {
+	Start    time.Time `bson:"start"`
+	UserID   int64     `bson:"userId"`
+	Group    string    `bson:"group"`
+	Tag      string    `bson:"tag"`
+	Count    int       `bson:"count"`
+	LastUsed time.Time `bson:"lastUsed"`
+}
+
+type PostRollup struct {
+	Start  time.Time `bson:"start"`
+	UserID int64     `bson:"userId"`
+	Posts  int       `bson:"posts"`
+	Items  int       `bson:"items"`
+}
+
+type PostTag struct {
+	Tag   string `bson:"tag"`
+	Group string `bson:"group"`
+}
+
+type Post struct {
+	DestinationChatID int64     `bson:"destinationChatId"`
+	ChannelMessageIDs []int64   `bson:"channelMessageIds"`
+	UserID            int64     `bson:"userId"`
+	MediaKind         string    `bson:"mediaKind"`
+	ItemCount         int       `bson:"itemCount"`
+	PostingMode       string    `bson:"postingMode"`
+	Date              time.Time `bson:"date"`
+	Tags              []PostTag `bson:"tags"`
+	CorrelationID     string    `bson:"correlationId,omitempty"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// PendingMedia is a message the bot sent back for tagging. FileID points to a
+// small preview of it, so the webapp can show what is being tagged.
+type PendingMedia struct {
+	ChatID    int64     `bson:"chatId"`
+	MessageID int64     `bson:"messageId"`
+	Kind      string    `bson:"kind"`
+	FileID    string    `bson:"fileId"`
+	CreatedAt time.Time `bson:"createdAt"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// TaggingSession ties a webapp button to the media it tags, so neither the
+// bot nor the webapp has to trust message IDs sent by the client.
+type TaggingSession struct {
+	ID               string    `bson:"_id"`
+	ChatID           int64     `bson:"chatId"`
+	SourceMessageIDs []int64   `bson:"sourceMessageIds"`
+	MessageIDs       []int64   `bson:"messageIds"`
+	ButtonMessageID  int64     `bson:"buttonMessageId"`
+	MediaKind        string    `bson:"mediaKind"`
+	CorrelationID    string    `bson:"correlationId,omitempty"`
+	CreatedAt        time.Time `bson:"createdAt"`
+	ExpiresAt        time.Time `bson:"expiresAt"`
+}
+
+// Draft is the state of the tag picker a user left a tagging session in, so
+// it can be picked up on another device.
+type Draft struct {
+	SessionID    string    `bson:"sessionId"`
+	UserID       int64     `bson:"userId"`
+	OpenedGroups []string  `bson:"openedGroups"`
+	SelectedTags []string  `bson:"selectedTags"`
+	ScrollY      float64   `bson:"scrollY"`
+	UpdatedAt    time.Time `bson:"updatedAt"`
+	ExpiresAt    time.Time `bson:"expiresAt"`
+}
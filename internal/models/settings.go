@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+const (
+	CaptionFormatLines  = "lines"
+	CaptionFormatInline = "inline"
+	CaptionFormatNone   = "none"
+)
+
+// Settings are the posting settings admins change at runtime from the
+// /settings menu of the bot.
+type Settings struct {
+	ReceiverID         int64         `bson:"receiverId"`
+	MediaGroupInterval time.Duration `bson:"mediaGroupInterval"`
+	CaptionFormat      string        `bson:"captionFormat"`
+	PostingMode        string        `bson:"postingMode"`
+	Version            int64         `bson:"version"`
+	UpdatedAt          time.Time     `bson:"updatedAt,omitempty"`
+	UpdatedBy          int64         `bson:"updatedBy,omitempty"`
+}
+
+// SettingChange is the audit record of one changed setting.
+type SettingChange struct {
+	Setting       string    `bson:"setting"`
+	From          string    `bson:"from"`
+	To            string    `bson:"to"`
+	UserID        int64     `bson:"userId"`
+	Date          time.Time `bson:"date"`
+	CorrelationID string    `bson:"correlationId,omitempty"`
+}
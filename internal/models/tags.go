@@ -7,8 +7,9 @@ import (
 )
 
 type Tag struct {
-	ID   primitive.ObjectID `bson:"_id,omitempty"`
-	Name string             `bson:"tag"`
+	ID      primitive.ObjectID `bson:"_id,omitempty"`
+	Name    string             `bson:"tag"`
+	Aliases []string           `bson:"aliases,omitempty"`
 }
 
 type Group struct {
@@ -18,9 +19,22 @@ type Group struct {
 	Tags          []Tag              `bson:"tags"`
 }
 
-type Analytics struct {
-	ID    primitive.ObjectID `bson:"_id,omitempty"`
-	Tag   string             `bson:"tag"`
-	Group string             `bson:"group"`
-	Date  time.Time          `bson:"dateUsed"`
+type Favorites struct {
+	UserID int64    `bson:"_id"`
+	Tags   []string `bson:"tags"`
+}
+
+// TagMenu is the whole menu with the version it was read at. Every change to
+// the menu increments the version.
+type TagMenu struct {
+	Version int64
+	Groups  []Group
+}
+
+type TagMenuState struct {
+	ID        string    `bson:"_id"`
+	Version   int64     `bson:"version"`
+	UpdatedAt time.Time `bson:"updatedAt"`
+	// Generation marks the groups of the current menu
+	Generation string `bson:"generation,omitempty"`
 }
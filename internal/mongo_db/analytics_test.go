@@ -0,0 +1,81 @@
+package mongo_db
+
+import (
+	"ratatoskr/internal/models"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDecodeAnalytics(t *testing.T) {
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	type tc struct {
+		name     string
+		document bson.D
+		expected models.Analytics
+	}
+
+	table := []tc{
+		{
+			name: "should load documents written before extended fields",
+			document: bson.D{
+				{Key: "tag", Value: "#tag1"},
+				{Key: "group", Value: "Group 1"},
+				{Key: "dateUsed", Value: date},
+			},
+			expected: models.Analytics{Tag: "#tag1", Group: "Group 1", Date: date},
+		},
+
+		{
+			name: "should load extended fields",
+			document: bson.D{
+				{Key: "tag", Value: "#tag1"},
+				{Key: "group", Value: "Group 1"},
+				{Key: "dateUsed", Value: date},
+				{Key: "userId", Value: int64(1234)},
+				{Key: "mediaKind", Value: "album"},
+				{Key: "itemCount", Value: 3},
+				{Key: "destinationChatId", Value: int64(-100)},
+				{Key: "channelMessageIds", Value: bson.A{int64(1), int64(2), int64(3)}},
+				{Key: "postingMode", Value: "immediate"},
+			},
+			expected: models.Analytics{
+				Tag:               "#tag1",
+				Group:             "Group 1",
+				Date:              date,
+				UserID:            1234,
+				MediaKind:         models.MediaKindAlbum,
+				ItemCount:         3,
+				DestinationChatID: -100,
+				ChannelMessageIDs: []int64{1, 2, 3},
+				PostingMode:       models.PostingModeImmediate,
+			},
+		},
+	}
+
+	for _, test := range table {
+		raw, err := bson.Marshal(test.document)
+		if err != nil {
+			t.Errorf("%s - failed to marshal document: %v", test.name, err)
+			continue
+		}
+		var actual models.Analytics
+		err = bson.Unmarshal(raw, &actual)
+		if err != nil {
+			t.Errorf("%s - failed to decode document: %v", test.name, err)
+			continue
+		}
+		actual.Date = actual.Date.UTC()
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf(
+				"%s - wrong analytics\nexpected: %+v\nactual:   %+v",
+				test.name,
+				test.expected,
+				actual,
+			)
+		}
+	}
+}
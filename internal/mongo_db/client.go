@@ -2,25 +2,43 @@ package mongo_db
 
 import (
 	"context"
+	"errors"
+	"ratatoskr/internal/db"
 	"ratatoskr/internal/models"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+const duplicateKeyCode = 11000
+
 type MongoDB struct {
-	client              *mongo.Client
-	db                  *mongo.Database
-	tagsCollection      *mongo.Collection
-	analyticsCollection *mongo.Collection
+	client                  *mongo.Client
+	db                      *mongo.Database
+	tagsCollection          *mongo.Collection
+	analyticsCollection     *mongo.Collection
+	tagsDailyCollection     *mongo.Collection
+	tagsMonthlyCollection   *mongo.Collection
+	postsDailyCollection    *mongo.Collection
+	postsMonthlyCollection  *mongo.Collection
+	rollupStateCollection   *mongo.Collection
+	favoritesCollection     *mongo.Collection
+	menuStateCollection     *mongo.Collection
+	pendingMediaCollection  *mongo.Collection
+	sessionsCollection      *mongo.Collection
+	draftsCollection        *mongo.Collection
+	settingsCollection      *mongo.Collection
+	settingsAuditCollection *mongo.Collection
 }
 
 func NewMongoDB(ctx context.Context, URI string, database string) (*MongoDB, error) {
 	client, err := mongo.Connect(
 		ctx,
 		options.Client().
-			ApplyURI(URI),
+			ApplyURI(URI).
+			SetMonitor(commandMonitor()),
 	)
 	if err != nil {
 		return nil, err
@@ -31,46 +49,161 @@ func NewMongoDB(ctx context.Context, URI string, database string) (*MongoDB, err
 	}
 	db := client.Database(database)
 	return &MongoDB{
-		client:              client,
-		db:                  db,
-		tagsCollection:      db.Collection("tags_menus"),
-		analyticsCollection: db.Collection("tags_usage_statistics"),
+		client:                  client,
+		db:                      db,
+		tagsCollection:          db.Collection("tags_menus"),
+		analyticsCollection:     db.Collection("tags_usage_statistics"),
+		tagsDailyCollection:     db.Collection("tags_usage_daily"),
+		tagsMonthlyCollection:   db.Collection("tags_usage_monthly"),
+		postsDailyCollection:    db.Collection("posts_usage_daily"),
+		postsMonthlyCollection:  db.Collection("posts_usage_monthly"),
+		rollupStateCollection:   db.Collection("analytics_rollup_state"),
+		favoritesCollection:     db.Collection("favorite_tags"),
+		menuStateCollection:     db.Collection("tags_menu_state"),
+		pendingMediaCollection:  db.Collection("pending_media"),
+		sessionsCollection:      db.Collection("tagging_sessions"),
+		draftsCollection:        db.Collection("tagging_drafts"),
+		settingsCollection:      db.Collection("runtime_settings"),
+		settingsAuditCollection: db.Collection("runtime_settings_audit"),
 	}, nil
 }
 
+func (m MongoDB) Ping(ctx context.Context) error {
+	return m.client.Ping(ctx, nil)
+}
+
+// Close disconnects the client, waiting for operations in progress.
+func (m MongoDB) Close(ctx context.Context) error {
+	return m.client.Disconnect(ctx)
+}
+
 func (m MongoDB) GetAllGroupsWithTags(ctx context.Context) (*[]models.Group, error) {
-	c, err := m.tagsCollection.Find(ctx, bson.D{{}})
+	state, err := m.menuState(ctx)
 	if err != nil {
 		return nil, err
 	}
-	var res []models.Group
-	err = c.All(ctx, &res)
+	groups, err := m.readGroups(ctx, state)
 	if err != nil {
 		return nil, err
 	}
-	sorted := fixSorting(res)
-	return &sorted, nil
+	return &groups, nil
 }
 
-func (m MongoDB) UpdateTags(ctx context.Context, g *[]models.Group) error {
-	m.tagsCollection.DeleteMany(ctx, bson.D{{}})
-	docs := make([]interface{}, len(*g))
-	for i, v := range *g {
-		docs[i] = v
+func (m MongoDB) AddTag(ctx context.Context, group string, tag models.Tag) error {
+	state, err := m.menuState(ctx)
+	if err != nil {
+		return err
+	}
+	current := generationFilter(state.Generation)
+	count, err := m.tagsCollection.CountDocuments(ctx, bson.M{"$and": bson.A{current, bson.M{"tags.tag": tag.Name}}})
+	if err != nil {
+		return err
 	}
-	_, err := m.tagsCollection.InsertMany(ctx, docs)
-	return err
+	if count != 0 {
+		return db.ErrTagExists
+	}
+	res, err := m.tagsCollection.UpdateOne(
+		ctx,
+		bson.M{"$and": bson.A{current, bson.M{"groupName": group, "tags.tag": bson.M{"$ne": tag.Name}}}},
+		bson.M{"$push": bson.M{"tags": tag}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount != 0 {
+		_, err = m.bumpMenuVersion(ctx, nil, nil)
+		return err
+	}
+	count, err = m.tagsCollection.CountDocuments(ctx, bson.M{"$and": bson.A{current, bson.M{"groupName": group}}})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return db.ErrGroupNotFound
+	}
+	return db.ErrTagExists
 }
 
 func (m MongoDB) InsertAnalytics(ctx context.Context, a *[]models.Analytics) error {
+	if len(*a) == 0 {
+		return nil
+	}
 	docs := make([]interface{}, len(*a))
 	for i, v := range *a {
 		docs[i] = v
 	}
-	m.analyticsCollection.InsertMany(ctx, docs)
+	_, err := m.analyticsCollection.InsertMany(
+		ctx,
+		docs,
+		options.InsertMany().SetOrdered(false),
+	)
+	if err != nil && !isOnlyDuplicateKeyError(err) {
+		return err
+	}
 	return nil
 }
 
+func (m MongoDB) GetAdminAnalytics(
+	ctx context.Context,
+	from time.Time,
+	to time.Time,
+) (*[]models.AdminAnalytics, error) {
+	tags, err := m.readTagRollups(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	posts, err := m.readPostRollups(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	res := aggregateAdminAnalytics(tags, posts)
+	return &res, nil
+}
+
+// StreamAnalytics reads raw events, so it only covers the analytics
+// retention window.
+func (m MongoDB) StreamAnalytics(
+	ctx context.Context,
+	from time.Time,
+	to time.Time,
+	fn func(models.Analytics) error,
+) error {
+	c, err := m.analyticsCollection.Find(
+		ctx,
+		bson.M{"dateUsed": bson.M{"$gte": from, "$lt": to}},
+		options.Find().SetSort(bson.D{{Key: "dateUsed", Value: 1}, {Key: "_id", Value: 1}}),
+	)
+	if err != nil {
+		return err
+	}
+	defer c.Close(ctx)
+	for c.Next(ctx) {
+		var a models.Analytics
+		err = c.Decode(&a)
+		if err != nil {
+			return err
+		}
+		err = fn(a)
+		if err != nil {
+			return err
+		}
+	}
+	return c.Err()
+}
+
+func isOnlyDuplicateKeyError(err error) bool {
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+		return false
+	}
+	for _, e := range bulkErr.WriteErrors {
+		if e.Code != duplicateKeyCode {
+			return false
+		}
+	}
+	return true
+}
+
 func fixSorting(group []models.Group) []models.Group {
 	sorted := make([]models.Group, len(group))
 	for _, v := range group {
@@ -0,0 +1,41 @@
+package mongo_db
+
+import (
+	"context"
+	"errors"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (m MongoDB) GetDraft(ctx context.Context, sessionID string, userID int64) (*models.Draft, error) {
+	var d models.Draft
+	err := m.draftsCollection.FindOne(ctx, bson.M{"sessionId": sessionID, "userId": userID}).Decode(&d)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, db.ErrDraftNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// SaveDraft replaces the user's draft of the session. Drafts expire together
+// with their session.
+func (m MongoDB) SaveDraft(ctx context.Context, d *models.Draft) error {
+	_, err := m.draftsCollection.ReplaceOne(
+		ctx,
+		bson.M{"sessionId": d.SessionID, "userId": d.UserID},
+		d,
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+func (m MongoDB) DeleteDrafts(ctx context.Context, sessionID string) error {
+	_, err := m.draftsCollection.DeleteMany(ctx, bson.M{"sessionId": sessionID})
+	return err
+}
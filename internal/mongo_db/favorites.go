@@ -0,0 +1,145 @@
+package mongo_db
+
+import (
+	"context"
+	"errors"
+	"ratatoskr/internal/models"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (m MongoDB) GetFavoriteTags(ctx context.Context, userID int64) (*[]string, error) {
+	var f models.Favorites
+	err := m.favoritesCollection.FindOne(ctx, bson.M{"_id": userID}).Decode(&f)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return &[]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if f.Tags == nil {
+		f.Tags = []string{}
+	}
+	return &f.Tags, nil
+}
+
+func (m MongoDB) SetFavoriteTag(
+	ctx context.Context,
+	userID int64,
+	tag string,
+	favorite bool,
+) error {
+	update := bson.M{"$pull": bson.M{"tags": tag}}
+	if favorite {
+		update = bson.M{"$addToSet": bson.M{"tags": tag}}
+	}
+	_, err := m.favoritesCollection.UpdateOne(
+		ctx,
+		bson.M{"_id": userID},
+		update,
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// GetRecentTags returns the last distinct tags the user posted with, most
+// recent first, with how many times each of them was used. It reads the daily
+// tag rollups and the raw events written since the last rollup run.
+func (m MongoDB) GetRecentTags(
+	ctx context.Context,
+	userID int64,
+	limit int,
+) (*[]models.TagUsage, error) {
+	var state rollupState
+	err := m.rollupStateCollection.FindOne(ctx, bson.M{"_id": rollupStateID}).Decode(&state)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+	c, err := m.tagsDailyCollection.Aggregate(ctx, recentTagsPipeline(
+		bson.M{"userId": userID},
+		"$count",
+		"lastUsed",
+	))
+	if err != nil {
+		return nil, err
+	}
+	var rolledUp []models.TagRollup
+	err = c.All(ctx, &rolledUp)
+	if err != nil {
+		return nil, err
+	}
+	c, err = m.analyticsCollection.Aggregate(ctx, recentTagsPipeline(
+		bson.M{"userId": userID, "dateUsed": bson.M{"$gte": state.LastRun}},
+		1,
+		"dateUsed",
+	))
+	if err != nil {
+		return nil, err
+	}
+	var fresh []models.TagRollup
+	err = c.All(ctx, &fresh)
+	if err != nil {
+		return nil, err
+	}
+	res := mergeRecentTags(append(rolledUp, fresh...), limit)
+	return &res, nil
+}
+
+// recentTagsPipeline sums count per tag and keeps the group of its latest use.
+func recentTagsPipeline(match bson.M, count any, dateField string) mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$sort", Value: bson.D{{Key: dateField, Value: 1}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$tag"},
+			{Key: "group", Value: bson.M{"$last": "$group"}},
+			{Key: "count", Value: bson.M{"$sum": count}},
+			{Key: "lastUsed", Value: bson.M{"$max": "$" + dateField}},
+		}}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "tag", Value: "$_id"},
+			{Key: "group", Value: 1},
+			{Key: "count", Value: 1},
+			{Key: "lastUsed", Value: 1},
+		}}},
+	}
+}
+
+func mergeRecentTags(rollups []models.TagRollup, limit int) []models.TagUsage {
+	byTag := map[string]*models.TagRollup{}
+	for _, r := range rollups {
+		t, ok := byTag[r.Tag]
+		if !ok {
+			t = &models.TagRollup{Tag: r.Tag}
+			byTag[r.Tag] = t
+		}
+		t.Count += r.Count
+		if !r.LastUsed.Before(t.LastUsed) {
+			t.LastUsed = r.LastUsed
+			t.Group = r.Group
+		}
+	}
+	merged := []*models.TagRollup{}
+	for _, t := range byTag {
+		merged = append(merged, t)
+	}
+	slices.SortFunc(merged, func(x, y *models.TagRollup) int {
+		if c := y.LastUsed.Compare(x.LastUsed); c != 0 {
+			return c
+		}
+		return strings.Compare(x.Tag, y.Tag)
+	})
+	res := []models.TagUsage{}
+	for _, t := range merged {
+		if len(res) == limit {
+			break
+		}
+		res = append(res, models.TagUsage{Tag: t.Tag, Group: t.Group, Count: t.Count})
+	}
+	return res
+}
@@ -0,0 +1,75 @@
+package mongo_db
+
+import (
+	"ratatoskr/internal/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMergeRecentTags(t *testing.T) {
+	day := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+
+	type tc struct {
+		name     string
+		rollups  []models.TagRollup
+		limit    int
+		expected []models.TagUsage
+	}
+
+	table := []tc{
+		{
+			name: "should add fresh events to the rolled up count",
+			rollups: []models.TagRollup{
+				{Group: "Group 1", Tag: "#tag1", Count: 3, LastUsed: day},
+				{Group: "Group 2", Tag: "#tag1", Count: 1, LastUsed: day.Add(time.Hour)},
+			},
+			limit:    5,
+			expected: []models.TagUsage{{Tag: "#tag1", Group: "Group 2", Count: 4}},
+		},
+
+		{
+			name: "should sort by last use and then by tag",
+			rollups: []models.TagRollup{
+				{Group: "Group 1", Tag: "#tag2", Count: 1, LastUsed: day},
+				{Group: "Group 1", Tag: "#tag1", Count: 2, LastUsed: day},
+				{Group: "Group 1", Tag: "#tag3", Count: 1, LastUsed: day.Add(time.Hour)},
+			},
+			limit: 5,
+			expected: []models.TagUsage{
+				{Tag: "#tag3", Group: "Group 1", Count: 1},
+				{Tag: "#tag1", Group: "Group 1", Count: 2},
+				{Tag: "#tag2", Group: "Group 1", Count: 1},
+			},
+		},
+
+		{
+			name: "should cut at the limit",
+			rollups: []models.TagRollup{
+				{Group: "Group 1", Tag: "#tag1", Count: 1, LastUsed: day},
+				{Group: "Group 1", Tag: "#tag2", Count: 1, LastUsed: day.Add(time.Hour)},
+			},
+			limit:    1,
+			expected: []models.TagUsage{{Tag: "#tag2", Group: "Group 1", Count: 1}},
+		},
+
+		{
+			name:     "should return empty list without usage",
+			rollups:  []models.TagRollup{},
+			limit:    5,
+			expected: []models.TagUsage{},
+		},
+	}
+
+	for _, test := range table {
+		actual := mergeRecentTags(test.rollups, test.limit)
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf(
+				"%s - wrong recent tags\nexpected: %+v\nactual:   %+v",
+				test.name,
+				test.expected,
+				actual,
+			)
+		}
+	}
+}
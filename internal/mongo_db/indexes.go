@@ -0,0 +1,78 @@
+package mongo_db
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	sessionIndexes = []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+	draftIndexes = []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "sessionId", Value: 1}, {Key: "userId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+	pendingMediaIndexes = []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "chatId", Value: 1}, {Key: "messageId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "createdAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(pendingMediaTTL.Seconds())),
+		},
+	}
+	settingsAuditIndexes = []mongo.IndexModel{
+		{Keys: bson.D{{Key: "date", Value: -1}}},
+	}
+	analyticsIndexes = []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "dateUsed", Value: -1}}},
+	}
+	tagsDailyIndexes = []mongo.IndexModel{
+		{Keys: bson.D{{Key: "start", Value: 1}}},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "lastUsed", Value: 1}}},
+	}
+	rollupIndexes = []mongo.IndexModel{
+		{Keys: bson.D{{Key: "start", Value: 1}}},
+	}
+)
+
+// EnsureIndexes creates the indexes of all collections, writes do not create
+// them. The bot and the webapp call it on start. The analytics retention
+// and dateUsed indexes depend on the bot config and are kept by
+// EnsureAnalyticsRetention.
+func (m MongoDB) EnsureIndexes(ctx context.Context) error {
+	for _, v := range []struct {
+		collection *mongo.Collection
+		indexes    []mongo.IndexModel
+	}{
+		{m.sessionsCollection, sessionIndexes},
+		{m.draftsCollection, draftIndexes},
+		{m.pendingMediaCollection, pendingMediaIndexes},
+		{m.settingsAuditCollection, settingsAuditIndexes},
+		{m.analyticsCollection, analyticsIndexes},
+		{m.tagsDailyCollection, tagsDailyIndexes},
+		{m.tagsMonthlyCollection, rollupIndexes},
+		{m.postsDailyCollection, rollupIndexes},
+		{m.postsMonthlyCollection, rollupIndexes},
+	} {
+		_, err := v.collection.Indexes().CreateMany(ctx, v.indexes)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
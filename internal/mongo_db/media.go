@@ -0,0 +1,65 @@
+package mongo_db
+
+import (
+	"context"
+	"ratatoskr/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// pendingMediaTTL is how long previews stay available after the media was
+// sent, posts are usually tagged within minutes.
+const pendingMediaTTL = time.Hour * 48
+
+func (m MongoDB) RegisterPendingMedia(ctx context.Context, media *[]models.PendingMedia) error {
+	if len(*media) == 0 {
+		return nil
+	}
+	writes := []mongo.WriteModel{}
+	for _, v := range *media {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"chatId": v.ChatID, "messageId": v.MessageID}).
+			SetUpdate(bson.M{
+				"$set":         bson.M{"kind": v.Kind, "fileId": v.FileID},
+				"$currentDate": bson.M{"createdAt": true},
+			}).
+			SetUpsert(true))
+	}
+	_, err := m.pendingMediaCollection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// GetPendingMedia returns the registered media among messageIDs, in the
+// order they were asked for.
+func (m MongoDB) GetPendingMedia(
+	ctx context.Context,
+	chatID int64,
+	messageIDs []int64,
+) (*[]models.PendingMedia, error) {
+	c, err := m.pendingMediaCollection.Find(ctx, bson.M{
+		"chatId":    chatID,
+		"messageId": bson.M{"$in": messageIDs},
+	})
+	if err != nil {
+		return nil, err
+	}
+	found := []models.PendingMedia{}
+	err = c.All(ctx, &found)
+	if err != nil {
+		return nil, err
+	}
+	byID := map[int64]models.PendingMedia{}
+	for _, v := range found {
+		byID[v.MessageID] = v
+	}
+	res := []models.PendingMedia{}
+	for _, id := range messageIDs {
+		if v, ok := byID[id]; ok {
+			res = append(res, v)
+		}
+	}
+	return &res, nil
+}
@@ -0,0 +1,177 @@
+package mongo_db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	menuStateID      = "menu"
+	menuReadAttempts = 3
+)
+
+// groupDocument is a group of one menu generation. A new menu is written
+// under a new generation first and the menu state is switched to it in one
+// update, so readers see either the old or the new menu, never a mix.
+type groupDocument struct {
+	models.Group `bson:",inline"`
+	Generation   string `bson:"generation"`
+}
+
+// GetTagMenu reads the groups together with the menu version, retrying when
+// the menu changes while it is being read.
+func (m MongoDB) GetTagMenu(ctx context.Context) (*models.TagMenu, error) {
+	for range menuReadAttempts {
+		before, err := m.menuState(ctx)
+		if err != nil {
+			return nil, err
+		}
+		groups, err := m.readGroups(ctx, before)
+		if err != nil {
+			return nil, err
+		}
+		after, err := m.menuState(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if before.Version == after.Version {
+			return &models.TagMenu{Version: after.Version, Groups: groups}, nil
+		}
+	}
+	return nil, db.ErrMenuConflict
+}
+
+// GetTagMenuVersion reads only the menu version, so cached copies of the
+// menu can be checked cheaply.
+func (m MongoDB) GetTagMenuVersion(ctx context.Context) (int64, error) {
+	state, err := m.menuState(ctx)
+	return state.Version, err
+}
+
+// ReplaceTagMenu stores groups only if the menu is still at the given
+// version and returns the new version. Of two concurrent editors only one
+// gets to switch the menu to its groups.
+func (m MongoDB) ReplaceTagMenu(
+	ctx context.Context,
+	version int64,
+	groups *[]models.Group,
+) (int64, error) {
+	return m.saveMenu(ctx, &version, groups)
+}
+
+func (m MongoDB) UpdateTags(ctx context.Context, g *[]models.Group) error {
+	_, err := m.saveMenu(ctx, nil, g)
+	return err
+}
+
+// saveMenu writes groups as a new generation, switches the menu state to it
+// and then removes the generation it replaced. An empty menu is a generation
+// without groups.
+func (m MongoDB) saveMenu(ctx context.Context, expected *int64, groups *[]models.Group) (int64, error) {
+	generation := primitive.NewObjectID().Hex()
+	if len(*groups) != 0 {
+		docs := make([]interface{}, len(*groups))
+		for i, g := range *groups {
+			g.ID = primitive.NilObjectID
+			docs[i] = groupDocument{Group: g, Generation: generation}
+		}
+		_, err := m.tagsCollection.InsertMany(ctx, docs)
+		if err != nil {
+			return 0, errors.Join(err, m.deleteGeneration(ctx, generation))
+		}
+	}
+	previous, err := m.bumpMenuVersion(ctx, expected, &generation)
+	if err != nil {
+		return 0, errors.Join(err, m.deleteGeneration(ctx, generation))
+	}
+	err = m.deleteGeneration(ctx, previous.Generation)
+	if err != nil {
+		return previous.Version + 1, fmt.Errorf("failed to remove the previous menu: %w", err)
+	}
+	return previous.Version + 1, nil
+}
+
+func (m MongoDB) readGroups(ctx context.Context, state models.TagMenuState) ([]models.Group, error) {
+	c, err := m.tagsCollection.Find(ctx, generationFilter(state.Generation))
+	if err != nil {
+		return nil, err
+	}
+	var res []models.Group
+	err = c.All(ctx, &res)
+	if err != nil {
+		return nil, err
+	}
+	return fixSorting(res), nil
+}
+
+func (m MongoDB) deleteGeneration(ctx context.Context, generation string) error {
+	_, err := m.tagsCollection.DeleteMany(ctx, generationFilter(generation))
+	return err
+}
+
+// generationFilter matches the groups of a generation. Menus saved before
+// generations were introduced have none.
+func generationFilter(generation string) bson.M {
+	if generation == "" {
+		return bson.M{"generation": bson.M{"$exists": false}}
+	}
+	return bson.M{"generation": generation}
+}
+
+func (m MongoDB) menuState(ctx context.Context) (models.TagMenuState, error) {
+	var state models.TagMenuState
+	err := m.menuStateCollection.FindOne(ctx, bson.M{"_id": menuStateID}).Decode(&state)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.TagMenuState{}, nil
+	}
+	return state, err
+}
+
+// bumpMenuVersion increments the menu version and, with generation set,
+// switches the menu to that generation. With expected set the increment only
+// happens at that version, otherwise db.ErrMenuConflict is returned. It
+// returns the state before the increment.
+func (m MongoDB) bumpMenuVersion(
+	ctx context.Context,
+	expected *int64,
+	generation *string,
+) (models.TagMenuState, error) {
+	filter := bson.M{"_id": menuStateID}
+	if expected != nil {
+		filter["version"] = *expected
+	}
+	set := bson.M{"updatedAt": time.Now()}
+	if generation != nil {
+		set["generation"] = *generation
+	}
+	upsert := expected == nil || *expected == 0
+	var state models.TagMenuState
+	err := m.menuStateCollection.FindOneAndUpdate(
+		ctx,
+		filter,
+		bson.M{"$inc": bson.M{"version": 1}, "$set": set},
+		options.FindOneAndUpdate().
+			SetUpsert(upsert).
+			SetReturnDocument(options.Before),
+	).Decode(&state)
+	// an upsert that inserted the state has no previous document
+	if upsert && errors.Is(err, mongo.ErrNoDocuments) {
+		return models.TagMenuState{}, nil
+	}
+	if expected != nil && (errors.Is(err, mongo.ErrNoDocuments) || mongo.IsDuplicateKeyError(err)) {
+		return models.TagMenuState{}, db.ErrMenuConflict
+	}
+	if err != nil {
+		return models.TagMenuState{}, err
+	}
+	return state, nil
+}
@@ -0,0 +1,20 @@
+package mongo_db
+
+import (
+	"context"
+	"ratatoskr/internal/metrics"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// commandMonitor records the latency of every command sent to MongoDB.
+func commandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			metrics.DBOperationDuration.Observe(e.Duration.Seconds(), e.CommandName, "ok")
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			metrics.DBOperationDuration.Observe(e.Duration.Seconds(), e.CommandName, "error")
+		},
+	}
+}
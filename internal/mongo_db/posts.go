@@ -0,0 +1,70 @@
+package mongo_db
+
+import (
+	"context"
+	"ratatoskr/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GetRecentPosts rebuilds posts from their analytics events, newest first.
+// Legacy events without channel message ids cannot be told apart and are
+// left out, as are posts older than the analytics retention window.
+func (m MongoDB) GetRecentPosts(
+	ctx context.Context,
+	since time.Time,
+	limit int,
+) (*[]models.Post, error) {
+	c, err := m.analyticsCollection.Aggregate(ctx, recentPostsPipeline(since, limit))
+	if err != nil {
+		return nil, err
+	}
+	res := []models.Post{}
+	err = c.All(ctx, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func recentPostsPipeline(since time.Time, limit int) mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"dateUsed":          bson.M{"$gte": since},
+			"channelMessageIds": bson.M{"$exists": true},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "dateUsed", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{
+				{Key: "chat", Value: "$destinationChatId"},
+				{Key: "post", Value: "$channelMessageIds"},
+			}},
+			{Key: "userId", Value: bson.M{"$first": "$userId"}},
+			{Key: "mediaKind", Value: bson.M{"$first": "$mediaKind"}},
+			{Key: "itemCount", Value: bson.M{"$max": "$itemCount"}},
+			{Key: "postingMode", Value: bson.M{"$first": "$postingMode"}},
+			{Key: "correlationId", Value: bson.M{"$first": "$correlationId"}},
+			{Key: "date", Value: bson.M{"$min": "$dateUsed"}},
+			{Key: "tags", Value: bson.M{"$push": bson.D{
+				{Key: "tag", Value: "$tag"},
+				{Key: "group", Value: "$group"},
+			}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "date", Value: -1}}}},
+		{{Key: "$limit", Value: limit}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "destinationChatId", Value: "$_id.chat"},
+			{Key: "channelMessageIds", Value: "$_id.post"},
+			{Key: "userId", Value: 1},
+			{Key: "mediaKind", Value: 1},
+			{Key: "itemCount", Value: 1},
+			{Key: "postingMode", Value: 1},
+			{Key: "date", Value: 1},
+			{Key: "tags", Value: 1},
+			{Key: "correlationId", Value: 1},
+		}}},
+	}
+}
@@ -0,0 +1,446 @@
+package mongo_db
+
+import (
+	"context"
+	"errors"
+	"ratatoskr/internal/models"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	rollupStateID         = "analytics"
+	retentionIndexName    = "dateUsed_ttl"
+	dateUsedIndexName     = "dateUsed_1"
+	indexOptionsConflict  = 85
+	indexNotFoundCode     = 27
+	namespaceNotFoundCode = 26
+)
+
+// RollupLookback is how far behind the previous run raw events are
+// re-aggregated, so events written late (e.g. by a retried outbox flush)
+// still land in their day. Retention has to be longer than this.
+const RollupLookback = time.Hour * 72
+
+type rollupState struct {
+	ID      string    `bson:"_id"`
+	LastRun time.Time `bson:"lastRun"`
+}
+
+type rollupRange struct {
+	monthly bool
+	from    time.Time
+	to      time.Time
+}
+
+func (m MongoDB) RollupAnalytics(ctx context.Context, now time.Time) error {
+	var state rollupState
+	err := m.rollupStateCollection.FindOne(ctx, bson.M{"_id": rollupStateID}).Decode(&state)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+	since := time.Time{}
+	if !state.LastRun.IsZero() {
+		since = startOfDay(state.LastRun.Add(-RollupLookback))
+	}
+	pipelines := []struct {
+		collection *mongo.Collection
+		pipeline   mongo.Pipeline
+	}{
+		{m.analyticsCollection, dailyTagsPipeline(since, now, m.tagsDailyCollection.Name())},
+		{m.analyticsCollection, dailyPostsPipeline(since, now, m.postsDailyCollection.Name())},
+		{m.tagsDailyCollection, monthlyTagsPipeline(since, m.tagsMonthlyCollection.Name())},
+		{m.postsDailyCollection, monthlyPostsPipeline(since, m.postsMonthlyCollection.Name())},
+	}
+	for _, p := range pipelines {
+		c, err := p.collection.Aggregate(ctx, p.pipeline)
+		if err != nil {
+			return err
+		}
+		c.Close(ctx)
+	}
+	_, err = m.rollupStateCollection.UpdateOne(
+		ctx,
+		bson.M{"_id": rollupStateID},
+		bson.M{"$set": bson.M{"lastRun": now}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// EnsureAnalyticsRetention keeps raw analytics for the given duration through
+// a TTL index on dateUsed. Zero retention replaces it with a plain index on
+// dateUsed and keeps raw events forever.
+func (m MongoDB) EnsureAnalyticsRetention(ctx context.Context, retention time.Duration) error {
+	if retention == 0 {
+		err := m.dropAnalyticsIndex(ctx, retentionIndexName)
+		if err != nil {
+			return err
+		}
+		_, err = m.analyticsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "dateUsed", Value: 1}},
+			Options: options.Index().SetName(dateUsedIndexName),
+		})
+		return err
+	}
+	err := m.dropAnalyticsIndex(ctx, dateUsedIndexName)
+	if err != nil {
+		return err
+	}
+	seconds := int32(retention.Seconds())
+	_, err = m.analyticsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "dateUsed", Value: 1}},
+		Options: options.Index().
+			SetName(retentionIndexName).
+			SetExpireAfterSeconds(seconds),
+	})
+	if !hasErrorCode(err, indexOptionsConflict) {
+		return err
+	}
+	return m.db.RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: m.analyticsCollection.Name()},
+		{Key: "index", Value: bson.D{
+			{Key: "name", Value: retentionIndexName},
+			{Key: "expireAfterSeconds", Value: seconds},
+		}},
+	}).Err()
+}
+
+func (m MongoDB) dropAnalyticsIndex(ctx context.Context, name string) error {
+	_, err := m.analyticsCollection.Indexes().DropOne(ctx, name)
+	if hasErrorCode(err, indexNotFoundCode, namespaceNotFoundCode) {
+		return nil
+	}
+	return err
+}
+
+func (m MongoDB) readTagRollups(
+	ctx context.Context,
+	from time.Time,
+	to time.Time,
+) ([]models.TagRollup, error) {
+	res := []models.TagRollup{}
+	for _, r := range splitRange(from, to) {
+		collection := m.tagsDailyCollection
+		if r.monthly {
+			collection = m.tagsMonthlyCollection
+		}
+		c, err := collection.Find(ctx, rangeFilter(r))
+		if err != nil {
+			return nil, err
+		}
+		var rollups []models.TagRollup
+		err = c.All(ctx, &rollups)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, rollups...)
+	}
+	return res, nil
+}
+
+func (m MongoDB) readPostRollups(
+	ctx context.Context,
+	from time.Time,
+	to time.Time,
+) ([]models.PostRollup, error) {
+	res := []models.PostRollup{}
+	for _, r := range splitRange(from, to) {
+		collection := m.postsDailyCollection
+		if r.monthly {
+			collection = m.postsMonthlyCollection
+		}
+		c, err := collection.Find(ctx, rangeFilter(r))
+		if err != nil {
+			return nil, err
+		}
+		var rollups []models.PostRollup
+		err = c.All(ctx, &rollups)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, rollups...)
+	}
+	return res, nil
+}
+
+// GetDailyPosts returns daily post rollups of every admin for the days
+// touched by [from, to).
+func (m MongoDB) GetDailyPosts(
+	ctx context.Context,
+	from time.Time,
+	to time.Time,
+) (*[]models.PostRollup, error) {
+	end := startOfDay(to)
+	if end.Before(to) {
+		end = end.AddDate(0, 0, 1)
+	}
+	c, err := m.postsDailyCollection.Find(
+		ctx,
+		rangeFilter(rollupRange{from: startOfDay(from), to: end}),
+		options.Find().SetSort(bson.D{{Key: "start", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	res := []models.PostRollup{}
+	err = c.All(ctx, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func rangeFilter(r rollupRange) bson.M {
+	return bson.M{"start": bson.M{"$gte": r.from, "$lt": r.to}}
+}
+
+// splitRange covers the days touched by [from, to) with monthly rollups for
+// every whole month and daily rollups for the remaining edges.
+func splitRange(from time.Time, to time.Time) []rollupRange {
+	start := startOfDay(from)
+	end := startOfDay(to)
+	if end.Before(to) {
+		end = end.AddDate(0, 0, 1)
+	}
+	if !start.Before(end) {
+		return []rollupRange{}
+	}
+	firstMonth := startOfMonth(start)
+	if firstMonth.Before(start) {
+		firstMonth = firstMonth.AddDate(0, 1, 0)
+	}
+	lastMonth := startOfMonth(end)
+	if !firstMonth.Before(lastMonth) {
+		return []rollupRange{{from: start, to: end}}
+	}
+	ranges := []rollupRange{}
+	if start.Before(firstMonth) {
+		ranges = append(ranges, rollupRange{from: start, to: firstMonth})
+	}
+	ranges = append(ranges, rollupRange{monthly: true, from: firstMonth, to: lastMonth})
+	if lastMonth.Before(end) {
+		ranges = append(ranges, rollupRange{from: lastMonth, to: end})
+	}
+	return ranges
+}
+
+func aggregateAdminAnalytics(
+	tags []models.TagRollup,
+	posts []models.PostRollup,
+) []models.AdminAnalytics {
+	type tagKey struct {
+		group string
+		tag   string
+	}
+	type admin struct {
+		analytics models.AdminAnalytics
+		tags      map[tagKey]int
+	}
+	admins := map[int64]*admin{}
+	get := func(userID int64) *admin {
+		a, ok := admins[userID]
+		if !ok {
+			a = &admin{
+				analytics: models.AdminAnalytics{UserID: userID},
+				tags:      map[tagKey]int{},
+			}
+			admins[userID] = a
+		}
+		return a
+	}
+	for _, r := range tags {
+		a := get(r.UserID)
+		a.analytics.Total += r.Count
+		a.tags[tagKey{group: r.Group, tag: r.Tag}] += r.Count
+		if r.LastUsed.After(a.analytics.LastUsed) {
+			a.analytics.LastUsed = r.LastUsed
+		}
+	}
+	for _, r := range posts {
+		get(r.UserID).analytics.Posts += r.Posts
+	}
+	res := []models.AdminAnalytics{}
+	for _, a := range admins {
+		a.analytics.Tags = []models.TagUsage{}
+		for k, count := range a.tags {
+			a.analytics.Tags = append(a.analytics.Tags, models.TagUsage{
+				Tag:   k.tag,
+				Group: k.group,
+				Count: count,
+			})
+		}
+		slices.SortFunc(a.analytics.Tags, func(x, y models.TagUsage) int {
+			if x.Count != y.Count {
+				return y.Count - x.Count
+			}
+			if x.Tag < y.Tag {
+				return -1
+			}
+			if x.Tag > y.Tag {
+				return 1
+			}
+			return 0
+		})
+		res = append(res, a.analytics)
+	}
+	slices.SortFunc(res, func(x, y models.AdminAnalytics) int {
+		if x.Total != y.Total {
+			return y.Total - x.Total
+		}
+		if x.UserID < y.UserID {
+			return -1
+		}
+		if x.UserID > y.UserID {
+			return 1
+		}
+		return 0
+	})
+	return res
+}
+
+func dailyTagsPipeline(since time.Time, until time.Time, into string) mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"dateUsed": bson.M{"$gte": since, "$lt": until}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{
+				{Key: "start", Value: dayExpr("$dateUsed")},
+				{Key: "userId", Value: bson.M{"$ifNull": bson.A{"$userId", int64(0)}}},
+				{Key: "group", Value: "$group"},
+				{Key: "tag", Value: "$tag"},
+			}},
+			{Key: "count", Value: bson.M{"$sum": 1}},
+			{Key: "lastUsed", Value: bson.M{"$max": "$dateUsed"}},
+		}}},
+		tagsProjection(),
+		mergeInto(into),
+	}
+}
+
+func monthlyTagsPipeline(since time.Time, into string) mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"start": bson.M{"$gte": startOfMonth(since)}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{
+				{Key: "start", Value: monthExpr("$start")},
+				{Key: "userId", Value: "$userId"},
+				{Key: "group", Value: "$group"},
+				{Key: "tag", Value: "$tag"},
+			}},
+			{Key: "count", Value: bson.M{"$sum": "$count"}},
+			{Key: "lastUsed", Value: bson.M{"$max": "$lastUsed"}},
+		}}},
+		tagsProjection(),
+		mergeInto(into),
+	}
+}
+
+func dailyPostsPipeline(since time.Time, until time.Time, into string) mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"dateUsed":          bson.M{"$gte": since, "$lt": until},
+			"channelMessageIds": bson.M{"$exists": true},
+		}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{
+				{Key: "start", Value: dayExpr("$dateUsed")},
+				{Key: "userId", Value: bson.M{"$ifNull": bson.A{"$userId", int64(0)}}},
+				{Key: "chat", Value: "$destinationChatId"},
+				{Key: "post", Value: "$channelMessageIds"},
+			}},
+			{Key: "items", Value: bson.M{"$max": "$itemCount"}},
+		}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{
+				{Key: "start", Value: "$_id.start"},
+				{Key: "userId", Value: "$_id.userId"},
+			}},
+			{Key: "posts", Value: bson.M{"$sum": 1}},
+			{Key: "items", Value: bson.M{"$sum": "$items"}},
+		}}},
+		postsProjection(),
+		mergeInto(into),
+	}
+}
+
+func monthlyPostsPipeline(since time.Time, into string) mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"start": bson.M{"$gte": startOfMonth(since)}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{
+				{Key: "start", Value: monthExpr("$start")},
+				{Key: "userId", Value: "$userId"},
+			}},
+			{Key: "posts", Value: bson.M{"$sum": "$posts"}},
+			{Key: "items", Value: bson.M{"$sum": "$items"}},
+		}}},
+		postsProjection(),
+		mergeInto(into),
+	}
+}
+
+func tagsProjection() bson.D {
+	return bson.D{{Key: "$project", Value: bson.D{
+		{Key: "start", Value: "$_id.start"},
+		{Key: "userId", Value: "$_id.userId"},
+		{Key: "group", Value: "$_id.group"},
+		{Key: "tag", Value: "$_id.tag"},
+		{Key: "count", Value: 1},
+		{Key: "lastUsed", Value: 1},
+	}}}
+}
+
+func postsProjection() bson.D {
+	return bson.D{{Key: "$project", Value: bson.D{
+		{Key: "start", Value: "$_id.start"},
+		{Key: "userId", Value: "$_id.userId"},
+		{Key: "posts", Value: 1},
+		{Key: "items", Value: 1},
+	}}}
+}
+
+func mergeInto(collection string) bson.D {
+	return bson.D{{Key: "$merge", Value: bson.D{
+		{Key: "into", Value: collection},
+		{Key: "on", Value: "_id"},
+		{Key: "whenMatched", Value: "replace"},
+		{Key: "whenNotMatched", Value: "insert"},
+	}}}
+}
+
+func dayExpr(field string) bson.M {
+	return bson.M{"$dateFromParts": bson.D{
+		{Key: "year", Value: bson.M{"$year": field}},
+		{Key: "month", Value: bson.M{"$month": field}},
+		{Key: "day", Value: bson.M{"$dayOfMonth": field}},
+	}}
+}
+
+func monthExpr(field string) bson.M {
+	return bson.M{"$dateFromParts": bson.D{
+		{Key: "year", Value: bson.M{"$year": field}},
+		{Key: "month", Value: bson.M{"$month": field}},
+	}}
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func startOfMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func hasErrorCode(err error, codes ...int) bool {
+	var commandErr mongo.CommandError
+	if !errors.As(err, &commandErr) {
+		return false
+	}
+	return slices.Contains(codes, int(commandErr.Code))
+}
@@ -0,0 +1,116 @@
+package mongo_db
+
+import (
+	"ratatoskr/internal/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSplitRange(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	type tc struct {
+		name     string
+		from     time.Time
+		to       time.Time
+		expected []rollupRange
+	}
+
+	table := []tc{
+		{
+			name:     "should use daily rollups inside one month",
+			from:     date(2024, 3, 5),
+			to:       date(2024, 3, 20),
+			expected: []rollupRange{{from: date(2024, 3, 5), to: date(2024, 3, 20)}},
+		},
+
+		{
+			name: "should include the day of a partial end",
+			from: date(2024, 3, 5).Add(time.Hour * 5),
+			to:   date(2024, 3, 20).Add(time.Hour),
+			expected: []rollupRange{
+				{from: date(2024, 3, 5), to: date(2024, 3, 21)},
+			},
+		},
+
+		{
+			name: "should use monthly rollups for whole months",
+			from: date(2024, 1, 20),
+			to:   date(2024, 5, 3),
+			expected: []rollupRange{
+				{from: date(2024, 1, 20), to: date(2024, 2, 1)},
+				{monthly: true, from: date(2024, 2, 1), to: date(2024, 5, 1)},
+				{from: date(2024, 5, 1), to: date(2024, 5, 3)},
+			},
+		},
+
+		{
+			name: "should not add empty daily edges",
+			from: date(2024, 1, 1),
+			to:   date(2024, 3, 1),
+			expected: []rollupRange{
+				{monthly: true, from: date(2024, 1, 1), to: date(2024, 3, 1)},
+			},
+		},
+
+		{
+			name:     "should return nothing for empty range",
+			from:     date(2024, 3, 5),
+			to:       date(2024, 3, 5),
+			expected: []rollupRange{},
+		},
+	}
+
+	for _, test := range table {
+		actual := splitRange(test.from, test.to)
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf(
+				"%s - wrong ranges\nexpected: %+v\nactual:   %+v",
+				test.name,
+				test.expected,
+				actual,
+			)
+		}
+	}
+}
+
+func TestAggregateAdminAnalytics(t *testing.T) {
+	day := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	tags := []models.TagRollup{
+		{Start: day, UserID: 1, Group: "Group 1", Tag: "#tag1", Count: 2, LastUsed: day.Add(time.Hour)},
+		{Start: day, UserID: 1, Group: "Group 1", Tag: "#tag2", Count: 1, LastUsed: day},
+		{Start: day.AddDate(0, 0, 1), UserID: 1, Group: "Group 1", Tag: "#tag2", Count: 3, LastUsed: day.AddDate(0, 0, 1)},
+		{Start: day, UserID: 2, Group: "Group 2", Tag: "#tag3", Count: 1, LastUsed: day},
+	}
+	posts := []models.PostRollup{
+		{Start: day, UserID: 1, Posts: 2, Items: 4},
+		{Start: day.AddDate(0, 0, 1), UserID: 1, Posts: 3, Items: 3},
+		{Start: day, UserID: 2, Posts: 1, Items: 1},
+	}
+	expected := []models.AdminAnalytics{
+		{
+			UserID:   1,
+			Total:    6,
+			Posts:    5,
+			LastUsed: day.AddDate(0, 0, 1),
+			Tags: []models.TagUsage{
+				{Tag: "#tag2", Group: "Group 1", Count: 4},
+				{Tag: "#tag1", Group: "Group 1", Count: 2},
+			},
+		},
+		{
+			UserID:   2,
+			Total:    1,
+			Posts:    1,
+			LastUsed: day,
+			Tags:     []models.TagUsage{{Tag: "#tag3", Group: "Group 2", Count: 1}},
+		},
+	}
+	actual := aggregateAdminAnalytics(tags, posts)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("wrong admin analytics\nexpected: %+v\nactual:   %+v", expected, actual)
+	}
+}
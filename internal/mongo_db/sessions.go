@@ -0,0 +1,47 @@
+package mongo_db
+
+import (
+	"context"
+	"errors"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (m MongoDB) InsertTaggingSession(ctx context.Context, s *models.TaggingSession) error {
+	_, err := m.sessionsCollection.InsertOne(ctx, s)
+	return err
+}
+
+func (m MongoDB) GetTaggingSession(ctx context.Context, id string) (*models.TaggingSession, error) {
+	var s models.TaggingSession
+	err := m.sessionsCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&s)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, db.ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (m MongoDB) DeleteTaggingSession(ctx context.Context, id string) error {
+	_, err := m.sessionsCollection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+// ClaimTaggingSession deletes the session and returns it, so of concurrent
+// callers only one gets it.
+func (m MongoDB) ClaimTaggingSession(ctx context.Context, id string) (*models.TaggingSession, error) {
+	var s models.TaggingSession
+	err := m.sessionsCollection.FindOneAndDelete(ctx, bson.M{"_id": id}).Decode(&s)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, db.ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
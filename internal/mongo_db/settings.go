@@ -0,0 +1,128 @@
+package mongo_db
+
+import (
+	"context"
+	"errors"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const settingsID = "settings"
+
+// settingsDocument keeps the ID of the change that produced the settings, so
+// that change is listed even if it was never marked applied.
+type settingsDocument struct {
+	models.Settings `bson:",inline"`
+	LastChangeID    primitive.ObjectID `bson:"lastChangeId,omitempty"`
+}
+
+// settingChangeDocument is written before the settings it describes and
+// marked applied after them. Records without applied predate this.
+type settingChangeDocument struct {
+	ID                   primitive.ObjectID `bson:"_id"`
+	models.SettingChange `bson:",inline"`
+	Applied              bool `bson:"applied"`
+}
+
+func (m MongoDB) GetSettings(ctx context.Context) (*models.Settings, error) {
+	var s models.Settings
+	err := m.settingsCollection.FindOne(ctx, bson.M{"_id": settingsID}).Decode(&s)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, db.ErrSettingsNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SaveSettings replaces the settings only if they are still at the given
+// version and records the change. Version 0 means the settings were never
+// saved, of two admins saving them first only one can insert them.
+//
+// Without a transaction the change is written first, then the settings
+// pointing to it, so settings are never live without their record. The
+// record is marked applied afterwards, failing that does not fail the save:
+// the settings still point to it and the next save marks it again.
+func (m MongoDB) SaveSettings(
+	ctx context.Context,
+	version int64,
+	settings *models.Settings,
+	change *models.SettingChange,
+) (int64, error) {
+	changeID := primitive.NewObjectID()
+	_, err := m.settingsAuditCollection.InsertOne(ctx, settingChangeDocument{
+		ID:            changeID,
+		SettingChange: *change,
+	})
+	if err != nil {
+		return 0, err
+	}
+	next := settingsDocument{Settings: *settings, LastChangeID: changeID}
+	next.Version = version + 1
+	var previous settingsDocument
+	err = m.settingsCollection.FindOneAndReplace(
+		ctx,
+		bson.M{"_id": settingsID, "version": version},
+		next,
+		options.FindOneAndReplace().
+			SetUpsert(version == 0).
+			SetReturnDocument(options.Before),
+	).Decode(&previous)
+	// an upsert has no document before it
+	inserted := version == 0 && errors.Is(err, mongo.ErrNoDocuments)
+	if mongo.IsDuplicateKeyError(err) || (errors.Is(err, mongo.ErrNoDocuments) && !inserted) {
+		// best effort, an unapplied record that is not pointed to is not listed
+		m.settingsAuditCollection.DeleteOne(ctx, bson.M{"_id": changeID})
+		return 0, db.ErrSettingsConflict
+	}
+	if err != nil && !inserted {
+		return 0, err
+	}
+	applied := bson.A{changeID}
+	if !previous.LastChangeID.IsZero() {
+		applied = append(applied, previous.LastChangeID)
+	}
+	// the save is done either way, see above
+	m.settingsAuditCollection.UpdateMany(
+		ctx,
+		bson.M{"_id": bson.M{"$in": applied}},
+		bson.M{"$set": bson.M{"applied": true}},
+	)
+	return next.Version, nil
+}
+
+// GetSettingChanges returns the latest changes first. Changes of saves that
+// failed are left out.
+func (m MongoDB) GetSettingChanges(ctx context.Context, limit int) (*[]models.SettingChange, error) {
+	var current settingsDocument
+	err := m.settingsCollection.FindOne(ctx, bson.M{"_id": settingsID}).Decode(&current)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+	filter := bson.A{bson.M{"applied": bson.M{"$ne": false}}}
+	if !current.LastChangeID.IsZero() {
+		filter = append(filter, bson.M{"_id": current.LastChangeID})
+	}
+	c, err := m.settingsAuditCollection.Find(
+		ctx,
+		bson.M{"$or": filter},
+		options.Find().
+			SetSort(bson.D{{Key: "date", Value: -1}, {Key: "_id", Value: -1}}).
+			SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	res := []models.SettingChange{}
+	err = c.All(ctx, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
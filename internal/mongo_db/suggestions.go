@@ -0,0 +1,77 @@
+package mongo_db
+
+import (
+	"context"
+	"ratatoskr/internal/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GetTagCooccurrence ranks tags by how often they were posted together with
+// the given ones since the given date. Count is the number of selected tags a
+// candidate shared a post with, summed over all such posts. Rollups do not
+// keep which tags were posted together, so only raw events within the
+// analytics retention window count.
+func (m MongoDB) GetTagCooccurrence(
+	ctx context.Context,
+	tags []string,
+	since time.Time,
+) (*[]models.TagUsage, error) {
+	res := []models.TagUsage{}
+	if len(tags) == 0 {
+		return &res, nil
+	}
+	c, err := m.analyticsCollection.Aggregate(ctx, cooccurrencePipeline(tags, since))
+	if err != nil {
+		return nil, err
+	}
+	err = c.All(ctx, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Posts are identified by the copied channel messages. Legacy events without
+// them were inserted together, so their timestamp stands in for the post.
+func cooccurrencePipeline(tags []string, since time.Time) mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"dateUsed": bson.M{"$gte": since}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{
+				{Key: "chat", Value: "$destinationChatId"},
+				{Key: "post", Value: bson.M{"$ifNull": bson.A{
+					"$channelMessageIds",
+					bson.M{"$dateToString": bson.M{
+						"date":   "$dateUsed",
+						"format": "%Y-%m-%dT%H:%M:%S",
+					}},
+				}}},
+			}},
+			{Key: "tags", Value: bson.M{"$addToSet": bson.D{
+				{Key: "tag", Value: "$tag"},
+				{Key: "group", Value: "$group"},
+			}}},
+		}}},
+		{{Key: "$match", Value: bson.M{"tags.tag": bson.M{"$in": tags}}}},
+		{{Key: "$addFields", Value: bson.M{
+			"overlap": bson.M{"$size": bson.M{"$setIntersection": bson.A{"$tags.tag", tags}}},
+		}}},
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$match", Value: bson.M{"tags.tag": bson.M{"$nin": tags}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$tags.tag"},
+			{Key: "group", Value: bson.M{"$first": "$tags.group"}},
+			{Key: "count", Value: bson.M{"$sum": "$overlap"}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "tag", Value: "$_id"},
+			{Key: "group", Value: 1},
+			{Key: "count", Value: 1},
+		}}},
+	}
+}
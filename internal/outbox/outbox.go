@@ -0,0 +1,303 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/logger"
+	"ratatoskr/internal/metrics"
+	"ratatoskr/internal/models"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultInterval   = time.Second * 5
+	defaultMaxBackoff = time.Minute * 5
+	flushTimeout      = time.Second * 30
+	flushingSuffix    = ".flushing"
+)
+
+type Stats struct {
+	Enqueued      int64
+	Flushed       int64
+	FailedFlushes int64
+	Pending       int64
+}
+
+// AnalyticsOutbox wraps a db.DB so that InsertAnalytics only appends events to
+// a local file. Events are written to the wrapped database in the background
+// by Run, which keeps retrying until the database accepts them.
+type AnalyticsOutbox struct {
+	db.DB
+	logger     *logger.Logger
+	path       string
+	interval   time.Duration
+	maxBackoff time.Duration
+
+	mu   sync.Mutex
+	file *os.File
+
+	flushMu       sync.Mutex
+	enqueued      atomic.Int64
+	flushed       atomic.Int64
+	failedFlushes atomic.Int64
+	pending       atomic.Int64
+}
+
+func NewAnalyticsOutbox(
+	database db.DB,
+	logger *logger.Logger,
+	path string,
+) (*AnalyticsOutbox, error) {
+	o := &AnalyticsOutbox{
+		DB:         database,
+		logger:     logger,
+		path:       path,
+		interval:   defaultInterval,
+		maxBackoff: defaultMaxBackoff,
+	}
+	file, err := openAppend(path)
+	if err != nil {
+		return nil, err
+	}
+	o.file = file
+	pending, err := countEvents(path)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	flushing, err := countEvents(path + flushingSuffix)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	o.setPending(pending + flushing)
+	return o, nil
+}
+
+func (o *AnalyticsOutbox) InsertAnalytics(_ context.Context, a *[]models.Analytics) error {
+	if len(*a) == 0 {
+		return nil
+	}
+	buf := []byte{}
+	for i := range *a {
+		event := (*a)[i]
+		if event.ID.IsZero() {
+			event.ID = primitive.NewObjectID()
+		}
+		line, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.file == nil {
+		return fmt.Errorf("analytics outbox is closed")
+	}
+	if _, err := o.file.Write(buf); err != nil {
+		return fmt.Errorf("failed to write analytics outbox: %w", err)
+	}
+	if err := o.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync analytics outbox: %w", err)
+	}
+	o.enqueued.Add(int64(len(*a)))
+	metrics.AnalyticsEnqueued.Add(float64(len(*a)))
+	metrics.AnalyticsPending.Set(float64(o.pending.Add(int64(len(*a)))))
+	return nil
+}
+
+// Run flushes the outbox until ctx is cancelled, backing off exponentially
+// while the database keeps failing.
+func (o *AnalyticsOutbox) Run(ctx context.Context) {
+	wait := o.interval
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		c, cancel := context.WithTimeout(ctx, flushTimeout)
+		err := o.Flush(c)
+		cancel()
+		if err == nil {
+			wait = o.interval
+			continue
+		}
+		wait = min(wait*2, o.maxBackoff)
+		stats := o.Stats()
+		o.logger.Error(
+			"failed to flush analytics outbox",
+			"retry_in", wait,
+			"pending", stats.Pending,
+			"failed_flushes", stats.FailedFlushes,
+			"error", err,
+		)
+	}
+}
+
+// Flush moves everything appended so far aside and inserts it into the
+// database. Events stay in the flushing file until the insert succeeds, so a
+// failed flush is retried on the next call, including after a restart.
+func (o *AnalyticsOutbox) Flush(ctx context.Context) error {
+	o.flushMu.Lock()
+	defer o.flushMu.Unlock()
+	flushingPath := o.path + flushingSuffix
+	_, err := os.Stat(flushingPath)
+	if errors.Is(err, os.ErrNotExist) {
+		err = o.rotate(flushingPath)
+	}
+	if err != nil {
+		o.flushFailed()
+		return err
+	}
+	events, err := o.readEvents(flushingPath)
+	if err != nil {
+		o.flushFailed()
+		return err
+	}
+	if len(events) != 0 {
+		err = o.DB.InsertAnalytics(ctx, &events)
+		if err != nil {
+			o.flushFailed()
+			return err
+		}
+	}
+	if err := os.Remove(flushingPath); err != nil {
+		o.flushFailed()
+		return err
+	}
+	o.flushed.Add(int64(len(events)))
+	metrics.AnalyticsFlushed.Add(float64(len(events)))
+	if err := o.recountPending(); err != nil {
+		o.logger.Warning("failed to count pending analytics", "error", err)
+	}
+	if len(events) != 0 {
+		o.logger.Info("flushed analytics events", "count", len(events))
+	}
+	return nil
+}
+
+func (o *AnalyticsOutbox) Close(ctx context.Context) error {
+	err := o.Flush(ctx)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.file == nil {
+		return err
+	}
+	closeErr := o.file.Close()
+	o.file = nil
+	return errors.Join(err, closeErr)
+}
+
+func (o *AnalyticsOutbox) Stats() Stats {
+	return Stats{
+		Enqueued:      o.enqueued.Load(),
+		Flushed:       o.flushed.Load(),
+		FailedFlushes: o.failedFlushes.Load(),
+		Pending:       o.pending.Load(),
+	}
+}
+
+func (o *AnalyticsOutbox) rotate(flushingPath string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.file == nil {
+		return fmt.Errorf("analytics outbox is closed")
+	}
+	if err := o.file.Close(); err != nil {
+		return err
+	}
+	renameErr := os.Rename(o.path, flushingPath)
+	file, err := openAppend(o.path)
+	if err != nil {
+		o.file = nil
+		return errors.Join(renameErr, err)
+	}
+	o.file = file
+	return renameErr
+}
+
+func (o *AnalyticsOutbox) recountPending() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	pending, err := countEvents(o.path)
+	if err != nil {
+		return err
+	}
+	o.setPending(pending)
+	return nil
+}
+
+func (o *AnalyticsOutbox) setPending(pending int64) {
+	o.pending.Store(pending)
+	metrics.AnalyticsPending.Set(float64(pending))
+}
+
+func (o *AnalyticsOutbox) flushFailed() {
+	o.failedFlushes.Add(1)
+	metrics.AnalyticsFlushFailures.Inc()
+}
+
+func (o *AnalyticsOutbox) readEvents(path string) ([]models.Analytics, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	events := []models.Analytics{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event models.Analytics
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			o.logger.Warning(
+				"skipping malformed analytics outbox line",
+				"line", line,
+				"path", path,
+				"error", err,
+			)
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
+
+func openAppend(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+}
+
+func countEvents(path string) (int64, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	var count int64
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) != 0 {
+			count++
+		}
+	}
+	return count, scanner.Err()
+}
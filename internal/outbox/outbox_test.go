@@ -0,0 +1,145 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/logger"
+	"ratatoskr/internal/metrics"
+	"ratatoskr/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fakeLogger() *logger.Logger {
+	return logger.NewLogger(
+		"test logger",
+		&strings.Builder{},
+		logger.Options{},
+	)
+}
+
+type dbMock struct {
+	db.DB
+	fail      bool
+	analytics []models.Analytics
+}
+
+func (m *dbMock) InsertAnalytics(_ context.Context, a *[]models.Analytics) error {
+	if m.fail {
+		return fmt.Errorf("database is down")
+	}
+	m.analytics = append(m.analytics, *a...)
+	return nil
+}
+
+func TestAnalyticsOutbox(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	database := &dbMock{fail: true}
+	enqueued := metrics.AnalyticsEnqueued.Value()
+	flushed := metrics.AnalyticsFlushed.Value()
+	failures := metrics.AnalyticsFlushFailures.Value()
+	o, err := NewAnalyticsOutbox(database, fakeLogger(), path)
+	if err != nil {
+		t.Fatalf("failed to create outbox: %v", err)
+	}
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	err = o.InsertAnalytics(context.Background(), &[]models.Analytics{
+		{Group: "Group 1", Tag: "#tag1", Date: date},
+		{Group: "Group 1", Tag: "#tag2", Date: date},
+	})
+	if err != nil {
+		t.Fatalf("failed to enqueue analytics: %v", err)
+	}
+
+	if err := o.Flush(context.Background()); err == nil {
+		t.Errorf("flush did not report database error")
+	}
+	err = o.InsertAnalytics(context.Background(), &[]models.Analytics{
+		{Group: "Group 2", Tag: "#tag3", Date: date},
+	})
+	if err != nil {
+		t.Fatalf("failed to enqueue analytics: %v", err)
+	}
+	stats := o.Stats()
+	if stats.Pending != 3 || stats.FailedFlushes != 1 || stats.Enqueued != 3 {
+		t.Errorf("unexpected stats after failed flush: %+v", stats)
+	}
+	if metrics.AnalyticsPending.Value() != 3 ||
+		metrics.AnalyticsFlushFailures.Value()-failures != 1 ||
+		metrics.AnalyticsEnqueued.Value()-enqueued != 3 {
+		t.Errorf("outbox metrics do not match stats: %+v", stats)
+	}
+
+	database.fail = false
+	if err := o.Flush(context.Background()); err != nil {
+		t.Errorf("unexpected flush error: %v", err)
+	}
+	if len(database.analytics) != 2 {
+		t.Errorf("retried flush did not insert failed batch: %+v", database.analytics)
+	}
+	if err := o.Flush(context.Background()); err != nil {
+		t.Errorf("unexpected flush error: %v", err)
+	}
+	if len(database.analytics) != 3 {
+		t.Fatalf("did not insert events added during failure: %+v", database.analytics)
+	}
+	for i, tag := range []string{"#tag1", "#tag2", "#tag3"} {
+		actual := database.analytics[i]
+		if actual.Tag != tag || !actual.Date.Equal(date) || actual.ID.IsZero() {
+			t.Errorf("unexpected event %d: %+v", i, actual)
+		}
+	}
+	stats = o.Stats()
+	if stats.Pending != 0 || stats.Flushed != 3 {
+		t.Errorf("unexpected stats after flush: %+v", stats)
+	}
+	if metrics.AnalyticsPending.Value() != 0 || metrics.AnalyticsFlushed.Value()-flushed != 3 {
+		t.Errorf("outbox metrics do not match stats: %+v", stats)
+	}
+	if err := o.Close(context.Background()); err != nil {
+		t.Errorf("unexpected close error: %v", err)
+	}
+}
+
+func TestAnalyticsOutboxSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	database := &dbMock{fail: true}
+	o, err := NewAnalyticsOutbox(database, fakeLogger(), path)
+	if err != nil {
+		t.Fatalf("failed to create outbox: %v", err)
+	}
+	o.InsertAnalytics(context.Background(), &[]models.Analytics{{Tag: "#tag1"}})
+	o.Flush(context.Background())
+	o.InsertAnalytics(context.Background(), &[]models.Analytics{{Tag: "#tag2"}})
+	o.Close(context.Background())
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("failed to open outbox file: %v", err)
+	}
+	file.WriteString("{\"Tag\": \"#broken")
+	file.Close()
+
+	database.fail = false
+	restarted, err := NewAnalyticsOutbox(database, fakeLogger(), path)
+	if err != nil {
+		t.Fatalf("failed to reopen outbox: %v", err)
+	}
+	if pending := restarted.Stats().Pending; pending != 3 {
+		t.Errorf("wrong pending amount after restart: %d", pending)
+	}
+	restarted.Flush(context.Background())
+	restarted.Flush(context.Background())
+	if len(database.analytics) != 2 ||
+		database.analytics[0].Tag != "#tag1" ||
+		database.analytics[1].Tag != "#tag2" {
+		t.Errorf("did not deliver events persisted before restart: %+v", database.analytics)
+	}
+	if pending := restarted.Stats().Pending; pending != 0 {
+		t.Errorf("wrong pending amount after flush: %d", pending)
+	}
+}
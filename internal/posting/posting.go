@@ -0,0 +1,160 @@
+package posting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ratatoskr/internal/correlation"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/logger"
+	"ratatoskr/internal/metrics"
+	"ratatoskr/internal/models"
+	"ratatoskr/internal/sessions"
+	"ratatoskr/internal/settings"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+var (
+	ErrInvalidTag = errors.New("invalid tag")
+	ErrCaption    = errors.New("failed to set caption")
+	ErrCopy       = errors.New("failed to copy messages")
+)
+
+// Publisher is the part of the Bot API needed to publish a post.
+type Publisher interface {
+	EditMessageCaption(opts *gotgbot.EditMessageCaptionOpts) (*gotgbot.Message, bool, error)
+	CopyMessages(
+		chatId int64,
+		fromChatId int64,
+		messageIds []int64,
+		opts *gotgbot.CopyMessagesOpts,
+	) ([]gotgbot.MessageId, error)
+	DeleteMessages(chatId int64, messageIds []int64, opts *gotgbot.DeleteMessagesOpts) (bool, error)
+}
+
+// Post is the media of a tagging session with the tags chosen for it.
+type Post struct {
+	SessionID string
+	UserID    int64
+	Tags      []models.PostTag
+}
+
+type Result struct {
+	Session           models.TaggingSession
+	Settings          models.Settings
+	Caption           string
+	ChannelMessageIDs []int64
+}
+
+// ParseTags reads the [group, tag] pairs sent by the webapp.
+func ParseTags(data [][]string) ([]models.PostTag, error) {
+	tags := []models.PostTag{}
+	for _, v := range data {
+		if len(v) != 2 {
+			return nil, fmt.Errorf("%w %q", ErrInvalidTag, v)
+		}
+		tags = append(tags, models.PostTag{Group: v[0], Tag: v[1]})
+	}
+	return tags, nil
+}
+
+// Publish posts the media of a session to the receiver chat, then removes the
+// echoed media and its button from the private chat. The session is claimed
+// first, so it is posted once however often the button is tapped, and put
+// back when posting fails. Analytics are written to database, which should
+// be the analytics outbox.
+func Publish(
+	ctx context.Context,
+	bot Publisher,
+	database db.DB,
+	store *sessions.Store,
+	runtime *settings.Store,
+	log *logger.Logger,
+	post Post,
+	now time.Time,
+) (*Result, error) {
+	session, err := store.Claim(ctx, post.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	current, err := runtime.Get(ctx)
+	if err != nil {
+		log.Warning("failed to read settings, using last known", "error", err)
+	}
+	release := func() {
+		err := store.Release(ctx, session)
+		if err != nil {
+			log.Error("failed to release session", "error", err)
+		}
+	}
+	tags := []string{}
+	for _, t := range post.Tags {
+		tags = append(tags, t.Tag)
+	}
+	caption := settings.Caption(current.CaptionFormat, tags)
+	mediaIDs := session.MessageIDs
+	_, _, err = bot.EditMessageCaption(&gotgbot.EditMessageCaptionOpts{
+		ChatId:    session.ChatID,
+		MessageId: mediaIDs[0],
+		Caption:   caption,
+	})
+	if err != nil &&
+		!strings.Contains(err.Error(), "are exactly the same as a current content") {
+		release()
+		return nil, fmt.Errorf("%w: %w", ErrCaption, err)
+	}
+	copied, err := bot.CopyMessages(current.ReceiverID, session.ChatID, mediaIDs, &gotgbot.CopyMessagesOpts{
+		DisableNotification: current.PostingMode == models.PostingModeSilent,
+	})
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("%w to %d: %w", ErrCopy, current.ReceiverID, err)
+	}
+	metrics.PostsPublished.Inc(strconv.FormatInt(current.ReceiverID, 10))
+	res := &Result{
+		Session:           session,
+		Settings:          current,
+		Caption:           caption,
+		ChannelMessageIDs: []int64{},
+	}
+	for _, m := range copied {
+		res.ChannelMessageIDs = append(res.ChannelMessageIDs, m.MessageId)
+	}
+	analytics := []models.Analytics{}
+	for _, t := range post.Tags {
+		analytics = append(analytics, models.Analytics{
+			Group:             t.Group,
+			Tag:               t.Tag,
+			Date:              now,
+			UserID:            post.UserID,
+			MediaKind:         session.MediaKind,
+			ItemCount:         len(mediaIDs),
+			DestinationChatID: current.ReceiverID,
+			ChannelMessageIDs: res.ChannelMessageIDs,
+			PostingMode:       current.PostingMode,
+			CorrelationID:     correlation.ID(ctx),
+		})
+	}
+	err = database.InsertAnalytics(ctx, &analytics)
+	if err != nil {
+		log.Error("failed to insert analytics", "error", err)
+	}
+	err = store.Delete(ctx, session.ID)
+	if err != nil {
+		log.Warning("failed to delete session", "error", err)
+	}
+	toDelete := slices.Clone(mediaIDs)
+	if !slices.Contains(toDelete, session.ButtonMessageID) {
+		toDelete = append(toDelete, session.ButtonMessageID)
+	}
+	_, err = bot.DeleteMessages(session.ChatID, toDelete, nil)
+	if err != nil {
+		log.Warning("failed to delete posted messages", "message_ids", toDelete, "error", err)
+	}
+	return res, nil
+}
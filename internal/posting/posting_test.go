@@ -0,0 +1,45 @@
+package posting
+
+import (
+	"errors"
+	"ratatoskr/internal/models"
+	"reflect"
+	"testing"
+)
+
+func TestParseTags(t *testing.T) {
+	type tc struct {
+		name string
+		data [][]string
+		tags []models.PostTag
+		err  error
+	}
+	table := []tc{
+		{
+			name: "should read group and tag pairs",
+			data: [][]string{{"group1", "tag1"}, {"group2", "tag2"}},
+			tags: []models.PostTag{{Group: "group1", Tag: "tag1"}, {Group: "group2", Tag: "tag2"}},
+		},
+		{
+			name: "should return an empty list for no tags",
+			data: [][]string{},
+			tags: []models.PostTag{},
+		},
+		{
+			name: "should reject a pair without a group",
+			data: [][]string{{"tag1"}},
+			err:  ErrInvalidTag,
+		},
+	}
+	for _, c := range table {
+		t.Run(c.name, func(t *testing.T) {
+			tags, err := ParseTags(c.data)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("expected: %+v\nactual:   %+v", c.err, err)
+			}
+			if c.err == nil && !reflect.DeepEqual(tags, c.tags) {
+				t.Errorf("expected: %+v\nactual:   %+v", c.tags, tags)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package sessions
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/models"
+	"sync"
+	"time"
+)
+
+// DefaultTTL is how long a tagging button keeps working after the media was
+// sent.
+const DefaultTTL = time.Hour * 48
+
+// Store keeps tagging sessions in memory and in the database. Sessions are
+// created by the bot and resolved by both the bot and the webapp, which run
+// as separate processes.
+type Store struct {
+	db  db.DB
+	ttl time.Duration
+	now func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]models.TaggingSession
+}
+
+func NewStore(db db.DB, ttl time.Duration, now func() time.Time) *Store {
+	return &Store{
+		db:       db,
+		ttl:      ttl,
+		now:      now,
+		sessions: map[string]models.TaggingSession{},
+	}
+}
+
+// Create assigns an opaque ID and expiry to the session and stores it.
+func (s *Store) Create(
+	ctx context.Context,
+	session models.TaggingSession,
+) (models.TaggingSession, error) {
+	id, err := newID()
+	if err != nil {
+		return models.TaggingSession{}, err
+	}
+	now := s.now()
+	session.ID = id
+	session.CreatedAt = now
+	session.ExpiresAt = now.Add(s.ttl)
+	err = s.db.InsertTaggingSession(ctx, &session)
+	if err != nil {
+		return models.TaggingSession{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, v := range s.sessions {
+		if !now.Before(v.ExpiresAt) {
+			delete(s.sessions, id)
+		}
+	}
+	s.sessions[session.ID] = session
+	return session, nil
+}
+
+// Get returns a session that has not expired yet, or db.ErrSessionNotFound.
+func (s *Store) Get(ctx context.Context, id string) (models.TaggingSession, error) {
+	s.mu.Lock()
+	session, ok := s.sessions[id]
+	s.mu.Unlock()
+	if !ok {
+		found, err := s.db.GetTaggingSession(ctx, id)
+		if err != nil {
+			return models.TaggingSession{}, err
+		}
+		session = *found
+	}
+	if !s.now().Before(session.ExpiresAt) {
+		s.mu.Lock()
+		delete(s.sessions, id)
+		s.mu.Unlock()
+		return models.TaggingSession{}, db.ErrSessionNotFound
+	}
+	if !ok {
+		s.mu.Lock()
+		s.sessions[id] = session
+		s.mu.Unlock()
+	}
+	return session, nil
+}
+
+// Claim takes the session out of the database before its media is posted.
+// Of concurrent claims, from this or another process, only one gets the
+// session, the others get db.ErrSessionNotFound.
+func (s *Store) Claim(ctx context.Context, id string) (models.TaggingSession, error) {
+	s.mu.Lock()
+	delete(s.sessions, id)
+	s.mu.Unlock()
+	found, err := s.db.ClaimTaggingSession(ctx, id)
+	if err != nil {
+		return models.TaggingSession{}, err
+	}
+	if !s.now().Before(found.ExpiresAt) {
+		return models.TaggingSession{}, db.ErrSessionNotFound
+	}
+	return *found, nil
+}
+
+// Release puts a claimed session back after posting failed, so the media
+// can be tagged again.
+func (s *Store) Release(ctx context.Context, session models.TaggingSession) error {
+	err := s.db.InsertTaggingSession(ctx, &session)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[session.ID] = session
+	return nil
+}
+
+// Delete ends the session once its media is posted, together with the drafts
+// users left in it.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	delete(s.sessions, id)
+	s.mu.Unlock()
+	err := s.db.DeleteTaggingSession(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.db.DeleteDrafts(ctx, id)
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,171 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type dbMock struct {
+	db.DB
+	sessions map[string]models.TaggingSession
+	drafts   map[string]bool
+	reads    int
+}
+
+func (m *dbMock) InsertTaggingSession(_ context.Context, s *models.TaggingSession) error {
+	m.sessions[s.ID] = *s
+	return nil
+}
+
+func (m *dbMock) GetTaggingSession(_ context.Context, id string) (*models.TaggingSession, error) {
+	m.reads++
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, db.ErrSessionNotFound
+	}
+	return &s, nil
+}
+
+func (m *dbMock) DeleteTaggingSession(_ context.Context, id string) error {
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *dbMock) ClaimTaggingSession(_ context.Context, id string) (*models.TaggingSession, error) {
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, db.ErrSessionNotFound
+	}
+	delete(m.sessions, id)
+	return &s, nil
+}
+
+func (m *dbMock) DeleteDrafts(_ context.Context, sessionID string) error {
+	delete(m.drafts, sessionID)
+	return nil
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	database := &dbMock{
+		sessions: map[string]models.TaggingSession{},
+		drafts:   map[string]bool{},
+	}
+	store := NewStore(database, time.Hour, func() time.Time { return now })
+
+	created, err := store.Create(ctx, models.TaggingSession{
+		ChatID:           1,
+		SourceMessageIDs: []int64{10},
+		MessageIDs:       []int64{11},
+		ButtonMessageID:  11,
+		MediaKind:        models.MediaKindPhoto,
+	})
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	expected := models.TaggingSession{
+		ID:               created.ID,
+		ChatID:           1,
+		SourceMessageIDs: []int64{10},
+		MessageIDs:       []int64{11},
+		ButtonMessageID:  11,
+		MediaKind:        models.MediaKindPhoto,
+		CreatedAt:        now,
+		ExpiresAt:        now.Add(time.Hour),
+	}
+	if len(created.ID) != 22 || !reflect.DeepEqual(database.sessions[created.ID], expected) {
+		t.Errorf(
+			"wrong stored session\nexpected: %+v\nactual:   %+v",
+			expected,
+			database.sessions[created.ID],
+		)
+	}
+
+	// another process only sees the database
+	other := NewStore(database, time.Hour, func() time.Time { return now })
+
+	type tc struct {
+		name  string
+		store *Store
+		id    string
+		err   error
+		reads int
+	}
+
+	table := []tc{
+		{name: "should resolve from memory", store: store, id: created.ID, reads: 0},
+		{name: "should resolve from database", store: other, id: created.ID, reads: 1},
+		{name: "should cache database reads", store: other, id: created.ID, reads: 1},
+		{name: "should not resolve unknown id", store: store, id: "unknown", err: db.ErrSessionNotFound, reads: 2},
+	}
+
+	for _, test := range table {
+		actual, err := test.store.Get(ctx, test.id)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s - wrong error\nexpected: %v\nactual:   %v", test.name, test.err, err)
+		}
+		if err == nil && !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s\nexpected: %+v\nactual:   %+v", test.name, expected, actual)
+		}
+		if database.reads != test.reads {
+			t.Errorf("%s - wrong database reads\nexpected: %d\nactual:   %d", test.name, test.reads, database.reads)
+		}
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := other.Get(ctx, created.ID); !errors.Is(err, db.ErrSessionNotFound) {
+		t.Errorf("expired session was resolved, error: %v", err)
+	}
+
+	now = now.Add(-time.Minute)
+	database.drafts[created.ID] = true
+	err = store.Delete(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("failed to delete session: %v", err)
+	}
+	if _, err := store.Get(ctx, created.ID); !errors.Is(err, db.ErrSessionNotFound) {
+		t.Errorf("deleted session was resolved, error: %v", err)
+	}
+	if database.drafts[created.ID] {
+		t.Errorf("drafts of deleted session were kept")
+	}
+}
+
+func TestClaim(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	database := &dbMock{sessions: map[string]models.TaggingSession{}, drafts: map[string]bool{}}
+	store := NewStore(database, time.Hour, func() time.Time { return now })
+	other := NewStore(database, time.Hour, func() time.Time { return now })
+	created, err := store.Create(ctx, models.TaggingSession{ChatID: 1, MessageIDs: []int64{11}})
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	claimed, err := store.Claim(ctx, created.ID)
+	if err != nil || !reflect.DeepEqual(claimed, created) {
+		t.Fatalf("failed to claim session\nexpected: %+v\nactual:   %+v, %v", created, claimed, err)
+	}
+	for name, s := range map[string]*Store{"same store": store, "other store": other} {
+		if _, err := s.Claim(ctx, created.ID); !errors.Is(err, db.ErrSessionNotFound) {
+			t.Errorf("%s - session was claimed twice, error: %v", name, err)
+		}
+	}
+	if _, err := store.Get(ctx, created.ID); !errors.Is(err, db.ErrSessionNotFound) {
+		t.Errorf("claimed session was resolved, error: %v", err)
+	}
+
+	err = store.Release(ctx, claimed)
+	if err != nil {
+		t.Fatalf("failed to release session: %v", err)
+	}
+	if _, err := other.Claim(ctx, created.ID); err != nil {
+		t.Errorf("released session could not be claimed: %v", err)
+	}
+}
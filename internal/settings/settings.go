@@ -0,0 +1,236 @@
+package settings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ratatoskr/internal/correlation"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/models"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Names of the settings that can be changed at runtime.
+const (
+	Receiver           = "receiver"
+	MediaGroupInterval = "interval"
+	CaptionFormat      = "caption"
+	PostingMode        = "mode"
+)
+
+// CheckInterval is how long settings are used before they are read again,
+// so a change made in the bot reaches the webapp without a restart.
+const CheckInterval = time.Second * 5
+
+const (
+	minMediaGroupInterval = time.Millisecond * 100
+	maxMediaGroupInterval = time.Second * 10
+)
+
+var (
+	ErrUnknownSetting = errors.New("unknown setting")
+	ErrInvalidValue   = errors.New("invalid setting value")
+)
+
+var (
+	// IntervalOptions are the media group intervals offered in the menu.
+	IntervalOptions = []time.Duration{
+		time.Millisecond * 250,
+		time.Millisecond * 500,
+		time.Second,
+		time.Second * 2,
+	}
+	CaptionFormats = []string{
+		models.CaptionFormatLines,
+		models.CaptionFormatInline,
+		models.CaptionFormatNone,
+	}
+	PostingModes = []string{models.PostingModeImmediate, models.PostingModeSilent}
+)
+
+// Defaults are the settings used until an admin changes them.
+func Defaults(receiverID int64) models.Settings {
+	return models.Settings{
+		ReceiverID:         receiverID,
+		MediaGroupInterval: time.Millisecond * 500,
+		CaptionFormat:      models.CaptionFormatLines,
+		PostingMode:        models.PostingModeImmediate,
+	}
+}
+
+// Store reads the settings from the database and keeps them for a while.
+// The bot changes them and both the bot and the webapp use them.
+type Store struct {
+	db       db.DB
+	defaults models.Settings
+	interval time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	settings  *models.Settings
+}
+
+func NewStore(
+	db db.DB,
+	defaults models.Settings,
+	interval time.Duration,
+	now func() time.Time,
+) *Store {
+	return &Store{db: db, defaults: defaults, interval: interval, now: now}
+}
+
+// Get returns the current settings. When they can not be read, the last
+// known settings, or the defaults, are returned together with the error.
+func (s *Store) Get(ctx context.Context) (models.Settings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if s.settings != nil && now.Sub(s.checkedAt) < s.interval {
+		return *s.settings, nil
+	}
+	current, err := s.read(ctx)
+	if err != nil {
+		if s.settings != nil {
+			return *s.settings, err
+		}
+		return s.defaults, err
+	}
+	s.settings = &current
+	s.checkedAt = now
+	return current, nil
+}
+
+// Set validates and saves one setting and records the change. It returns
+// db.ErrSettingsConflict when the settings changed since they were read.
+func (s *Store) Set(
+	ctx context.Context,
+	name string,
+	value string,
+	userID int64,
+) (models.Settings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, err := s.read(ctx)
+	if err != nil {
+		return models.Settings{}, err
+	}
+	next := current
+	err = apply(&next, name, value)
+	if err != nil {
+		return current, err
+	}
+	from, to := Value(current, name), Value(next, name)
+	if from == to {
+		return current, nil
+	}
+	now := s.now()
+	next.UpdatedAt = now
+	next.UpdatedBy = userID
+	next.Version, err = s.db.SaveSettings(ctx, current.Version, &next, &models.SettingChange{
+		Setting:       name,
+		From:          from,
+		To:            to,
+		UserID:        userID,
+		Date:          now,
+		CorrelationID: correlation.ID(ctx),
+	})
+	if err != nil {
+		s.settings = nil
+		return current, err
+	}
+	s.settings = &next
+	s.checkedAt = now
+	return next, nil
+}
+
+// read returns the stored settings, with the defaults for the ones that were
+// never saved.
+func (s *Store) read(ctx context.Context) (models.Settings, error) {
+	stored, err := s.db.GetSettings(ctx)
+	if errors.Is(err, db.ErrSettingsNotFound) {
+		return s.defaults, nil
+	}
+	if err != nil {
+		return models.Settings{}, err
+	}
+	res := *stored
+	if res.ReceiverID == 0 {
+		res.ReceiverID = s.defaults.ReceiverID
+	}
+	if res.MediaGroupInterval == 0 {
+		res.MediaGroupInterval = s.defaults.MediaGroupInterval
+	}
+	if res.CaptionFormat == "" {
+		res.CaptionFormat = s.defaults.CaptionFormat
+	}
+	if res.PostingMode == "" {
+		res.PostingMode = s.defaults.PostingMode
+	}
+	return res, nil
+}
+
+func apply(settings *models.Settings, name string, value string) error {
+	switch name {
+	case Receiver:
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id == 0 {
+			return fmt.Errorf("%w: %q is not a chat ID", ErrInvalidValue, value)
+		}
+		settings.ReceiverID = id
+	case MediaGroupInterval:
+		d, err := time.ParseDuration(value)
+		if err != nil || d < minMediaGroupInterval || d > maxMediaGroupInterval {
+			return fmt.Errorf(
+				"%w: interval must be between %s and %s",
+				ErrInvalidValue,
+				minMediaGroupInterval,
+				maxMediaGroupInterval,
+			)
+		}
+		settings.MediaGroupInterval = d
+	case CaptionFormat:
+		if !slices.Contains(CaptionFormats, value) {
+			return fmt.Errorf("%w: caption format must be one of %v", ErrInvalidValue, CaptionFormats)
+		}
+		settings.CaptionFormat = value
+	case PostingMode:
+		if !slices.Contains(PostingModes, value) {
+			return fmt.Errorf("%w: posting mode must be one of %v", ErrInvalidValue, PostingModes)
+		}
+		settings.PostingMode = value
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownSetting, name)
+	}
+	return nil
+}
+
+// Value formats one setting the way Set accepts it.
+func Value(settings models.Settings, name string) string {
+	switch name {
+	case Receiver:
+		return strconv.FormatInt(settings.ReceiverID, 10)
+	case MediaGroupInterval:
+		return settings.MediaGroupInterval.String()
+	case CaptionFormat:
+		return settings.CaptionFormat
+	case PostingMode:
+		return settings.PostingMode
+	}
+	return ""
+}
+
+// Caption formats the tags of a post.
+func Caption(format string, tags []string) string {
+	switch format {
+	case models.CaptionFormatInline:
+		return strings.Join(tags, " ")
+	case models.CaptionFormatNone:
+		return ""
+	}
+	return strings.Join(tags, "\n")
+}
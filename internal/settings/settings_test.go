@@ -0,0 +1,190 @@
+package settings
+
+import (
+	"context"
+	"errors"
+	"ratatoskr/internal/correlation"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type dbMock struct {
+	db.DB
+	settings *models.Settings
+	changes  []models.SettingChange
+	readErr  error
+	conflict bool
+	reads    int
+}
+
+func (m *dbMock) GetSettings(context.Context) (*models.Settings, error) {
+	m.reads++
+	if m.readErr != nil {
+		return nil, m.readErr
+	}
+	if m.settings == nil {
+		return nil, db.ErrSettingsNotFound
+	}
+	s := *m.settings
+	return &s, nil
+}
+
+func (m *dbMock) SaveSettings(
+	_ context.Context,
+	version int64,
+	s *models.Settings,
+	change *models.SettingChange,
+) (int64, error) {
+	if m.conflict || m.settings != nil && m.settings.Version != version {
+		return 0, db.ErrSettingsConflict
+	}
+	next := *s
+	next.Version = version + 1
+	m.settings = &next
+	m.changes = append(m.changes, *change)
+	return next.Version, nil
+}
+
+func TestStore(t *testing.T) {
+	ctx := correlation.With(context.Background(), "abc123")
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	database := &dbMock{}
+	store := NewStore(database, Defaults(-100), time.Minute, func() time.Time { return now })
+
+	actual, err := store.Get(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(Defaults(-100), actual) {
+		t.Errorf("defaults\nexpected: %+v\nactual:   %+v", Defaults(-100), actual)
+	}
+
+	actual, err = store.Set(ctx, CaptionFormat, models.CaptionFormatInline, 1234)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := Defaults(-100)
+	expected.CaptionFormat = models.CaptionFormatInline
+	expected.Version = 1
+	expected.UpdatedAt = now
+	expected.UpdatedBy = 1234
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("set\nexpected: %+v\nactual:   %+v", expected, actual)
+	}
+	changes := []models.SettingChange{{
+		Setting:       CaptionFormat,
+		From:          models.CaptionFormatLines,
+		To:            models.CaptionFormatInline,
+		UserID:        1234,
+		Date:          now,
+		CorrelationID: "abc123",
+	}}
+	if !reflect.DeepEqual(changes, database.changes) {
+		t.Errorf("audit trail\nexpected: %+v\nactual:   %+v", changes, database.changes)
+	}
+
+	reads := database.reads
+	actual, _ = store.Get(ctx)
+	if database.reads != reads || !reflect.DeepEqual(expected, actual) {
+		t.Errorf("saved settings were not cached\nexpected: %+v\nactual:   %+v", expected, actual)
+	}
+
+	// another process changed the settings
+	database.settings.PostingMode = models.PostingModeSilent
+	database.settings.Version = 2
+	now = now.Add(time.Minute)
+	actual, _ = store.Get(ctx)
+	if actual.PostingMode != models.PostingModeSilent {
+		t.Errorf("change was not read\nexpected: %+v\nactual:   %+v", models.PostingModeSilent, actual.PostingMode)
+	}
+
+	_, err = store.Set(ctx, CaptionFormat, models.CaptionFormatInline, 1234)
+	if err != nil || len(database.changes) != 1 {
+		t.Errorf("unchanged value was recorded: %v %+v", err, database.changes)
+	}
+
+	database.readErr = errors.New("connection refused")
+	now = now.Add(time.Minute)
+	actual, err = store.Get(ctx)
+	if err == nil || actual.PostingMode != models.PostingModeSilent {
+		t.Errorf("expected last known settings and an error, got %+v %v", actual, err)
+	}
+}
+
+func TestSetValidation(t *testing.T) {
+	type tc struct {
+		name     string
+		setting  string
+		value    string
+		expected error
+	}
+
+	table := []tc{
+		{name: "should set receiver", setting: Receiver, value: "-1001234"},
+		{name: "should set interval", setting: MediaGroupInterval, value: "1s"},
+		{name: "should set posting mode", setting: PostingMode, value: models.PostingModeSilent},
+		{name: "should reject zero receiver", setting: Receiver, value: "0", expected: ErrInvalidValue},
+		{name: "should reject chat name", setting: Receiver, value: "@channel", expected: ErrInvalidValue},
+		{name: "should reject long interval", setting: MediaGroupInterval, value: "1m", expected: ErrInvalidValue},
+		{name: "should reject short interval", setting: MediaGroupInterval, value: "1ms", expected: ErrInvalidValue},
+		{name: "should reject unknown caption", setting: CaptionFormat, value: "bold", expected: ErrInvalidValue},
+		{name: "should reject unknown mode", setting: PostingMode, value: "queued", expected: ErrInvalidValue},
+		{name: "should reject unknown setting", setting: "token", value: "x", expected: ErrUnknownSetting},
+	}
+
+	for _, test := range table {
+		database := &dbMock{}
+		store := NewStore(database, Defaults(-100), time.Minute, time.Now)
+		actual, err := store.Set(context.Background(), test.setting, test.value, 1)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%s\nexpected: %v\nactual:   %v", test.name, test.expected, err)
+		}
+		if test.expected == nil && Value(actual, test.setting) != test.value {
+			t.Errorf("%s\nexpected: %s\nactual:   %s", test.name, test.value, Value(actual, test.setting))
+		}
+		if test.expected != nil && len(database.changes) != 0 {
+			t.Errorf("%s - invalid value was saved: %+v", test.name, database.changes)
+		}
+	}
+}
+
+func TestStoreConflict(t *testing.T) {
+	database := &dbMock{conflict: true}
+	store := NewStore(database, Defaults(-100), time.Minute, time.Now)
+	ctx := context.Background()
+
+	store.Get(ctx)
+	_, err := store.Set(ctx, Receiver, "-200", 1)
+	if !errors.Is(err, db.ErrSettingsConflict) {
+		t.Errorf("expected: %v\nactual:   %v", db.ErrSettingsConflict, err)
+	}
+	reads := database.reads
+	store.Get(ctx)
+	if database.reads != reads+1 {
+		t.Errorf("settings were not read again after a conflict")
+	}
+}
+
+func TestCaption(t *testing.T) {
+	type tc struct {
+		format   string
+		expected string
+	}
+
+	table := []tc{
+		{format: models.CaptionFormatLines, expected: "#tag1\n#tag2"},
+		{format: models.CaptionFormatInline, expected: "#tag1 #tag2"},
+		{format: models.CaptionFormatNone, expected: ""},
+		{format: "", expected: "#tag1\n#tag2"},
+	}
+
+	for _, test := range table {
+		actual := Caption(test.format, []string{"#tag1", "#tag2"})
+		if actual != test.expected {
+			t.Errorf("%q\nexpected: %q\nactual:   %q", test.format, test.expected, actual)
+		}
+	}
+}
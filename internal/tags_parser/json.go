@@ -0,0 +1,68 @@
+package tags_parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"ratatoskr/internal/models"
+	"strings"
+)
+
+// jsonMenu is the shape of GET /api/v1/groups, so an export can be compared
+// with what the API returns.
+type jsonMenu struct {
+	Groups []jsonGroup `json:"groups"`
+}
+
+type jsonGroup struct {
+	Name  string    `json:"name"`
+	Index int       `json:"index"`
+	Tags  []jsonTag `json:"tags"`
+}
+
+type jsonTag struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+func FormatJSON(groups []models.Group) ([]byte, error) {
+	menu := jsonMenu{Groups: []jsonGroup{}}
+	for _, g := range groups {
+		group := jsonGroup{Name: g.Name, Index: g.OriginalIndex, Tags: []jsonTag{}}
+		for _, t := range g.Tags {
+			group.Tags = append(group.Tags, jsonTag{Name: t.Name, Aliases: t.Aliases})
+		}
+		menu.Groups = append(menu.Groups, group)
+	}
+	return json.MarshalIndent(menu, "", "  ")
+}
+
+// ParseJSON reads groups written by FormatJSON. Groups keep the order of
+// the file, names are trimmed and the result is checked with Validate.
+func ParseJSON(data []byte) ([]models.Group, error) {
+	var menu jsonMenu
+	err := json.Unmarshal(data, &menu)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	groups := []models.Group{}
+	for i, g := range menu.Groups {
+		group := models.Group{
+			Name:          strings.TrimSpace(g.Name),
+			OriginalIndex: i,
+			Tags:          []models.Tag{},
+		}
+		for _, t := range g.Tags {
+			tag := models.Tag{Name: strings.TrimSpace(t.Name)}
+			for _, a := range t.Aliases {
+				tag.Aliases = append(tag.Aliases, strings.TrimSpace(a))
+			}
+			group.Tags = append(group.Tags, tag)
+		}
+		groups = append(groups, group)
+	}
+	err = Validate(groups)
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
@@ -0,0 +1,112 @@
+package tags_parser
+
+import (
+	"errors"
+	"ratatoskr/internal/models"
+	"reflect"
+	"testing"
+)
+
+func TestFormatJSON(t *testing.T) {
+	groups := []models.Group{
+		{Name: "Group 1", Tags: []models.Tag{{Name: "#tag1"}, {Name: "#tag2"}}},
+		{Name: "Group 2", OriginalIndex: 1, Tags: []models.Tag{{Name: "#tag3", Aliases: []string{"a", "b"}}}},
+	}
+	expected := `{
+  "groups": [
+    {
+      "name": "Group 1",
+      "index": 0,
+      "tags": [
+        {
+          "name": "#tag1"
+        },
+        {
+          "name": "#tag2"
+        }
+      ]
+    },
+    {
+      "name": "Group 2",
+      "index": 1,
+      "tags": [
+        {
+          "name": "#tag3",
+          "aliases": [
+            "a",
+            "b"
+          ]
+        }
+      ]
+    }
+  ]
+}`
+	actual, err := FormatJSON(groups)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(actual) != expected {
+		t.Errorf("wrong format\nexpected: %s\nactual:   %s", expected, actual)
+	}
+	parsed, err := ParseJSON(actual)
+	if err != nil {
+		t.Errorf("failed to parse formatted groups: %v", err)
+	}
+	if !reflect.DeepEqual(groups, parsed) {
+		t.Errorf("format is not reversible\nexpected: %+v\nactual:   %+v", groups, parsed)
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	type tc struct {
+		name     string
+		input    string
+		expected []models.Group
+		err      error
+	}
+
+	table := []tc{
+		{
+			name:  "should order groups as in the file and trim names",
+			input: `{"groups":[{"name":" B ","index":3,"tags":[{"name":" #b ","aliases":[" x "]}]},{"name":"A","index":0,"tags":[{"name":"#a"}]}]}`,
+			expected: []models.Group{
+				{Name: "B", OriginalIndex: 0, Tags: []models.Tag{{Name: "#b", Aliases: []string{"x"}}}},
+				{Name: "A", OriginalIndex: 1, Tags: []models.Tag{{Name: "#a"}}},
+			},
+		},
+
+		{
+			name:  "should validate groups",
+			input: `{"groups":[{"name":"A","tags":[{"name":"a"}]}]}`,
+			err:   ErrTagWithoutHash,
+		},
+
+		{
+			name:  "should reject empty menu",
+			input: `{}`,
+			err:   ErrNoGroups,
+		},
+	}
+
+	for _, test := range table {
+		actual, err := ParseJSON([]byte(test.input))
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s - wrong error\nexpected: %+v\nactual:   %+v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s - unexpected error: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf("%s - wrong groups\nexpected: %+v\nactual:   %+v", test.name, test.expected, actual)
+		}
+	}
+
+	_, err := ParseJSON([]byte(`{"groups":`))
+	if err == nil {
+		t.Errorf("expected an error for invalid JSON")
+	}
+}
@@ -0,0 +1,262 @@
+package tags_parser
+
+import (
+	"errors"
+	"fmt"
+	"ratatoskr/internal/models"
+	"slices"
+	"strings"
+)
+
+const (
+	groupPrefix   = "•"
+	tagPrefix     = "#"
+	commentPrefix = "//"
+	aliasesPrefix = "="
+)
+
+var (
+	ErrMissingColon     = errors.New("group name must end with a colon")
+	ErrMissingGroupName = errors.New("group name is empty")
+	ErrEmptyGroup       = errors.New("group has no tags")
+	ErrTagWithoutHash   = errors.New("tag must start with #")
+	ErrTagReservedChar  = errors.New("tag must not contain \"=\" or line breaks")
+	ErrGroupReserved    = errors.New("group name must not end with a colon or contain line breaks")
+	ErrInvalidAlias     = errors.New("alias must not be empty or contain commas or line breaks")
+	ErrDuplicateTag     = errors.New("duplicate tag")
+	ErrDuplicateGroup   = errors.New("duplicate group")
+	ErrNoGroups         = errors.New("no groups found")
+)
+
+type LineError struct {
+	Line   int
+	Text   string
+	Reason error
+}
+
+func (e LineError) Error() string {
+	if e.Line == 0 {
+		return e.Reason.Error()
+	}
+	if e.Text == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+	}
+	return fmt.Sprintf("line %d: %s (%q)", e.Line, e.Reason, e.Text)
+}
+
+func (e LineError) Unwrap() error {
+	return e.Reason
+}
+
+type ParseError struct {
+	Errors []LineError
+}
+
+func (e *ParseError) Error() string {
+	lines := make([]string, len(e.Errors))
+	for i, v := range e.Errors {
+		lines[i] = v.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e *ParseError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, v := range e.Errors {
+		errs[i] = v
+	}
+	return errs
+}
+
+// Parse reads groups written as "• Name:" followed by one "#tag" per line.
+// A tag may list search aliases after "=", separated by commas. Text before
+// the first group is ignored, as are blank lines and lines starting with
+// "//". All problems are collected into a *ParseError.
+func Parse(text string) ([]models.Group, error) {
+	groups := []models.Group{}
+	errs := []LineError{}
+	seenTags := map[string]int{}
+	seenGroups := map[string]int{}
+	var current *models.Group
+	currentLine := 0
+
+	closeGroup := func() {
+		if current == nil {
+			return
+		}
+		if len(current.Tags) == 0 {
+			errs = append(errs, LineError{Line: currentLine, Reason: ErrEmptyGroup, Text: current.Name})
+		}
+		groups = append(groups, *current)
+		current = nil
+	}
+
+	for i, raw := range strings.Split(text, "\n") {
+		line := strings.TrimSpace(raw)
+		lineNumber := i + 1
+		if line == "" || strings.HasPrefix(line, commentPrefix) {
+			continue
+		}
+		if strings.HasPrefix(line, groupPrefix) {
+			closeGroup()
+			name, err := parseGroupName(line)
+			if err != nil {
+				errs = append(errs, LineError{Line: lineNumber, Reason: err, Text: line})
+			}
+			if name != "" {
+				if first, ok := seenGroups[name]; ok {
+					errs = append(errs, LineError{
+						Line:   lineNumber,
+						Reason: fmt.Errorf("%w, first defined on line %d", ErrDuplicateGroup, first),
+						Text:   name,
+					})
+				} else {
+					seenGroups[name] = lineNumber
+				}
+			}
+			current = &models.Group{Name: name, OriginalIndex: len(groups), Tags: []models.Tag{}}
+			currentLine = lineNumber
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		tag := parseTag(line)
+		if err := ValidateTag(tag.Name); err != nil {
+			errs = append(errs, LineError{Line: lineNumber, Reason: err, Text: line})
+			continue
+		}
+		if first, ok := seenTags[tag.Name]; ok {
+			errs = append(errs, LineError{
+				Line:   lineNumber,
+				Reason: fmt.Errorf("%w, first defined on line %d", ErrDuplicateTag, first),
+				Text:   tag.Name,
+			})
+			continue
+		}
+		seenTags[tag.Name] = lineNumber
+		current.Tags = append(current.Tags, tag)
+	}
+	closeGroup()
+
+	if len(groups) == 0 && len(errs) == 0 {
+		errs = append(errs, LineError{Reason: ErrNoGroups})
+	}
+	if len(errs) != 0 {
+		slices.SortStableFunc(errs, func(a, b LineError) int {
+			return a.Line - b.Line
+		})
+		return nil, &ParseError{Errors: errs}
+	}
+	return groups, nil
+}
+
+func parseGroupName(line string) (string, error) {
+	name := strings.TrimSpace(strings.TrimPrefix(line, groupPrefix))
+	hasColon := strings.HasSuffix(name, ":")
+	name = strings.TrimSpace(strings.TrimSuffix(name, ":"))
+	if name == "" {
+		return "", ErrMissingGroupName
+	}
+	if !hasColon {
+		return name, ErrMissingColon
+	}
+	return name, nil
+}
+
+// ValidateTag checks that a single tag name can be written into the menu.
+func ValidateTag(name string) error {
+	if !strings.HasPrefix(name, tagPrefix) || len(name) == len(tagPrefix) {
+		return ErrTagWithoutHash
+	}
+	if strings.ContainsAny(name, aliasesPrefix+"\r\n") {
+		return ErrTagReservedChar
+	}
+	return nil
+}
+
+func ValidateGroupName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return ErrMissingGroupName
+	}
+	if strings.HasSuffix(name, ":") || strings.ContainsAny(name, "\r\n") {
+		return ErrGroupReserved
+	}
+	return nil
+}
+
+// Validate checks groups edited outside of the text format, so that they
+// can still be written with Format and parsed back. Names are expected to be
+// trimmed already.
+func Validate(groups []models.Group) error {
+	errs := []error{}
+	if len(groups) == 0 {
+		errs = append(errs, ErrNoGroups)
+	}
+	seenGroups := map[string]bool{}
+	seenTags := map[string]bool{}
+	for _, g := range groups {
+		if err := ValidateGroupName(g.Name); err != nil {
+			errs = append(errs, fmt.Errorf("group %q: %w", g.Name, err))
+		}
+		if seenGroups[g.Name] {
+			errs = append(errs, fmt.Errorf("group %q: %w", g.Name, ErrDuplicateGroup))
+		}
+		seenGroups[g.Name] = true
+		if len(g.Tags) == 0 {
+			errs = append(errs, fmt.Errorf("group %q: %w", g.Name, ErrEmptyGroup))
+		}
+		for _, t := range g.Tags {
+			if err := ValidateTag(t.Name); err != nil {
+				errs = append(errs, fmt.Errorf("tag %q: %w", t.Name, err))
+				continue
+			}
+			if seenTags[t.Name] {
+				errs = append(errs, fmt.Errorf("tag %q: %w", t.Name, ErrDuplicateTag))
+			}
+			seenTags[t.Name] = true
+			for _, a := range t.Aliases {
+				if strings.TrimSpace(a) == "" || strings.ContainsAny(a, ",\r\n") {
+					errs = append(errs, fmt.Errorf("tag %q alias %q: %w", t.Name, a, ErrInvalidAlias))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func parseTag(line string) models.Tag {
+	name, aliases, found := strings.Cut(line, aliasesPrefix)
+	tag := models.Tag{Name: strings.TrimSpace(name)}
+	if !found {
+		return tag
+	}
+	for _, alias := range strings.Split(aliases, ",") {
+		alias = strings.TrimSpace(alias)
+		if alias != "" && !slices.Contains(tag.Aliases, alias) {
+			tag.Aliases = append(tag.Aliases, alias)
+		}
+	}
+	return tag
+}
+
+func Format(groups []models.Group) string {
+	blocks := make([]string, len(groups))
+	for i, group := range groups {
+		lines := []string{fmt.Sprintf("%s %s:", groupPrefix, group.Name)}
+		for _, tag := range group.Tags {
+			if len(tag.Aliases) == 0 {
+				lines = append(lines, tag.Name)
+				continue
+			}
+			lines = append(lines, fmt.Sprintf(
+				"%s %s %s",
+				tag.Name,
+				aliasesPrefix,
+				strings.Join(tag.Aliases, ", "),
+			))
+		}
+		blocks[i] = strings.Join(lines, "\n")
+	}
+	return strings.Join(blocks, "\n\n")
+}
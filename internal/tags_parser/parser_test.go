@@ -0,0 +1,279 @@
+package tags_parser
+
+import (
+	"errors"
+	"ratatoskr/internal/models"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	type tc struct {
+		name     string
+		input    string
+		expected []models.Group
+		errors   []LineError
+	}
+
+	table := []tc{
+		{
+			name: "should parse groups and ignore header",
+			input: `Tags list
+
+• Group 1:
+#tag1
+#tag2
+
+• Group 2:
+#tag3`,
+			expected: []models.Group{
+				{Name: "Group 1", OriginalIndex: 0, Tags: []models.Tag{{Name: "#tag1"}, {Name: "#tag2"}}},
+				{Name: "Group 2", OriginalIndex: 1, Tags: []models.Tag{{Name: "#tag3"}}},
+			},
+		},
+
+		{
+			name: "should skip comments and surrounding whitespace",
+			input: `// header comment
+•  Group 1 :
+  #tag1
+// #tag2 is disabled
+#tag3  `,
+			expected: []models.Group{
+				{Name: "Group 1", OriginalIndex: 0, Tags: []models.Tag{{Name: "#tag1"}, {Name: "#tag3"}}},
+			},
+		},
+
+		{
+			name: "should parse aliases",
+			input: `• Group 1:
+#tag1 = first, one ,, first
+#tag2 =
+#tag3=third`,
+			expected: []models.Group{
+				{Name: "Group 1", OriginalIndex: 0, Tags: []models.Tag{
+					{Name: "#tag1", Aliases: []string{"first", "one"}},
+					{Name: "#tag2"},
+					{Name: "#tag3", Aliases: []string{"third"}},
+				}},
+			},
+		},
+
+		{
+			name: "should report every problem with line numbers",
+			input: `• Group 1
+#tag1
+tag2
+
+• Group 2:
+
+• :
+#tag1
+#
+• Group 1:
+#tag4`,
+			errors: []LineError{
+				{Line: 1, Text: "• Group 1", Reason: ErrMissingColon},
+				{Line: 3, Text: "tag2", Reason: ErrTagWithoutHash},
+				{Line: 5, Text: "Group 2", Reason: ErrEmptyGroup},
+				{Line: 7, Text: "• :", Reason: ErrMissingGroupName},
+				{Line: 7, Reason: ErrEmptyGroup},
+				{Line: 8, Text: "#tag1", Reason: ErrDuplicateTag},
+				{Line: 9, Text: "#", Reason: ErrTagWithoutHash},
+				{Line: 10, Text: "Group 1", Reason: ErrDuplicateGroup},
+			},
+		},
+
+		{
+			name:   "should fail when there are no groups",
+			input:  "just text",
+			errors: []LineError{{Reason: ErrNoGroups}},
+		},
+	}
+
+	for _, test := range table {
+		actual, err := Parse(test.input)
+		if len(test.errors) == 0 {
+			if err != nil {
+				t.Errorf("%s - unexpected error: %v", test.name, err)
+			}
+			if !reflect.DeepEqual(test.expected, actual) {
+				t.Errorf(
+					"%s - wrong groups\nexpected: %+v\nactual:   %+v",
+					test.name,
+					test.expected,
+					actual,
+				)
+			}
+			continue
+		}
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("%s - expected parse error, got: %v", test.name, err)
+			continue
+		}
+		if len(parseErr.Errors) != len(test.errors) {
+			t.Errorf(
+				"%s - wrong amount of errors\nexpected: %+v\nactual:   %+v",
+				test.name,
+				test.errors,
+				parseErr.Errors,
+			)
+			continue
+		}
+		for i, expected := range test.errors {
+			actual := parseErr.Errors[i]
+			if expected.Line != actual.Line ||
+				expected.Text != actual.Text ||
+				!errors.Is(actual, expected.Reason) {
+				t.Errorf(
+					"%s - wrong error %d\nexpected: %+v\nactual:   %+v",
+					test.name,
+					i,
+					expected,
+					actual,
+				)
+			}
+		}
+	}
+}
+
+func TestParseErrorMessage(t *testing.T) {
+	_, err := Parse("• Group 1\n#tag1\n#tag1")
+	expected := "line 1: group name must end with a colon (\"• Group 1\")\n" +
+		"line 3: duplicate tag, first defined on line 2 (\"#tag1\")"
+	if err == nil || err.Error() != expected {
+		t.Errorf("wrong error message\nexpected: %s\nactual:   %v", expected, err)
+	}
+	if !errors.Is(err, ErrDuplicateTag) {
+		t.Errorf("parse error does not unwrap to line errors")
+	}
+}
+
+func TestValidateTag(t *testing.T) {
+	type tc struct {
+		name     string
+		expected error
+	}
+
+	table := []tc{
+		{name: "#tag"},
+		{name: "#tag with spaces"},
+		{name: "tag", expected: ErrTagWithoutHash},
+		{name: "#", expected: ErrTagWithoutHash},
+		{name: "#tag=alias", expected: ErrTagReservedChar},
+		{name: "#tag\n#other", expected: ErrTagReservedChar},
+	}
+
+	for _, test := range table {
+		actual := ValidateTag(test.name)
+		if actual != test.expected {
+			t.Errorf("%q\nexpected: %+v\nactual:   %+v", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	type tc struct {
+		name     string
+		groups   []models.Group
+		expected []error
+	}
+
+	table := []tc{
+		{
+			name: "should accept valid groups",
+			groups: []models.Group{
+				{Name: "Group 1", Tags: []models.Tag{{Name: "#tag1", Aliases: []string{"one"}}}},
+				{Name: "Group 2", Tags: []models.Tag{{Name: "#tag2"}}},
+			},
+		},
+
+		{
+			name:     "should reject empty menu",
+			groups:   []models.Group{},
+			expected: []error{ErrNoGroups},
+		},
+
+		{
+			name: "should report every problem",
+			groups: []models.Group{
+				{Name: "Group 1:", Tags: []models.Tag{{Name: "tag1"}}},
+				{Name: "Group 1:", Tags: []models.Tag{}},
+				{Name: " ", Tags: []models.Tag{{Name: "#tag2", Aliases: []string{"a,b"}}, {Name: "#tag2"}}},
+			},
+			expected: []error{
+				ErrGroupReserved,
+				ErrTagWithoutHash,
+				ErrDuplicateGroup,
+				ErrEmptyGroup,
+				ErrMissingGroupName,
+				ErrInvalidAlias,
+				ErrDuplicateTag,
+			},
+		},
+	}
+
+	for _, test := range table {
+		err := Validate(test.groups)
+		if len(test.expected) == 0 {
+			if err != nil {
+				t.Errorf("%s - unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		for _, expected := range test.expected {
+			if !errors.Is(err, expected) {
+				t.Errorf("%s - missing error\nexpected: %+v\nactual:   %+v", test.name, expected, err)
+			}
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	groups := []models.Group{
+		{Name: "Group 1", Tags: []models.Tag{{Name: "#tag1"}, {Name: "#tag2"}}},
+		{Name: "Group 2", OriginalIndex: 1, Tags: []models.Tag{{Name: "#tag3", Aliases: []string{"a", "b"}}}},
+	}
+	expected := "• Group 1:\n#tag1\n#tag2\n\n• Group 2:\n#tag3 = a, b"
+	actual := Format(groups)
+	if actual != expected {
+		t.Errorf("wrong format\nexpected: %q\nactual:   %q", expected, actual)
+	}
+	parsed, err := Parse(actual)
+	if err != nil {
+		t.Errorf("failed to parse formatted groups: %v", err)
+	}
+	if !reflect.DeepEqual(groups, parsed) {
+		t.Errorf("format is not reversible\nexpected: %+v\nactual:   %+v", groups, parsed)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add("Tags list\n\n• Group 1:\n#tag1\n#tag2\n\n• Group 2:\n#tag3")
+	f.Add("• Group\n#tag")
+	f.Add("• :\n\n#")
+	f.Add("// comment\n•\n•:\ntag")
+	f.Add("")
+	f.Add("• Group:\n#tag = alias, other\n#tag2=")
+	f.Fuzz(func(t *testing.T, input string) {
+		groups, err := Parse(input)
+		if err != nil {
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) || len(parseErr.Errors) == 0 {
+				t.Fatalf("unexpected error type %T: %v", err, err)
+			}
+			return
+		}
+		if len(groups) == 0 {
+			t.Fatalf("parsed without errors but no groups returned")
+		}
+		reparsed, err := Parse(Format(groups))
+		if err != nil {
+			t.Fatalf("failed to parse formatted output: %v", err)
+		}
+		if !reflect.DeepEqual(groups, reparsed) {
+			t.Fatalf("format is not reversible\nexpected: %+v\nactual:   %+v", groups, reparsed)
+		}
+	})
+}
@@ -0,0 +1,317 @@
+package webapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"ratatoskr/internal/analytics"
+	"ratatoskr/internal/config"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/logger"
+	"ratatoskr/internal/models"
+	"time"
+)
+
+const (
+	apiPrefix              = "/api/v1"
+	defaultRecentPosts     = 20
+	maxRecentPosts         = 100
+	recentPostsLookback    = time.Hour * 24 * 30
+	defaultSummaryLookback = time.Hour * 24 * 30
+)
+
+const (
+	apiErrUnauthorized = "unauthorized"
+	apiErrNotFound     = "not_found"
+	apiErrBadRequest   = "bad_request"
+	apiErrInternal     = "internal"
+	apiErrForbidden    = "forbidden"
+	apiErrConflict     = "conflict"
+)
+
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type apiTag struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+type apiGroup struct {
+	Name  string   `json:"name"`
+	Index int      `json:"index"`
+	Tags  []apiTag `json:"tags"`
+}
+
+type apiTagUsage struct {
+	Tag   string `json:"tag"`
+	Group string `json:"group"`
+	Count int    `json:"count"`
+}
+
+type apiAdminSummary struct {
+	UserID   int64         `json:"userId"`
+	Total    int           `json:"total"`
+	Posts    int           `json:"posts"`
+	LastUsed time.Time     `json:"lastUsed"`
+	Tags     []apiTagUsage `json:"tags"`
+}
+
+type apiPost struct {
+	DestinationChatID int64        `json:"destinationChatId"`
+	ChannelMessageIDs []int64      `json:"channelMessageIds"`
+	UserID            int64        `json:"userId,omitempty"`
+	MediaKind         string       `json:"mediaKind,omitempty"`
+	ItemCount         int          `json:"itemCount,omitempty"`
+	PostingMode       string       `json:"postingMode,omitempty"`
+	Date              time.Time    `json:"date"`
+	Tags              []apiPostTag `json:"tags"`
+	CorrelationID     string       `json:"correlationId,omitempty"`
+}
+
+type apiPostTag struct {
+	Tag   string `json:"tag"`
+	Group string `json:"group"`
+}
+
+func addAPIRoutes(
+	mux *http.ServeMux,
+	config *config.WepAppConfig,
+	db db.DB,
+	logger *logger.Logger,
+	now func() time.Time,
+) {
+	route := func(pattern string, h apiHandler) {
+		mux.Handle(pattern, apiAuth(config, logger, h))
+	}
+	route("GET "+apiPrefix+"/groups", apiListGroups(db))
+	route("GET "+apiPrefix+"/groups/{name}", apiGetGroup(db))
+	route("GET "+apiPrefix+"/analytics/summary", apiAnalyticsSummary(db, now))
+	route("GET "+apiPrefix+"/posts/recent", apiRecentPosts(db, now))
+	route(apiPrefix+"/", func(r *http.Request) (any, *apiFailure) {
+		return nil, &apiFailure{
+			status: http.StatusNotFound,
+			body:   apiError{Code: apiErrNotFound, Message: fmt.Sprintf("no endpoint %s %s", r.Method, r.URL.Path)},
+		}
+	})
+}
+
+type apiFailure struct {
+	status int
+	body   apiError
+	err    error
+}
+
+// apiHandler returns the value to encode as the response body, or a failure
+// that is written as {"error": {"code": ..., "message": ...}}.
+type apiHandler func(r *http.Request) (any, *apiFailure)
+
+func apiAuth(c *config.WepAppConfig, l *logger.Logger, next apiHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l := l.WithContext(r.Context())
+		if !hasValidToken(c, r) {
+			writeAPI(w, l, nil, &apiFailure{
+				status: http.StatusUnauthorized,
+				body:   apiError{Code: apiErrUnauthorized, Message: "missing or invalid token"},
+			})
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+		res, failure := next(r.WithContext(ctx))
+		writeAPI(w, l, res, failure)
+	})
+}
+
+type adminContextKey struct{}
+
+// adminAPIAuth lets through requests signed with webapp init data of an
+// admin, whose id is then available through adminFromContext.
+func adminAPIAuth(
+	c *config.WepAppConfig,
+	l *logger.Logger,
+	now func() time.Time,
+	next apiHandler,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l := l.WithContext(r.Context())
+		userID, err := webAppAdmin(c, r, now())
+		if err != nil {
+			l.Error("rejected admin api request", "error", err)
+			writeAPI(w, l, nil, &apiFailure{
+				status: http.StatusForbidden,
+				body:   apiError{Code: apiErrForbidden, Message: "missing or invalid init data"},
+			})
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+		ctx = context.WithValue(ctx, adminContextKey{}, userID)
+		res, failure := next(r.WithContext(ctx))
+		writeAPI(w, l, res, failure)
+	})
+}
+
+func adminFromContext(ctx context.Context) int64 {
+	userID, _ := ctx.Value(adminContextKey{}).(int64)
+	return userID
+}
+
+func writeAPI(w http.ResponseWriter, l *logger.Logger, res any, failure *apiFailure) {
+	status := http.StatusOK
+	if failure != nil {
+		if failure.err != nil {
+			l.Error("api request failed", "status", failure.status, "error", failure.err)
+		}
+		status = failure.status
+		res = struct {
+			Error apiError `json:"error"`
+		}{failure.body}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(res)
+	if err != nil {
+		l.Error("failed to write api response", "error", err)
+	}
+}
+
+func badRequest(err error) *apiFailure {
+	return &apiFailure{
+		status: http.StatusBadRequest,
+		body:   apiError{Code: apiErrBadRequest, Message: err.Error()},
+	}
+}
+
+func internalError(err error) *apiFailure {
+	return &apiFailure{
+		status: http.StatusInternalServerError,
+		body:   apiError{Code: apiErrInternal, Message: "internal server error"},
+		err:    err,
+	}
+}
+
+func apiListGroups(db db.DB) apiHandler {
+	type response struct {
+		Groups []apiGroup `json:"groups"`
+	}
+	return func(r *http.Request) (any, *apiFailure) {
+		groups, err := db.GetAllGroupsWithTags(r.Context())
+		if err != nil {
+			return nil, internalError(err)
+		}
+		res := response{Groups: []apiGroup{}}
+		for _, g := range *groups {
+			res.Groups = append(res.Groups, newAPIGroup(g))
+		}
+		return res, nil
+	}
+}
+
+func apiGetGroup(db db.DB) apiHandler {
+	return func(r *http.Request) (any, *apiFailure) {
+		name := r.PathValue("name")
+		groups, err := db.GetAllGroupsWithTags(r.Context())
+		if err != nil {
+			return nil, internalError(err)
+		}
+		for _, g := range *groups {
+			if g.Name == name {
+				return newAPIGroup(g), nil
+			}
+		}
+		return nil, &apiFailure{
+			status: http.StatusNotFound,
+			body:   apiError{Code: apiErrNotFound, Message: fmt.Sprintf("group %q not found", name)},
+		}
+	}
+}
+
+func apiAnalyticsSummary(db db.DB, now func() time.Time) apiHandler {
+	type response struct {
+		From   time.Time         `json:"from"`
+		To     time.Time         `json:"to"`
+		Admins []apiAdminSummary `json:"admins"`
+	}
+	return func(r *http.Request) (any, *apiFailure) {
+		query := r.URL.Query()
+		current := now()
+		from, to, err := analytics.ParseRange(query.Get("from"), query.Get("to"), current)
+		if err != nil {
+			return nil, badRequest(err)
+		}
+		if query.Get("from") == "" {
+			from = to.Add(-defaultSummaryLookback)
+		}
+		admins, err := db.GetAdminAnalytics(r.Context(), from, to)
+		if err != nil {
+			return nil, internalError(err)
+		}
+		res := response{From: from, To: to, Admins: []apiAdminSummary{}}
+		for _, a := range *admins {
+			summary := apiAdminSummary{
+				UserID:   a.UserID,
+				Total:    a.Total,
+				Posts:    a.Posts,
+				LastUsed: a.LastUsed,
+				Tags:     []apiTagUsage{},
+			}
+			for _, t := range a.Tags {
+				summary.Tags = append(summary.Tags, apiTagUsage(t))
+			}
+			res.Admins = append(res.Admins, summary)
+		}
+		return res, nil
+	}
+}
+
+func apiRecentPosts(db db.DB, now func() time.Time) apiHandler {
+	type response struct {
+		Posts []apiPost `json:"posts"`
+	}
+	return func(r *http.Request) (any, *apiFailure) {
+		limit, err := parseLimit(r.URL.Query().Get("limit"), defaultRecentPosts, maxRecentPosts)
+		if err != nil {
+			return nil, badRequest(err)
+		}
+		posts, err := db.GetRecentPosts(r.Context(), now().Add(-recentPostsLookback), limit)
+		if err != nil {
+			return nil, internalError(err)
+		}
+		res := response{Posts: []apiPost{}}
+		for _, p := range *posts {
+			res.Posts = append(res.Posts, newAPIPost(p))
+		}
+		return res, nil
+	}
+}
+
+func newAPIGroup(g models.Group) apiGroup {
+	group := apiGroup{Name: g.Name, Index: g.OriginalIndex, Tags: []apiTag{}}
+	for _, t := range g.Tags {
+		group.Tags = append(group.Tags, apiTag{Name: t.Name, Aliases: t.Aliases})
+	}
+	return group
+}
+
+func newAPIPost(p models.Post) apiPost {
+	post := apiPost{
+		DestinationChatID: p.DestinationChatID,
+		ChannelMessageIDs: p.ChannelMessageIDs,
+		UserID:            p.UserID,
+		MediaKind:         p.MediaKind,
+		ItemCount:         p.ItemCount,
+		PostingMode:       p.PostingMode,
+		Date:              p.Date.UTC(),
+		Tags:              []apiPostTag{},
+		CorrelationID:     p.CorrelationID,
+	}
+	for _, t := range p.Tags {
+		post.Tags = append(post.Tags, apiPostTag(t))
+	}
+	return post
+}
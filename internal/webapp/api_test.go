@@ -0,0 +1,151 @@
+package webapp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"ratatoskr/internal/models"
+	"testing"
+	"time"
+)
+
+func TestAPI(t *testing.T) {
+	postDate := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	handler := newTestServer(t, dbMock{
+		admins: []models.AdminAnalytics{{
+			UserID:   1234,
+			Total:    3,
+			Posts:    2,
+			LastUsed: time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC),
+			Tags:     []models.TagUsage{{Tag: "#tag1", Group: "group1", Count: 3}},
+		}},
+		posts: []models.Post{
+			{
+				DestinationChatID: -100,
+				ChannelMessageIDs: []int64{7, 8},
+				UserID:            1234,
+				MediaKind:         models.MediaKindAlbum,
+				ItemCount:         2,
+				PostingMode:       models.PostingModeImmediate,
+				Date:              postDate,
+				Tags:              []models.PostTag{{Tag: "#tag1", Group: "group1"}},
+				CorrelationID:     "abc123",
+			},
+			{Date: postDate.AddDate(-1, 0, 0)},
+		},
+	})
+
+	type tc struct {
+		name     string
+		method   string
+		url      string
+		token    string
+		status   int
+		expected string
+	}
+
+	table := []tc{
+		{
+			name:     "should reject missing token",
+			url:      "/api/v1/groups",
+			status:   http.StatusUnauthorized,
+			expected: `{"error":{"code":"unauthorized","message":"missing or invalid token"}}`,
+		},
+
+		{
+			name:   "should list groups",
+			url:    "/api/v1/groups",
+			token:  "TOKEN",
+			status: http.StatusOK,
+			expected: `{"groups":[` +
+				`{"name":"group1","index":0,"tags":[{"name":"#tag1"},{"name":"#tag2"}]},` +
+				`{"name":"group2","index":1,"tags":[{"name":"#tag3"}]}]}`,
+		},
+
+		{
+			name:     "should get one group",
+			url:      "/api/v1/groups/group2",
+			token:    "TOKEN",
+			status:   http.StatusOK,
+			expected: `{"name":"group2","index":1,"tags":[{"name":"#tag3"}]}`,
+		},
+
+		{
+			name:     "should report missing group",
+			url:      "/api/v1/groups/missing",
+			token:    "TOKEN",
+			status:   http.StatusNotFound,
+			expected: `{"error":{"code":"not_found","message":"group \"missing\" not found"}}`,
+		},
+
+		{
+			name:   "should summarize analytics",
+			url:    "/api/v1/analytics/summary?from=2024-01-01&to=2024-01-31",
+			token:  "TOKEN",
+			status: http.StatusOK,
+			expected: `{"from":"2024-01-01T00:00:00Z","to":"2024-02-01T00:00:00Z","admins":[` +
+				`{"userId":1234,"total":3,"posts":2,"lastUsed":"2024-01-10T00:00:00Z",` +
+				`"tags":[{"tag":"#tag1","group":"group1","count":3}]}]}`,
+		},
+
+		{
+			name:     "should reject invalid range",
+			url:      "/api/v1/analytics/summary?from=yesterday",
+			token:    "TOKEN",
+			status:   http.StatusBadRequest,
+			expected: `{"error":{"code":"bad_request","message":"invalid from date \"yesterday\", expected YYYY-MM-DD"}}`,
+		},
+
+		{
+			name:   "should return recent posts",
+			url:    "/api/v1/posts/recent",
+			token:  "TOKEN",
+			status: http.StatusOK,
+			expected: `{"posts":[{"destinationChatId":-100,"channelMessageIds":[7,8],"userId":1234,` +
+				`"mediaKind":"album","itemCount":2,"postingMode":"immediate",` +
+				`"date":"` + postDate.Format(time.RFC3339) + `",` +
+				`"tags":[{"tag":"#tag1","group":"group1"}],"correlationId":"abc123"}]}`,
+		},
+
+		{
+			name:     "should reject invalid limit",
+			url:      "/api/v1/posts/recent?limit=0",
+			token:    "TOKEN",
+			status:   http.StatusBadRequest,
+			expected: `{"error":{"code":"bad_request","message":"invalid limit \"0\""}}`,
+		},
+
+		{
+			name:     "should report unknown endpoint",
+			method:   http.MethodPost,
+			url:      "/api/v1/groups",
+			token:    "TOKEN",
+			status:   http.StatusNotFound,
+			expected: `{"error":{"code":"not_found","message":"no endpoint POST /api/v1/groups"}}`,
+		},
+	}
+
+	for _, test := range table {
+		method := test.method
+		if method == "" {
+			method = http.MethodGet
+		}
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, test.url, nil)
+		if test.token != "" {
+			req.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		handler.ServeHTTP(rec, req)
+		res := rec.Result()
+		body, _ := io.ReadAll(res.Body)
+		if res.StatusCode != test.status {
+			t.Errorf("%s - wrong status, expected %d, actual %d", test.name, test.status, res.StatusCode)
+		}
+		if actual := res.Header.Get("Content-Type"); actual != "application/json" {
+			t.Errorf("%s - wrong content type %q", test.name, actual)
+		}
+		if string(body) != test.expected+"\n" {
+			t.Errorf("%s - wrong body\nexpected: %s\nactual:   %s", test.name, test.expected, body)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package webapp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"html/template"
+	"net/http"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/models"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// menuCheckInterval is how long a cached menu is served before its version
+// is compared with the database again.
+const menuCheckInterval = time.Second * 5
+
+type cachedMenu struct {
+	version int64
+	groups  []models.Group
+	tags    map[string]string
+	// HTML is the rendered menu, exported for the page template
+	HTML template.HTML
+}
+
+// menuCache keeps the tag menu and its rendered groups in memory. Every
+// change to the menu increments its version, so the menu is only read again
+// once the version in the database moved on.
+type menuCache struct {
+	db       db.DB
+	template *template.Template
+	interval time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	menu      *cachedMenu
+}
+
+func newMenuCache(
+	db db.DB,
+	template *template.Template,
+	interval time.Duration,
+	now func() time.Time,
+) *menuCache {
+	return &menuCache{db: db, template: template, interval: interval, now: now}
+}
+
+func (c *menuCache) get(ctx context.Context) (*cachedMenu, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if c.menu != nil && now.Sub(c.checkedAt) < c.interval {
+		return c.menu, nil
+	}
+	version, err := c.db.GetTagMenuVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if c.menu != nil && c.menu.version == version {
+		c.checkedAt = now
+		return c.menu, nil
+	}
+	// the menu is cached under the version read together with its groups,
+	// not the one checked above, so groups older than that version are
+	// read again on the next check
+	menu, err := c.db.GetTagMenu(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var html bytes.Buffer
+	err = c.template.ExecuteTemplate(&html, "groups", menu.Groups)
+	if err != nil {
+		return nil, err
+	}
+	c.menu = &cachedMenu{
+		version: menu.Version,
+		groups:  menu.Groups,
+		tags:    menuTags(menu.Groups),
+		HTML:    template.HTML(html.String()),
+	}
+	c.checkedAt = now
+	return c.menu, nil
+}
+
+// invalidate drops the cached menu after the webapp changed it itself.
+func (c *menuCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.menu = nil
+}
+
+// writeCached writes body with an ETag, answers a matching If-None-Match
+// with 304 and compresses the body for clients accepting gzip.
+func writeCached(w http.ResponseWriter, r *http.Request, contentType string, body []byte) error {
+	sum := sha256.Sum256(body)
+	etag := fmt.Sprintf(`"%x"`, sum[:16])
+	compress := acceptsGzip(r.Header.Get("Accept-Encoding"))
+	if compress {
+		etag = fmt.Sprintf(`"%x-gzip"`, sum[:16])
+	}
+	h := w.Header()
+	h.Set("ETag", etag)
+	h.Set("Vary", "Accept-Encoding")
+	h.Set("Cache-Control", "private, no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	h.Set("Content-Type", contentType)
+	if !compress {
+		h.Set("Content-Length", strconv.Itoa(len(body)))
+		_, err := w.Write(body)
+		return err
+	}
+	h.Set("Content-Encoding", "gzip")
+	gz := gzip.NewWriter(w)
+	_, err := gz.Write(body)
+	if err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.TrimSpace(coding)
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q=")
+		if !ok {
+			return true
+		}
+		weight, err := strconv.ParseFloat(q, 64)
+		return err == nil && weight > 0
+	}
+	return false
+}
+
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,243 @@
+package webapp
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+type menuDBMock struct {
+	db.DB
+	version int64
+	groups  []models.Group
+	// written is the version the groups belong to, behind version while a
+	// save is in progress
+	written int64
+	reads   int
+}
+
+func (m *menuDBMock) GetTagMenuVersion(context.Context) (int64, error) {
+	return m.version, nil
+}
+
+func (m *menuDBMock) GetTagMenu(context.Context) (*models.TagMenu, error) {
+	m.reads++
+	return &models.TagMenu{Version: m.written, Groups: m.groups}, nil
+}
+
+func TestMenuCache(t *testing.T) {
+	template, err := loadTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	database := &menuDBMock{
+		version: 1,
+		written: 1,
+		groups:  []models.Group{{Name: "group1", Tags: []models.Tag{{Name: "#tag1"}}}},
+	}
+	cache := newMenuCache(database, template, time.Second*5, func() time.Time { return now })
+
+	type tc struct {
+		name    string
+		change  func()
+		reads   int
+		version int64
+		tag     string
+	}
+
+	table := []tc{
+		{
+			name:    "should read menu once",
+			change:  func() {},
+			reads:   1,
+			version: 1,
+			tag:     "#tag1",
+		},
+
+		{
+			name: "should not check version within interval",
+			change: func() {
+				database.version = 2
+				database.written = 2
+				database.groups = []models.Group{{Name: "group1", Tags: []models.Tag{{Name: "#tag2"}}}}
+			},
+			reads:   1,
+			version: 1,
+			tag:     "#tag1",
+		},
+
+		{
+			name:    "should reload changed menu after interval",
+			change:  func() { now = now.Add(time.Second * 5) },
+			reads:   2,
+			version: 2,
+			tag:     "#tag2",
+		},
+
+		{
+			name:    "should keep menu at same version",
+			change:  func() { now = now.Add(time.Second * 5) },
+			reads:   2,
+			version: 2,
+			tag:     "#tag2",
+		},
+
+		{
+			name:    "should reload invalidated menu",
+			change:  func() { cache.invalidate() },
+			reads:   3,
+			version: 2,
+			tag:     "#tag2",
+		},
+
+		{
+			name: "should keep groups read during a save under their own version",
+			change: func() {
+				now = now.Add(time.Second * 5)
+				database.version = 3
+			},
+			reads:   4,
+			version: 2,
+			tag:     "#tag2",
+		},
+
+		{
+			name: "should reload groups once the save is done",
+			change: func() {
+				now = now.Add(time.Second * 5)
+				database.written = 3
+				database.groups = []models.Group{{Name: "group1", Tags: []models.Tag{{Name: "#tag3"}}}}
+			},
+			reads:   5,
+			version: 3,
+			tag:     "#tag3",
+		},
+	}
+
+	for _, test := range table {
+		test.change()
+		menu, err := cache.get(context.Background())
+		if err != nil {
+			t.Fatalf("%s - failed to get menu: %v", test.name, err)
+		}
+		if database.reads != test.reads {
+			t.Errorf("%s - wrong reads\nexpected: %d\nactual:   %d", test.name, test.reads, database.reads)
+		}
+		if menu.version != test.version {
+			t.Errorf("%s - wrong version\nexpected: %d\nactual:   %d", test.name, test.version, menu.version)
+		}
+		if _, ok := menu.tags[test.tag]; !ok || !strings.Contains(string(menu.HTML), test.tag) {
+			t.Errorf("%s - tag %q missing\nactual: %+v %s", test.name, test.tag, menu.tags, menu.HTML)
+		}
+	}
+}
+
+func TestWriteCached(t *testing.T) {
+	body := []byte("<html>menu</html>")
+	rec := httptest.NewRecorder()
+	writeCached(rec, httptest.NewRequest(http.MethodGet, "/", nil), "text/html", body)
+	etag := rec.Header().Get("ETag")
+	gzipRec := httptest.NewRecorder()
+	gzipReq := httptest.NewRequest(http.MethodGet, "/", nil)
+	gzipReq.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	writeCached(gzipRec, gzipReq, "text/html", body)
+	gzipETag := gzipRec.Header().Get("ETag")
+
+	type tc struct {
+		name           string
+		acceptEncoding string
+		ifNoneMatch    string
+		status         int
+		encoding       string
+		etag           string
+	}
+
+	table := []tc{
+		{
+			name:   "should write plain body",
+			status: http.StatusOK,
+			etag:   etag,
+		},
+
+		{
+			name:           "should compress body",
+			acceptEncoding: "deflate, gzip;q=0.5",
+			status:         http.StatusOK,
+			encoding:       "gzip",
+			etag:           gzipETag,
+		},
+
+		{
+			name:           "should not compress refused gzip",
+			acceptEncoding: "gzip;q=0",
+			status:         http.StatusOK,
+			etag:           etag,
+		},
+
+		{
+			name:        "should answer matching etag",
+			ifNoneMatch: `"other", ` + etag,
+			status:      http.StatusNotModified,
+			etag:        etag,
+		},
+
+		{
+			name:           "should answer matching weak etag of compressed body",
+			acceptEncoding: "gzip",
+			ifNoneMatch:    "W/" + gzipETag,
+			status:         http.StatusNotModified,
+			etag:           gzipETag,
+		},
+
+		{
+			name:           "should not answer etag of other encoding",
+			acceptEncoding: "gzip",
+			ifNoneMatch:    etag,
+			status:         http.StatusOK,
+			encoding:       "gzip",
+			etag:           gzipETag,
+		},
+	}
+
+	for _, test := range table {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", test.acceptEncoding)
+		req.Header.Set("If-None-Match", test.ifNoneMatch)
+		rec := httptest.NewRecorder()
+		err := writeCached(rec, req, "text/html", body)
+		if err != nil {
+			t.Fatalf("%s - %v", test.name, err)
+		}
+		if rec.Code != test.status {
+			t.Errorf("%s - wrong status, expected %d, actual %d", test.name, test.status, rec.Code)
+		}
+		if actual := rec.Header().Get("ETag"); actual != test.etag {
+			t.Errorf("%s - wrong etag\nexpected: %s\nactual:   %s", test.name, test.etag, actual)
+		}
+		if actual := rec.Header().Get("Content-Encoding"); actual != test.encoding {
+			t.Errorf("%s - wrong encoding\nexpected: %q\nactual:   %q", test.name, test.encoding, actual)
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+		var reader io.Reader = rec.Body
+		if test.encoding == "gzip" {
+			reader, err = gzip.NewReader(rec.Body)
+			if err != nil {
+				t.Fatalf("%s - %v", test.name, err)
+			}
+		}
+		actual, err := io.ReadAll(reader)
+		if err != nil || string(actual) != string(body) {
+			t.Errorf("%s - wrong body\nexpected: %s\nactual:   %s", test.name, body, actual)
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package webapp
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"net/url"
+	"ratatoskr/internal/analytics"
+	"ratatoskr/internal/config"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/logger"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDashboardDays = 30
+	defaultUnusedDays    = 30
+	maxUnusedDays        = 365
+	dashboardTopTags     = 15
+	chartWidth           = 640
+	chartHeight          = 200
+	chartAxisLabels      = 6
+	donutRadius          = 15.9155
+	dashboardCookie      = "dashboard_session"
+	dashboardSessionTTL  = time.Minute * 15
+)
+
+var chartColors = []string{
+	"#4e79a7",
+	"#f28e2b",
+	"#e15759",
+	"#76b7b2",
+	"#59a14f",
+	"#edc948",
+	"#b07aa1",
+	"#ff9da7",
+	"#9c755f",
+	"#bab0ac",
+}
+
+type chartBar struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+	Title  string
+}
+
+type chartLabel struct {
+	X    float64
+	Text string
+}
+
+type postsChart struct {
+	Width  int
+	Height int
+	Max    int
+	Bars   []chartBar
+	Labels []chartLabel
+}
+
+type rowBar struct {
+	Label   string
+	Group   string
+	Value   int
+	Percent float64
+}
+
+type donutSlice struct {
+	Label   string
+	Value   int
+	Percent float64
+	Color   string
+	Dash    string
+	Offset  float64
+}
+
+type dashboardPage struct {
+	Version    string
+	Error      string
+	From       string
+	To         string
+	UnusedDays int
+	Dashboard  *analytics.Dashboard
+	Posts      postsChart
+	TopTags    []rowBar
+	Groups     []donutSlice
+}
+
+// handleDashboard renders the charts for admins holding a dashboard cookie.
+// Telegram passes the init data only in the URL fragment, so without the
+// cookie the page posts it once to handleDashboardLogin.
+func handleDashboard(
+	config *config.WepAppConfig,
+	db db.DB,
+	logger *logger.Logger,
+	template *template.Template,
+	now func() time.Time,
+) http.HandlerFunc {
+	type login struct {
+		Version string
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.WithContext(r.Context())
+		w.Header().Set("Cache-Control", "no-store")
+		current := now()
+		_, err := dashboardAdmin(config, r, current)
+		if err != nil {
+			err = template.ExecuteTemplate(w, "dashboard-login", login{Version: config.Version})
+			if err != nil {
+				log.Error("failed to render dashboard login", "error", err)
+			}
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+		page, failure := newDashboardPage(ctx, db, r.URL.Query(), current)
+		page.Version = config.Version
+		status := http.StatusOK
+		if failure != nil {
+			if failure.err != nil {
+				log.Error("failed to build dashboard", "error", failure.err)
+			}
+			page.Error = failure.body.Message
+			status = failure.status
+		}
+		var html bytes.Buffer
+		err = template.ExecuteTemplate(&html, "dashboard", page)
+		if err != nil {
+			log.Error("failed to render dashboard", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		_, err = w.Write(html.Bytes())
+		if err != nil {
+			log.Error("failed to write dashboard", "error", err)
+		}
+	}
+}
+
+// handleDashboardLogin trades init data of an admin for a short-lived
+// dashboard cookie and sends the webapp back to the dashboard.
+func handleDashboardLogin(
+	config *config.WepAppConfig,
+	logger *logger.Logger,
+	now func() time.Time,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.WithContext(r.Context())
+		r.Body = http.MaxBytesReader(w, r.Body, 8192)
+		current := now()
+		userID, err := initDataAdmin(config, r.PostFormValue("initData"), current)
+		if err != nil {
+			log.Error("rejected dashboard login", "error", err)
+			http.Error(w, "missing or invalid init data", http.StatusForbidden)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     dashboardCookie,
+			Value:    signDashboardSession(config.Token, userID, current.Add(dashboardSessionTTL)),
+			Path:     "/dashboard",
+			MaxAge:   int(dashboardSessionTTL.Seconds()),
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		target := "/dashboard"
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusSeeOther)
+	}
+}
+
+// The session cookie holds the admin id and its expiry signed with a key
+// derived from the bot token, so it needs no server-side state.
+func signDashboardSession(botToken string, userID int64, expires time.Time) string {
+	payload := fmt.Sprintf("%d.%d", userID, expires.Unix())
+	return payload + "." + dashboardSignature(botToken, payload)
+}
+
+func dashboardSignature(botToken string, payload string) string {
+	secret := hmac.New(sha256.New, []byte("DashboardSession"))
+	secret.Write([]byte(botToken))
+	sign := hmac.New(sha256.New, secret.Sum(nil))
+	sign.Write([]byte(payload))
+	return hex.EncodeToString(sign.Sum(nil))
+}
+
+func dashboardAdmin(c *config.WepAppConfig, r *http.Request, now time.Time) (int64, error) {
+	cookie, err := r.Cookie(dashboardCookie)
+	if err != nil {
+		return 0, err
+	}
+	i := strings.LastIndex(cookie.Value, ".")
+	if i < 0 {
+		return 0, fmt.Errorf("malformed dashboard session")
+	}
+	payload, signature := cookie.Value[:i], cookie.Value[i+1:]
+	if !hmac.Equal([]byte(dashboardSignature(c.Token, payload)), []byte(signature)) {
+		return 0, fmt.Errorf("dashboard session signature mismatch")
+	}
+	id, expires, _ := strings.Cut(payload, ".")
+	userID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("malformed dashboard session")
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("malformed dashboard session")
+	}
+	if !now.Before(time.Unix(unix, 0)) {
+		return 0, fmt.Errorf("dashboard session expired")
+	}
+	if !isAdmin(c.AdminIDs, userID) {
+		return 0, fmt.Errorf("user %d is not an admin", userID)
+	}
+	return userID, nil
+}
+
+func newDashboardPage(
+	ctx context.Context,
+	db db.DB,
+	query url.Values,
+	current time.Time,
+) (dashboardPage, *apiFailure) {
+	page := dashboardPage{
+		From:       query.Get("from"),
+		To:         query.Get("to"),
+		UnusedDays: defaultUnusedDays,
+	}
+	from, to, err := analytics.ParseRange(query.Get("from"), query.Get("to"), current)
+	if err != nil {
+		return page, badRequest(err)
+	}
+	if query.Get("from") == "" {
+		from = startOfUTCDay(to.Add(-time.Nanosecond)).AddDate(0, 0, 1-defaultDashboardDays)
+	}
+	if u := query.Get("unused"); u != "" {
+		page.UnusedDays, err = strconv.Atoi(u)
+		if err != nil || page.UnusedDays < 1 || page.UnusedDays > maxUnusedDays {
+			page.UnusedDays = defaultUnusedDays
+			return page, badRequest(
+				fmt.Errorf("unused days must be between 1 and %d", maxUnusedDays),
+			)
+		}
+	}
+	page.From = from.Format(analytics.DateLayout)
+	page.To = to.Add(-time.Nanosecond).Format(analytics.DateLayout)
+	d, err := analytics.BuildDashboard(
+		ctx,
+		db,
+		from,
+		to,
+		current.AddDate(0, 0, -page.UnusedDays),
+		current,
+		dashboardTopTags,
+	)
+	if err != nil {
+		return page, internalError(fmt.Errorf("failed to build dashboard: %w", err))
+	}
+	page.Dashboard = d
+	page.Posts = newPostsChart(d.Days)
+	page.TopTags = newRowBars(d)
+	page.Groups = newDonut(d)
+	return page, nil
+}
+
+func newPostsChart(days []analytics.DayPosts) postsChart {
+	chart := postsChart{Width: chartWidth, Height: chartHeight, Bars: []chartBar{}, Labels: []chartLabel{}}
+	for _, d := range days {
+		chart.Max = max(chart.Max, d.Posts)
+	}
+	if len(days) == 0 {
+		return chart
+	}
+	step := float64(chartWidth) / float64(len(days))
+	every := max(1, int(math.Ceil(float64(len(days))/chartAxisLabels)))
+	for i, d := range days {
+		height := 0.0
+		if chart.Max != 0 {
+			height = float64(chartHeight) * float64(d.Posts) / float64(chart.Max)
+		}
+		chart.Bars = append(chart.Bars, chartBar{
+			X:      step * float64(i),
+			Y:      float64(chartHeight) - height,
+			Width:  math.Max(step-1, 1),
+			Height: height,
+			Title:  fmt.Sprintf("%s: %d", d.Day.Format(analytics.DateLayout), d.Posts),
+		})
+		if i%every == 0 {
+			chart.Labels = append(chart.Labels, chartLabel{
+				X:    step*float64(i) + step/2,
+				Text: d.Day.Format("Jan 2"),
+			})
+		}
+	}
+	return chart
+}
+
+func newRowBars(d *analytics.Dashboard) []rowBar {
+	bars := []rowBar{}
+	if len(d.TopTags) == 0 {
+		return bars
+	}
+	top := d.TopTags[0].Count
+	for _, t := range d.TopTags {
+		bars = append(bars, rowBar{
+			Label:   t.Tag,
+			Group:   t.Group,
+			Value:   t.Count,
+			Percent: 100 * float64(t.Count) / float64(top),
+		})
+	}
+	return bars
+}
+
+// newDonut draws every group as a dashed circle stroke. The radius makes the
+// circumference 100, so dash lengths are plain percentages.
+func newDonut(d *analytics.Dashboard) []donutSlice {
+	slices := []donutSlice{}
+	if d.TotalTags == 0 {
+		return slices
+	}
+	offset := 0.0
+	for i, g := range d.Groups {
+		percent := 100 * float64(g.Count) / float64(d.TotalTags)
+		slices = append(slices, donutSlice{
+			Label:   g.Group,
+			Value:   g.Count,
+			Percent: percent,
+			Color:   chartColors[i%len(chartColors)],
+			Dash:    fmt.Sprintf("%.3f %.3f", percent, 100-percent),
+			Offset:  25 - offset,
+		})
+		offset += percent
+	}
+	return slices
+}
+
+func startOfUTCDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
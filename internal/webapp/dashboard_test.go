@@ -0,0 +1,218 @@
+package webapp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"ratatoskr/internal/analytics"
+	"ratatoskr/internal/config"
+	"ratatoskr/internal/models"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDashboard(t *testing.T) {
+	handler := newTestServer(t, dbMock{
+		daily: []models.PostRollup{
+			{Start: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), UserID: 1234, Posts: 3},
+		},
+		admins: []models.AdminAnalytics{{
+			UserID: 1234,
+			Tags: []models.TagUsage{
+				{Tag: "#tag1", Group: "group1", Count: 3},
+				{Tag: "#tag3", Group: "group2", Count: 1},
+			},
+		}},
+	})
+	session := func(userID int64, expires time.Duration) string {
+		return signDashboardSession("TOKEN", userID, time.Now().Add(expires))
+	}
+
+	type tc struct {
+		name      string
+		method    string
+		url       string
+		initData  string
+		cookie    string
+		status    int
+		contains  []string
+		forbidden []string
+	}
+
+	table := []tc{
+		{
+			name:     "should serve login page without session",
+			method:   http.MethodGet,
+			url:      "/dashboard",
+			status:   http.StatusOK,
+			contains: []string{`/static/dashboard.js`, `id="dashboard-login"`},
+			forbidden: []string{
+				`TOKEN`,
+				`telegram.org`,
+				`<title>2024-01-02: 3</title>`,
+			},
+		},
+
+		{
+			name:     "should reject unsigned init data",
+			method:   http.MethodPost,
+			url:      "/dashboard",
+			initData: "user=%7B%22id%22%3A1234%7D",
+			status:   http.StatusForbidden,
+			contains: []string{"missing or invalid init data"},
+		},
+
+		{
+			name:     "should reject login of user who is not admin",
+			method:   http.MethodPost,
+			url:      "/dashboard",
+			initData: adminInitData(5678),
+			status:   http.StatusForbidden,
+			contains: []string{"missing or invalid init data"},
+		},
+
+		{
+			name:   "should render charts for range without scripts",
+			method: http.MethodGet,
+			url:    "/dashboard?from=2024-01-01&to=2024-01-04&unused=7",
+			cookie: session(1234, time.Minute),
+			status: http.StatusOK,
+			contains: []string{
+				`name="from" value="2024-01-01"`,
+				`name="to" value="2024-01-04"`,
+				`name="unused" min="1" max="365" value="7"`,
+				`<title>2024-01-02: 3</title>`,
+				`<th title="group1">#tag1</th>`,
+				`<title>group1: 3</title>`,
+				`group2 25.0%`,
+				`title="group1">#tag2</li>`,
+				`Unused for 7 days`,
+			},
+			forbidden: []string{`TOKEN`, `<script`},
+		},
+
+		{
+			name:      "should ask to log in again with expired session",
+			method:    http.MethodGet,
+			url:       "/dashboard",
+			cookie:    session(1234, -time.Minute),
+			status:    http.StatusOK,
+			contains:  []string{`id="dashboard-login"`},
+			forbidden: []string{`<title>2024-01-02: 3</title>`},
+		},
+
+		{
+			name:      "should ask to log in again with forged session",
+			method:    http.MethodGet,
+			url:       "/dashboard",
+			cookie:    strings.Replace(session(5678, time.Minute), "5678", "1234", 1),
+			status:    http.StatusOK,
+			contains:  []string{`id="dashboard-login"`},
+			forbidden: []string{`<title>2024-01-02: 3</title>`},
+		},
+
+		{
+			name:      "should ask user who is not admin to log in",
+			method:    http.MethodGet,
+			url:       "/dashboard",
+			cookie:    session(5678, time.Minute),
+			status:    http.StatusOK,
+			contains:  []string{`id="dashboard-login"`},
+			forbidden: []string{`<title>2024-01-02: 3</title>`},
+		},
+
+		{
+			name:     "should reject invalid unused days",
+			method:   http.MethodGet,
+			url:      "/dashboard?unused=0",
+			cookie:   session(1234, time.Minute),
+			status:   http.StatusBadRequest,
+			contains: []string{"unused days must be between 1 and 365"},
+		},
+
+		{
+			name:     "should reject invalid range",
+			method:   http.MethodGet,
+			url:      "/dashboard?from=2024-02-01&to=2024-01-01",
+			cookie:   session(1234, time.Minute),
+			status:   http.StatusBadRequest,
+			contains: []string{"from date must be before to date"},
+		},
+	}
+
+	for _, test := range table {
+		rec := httptest.NewRecorder()
+		var body io.Reader
+		if test.initData != "" {
+			body = strings.NewReader(url.Values{"initData": {test.initData}}.Encode())
+		}
+		req := httptest.NewRequest(test.method, test.url, body)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if test.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: dashboardCookie, Value: test.cookie})
+		}
+		handler.ServeHTTP(rec, req)
+		if rec.Code != test.status {
+			t.Errorf("%s - wrong status, expected %d, actual %d", test.name, test.status, rec.Code)
+			continue
+		}
+		html := rec.Body.String()
+		for _, c := range test.contains {
+			if !strings.Contains(html, c) {
+				t.Errorf("%s - body does not contain %s\n%s", test.name, c, html)
+			}
+		}
+		for _, f := range test.forbidden {
+			if strings.Contains(html, f) {
+				t.Errorf("%s - body contains %s", test.name, f)
+			}
+		}
+	}
+}
+
+func TestDashboardLogin(t *testing.T) {
+	handler := newTestServer(t, dbMock{})
+	body := strings.NewReader(url.Values{"initData": {adminInitData(1234)}}.Encode())
+	req := httptest.NewRequest(http.MethodPost, "/dashboard?from=2024-01-01", body)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	res := rec.Result()
+	if location := res.Header.Get("Location"); location != "/dashboard?from=2024-01-01" {
+		t.Errorf("wrong redirect\nexpected: %+v\nactual:   %+v", "/dashboard?from=2024-01-01", location)
+	}
+	cookies := res.Cookies()
+	if len(cookies) != 1 || cookies[0].Name != dashboardCookie || !cookies[0].HttpOnly {
+		t.Fatalf("wrong dashboard cookie: %+v", cookies)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/dashboard", nil)
+	req.AddCookie(cookies[0])
+	userID, err := dashboardAdmin(
+		&config.WepAppConfig{Token: "TOKEN", AdminIDs: []int64{1234}},
+		req,
+		time.Now(),
+	)
+	if err != nil || userID != 1234 {
+		t.Errorf("cookie does not hold admin session: %d, %v", userID, err)
+	}
+}
+
+func TestNewDonut(t *testing.T) {
+	actual := newDonut(&analytics.Dashboard{
+		TotalTags: 4,
+		Groups: []analytics.GroupShare{
+			{Group: "group1", Count: 3},
+			{Group: "group2", Count: 1},
+		},
+	})
+	expected := []donutSlice{
+		{Label: "group1", Value: 3, Percent: 75, Color: chartColors[0], Dash: "75.000 25.000", Offset: 25},
+		{Label: "group2", Value: 1, Percent: 25, Color: chartColors[1], Dash: "25.000 75.000", Offset: -50},
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("wrong donut\nexpected: %+v\nactual:   %+v", expected, actual)
+	}
+}
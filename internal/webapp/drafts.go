@@ -0,0 +1,140 @@
+package webapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"ratatoskr/internal/config"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/logger"
+	"ratatoskr/internal/models"
+	"ratatoskr/internal/sessions"
+	"time"
+)
+
+const maxDraftSize = 16 << 10
+
+// draft is what the tag picker restores when it is opened again, possibly on
+// another device.
+type draft struct {
+	OpenedGroups []string `json:"openedGroups"`
+	SelectedTags []string `json:"selectedTags"`
+	ScrollY      float64  `json:"scrollY"`
+}
+
+func handleGetDraft(
+	config *config.WepAppConfig,
+	database db.DB,
+	logger *logger.Logger,
+	store *sessions.Store,
+	now func() time.Time,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.WithContext(r.Context())
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+		userID, session, ok := draftSession(ctx, w, r, config, log, store, now())
+		if !ok {
+			return
+		}
+		res := draft{OpenedGroups: []string{}, SelectedTags: []string{}}
+		saved, err := database.GetDraft(ctx, session.ID, userID)
+		switch {
+		case errors.Is(err, db.ErrDraftNotFound):
+		case err != nil:
+			log.Error("failed to get draft", "session_id", session.ID, "user_id", userID, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		default:
+			res.OpenedGroups = append(res.OpenedGroups, saved.OpenedGroups...)
+			res.SelectedTags = append(res.SelectedTags, saved.SelectedTags...)
+			res.ScrollY = saved.ScrollY
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		err = json.NewEncoder(w).Encode(res)
+		if err != nil {
+			log.Error("failed to write draft", "error", err)
+		}
+	}
+}
+
+func handleSaveDraft(
+	config *config.WepAppConfig,
+	database db.DB,
+	logger *logger.Logger,
+	store *sessions.Store,
+	now func() time.Time,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.WithContext(r.Context())
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+		userID, session, ok := draftSession(ctx, w, r, config, log, store, now())
+		if !ok {
+			return
+		}
+		var req draft
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxDraftSize)).Decode(&req)
+		if err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		err = database.SaveDraft(ctx, &models.Draft{
+			SessionID:    session.ID,
+			UserID:       userID,
+			OpenedGroups: req.OpenedGroups,
+			SelectedTags: req.SelectedTags,
+			ScrollY:      req.ScrollY,
+			UpdatedAt:    now(),
+			ExpiresAt:    session.ExpiresAt,
+		})
+		if err != nil {
+			log.Error("failed to save draft", "session_id", session.ID, "user_id", userID, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// draftSession resolves the tagging session of a draft request and writes
+// the error response when the requesting admin may not use it.
+func draftSession(
+	ctx context.Context,
+	w http.ResponseWriter,
+	r *http.Request,
+	config *config.WepAppConfig,
+	logger *logger.Logger,
+	store *sessions.Store,
+	now time.Time,
+) (int64, models.TaggingSession, bool) {
+	userID, err := webAppAdmin(config, r, now)
+	if err != nil {
+		logger.Error("rejected draft request", "error", err)
+		w.WriteHeader(http.StatusForbidden)
+		return 0, models.TaggingSession{}, false
+	}
+	session, err := store.Get(ctx, r.PathValue("session"))
+	if errors.Is(err, db.ErrSessionNotFound) {
+		http.Error(w, "this item can not be tagged anymore", http.StatusNotFound)
+		return 0, models.TaggingSession{}, false
+	}
+	if err != nil {
+		logger.Error("failed to resolve session", "session_id", r.PathValue("session"), "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return 0, models.TaggingSession{}, false
+	}
+	if session.ChatID != userID {
+		logger.Error(
+			"user used session of another chat",
+			"user_id", userID,
+			"session_id", session.ID,
+			"chat_id", session.ChatID,
+		)
+		w.WriteHeader(http.StatusForbidden)
+		return 0, models.TaggingSession{}, false
+	}
+	return userID, session, true
+}
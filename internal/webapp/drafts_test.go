@@ -0,0 +1,133 @@
+package webapp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"ratatoskr/internal/models"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDrafts(t *testing.T) {
+	database := dbMock{
+		sessions: testSessions(),
+		drafts: map[string]models.Draft{
+			"admin/1234": {
+				SessionID:    "admin",
+				UserID:       1234,
+				OpenedGroups: []string{"group1"},
+				SelectedTags: []string{"group1::#tag1"},
+				ScrollY:      42,
+			},
+		},
+	}
+	handler := newTestServer(t, database)
+
+	type tc struct {
+		name     string
+		method   string
+		session  string
+		initData string
+		body     string
+		status   int
+		response string
+	}
+
+	table := []tc{
+		{
+			name:     "should reject unsigned request",
+			method:   http.MethodGet,
+			session:  "admin",
+			status:   http.StatusForbidden,
+			response: "",
+		},
+
+		{
+			name:     "should reject unknown session",
+			method:   http.MethodGet,
+			session:  "unknown",
+			initData: adminInitData(1234),
+			status:   http.StatusNotFound,
+			response: "this item can not be tagged anymore",
+		},
+
+		{
+			name:     "should reject session of another chat",
+			method:   http.MethodPut,
+			session:  "stranger",
+			initData: adminInitData(1234),
+			body:     `{"openedGroups":[],"selectedTags":[],"scrollY":0}`,
+			status:   http.StatusForbidden,
+			response: "",
+		},
+
+		{
+			name:     "should load saved draft",
+			method:   http.MethodGet,
+			session:  "admin",
+			initData: adminInitData(1234),
+			status:   http.StatusOK,
+			response: `{"openedGroups":["group1"],"selectedTags":["group1::#tag1"],"scrollY":42}`,
+		},
+
+		{
+			name:     "should reject malformed draft",
+			method:   http.MethodPut,
+			session:  "admin",
+			initData: adminInitData(1234),
+			body:     `{"selectedTags":"group1::#tag1"}`,
+			status:   http.StatusBadRequest,
+			response: "invalid request body",
+		},
+
+		{
+			name:     "should save draft",
+			method:   http.MethodPut,
+			session:  "admin",
+			initData: adminInitData(1234),
+			body:     `{"openedGroups":["group2"],"selectedTags":["group2::#tag4"],"scrollY":7.5}`,
+			status:   http.StatusNoContent,
+			response: "",
+		},
+
+		{
+			name:     "should load draft saved on another device",
+			method:   http.MethodGet,
+			session:  "admin",
+			initData: adminInitData(1234),
+			status:   http.StatusOK,
+			response: `{"openedGroups":["group2"],"selectedTags":["group2::#tag4"],"scrollY":7.5}`,
+		},
+	}
+
+	for _, test := range table {
+		req := httptest.NewRequest(test.method, "/drafts/"+test.session, strings.NewReader(test.body))
+		if test.initData != "" {
+			req.Header.Set("X-Telegram-Init-Data", test.initData)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != test.status {
+			t.Errorf("%s - wrong status, expected %d, actual %d", test.name, test.status, rec.Code)
+		}
+		if actual := strings.TrimSpace(rec.Body.String()); actual != test.response {
+			t.Errorf("%s\nexpected: %s\nactual:   %s", test.name, test.response, actual)
+		}
+	}
+
+	saved := database.drafts["admin/1234"]
+	expected := models.Draft{
+		SessionID:    "admin",
+		UserID:       1234,
+		OpenedGroups: []string{"group2"},
+		SelectedTags: []string{"group2::#tag4"},
+		ScrollY:      7.5,
+		UpdatedAt:    saved.UpdatedAt,
+		ExpiresAt:    database.sessions["admin"].ExpiresAt,
+	}
+	if !reflect.DeepEqual(saved, expected) || time.Since(saved.UpdatedAt) > time.Minute {
+		t.Errorf("wrong saved draft\nexpected: %+v\nactual:   %+v", expected, saved)
+	}
+}
@@ -0,0 +1,130 @@
+package webapp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"ratatoskr/internal/config"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/logger"
+	"ratatoskr/internal/models"
+	"ratatoskr/internal/tags_parser"
+	"strings"
+	"time"
+)
+
+type editorMenu struct {
+	Version int64      `json:"version"`
+	Groups  []apiGroup `json:"groups"`
+}
+
+func addEditorRoutes(
+	mux *http.ServeMux,
+	config *config.WepAppConfig,
+	db db.DB,
+	logger *logger.Logger,
+	template *template.Template,
+	menus *menuCache,
+	now func() time.Time,
+) {
+	mux.HandleFunc("GET /editor", handleEditor(config, logger, template))
+	mux.Handle("GET /editor/menu", adminAPIAuth(config, logger, now, editorGetMenu(db)))
+	mux.Handle(
+		"PUT /editor/menu",
+		adminAPIAuth(config, logger, now, editorSaveMenu(db, logger, menus)),
+	)
+}
+
+func handleEditor(
+	config *config.WepAppConfig,
+	logger *logger.Logger,
+	template *template.Template,
+) http.HandlerFunc {
+	type data struct {
+		Version string
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.WithContext(r.Context())
+		err := template.ExecuteTemplate(w, "editor", data{Version: config.Version})
+		if err != nil {
+			log.Error("failed to render editor", "error", err)
+		}
+	}
+}
+
+func editorGetMenu(db db.DB) apiHandler {
+	return func(r *http.Request) (any, *apiFailure) {
+		menu, err := db.GetTagMenu(r.Context())
+		if err != nil {
+			return nil, internalError(err)
+		}
+		return newEditorMenu(*menu), nil
+	}
+}
+
+func editorSaveMenu(database db.DB, logger *logger.Logger, menus *menuCache) apiHandler {
+	return func(r *http.Request) (any, *apiFailure) {
+		var req editorMenu
+		err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req)
+		if err != nil {
+			return nil, badRequest(fmt.Errorf("invalid request body"))
+		}
+		groups := editorGroups(req.Groups)
+		err = tags_parser.Validate(groups)
+		if err != nil {
+			return nil, badRequest(err)
+		}
+		version, err := database.ReplaceTagMenu(r.Context(), req.Version, &groups)
+		if errors.Is(err, db.ErrMenuConflict) {
+			return nil, &apiFailure{
+				status: http.StatusConflict,
+				body:   apiError{Code: apiErrConflict, Message: err.Error()},
+			}
+		}
+		if err != nil {
+			return nil, internalError(err)
+		}
+		menus.invalidate()
+		logger.WithContext(r.Context()).Info(
+			"tag menu saved",
+			"user_id", adminFromContext(r.Context()),
+			"version", version,
+		)
+		return newEditorMenu(models.TagMenu{Version: version, Groups: groups}), nil
+	}
+}
+
+func newEditorMenu(menu models.TagMenu) editorMenu {
+	res := editorMenu{Version: menu.Version, Groups: []apiGroup{}}
+	for _, g := range menu.Groups {
+		res.Groups = append(res.Groups, newAPIGroup(g))
+	}
+	return res
+}
+
+// editorGroups trims names and orders groups as they were sent, which is
+// the order chosen by dragging them in the editor.
+func editorGroups(groups []apiGroup) []models.Group {
+	res := []models.Group{}
+	for i, g := range groups {
+		group := models.Group{
+			Name:          strings.TrimSpace(g.Name),
+			OriginalIndex: i,
+			Tags:          []models.Tag{},
+		}
+		for _, t := range g.Tags {
+			tag := models.Tag{Name: strings.TrimSpace(t.Name)}
+			for _, a := range t.Aliases {
+				if a = strings.TrimSpace(a); a != "" {
+					tag.Aliases = append(tag.Aliases, a)
+				}
+			}
+			group.Tags = append(group.Tags, tag)
+		}
+		res = append(res, group)
+	}
+	return res
+}
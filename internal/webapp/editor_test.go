@@ -0,0 +1,111 @@
+package webapp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"ratatoskr/internal/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEditorMenu(t *testing.T) {
+	database := dbMock{menu: &models.TagMenu{
+		Version: 3,
+		Groups: []models.Group{
+			{Name: "group1", Tags: []models.Tag{{Name: "#tag1", Aliases: []string{"one"}}}},
+		},
+	}}
+	handler := newTestServer(t, database)
+
+	type tc struct {
+		name     string
+		method   string
+		initData string
+		body     string
+		status   int
+		expected string
+	}
+
+	table := []tc{
+		{
+			name:     "should reject user who is not admin",
+			method:   http.MethodGet,
+			initData: adminInitData(5678),
+			status:   http.StatusForbidden,
+			expected: `{"error":{"code":"forbidden","message":"missing or invalid init data"}}`,
+		},
+
+		{
+			name:     "should return menu with version",
+			method:   http.MethodGet,
+			initData: adminInitData(1234),
+			status:   http.StatusOK,
+			expected: `{"version":3,"groups":[{"name":"group1","index":0,"tags":[{"name":"#tag1","aliases":["one"]}]}]}`,
+		},
+
+		{
+			name:     "should save reordered menu",
+			method:   http.MethodPut,
+			initData: adminInitData(1234),
+			body: `{"version":3,"groups":[` +
+				`{"name":" group2 ","index":5,"tags":[{"name":"#tag2","aliases":[" ",""]}]},` +
+				`{"name":"group1","tags":[{"name":"#tag1 ","aliases":["one"]}]}]}`,
+			status: http.StatusOK,
+			expected: `{"version":4,"groups":[` +
+				`{"name":"group2","index":0,"tags":[{"name":"#tag2"}]},` +
+				`{"name":"group1","index":1,"tags":[{"name":"#tag1","aliases":["one"]}]}]}`,
+		},
+
+		{
+			name:     "should reject stale version",
+			method:   http.MethodPut,
+			initData: adminInitData(1234),
+			body:     `{"version":3,"groups":[{"name":"group1","tags":[{"name":"#tag1"}]}]}`,
+			status:   http.StatusConflict,
+			expected: `{"error":{"code":"conflict","message":"tag menu was changed by someone else"}}`,
+		},
+
+		{
+			name:     "should reject invalid menu",
+			method:   http.MethodPut,
+			initData: adminInitData(1234),
+			body:     `{"version":4,"groups":[{"name":"group1","tags":[{"name":"tag1"}]}]}`,
+			status:   http.StatusBadRequest,
+			expected: `{"error":{"code":"bad_request","message":"tag \"tag1\": tag must start with #"}}`,
+		},
+	}
+
+	for _, test := range table {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(test.method, "/editor/menu", strings.NewReader(test.body))
+		req.Header.Set("X-Telegram-Init-Data", test.initData)
+		handler.ServeHTTP(rec, req)
+		res := rec.Result()
+		body, _ := io.ReadAll(res.Body)
+		if res.StatusCode != test.status {
+			t.Errorf("%s - wrong status, expected %d, actual %d", test.name, test.status, res.StatusCode)
+		}
+		if string(body) != test.expected+"\n" {
+			t.Errorf("%s - wrong body\nexpected: %s\nactual:   %s", test.name, test.expected, body)
+		}
+	}
+
+	expected := []models.Group{
+		{Name: "group2", OriginalIndex: 0, Tags: []models.Tag{{Name: "#tag2"}}},
+		{Name: "group1", OriginalIndex: 1, Tags: []models.Tag{{Name: "#tag1", Aliases: []string{"one"}}}},
+	}
+	if !reflect.DeepEqual(expected, database.menu.Groups) {
+		t.Errorf("menu was not stored\nexpected: %+v\nactual:   %+v", expected, database.menu.Groups)
+	}
+}
+
+func TestEditorPage(t *testing.T) {
+	handler := newTestServer(t, dbMock{})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/editor", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "/static/editor.js") {
+		t.Errorf("editor page was not rendered: %d\n%s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,79 @@
+package webapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/logger"
+	"time"
+)
+
+const readinessTimeout = time.Second * 3
+
+const (
+	checkOK          = "ok"
+	checkUnavailable = "unavailable"
+)
+
+// handleHealthz only reports that the process is serving requests.
+func handleHealthz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		fmt.Fprintf(w, "ok")
+	}
+}
+
+// handleReadyz reports whether the webapp can serve the tag picker. Failure
+// details are only logged, the endpoint is not authenticated.
+func handleReadyz(
+	db db.DB,
+	logger *logger.Logger,
+	template *template.Template,
+	menus *menuCache,
+) http.HandlerFunc {
+	type response struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.WithContext(r.Context())
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+		checks := map[string]func() error{
+			"database": func() error { return db.Ping(ctx) },
+			"template": func() error {
+				if template == nil || template.Lookup("webapp") == nil {
+					return errors.New("webapp template is not loaded")
+				}
+				return nil
+			},
+			"menu": func() error {
+				_, err := menus.get(ctx)
+				return err
+			},
+		}
+		res := response{Status: checkOK, Checks: map[string]string{}}
+		status := http.StatusOK
+		for name, check := range checks {
+			res.Checks[name] = checkOK
+			err := check()
+			if err != nil {
+				log.Error("readiness check failed", "check", name, "error", err)
+				res.Checks[name] = checkUnavailable
+				res.Status = checkUnavailable
+				status = http.StatusServiceUnavailable
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		err := json.NewEncoder(w).Encode(res)
+		if err != nil {
+			log.Error("failed to write readiness report", "error", err)
+		}
+	}
+}
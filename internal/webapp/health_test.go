@@ -0,0 +1,57 @@
+package webapp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHealth(t *testing.T) {
+	type tc struct {
+		name     string
+		path     string
+		database dbMock
+		status   int
+		response string
+	}
+
+	table := []tc{
+		{
+			name:     "should report live process",
+			path:     "/healthz",
+			database: dbMock{pingErr: errors.New("server selection timeout")},
+			status:   http.StatusOK,
+			response: "ok",
+		},
+
+		{
+			name:     "should report ready",
+			path:     "/readyz",
+			database: dbMock{},
+			status:   http.StatusOK,
+			response: `{"status":"ok","checks":{"database":"ok","menu":"ok","template":"ok"}}`,
+		},
+
+		{
+			name:     "should report unreachable database",
+			path:     "/readyz",
+			database: dbMock{pingErr: errors.New("server selection timeout")},
+			status:   http.StatusServiceUnavailable,
+			response: `{"status":"unavailable","checks":{"database":"unavailable","menu":"ok","template":"ok"}}`,
+		},
+	}
+
+	for _, test := range table {
+		handler := newTestServer(t, test.database)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if rec.Code != test.status {
+			t.Errorf("%s - wrong status, expected %d, actual %d", test.name, test.status, rec.Code)
+		}
+		if actual := strings.TrimSpace(rec.Body.String()); actual != test.response {
+			t.Errorf("%s\nexpected: %s\nactual:   %s", test.name, test.response, actual)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package webapp
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/logger"
+	"ratatoskr/internal/metrics"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxPreviewSize   = 1 << 20
+	previewCacheSize = 32 << 20
+	previewCacheTTL  = time.Hour
+	previewTimeout   = time.Second * 15
+)
+
+var errPreviewTooLarge = errors.New("preview is too large")
+
+type preview struct {
+	URL  string
+	Kind string
+}
+
+// previews links the registered media among mediaIDs to the preview proxy.
+func previews(
+	ctx context.Context,
+	db db.DB,
+	token string,
+	chatID int64,
+	mediaIDs []int64,
+) ([]preview, error) {
+	media, err := db.GetPendingMedia(ctx, chatID, mediaIDs)
+	if err != nil {
+		return nil, err
+	}
+	res := []preview{}
+	for _, m := range *media {
+		res = append(res, preview{
+			URL: fmt.Sprintf(
+				"/media/%d/%d?%s",
+				m.ChatID,
+				m.MessageID,
+				url.Values{"token": {token}}.Encode(),
+			),
+			Kind: m.Kind,
+		})
+	}
+	return res, nil
+}
+
+func handleMediaPreview(
+	admins []int64,
+	db db.DB,
+	logger *logger.Logger,
+	files *botFiles,
+	cache *previewCache,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.WithContext(r.Context())
+		chatID, err := strconv.ParseInt(r.PathValue("chat"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid chat id", http.StatusBadRequest)
+			return
+		}
+		messageID, err := strconv.ParseInt(r.PathValue("message"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid message id", http.StatusBadRequest)
+			return
+		}
+		if !isAdmin(admins, chatID) {
+			http.NotFound(w, r)
+			return
+		}
+		key := fmt.Sprintf("%d/%d", chatID, messageID)
+		body, ok := cache.get(key)
+		if !ok {
+			ctx, cancel := context.WithTimeout(r.Context(), previewTimeout)
+			defer cancel()
+			media, err := db.GetPendingMedia(ctx, chatID, []int64{messageID})
+			if err != nil {
+				log.Error(
+					"failed to get pending media",
+					"chat_id", chatID,
+					"message_id", messageID,
+					"error", err,
+				)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if len(*media) == 0 {
+				http.NotFound(w, r)
+				return
+			}
+			body, err = files.download(ctx, (*media)[0].FileID, maxPreviewSize)
+			if err == nil && !strings.HasPrefix(http.DetectContentType(body), "image/") {
+				err = fmt.Errorf("not an image: %s", http.DetectContentType(body))
+			}
+			if err != nil {
+				log.Error(
+					"failed to download preview",
+					"chat_id", chatID,
+					"message_id", messageID,
+					"error", err,
+				)
+				http.Error(w, "preview unavailable", http.StatusBadGateway)
+				return
+			}
+			cache.add(key, body)
+		}
+		w.Header().Set("Content-Type", http.DetectContentType(body))
+		w.Header().Set("Cache-Control", "private, max-age=3600")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Write(body)
+	}
+}
+
+// botFiles downloads files through the Bot API getFile method.
+type botFiles struct {
+	client *http.Client
+	apiURL string
+	token  string
+}
+
+func newBotFiles(apiURL string, token string) *botFiles {
+	return &botFiles{client: &http.Client{}, apiURL: apiURL, token: token}
+}
+
+func (f *botFiles) download(ctx context.Context, fileID string, maxSize int64) ([]byte, error) {
+	var res struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+		Result      struct {
+			FilePath string `json:"file_path"`
+			FileSize int64  `json:"file_size"`
+		} `json:"result"`
+	}
+	start := time.Now()
+	_, body, err := f.get(
+		ctx,
+		fmt.Sprintf("%s/bot%s/getFile?%s", f.apiURL, f.token, url.Values{"file_id": {fileID}}.Encode()),
+		64<<10,
+	)
+	metrics.TelegramRequestDuration.Observe(time.Since(start).Seconds(), "getFile")
+	if err == nil {
+		err = json.Unmarshal(body, &res)
+	}
+	if err == nil && !res.OK {
+		err = fmt.Errorf("getFile failed: %s", res.Description)
+	}
+	if err != nil {
+		metrics.TelegramRequestErrors.Inc("getFile")
+		return nil, err
+	}
+	if res.Result.FileSize > maxSize {
+		return nil, errPreviewTooLarge
+	}
+	status, body, err := f.get(
+		ctx,
+		fmt.Sprintf("%s/file/bot%s/%s", f.apiURL, f.token, res.Result.FilePath),
+		maxSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("file download failed with status %d", status)
+	}
+	return body, nil
+}
+
+func (f *botFiles) get(ctx context.Context, u string, maxSize int64) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		// url.Error repeats the url, which contains the bot token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return 0, nil, err
+	}
+	if int64(len(body)) > maxSize {
+		return 0, nil, errPreviewTooLarge
+	}
+	return resp.StatusCode, body, nil
+}
+
+// previewCache keeps recently shown previews in memory, dropping the least
+// recently used ones once maxBytes is reached.
+type previewCache struct {
+	maxBytes int
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+}
+
+type cachedPreview struct {
+	key      string
+	body     []byte
+	loadedAt time.Time
+}
+
+func newPreviewCache(maxBytes int, ttl time.Duration, now func() time.Time) *previewCache {
+	return &previewCache{
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		now:      now,
+		order:    list.New(),
+		items:    map[string]*list.Element{},
+	}
+}
+
+func (c *previewCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	p := e.Value.(cachedPreview)
+	if c.now().Sub(p.loadedAt) >= c.ttl {
+		c.remove(e)
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return p.body, true
+}
+
+func (c *previewCache) add(key string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.remove(e)
+	}
+	if len(body) > c.maxBytes {
+		return
+	}
+	c.items[key] = c.order.PushFront(cachedPreview{key: key, body: body, loadedAt: c.now()})
+	c.size += len(body)
+	for c.size > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *previewCache) remove(e *list.Element) {
+	p := c.order.Remove(e).(cachedPreview)
+	delete(c.items, p.key)
+	c.size -= len(p.body)
+}
@@ -0,0 +1,287 @@
+package webapp
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"ratatoskr/internal/config"
+	"ratatoskr/internal/models"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n0000")
+
+func fakeBotAPI(t *testing.T, downloads *atomic.Int32) *httptest.Server {
+	t.Helper()
+	files := map[string]struct {
+		size int
+		body []byte
+	}{
+		"thumb":   {size: len(pngHeader), body: pngHeader},
+		"huge":    {size: maxPreviewSize + 1, body: pngHeader},
+		"text":    {size: 4, body: []byte("text")},
+		"missing": {},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/botTOKEN/getFile":
+			f, ok := files[r.URL.Query().Get("file_id")]
+			if !ok || f.body == nil {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"ok":false,"description":"Bad Request: invalid file_id"}`)
+				return
+			}
+			fmt.Fprintf(
+				w,
+				`{"ok":true,"result":{"file_path":"thumbnails/%s","file_size":%d}}`,
+				r.URL.Query().Get("file_id"),
+				f.size,
+			)
+		case strings.HasPrefix(r.URL.Path, "/file/botTOKEN/thumbnails/"):
+			downloads.Add(1)
+			w.Write(files[strings.TrimPrefix(r.URL.Path, "/file/botTOKEN/thumbnails/")].body)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestMediaPreview(t *testing.T) {
+	var downloads atomic.Int32
+	api := fakeBotAPI(t, &downloads)
+	handler, err := NewServer(
+		&config.WepAppConfig{
+			Version:   "test",
+			Token:     "TOKEN",
+			AdminIDs:  []int64{1234},
+			BotAPIURL: api.URL,
+		},
+		dbMock{media: []models.PendingMedia{
+			{ChatID: 1234, MessageID: 1, Kind: "photo", FileID: "thumb"},
+			{ChatID: 1234, MessageID: 2, Kind: "video", FileID: "huge"},
+			{ChatID: 1234, MessageID: 3, Kind: "photo", FileID: "text"},
+			{ChatID: 1234, MessageID: 4, Kind: "photo", FileID: "missing"},
+			{ChatID: 5678, MessageID: 1, Kind: "photo", FileID: "thumb"},
+		}},
+		fakeLogger(),
+		nil,
+		nil,
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+
+	type tc struct {
+		name        string
+		url         string
+		status      int
+		contentType string
+		body        []byte
+		downloads   int32
+	}
+
+	table := []tc{
+		{
+			name:   "should reject wrong token",
+			url:    "/media/1234/1?token=WRONG",
+			status: http.StatusForbidden,
+		},
+
+		{
+			name:   "should not serve media of other chats",
+			url:    "/media/5678/1?token=TOKEN",
+			status: http.StatusNotFound,
+		},
+
+		{
+			name:   "should return not found for unregistered media",
+			url:    "/media/1234/9?token=TOKEN",
+			status: http.StatusNotFound,
+		},
+
+		{
+			name:   "should reject invalid message id",
+			url:    "/media/1234/abc?token=TOKEN",
+			status: http.StatusBadRequest,
+		},
+
+		{
+			name:        "should proxy preview",
+			url:         "/media/1234/1?token=TOKEN",
+			status:      http.StatusOK,
+			contentType: "image/png",
+			body:        pngHeader,
+			downloads:   1,
+		},
+
+		{
+			name:        "should serve preview from cache",
+			url:         "/media/1234/1?token=TOKEN",
+			status:      http.StatusOK,
+			contentType: "image/png",
+			body:        pngHeader,
+			downloads:   1,
+		},
+
+		{
+			name:      "should not download files over the size cap",
+			url:       "/media/1234/2?token=TOKEN",
+			status:    http.StatusBadGateway,
+			downloads: 1,
+		},
+
+		{
+			name:      "should not serve files that are not images",
+			url:       "/media/1234/3?token=TOKEN",
+			status:    http.StatusBadGateway,
+			downloads: 2,
+		},
+
+		{
+			name:      "should fail when bot api rejects the file",
+			url:       "/media/1234/4?token=TOKEN",
+			status:    http.StatusBadGateway,
+			downloads: 2,
+		},
+	}
+
+	for _, test := range table {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.url, nil))
+		if rec.Code != test.status {
+			t.Errorf("%s - wrong status, expected %d, actual %d", test.name, test.status, rec.Code)
+		}
+		if test.contentType != "" && rec.Header().Get("Content-Type") != test.contentType {
+			t.Errorf(
+				"%s - wrong content type\nexpected: %s\nactual:   %s",
+				test.name,
+				test.contentType,
+				rec.Header().Get("Content-Type"),
+			)
+		}
+		if test.body != nil && !bytes.Equal(rec.Body.Bytes(), test.body) {
+			t.Errorf("%s - wrong body\nexpected: %q\nactual:   %q", test.name, test.body, rec.Body.Bytes())
+		}
+		if downloads.Load() != test.downloads {
+			t.Errorf(
+				"%s - wrong download count\nexpected: %d\nactual:   %d",
+				test.name,
+				test.downloads,
+				downloads.Load(),
+			)
+		}
+	}
+}
+
+func TestHomePreviews(t *testing.T) {
+	handler := newTestServer(t, dbMock{
+		media: []models.PendingMedia{
+			{ChatID: 1234, MessageID: 1, Kind: "photo", FileID: "thumb 1"},
+			{ChatID: 1234, MessageID: 2, Kind: "video", FileID: "thumb 2"},
+			{ChatID: 5678, MessageID: 1, Kind: "photo", FileID: "thumb 1"},
+		},
+		sessions: map[string]models.TaggingSession{
+			"album": {
+				ID:         "album",
+				ChatID:     1234,
+				MessageIDs: []int64{1, 2, 3},
+				MediaKind:  models.MediaKindAlbum,
+				ExpiresAt:  time.Now().Add(time.Hour),
+			},
+			"stranger": {
+				ID:         "stranger",
+				ChatID:     5678,
+				MessageIDs: []int64{1},
+				MediaKind:  models.MediaKindPhoto,
+				ExpiresAt:  time.Now().Add(time.Hour),
+			},
+		},
+	})
+
+	type tc struct {
+		name      string
+		url       string
+		contains  []string
+		forbidden []string
+	}
+
+	table := []tc{
+		{
+			name: "should show album previews",
+			url:  "/TOKEN?session=album",
+			contains: []string{
+				`<div class="preview photo">`,
+				`src="/media/1234/1?token=TOKEN"`,
+				`<div class="preview video">`,
+				`src="/media/1234/2?token=TOKEN"`,
+			},
+			forbidden: []string{`/media/1234/3`},
+		},
+
+		{
+			name:      "should not show previews for unknown users",
+			url:       "/TOKEN?session=stranger",
+			forbidden: []string{`class="previews"`},
+		},
+	}
+
+	for _, test := range table {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.url, nil))
+		html := rec.Body.String()
+		for _, c := range test.contains {
+			if !strings.Contains(html, c) {
+				t.Errorf("%s - html does not contain %s\n%s", test.name, c, html)
+			}
+		}
+		for _, f := range test.forbidden {
+			if strings.Contains(html, f) {
+				t.Errorf("%s - html contains %s", test.name, f)
+			}
+		}
+	}
+}
+
+func TestPreviewCache(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := newPreviewCache(10, time.Minute, func() time.Time { return now })
+	cache.add("a", []byte("aaaa"))
+	cache.add("b", []byte("bbbb"))
+	cache.get("a")
+	cache.add("c", []byte("cccc"))
+	cache.add("big", []byte("too large to cache"))
+
+	type tc struct {
+		key    string
+		cached bool
+	}
+
+	table := []tc{
+		{key: "a", cached: true},
+		{key: "b", cached: false},
+		{key: "c", cached: true},
+		{key: "big", cached: false},
+	}
+
+	for _, test := range table {
+		_, ok := cache.get(test.key)
+		if ok != test.cached {
+			t.Errorf("%s\nexpected: %+v\nactual:   %+v", test.key, test.cached, ok)
+		}
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := cache.get("a"); ok {
+		t.Errorf("expired preview was served from cache")
+	}
+	if cache.size != 4 {
+		t.Errorf("wrong cache size\nexpected: %+v\nactual:   %+v", 4, cache.size)
+	}
+}
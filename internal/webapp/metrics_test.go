@@ -0,0 +1,94 @@
+package webapp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"ratatoskr/internal/config"
+	"ratatoskr/internal/metrics"
+	"strings"
+	"testing"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	handler := newTestServer(t, dbMock{})
+
+	type tc struct {
+		name   string
+		path   string
+		route  string
+		method string
+		status string
+	}
+
+	table := []tc{
+		{
+			name:   "should label picker by pattern instead of token",
+			path:   "/TOKEN",
+			route:  "/",
+			method: http.MethodGet,
+			status: "200",
+		},
+
+		{
+			name:   "should record status written by handler",
+			path:   "/dashboard",
+			route:  "POST /dashboard",
+			method: http.MethodPost,
+			status: "403",
+		},
+
+		{
+			name:   "should label missing static file by prefix",
+			path:   "/static/missing.css",
+			route:  "/static/",
+			method: http.MethodGet,
+			status: "404",
+		},
+	}
+
+	for _, test := range table {
+		before := metrics.HTTPRequestDuration.Count(test.route, test.method, test.status)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(test.method, test.path, nil))
+		actual := metrics.HTTPRequestDuration.Count(test.route, test.method, test.status)
+		if actual != before+1 {
+			t.Errorf(
+				"%s - wrong request count\nexpected: %d\nactual:   %d",
+				test.name,
+				before+1,
+				actual,
+			)
+		}
+	}
+
+	handler, err := NewServer(
+		&config.WepAppConfig{Token: "TOKEN", MetricsToken: "SCRAPE", AdminIDs: []int64{1234}},
+		dbMock{},
+		fakeLogger(),
+		nil,
+		nil,
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	for _, token := range []string{"", "TOKEN"} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("metrics with token %q - wrong status, expected %d, actual %d", token, http.StatusForbidden, rec.Code)
+		}
+	}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer SCRAPE")
+	handler.ServeHTTP(rec, req)
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK ||
+		!strings.Contains(body, `ratatoskr_http_request_duration_seconds_count{route="/",method="GET",status="200"}`) ||
+		strings.Contains(body, "SCRAPE") {
+		t.Errorf("wrong metrics response %d\n%s", rec.Code, body)
+	}
+}
@@ -0,0 +1,168 @@
+package webapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"ratatoskr/internal/config"
+	"ratatoskr/internal/correlation"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/logger"
+	"ratatoskr/internal/metrics"
+	"ratatoskr/internal/posting"
+	"ratatoskr/internal/sessions"
+	"ratatoskr/internal/settings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+// publisher is the part of the Bot API the webapp needs to publish posts.
+type publisher interface {
+	EditMessageCaption(opts *gotgbot.EditMessageCaptionOpts) (*gotgbot.Message, bool, error)
+	CopyMessages(
+		chatId int64,
+		fromChatId int64,
+		messageIds []int64,
+		opts *gotgbot.CopyMessagesOpts,
+	) ([]gotgbot.MessageId, error)
+	DeleteMessages(int64, []int64, *gotgbot.DeleteMessagesOpts) (bool, error)
+	AnswerWebAppQuery(
+		webAppQueryId string,
+		result gotgbot.InlineQueryResult,
+		opts *gotgbot.AnswerWebAppQueryOpts,
+	) (*gotgbot.SentWebAppMessage, error)
+}
+
+func newPublisher(c *config.WepAppConfig, logger *logger.Logger) (*gotgbot.Bot, error) {
+	return gotgbot.NewBot(c.Token, &gotgbot.BotOpts{
+		DisableTokenCheck: true,
+		BotClient: metrics.TelegramClient{BotClient: &gotgbot.BaseBotClient{
+			DefaultRequestOpts: &gotgbot.RequestOpts{
+				APIURL:  c.BotAPIURL,
+				Timeout: time.Second * 10,
+			},
+		}, Logger: logger},
+	})
+}
+
+// requestPublisher returns a publisher whose Bot API requests carry the
+// correlation ID of r.
+func requestPublisher(r *http.Request, bot publisher) publisher {
+	if b, ok := bot.(*gotgbot.Bot); ok {
+		return metrics.WithCorrelationID(b, correlation.ID(r.Context()))
+	}
+	return bot
+}
+
+// handlePost publishes media tagged from the inline webapp button and closes
+// the webapp query with answerWebAppQuery. Inline buttons can not send
+// web_app_data, so this is the only way posts are published.
+func handlePost(
+	config *config.WepAppConfig,
+	database db.DB,
+	logger *logger.Logger,
+	bot publisher,
+	store *sessions.Store,
+	runtime *settings.Store,
+	now func() time.Time,
+) http.HandlerFunc {
+	type request struct {
+		Session string     `json:"session"`
+		Data    [][]string `json:"data"`
+	}
+	type response struct {
+		ChannelMessageIDs []int64 `json:"channelMessageIds"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.WithContext(r.Context())
+		userID, err := webAppAdmin(config, r, now())
+		if err != nil {
+			log.Error("rejected post request", "error", err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		queryID := initDataQueryID(r)
+		if queryID == "" {
+			http.Error(w, "webapp was not opened from an inline button", http.StatusBadRequest)
+			return
+		}
+		var req request
+		err = json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req)
+		if err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		log = log.With("user_id", userID, "session_id", req.Session)
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+		session, err := store.Get(ctx, req.Session)
+		if errors.Is(err, db.ErrSessionNotFound) {
+			http.Error(w, "this item can not be tagged anymore", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Error("failed to resolve session", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		// the webapp only runs in the private chat with the bot
+		chatID := userID
+		if session.ChatID != chatID {
+			log.Error("user used session of another chat", "chat_id", session.ChatID)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		// ties the post to the update that received the media
+		log = log.With("origin_correlation_id", session.CorrelationID)
+		tags, err := posting.ParseTags(req.Data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		bot := requestPublisher(r, bot)
+		published, err := posting.Publish(ctx, bot, database, store, runtime, log, posting.Post{
+			SessionID: session.ID,
+			UserID:    userID,
+			Tags:      tags,
+		}, now())
+		if errors.Is(err, db.ErrSessionNotFound) {
+			http.Error(w, "this item was already posted", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Error("failed to publish post", "error", err)
+			switch {
+			case errors.Is(err, posting.ErrCaption):
+				http.Error(w, "failed to set caption", http.StatusBadGateway)
+			case errors.Is(err, posting.ErrCopy):
+				http.Error(w, "failed to publish post", http.StatusBadGateway)
+			default:
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+		mediaIDs := published.Session.MessageIDs
+		res := response{ChannelMessageIDs: published.ChannelMessageIDs}
+		text := "posted"
+		if published.Caption != "" {
+			text = "posted\n" + published.Caption
+		}
+		_, err = bot.AnswerWebAppQuery(queryID, gotgbot.InlineQueryResultArticle{
+			Id:                  "posted",
+			Title:               "Posted",
+			InputMessageContent: gotgbot.InputTextMessageContent{MessageText: text},
+		}, nil)
+		if err != nil {
+			log.Warning("failed to answer webapp query", "error", err)
+		}
+		log.Info("post published", "message_ids", mediaIDs, "channel_message_ids", res.ChannelMessageIDs)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		err = json.NewEncoder(w).Encode(res)
+		if err != nil {
+			log.Error("failed to write post response", "error", err)
+		}
+	}
+}
@@ -0,0 +1,403 @@
+package webapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"ratatoskr/internal/config"
+	"ratatoskr/internal/correlation"
+	"ratatoskr/internal/models"
+	"ratatoskr/internal/sessions"
+	"ratatoskr/internal/settings"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+type publisherMock struct {
+	copyErr  error
+	captions []gotgbot.EditMessageCaptionOpts
+	copied   [][]int64
+	deleted  [][]int64
+	answered []string
+}
+
+func (p *publisherMock) EditMessageCaption(
+	opts *gotgbot.EditMessageCaptionOpts,
+) (*gotgbot.Message, bool, error) {
+	p.captions = append(p.captions, *opts)
+	return nil, true, nil
+}
+
+func (p *publisherMock) CopyMessages(
+	chatId int64,
+	fromChatId int64,
+	messageIds []int64,
+	_ *gotgbot.CopyMessagesOpts,
+) ([]gotgbot.MessageId, error) {
+	if p.copyErr != nil {
+		return nil, p.copyErr
+	}
+	p.copied = append(p.copied, append([]int64{chatId, fromChatId}, messageIds...))
+	res := []gotgbot.MessageId{}
+	for _, id := range messageIds {
+		res = append(res, gotgbot.MessageId{MessageId: id + 100})
+	}
+	return res, nil
+}
+
+func (p *publisherMock) DeleteMessages(
+	chatId int64,
+	messageIds []int64,
+	_ *gotgbot.DeleteMessagesOpts,
+) (bool, error) {
+	p.deleted = append(p.deleted, append([]int64{chatId}, messageIds...))
+	return true, nil
+}
+
+func (p *publisherMock) AnswerWebAppQuery(
+	webAppQueryId string,
+	result gotgbot.InlineQueryResult,
+	_ *gotgbot.AnswerWebAppQueryOpts,
+) (*gotgbot.SentWebAppMessage, error) {
+	article := result.(gotgbot.InlineQueryResultArticle)
+	p.answered = append(
+		p.answered,
+		webAppQueryId+": "+article.InputMessageContent.(gotgbot.InputTextMessageContent).MessageText,
+	)
+	return &gotgbot.SentWebAppMessage{}, nil
+}
+
+func inlineInitData(userID int64) string {
+	return signedInitData(url.Values{
+		"auth_date": {strconv.FormatInt(time.Now().Unix(), 10)},
+		"query_id":  {"QUERY"},
+		"user":      {`{"id":` + strconv.FormatInt(userID, 10) + `}`},
+	}, "TOKEN")
+}
+
+func TestPost(t *testing.T) {
+	now := time.Now()
+
+	type tc struct {
+		name      string
+		initData  string
+		body      string
+		copyErr   error
+		status    int
+		response  string
+		settings  *models.Settings
+		publisher publisherMock
+		analytics []models.Analytics
+		ended     bool
+	}
+
+	table := []tc{
+		{
+			name:      "should reject unsigned request",
+			body:      `{"session":"photo","data":[]}`,
+			status:    http.StatusForbidden,
+			analytics: []models.Analytics{},
+		},
+
+		{
+			name:      "should reject webapp opened from reply keyboard",
+			initData:  adminInitData(1234),
+			body:      `{"session":"photo","data":[]}`,
+			status:    http.StatusBadRequest,
+			analytics: []models.Analytics{},
+		},
+
+		{
+			name:      "should reject malformed tags",
+			initData:  inlineInitData(1234),
+			body:      `{"session":"photo","data":[["group1"]]}`,
+			status:    http.StatusBadRequest,
+			analytics: []models.Analytics{},
+		},
+
+		{
+			name:      "should reject unknown session",
+			initData:  inlineInitData(1234),
+			body:      `{"session":"unknown","data":[]}`,
+			status:    http.StatusNotFound,
+			analytics: []models.Analytics{},
+		},
+
+		{
+			name:      "should reject session of another chat",
+			initData:  inlineInitData(1234),
+			body:      `{"session":"foreign","data":[]}`,
+			status:    http.StatusForbidden,
+			analytics: []models.Analytics{},
+		},
+
+		{
+			name:     "should publish photo",
+			initData: inlineInitData(1234),
+			body:     `{"session":"photo","data":[["group1","#tag1"],["group2","#tag4"]]}`,
+			status:   http.StatusCreated,
+			response: `{"channelMessageIds":[110]}`,
+			ended:    true,
+			publisher: publisherMock{
+				captions: []gotgbot.EditMessageCaptionOpts{
+					{ChatId: 1234, MessageId: 10, Caption: "#tag1\n#tag4"},
+				},
+				copied:   [][]int64{{-100, 1234, 10}},
+				deleted:  [][]int64{{1234, 10}},
+				answered: []string{"QUERY: posted\n#tag1\n#tag4"},
+			},
+			analytics: []models.Analytics{
+				{
+					Group:             "group1",
+					Tag:               "#tag1",
+					Date:              now,
+					UserID:            1234,
+					MediaKind:         "photo",
+					ItemCount:         1,
+					DestinationChatID: -100,
+					ChannelMessageIDs: []int64{110},
+					PostingMode:       models.PostingModeImmediate,
+					CorrelationID:     "abc123",
+				},
+				{
+					Group:             "group2",
+					Tag:               "#tag4",
+					Date:              now,
+					UserID:            1234,
+					MediaKind:         "photo",
+					ItemCount:         1,
+					DestinationChatID: -100,
+					ChannelMessageIDs: []int64{110},
+					PostingMode:       models.PostingModeImmediate,
+					CorrelationID:     "abc123",
+				},
+			},
+		},
+
+		{
+			name:     "should publish album and remove its button message",
+			initData: inlineInitData(1234),
+			body:     `{"session":"album","data":[["group1","#tag1"]]}`,
+			status:   http.StatusCreated,
+			response: `{"channelMessageIds":[110,111]}`,
+			ended:    true,
+			publisher: publisherMock{
+				captions: []gotgbot.EditMessageCaptionOpts{
+					{ChatId: 1234, MessageId: 10, Caption: "#tag1"},
+				},
+				copied:   [][]int64{{-100, 1234, 10, 11}},
+				deleted:  [][]int64{{1234, 10, 11, 12}},
+				answered: []string{"QUERY: posted\n#tag1"},
+			},
+			analytics: []models.Analytics{
+				{
+					Group:             "group1",
+					Tag:               "#tag1",
+					Date:              now,
+					UserID:            1234,
+					MediaKind:         models.MediaKindAlbum,
+					ItemCount:         2,
+					DestinationChatID: -100,
+					ChannelMessageIDs: []int64{110, 111},
+					PostingMode:       models.PostingModeImmediate,
+					CorrelationID:     "abc123",
+				},
+			},
+		},
+
+		{
+			name:     "should publish with runtime settings",
+			initData: inlineInitData(1234),
+			body:     `{"session":"photo","data":[["group1","#tag1"],["group2","#tag4"]]}`,
+			settings: &models.Settings{
+				ReceiverID:    -200,
+				CaptionFormat: models.CaptionFormatInline,
+				PostingMode:   models.PostingModeSilent,
+				Version:       3,
+			},
+			status:   http.StatusCreated,
+			response: `{"channelMessageIds":[110]}`,
+			ended:    true,
+			publisher: publisherMock{
+				captions: []gotgbot.EditMessageCaptionOpts{
+					{ChatId: 1234, MessageId: 10, Caption: "#tag1 #tag4"},
+				},
+				copied:   [][]int64{{-200, 1234, 10}},
+				deleted:  [][]int64{{1234, 10}},
+				answered: []string{"QUERY: posted\n#tag1 #tag4"},
+			},
+			analytics: []models.Analytics{
+				{
+					Group:             "group1",
+					Tag:               "#tag1",
+					Date:              now,
+					UserID:            1234,
+					MediaKind:         "photo",
+					ItemCount:         1,
+					DestinationChatID: -200,
+					ChannelMessageIDs: []int64{110},
+					PostingMode:       models.PostingModeSilent,
+					CorrelationID:     "abc123",
+				},
+				{
+					Group:             "group2",
+					Tag:               "#tag4",
+					Date:              now,
+					UserID:            1234,
+					MediaKind:         "photo",
+					ItemCount:         1,
+					DestinationChatID: -200,
+					ChannelMessageIDs: []int64{110},
+					PostingMode:       models.PostingModeSilent,
+					CorrelationID:     "abc123",
+				},
+			},
+		},
+
+		{
+			name:     "should keep media when copying fails",
+			initData: inlineInitData(1234),
+			body:     `{"session":"photo","data":[["group1","#tag1"]]}`,
+			copyErr:  errors.New("Bad Request: chat not found"),
+			status:   http.StatusBadGateway,
+			publisher: publisherMock{
+				captions: []gotgbot.EditMessageCaptionOpts{
+					{ChatId: 1234, MessageId: 10, Caption: "#tag1"},
+				},
+			},
+			analytics: []models.Analytics{},
+		},
+	}
+
+	for _, test := range table {
+		inserted := []models.Analytics{}
+		database := dbMock{inserted: &inserted, settings: test.settings, drafts: map[string]models.Draft{
+			"photo/1234": {SessionID: "photo", UserID: 1234, SelectedTags: []string{"group1::#tag1"}},
+		}, sessions: map[string]models.TaggingSession{
+			"photo": {
+				ID:              "photo",
+				ChatID:          1234,
+				MessageIDs:      []int64{10},
+				ButtonMessageID: 10,
+				MediaKind:       models.MediaKindPhoto,
+				ExpiresAt:       now.Add(time.Hour),
+			},
+			"album": {
+				ID:              "album",
+				ChatID:          1234,
+				MessageIDs:      []int64{10, 11},
+				ButtonMessageID: 12,
+				MediaKind:       models.MediaKindAlbum,
+				ExpiresAt:       now.Add(time.Hour),
+			},
+			"foreign": {
+				ID:         "foreign",
+				ChatID:     5678,
+				MessageIDs: []int64{10},
+				ExpiresAt:  now.Add(time.Hour),
+			},
+		}}
+		bot := &publisherMock{copyErr: test.copyErr}
+		handler := handlePost(
+			&config.WepAppConfig{Token: "TOKEN", AdminIDs: []int64{1234}, ReceiverID: -100},
+			database,
+			fakeLogger(),
+			bot,
+			sessions.NewStore(database, sessions.DefaultTTL, func() time.Time { return now }),
+			settings.NewStore(database, settings.Defaults(-100), settings.CheckInterval, func() time.Time { return now }),
+			func() time.Time { return now },
+		)
+		var body struct {
+			Session string `json:"session"`
+		}
+		json.Unmarshal([]byte(test.body), &body)
+		_, opened := database.sessions[body.Session]
+		req := httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(test.body))
+		req = req.WithContext(correlation.With(req.Context(), "abc123"))
+		if test.initData != "" {
+			req.Header.Set("X-Telegram-Init-Data", test.initData)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != test.status {
+			t.Errorf("%s - wrong status, expected %d, actual %d", test.name, test.status, rec.Code)
+		}
+		if test.response != "" && strings.TrimSpace(rec.Body.String()) != test.response {
+			t.Errorf(
+				"%s - wrong response\nexpected: %s\nactual:   %s",
+				test.name,
+				test.response,
+				rec.Body.String(),
+			)
+		}
+		test.publisher.copyErr = test.copyErr
+		if !reflect.DeepEqual(test.publisher, *bot) {
+			t.Errorf(
+				"%s - wrong bot api calls\nexpected: %+v\nactual:   %+v",
+				test.name,
+				test.publisher,
+				*bot,
+			)
+		}
+		if _, ok := database.sessions[body.Session]; opened && ok == test.ended {
+			t.Errorf("%s - session %q ended: %v", test.name, body.Session, !ok)
+		}
+		if _, ok := database.drafts["photo/1234"]; body.Session == "photo" && ok == test.ended {
+			t.Errorf("%s - draft of session %q kept: %v", test.name, body.Session, ok)
+		}
+		if !reflect.DeepEqual(test.analytics, inserted) {
+			t.Errorf(
+				"%s - wrong analytics\nexpected: %+v\nactual:   %+v",
+				test.name,
+				test.analytics,
+				inserted,
+			)
+		}
+	}
+}
+
+func TestPostClaimedSession(t *testing.T) {
+	now := time.Now()
+	database := dbMock{sessions: map[string]models.TaggingSession{
+		"photo": {ID: "photo", ChatID: 1234, MessageIDs: []int64{10}, ExpiresAt: now.Add(time.Hour)},
+	}}
+	store := sessions.NewStore(database, sessions.DefaultTTL, func() time.Time { return now })
+	// the bot resolved the session before the webapp, then posted it
+	other := sessions.NewStore(database, sessions.DefaultTTL, func() time.Time { return now })
+	_, err := store.Get(context.Background(), "photo")
+	if err != nil {
+		t.Fatalf("failed to get session: %v", err)
+	}
+	_, err = other.Claim(context.Background(), "photo")
+	if err != nil {
+		t.Fatalf("failed to claim session: %v", err)
+	}
+	bot := &publisherMock{}
+	handler := handlePost(
+		&config.WepAppConfig{Token: "TOKEN", AdminIDs: []int64{1234}, ReceiverID: -100},
+		database,
+		fakeLogger(),
+		bot,
+		store,
+		settings.NewStore(database, settings.Defaults(-100), settings.CheckInterval, func() time.Time { return now }),
+		func() time.Time { return now },
+	)
+	req := httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(`{"session":"photo","data":[["group1","#tag1"]]}`))
+	req.Header.Set("X-Telegram-Init-Data", inlineInitData(1234))
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("wrong status\nexpected: %d\nactual:   %d", http.StatusConflict, rec.Code)
+	}
+	if !reflect.DeepEqual(publisherMock{}, *bot) {
+		t.Errorf("claimed session was posted again: %+v", *bot)
+	}
+}
@@ -0,0 +1,286 @@
+package webapp
+
+import (
+	"context"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/models"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	matchExact     = "exact"
+	matchPrefix    = "prefix"
+	matchSubstring = "substring"
+	matchFuzzy     = "fuzzy"
+	matchGroup     = "group"
+)
+
+var matchRank = map[string]int{
+	matchExact:     0,
+	matchPrefix:    1,
+	matchSubstring: 2,
+	matchFuzzy:     3,
+	matchGroup:     4,
+}
+
+type searchResult struct {
+	Tag   string `json:"tag"`
+	Group string `json:"group"`
+	Match string `json:"match"`
+	Alias string `json:"alias,omitempty"`
+	Usage int    `json:"usage"`
+
+	distance int
+}
+
+// searchTags matches the query against tag names and aliases, falling back
+// to edit distance for typos and to group names, and ranks results by how
+// well they matched and then by usage.
+func searchTags(
+	groups []models.Group,
+	usage map[string]int,
+	query string,
+	limit int,
+) []searchResult {
+	query = normalizeSearch(query)
+	results := []searchResult{}
+	if query == "" {
+		return results
+	}
+	for _, g := range groups {
+		groupMatches := strings.Contains(strings.ToLower(g.Name), query)
+		for _, t := range g.Tags {
+			best, ok := matchTag(t, query)
+			if !ok && groupMatches {
+				best, ok = searchResult{Match: matchGroup}, true
+			}
+			if !ok {
+				continue
+			}
+			best.Tag = t.Name
+			best.Group = g.Name
+			best.Usage = usage[t.Name]
+			results = append(results, best)
+		}
+	}
+	slices.SortStableFunc(results, func(a, b searchResult) int {
+		if d := matchRank[a.Match] - matchRank[b.Match]; d != 0 {
+			return d
+		}
+		if d := b.Usage - a.Usage; d != 0 {
+			return d
+		}
+		if d := a.distance - b.distance; d != 0 {
+			return d
+		}
+		return strings.Compare(a.Tag, b.Tag)
+	})
+	return results[:min(limit, len(results))]
+}
+
+func matchTag(t models.Tag, query string) (searchResult, bool) {
+	var best searchResult
+	found := false
+	candidates := append([]string{t.Name}, t.Aliases...)
+	for i, candidate := range candidates {
+		r, ok := matchCandidate(normalizeSearch(candidate), query)
+		if !ok {
+			continue
+		}
+		if i != 0 {
+			r.Alias = candidate
+		}
+		if !found || matchRank[r.Match] < matchRank[best.Match] ||
+			(r.Match == best.Match && r.distance < best.distance) {
+			best = r
+			found = true
+		}
+	}
+	return best, found
+}
+
+func matchCandidate(candidate string, query string) (searchResult, bool) {
+	switch {
+	case candidate == query:
+		return searchResult{Match: matchExact}, true
+	case strings.HasPrefix(candidate, query):
+		return searchResult{Match: matchPrefix}, true
+	case strings.Contains(candidate, query):
+		return searchResult{Match: matchSubstring}, true
+	}
+	allowed := allowedEdits(query)
+	if allowed == 0 {
+		return searchResult{}, false
+	}
+	// Compare against the start of the candidate as well, so a typo in a
+	// partially typed tag still matches.
+	distance := levenshtein(candidate, query)
+	if prefix := runePrefix(candidate, utf8.RuneCountInString(query)); prefix != candidate {
+		distance = min(distance, levenshtein(prefix, query))
+	}
+	if distance > allowed {
+		return searchResult{}, false
+	}
+	return searchResult{Match: matchFuzzy, distance: distance}, true
+}
+
+func normalizeSearch(s string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(s), "#"))
+}
+
+func allowedEdits(query string) int {
+	switch n := utf8.RuneCountInString(query); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func runePrefix(s string, n int) string {
+	i := 0
+	for j := range s {
+		if i == n {
+			return s[:j]
+		}
+		i++
+	}
+	return s
+}
+
+func levenshtein(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// usageCache keeps total tag usage from analytics rollups for a while, so
+// searching as you type does not aggregate analytics on every keystroke.
+type usageCache struct {
+	db       db.DB
+	ttl      time.Duration
+	lookback time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	loadedAt time.Time
+	usage    map[string]int
+}
+
+func newUsageCache(
+	db db.DB,
+	ttl time.Duration,
+	lookback time.Duration,
+	now func() time.Time,
+) *usageCache {
+	return &usageCache{db: db, ttl: ttl, lookback: lookback, now: now}
+}
+
+func (c *usageCache) get(ctx context.Context) (map[string]int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if c.usage != nil && now.Sub(c.loadedAt) < c.ttl {
+		return c.usage, nil
+	}
+	admins, err := c.db.GetAdminAnalytics(ctx, now.Add(-c.lookback), now)
+	if err != nil {
+		return nil, err
+	}
+	usage := map[string]int{}
+	for _, a := range *admins {
+		for _, t := range a.Tags {
+			usage[t.Tag] += t.Count
+		}
+	}
+	c.usage = usage
+	c.loadedAt = now
+	return usage, nil
+}
+
+// cooccurrenceCache keeps tag co-occurrence per selected tag set for a while,
+// so toggling tags back and forth does not aggregate raw analytics each time.
+type cooccurrenceCache struct {
+	db         db.DB
+	ttl        time.Duration
+	lookback   time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cooccurrenceEntry
+}
+
+type cooccurrenceEntry struct {
+	loadedAt time.Time
+	usage    []models.TagUsage
+}
+
+func newCooccurrenceCache(
+	db db.DB,
+	ttl time.Duration,
+	lookback time.Duration,
+	maxEntries int,
+	now func() time.Time,
+) *cooccurrenceCache {
+	return &cooccurrenceCache{
+		db:         db,
+		ttl:        ttl,
+		lookback:   lookback,
+		maxEntries: maxEntries,
+		now:        now,
+		entries:    map[string]cooccurrenceEntry{},
+	}
+}
+
+func (c *cooccurrenceCache) get(ctx context.Context, tags []string) ([]models.TagUsage, error) {
+	selected := slices.Clone(tags)
+	slices.Sort(selected)
+	selected = slices.Compact(selected)
+	key := strings.Join(selected, "\n")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	e, ok := c.entries[key]
+	if ok && now.Sub(e.loadedAt) < c.ttl {
+		return e.usage, nil
+	}
+	usage, err := c.db.GetTagCooccurrence(ctx, selected, now.Add(-c.lookback))
+	if err != nil {
+		return nil, err
+	}
+	for k, e := range c.entries {
+		if now.Sub(e.loadedAt) >= c.ttl {
+			delete(c.entries, k)
+		}
+	}
+	if len(c.entries) >= c.maxEntries {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = cooccurrenceEntry{loadedAt: now, usage: *usage}
+	return *usage, nil
+}
@@ -0,0 +1,245 @@
+package webapp
+
+import (
+	"context"
+	"ratatoskr/internal/models"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchTags(t *testing.T) {
+	groups := []models.Group{
+		{Name: "Characters", Tags: []models.Tag{
+			{Name: "#cat"},
+			{Name: "#catgirl", Aliases: []string{"nekomimi"}},
+			{Name: "#bobcat"},
+		}},
+		{Name: "Places", Tags: []models.Tag{
+			{Name: "#mountains"},
+			{Name: "#sea"},
+		}},
+	}
+	usage := map[string]int{"#catgirl": 10, "#cat": 1, "#bobcat": 5, "#sea": 3}
+
+	type tc struct {
+		name     string
+		query    string
+		limit    int
+		expected []searchResult
+	}
+
+	table := []tc{
+		{
+			name:     "should return nothing for empty query",
+			query:    " # ",
+			limit:    10,
+			expected: []searchResult{},
+		},
+
+		{
+			name:  "should rank exact, prefix and substring matches, then usage",
+			query: "#Cat",
+			limit: 10,
+			expected: []searchResult{
+				{Tag: "#cat", Group: "Characters", Match: matchExact, Usage: 1},
+				{Tag: "#catgirl", Group: "Characters", Match: matchPrefix, Usage: 10},
+				{Tag: "#bobcat", Group: "Characters", Match: matchSubstring, Usage: 5},
+			},
+		},
+
+		{
+			name:  "should match aliases",
+			query: "neko",
+			limit: 10,
+			expected: []searchResult{
+				{Tag: "#catgirl", Group: "Characters", Match: matchPrefix, Alias: "nekomimi", Usage: 10},
+			},
+		},
+
+		{
+			name:  "should match typos",
+			query: "mountians",
+			limit: 10,
+			expected: []searchResult{
+				{Tag: "#mountains", Group: "Places", Match: matchFuzzy, distance: 2},
+			},
+		},
+
+		{
+			name:  "should match typos in partially typed tags",
+			query: "mointa",
+			limit: 10,
+			expected: []searchResult{
+				{Tag: "#mountains", Group: "Places", Match: matchFuzzy, distance: 1},
+			},
+		},
+
+		{
+			name:  "should match group names",
+			query: "place",
+			limit: 10,
+			expected: []searchResult{
+				{Tag: "#sea", Group: "Places", Match: matchGroup, Usage: 3},
+				{Tag: "#mountains", Group: "Places", Match: matchGroup},
+			},
+		},
+
+		{
+			name:  "should limit results",
+			query: "cat",
+			limit: 1,
+			expected: []searchResult{
+				{Tag: "#cat", Group: "Characters", Match: matchExact, Usage: 1},
+			},
+		},
+	}
+
+	for _, test := range table {
+		actual := searchTags(groups, usage, test.query, test.limit)
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf(
+				"%s\nexpected: %+v\nactual:   %+v",
+				test.name,
+				test.expected,
+				actual,
+			)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	type tc struct {
+		a        string
+		b        string
+		expected int
+	}
+
+	table := []tc{
+		{a: "", b: "abc", expected: 3},
+		{a: "kitten", b: "sitting", expected: 3},
+		{a: "котик", b: "кот", expected: 2},
+		{a: "same", b: "same", expected: 0},
+	}
+
+	for _, test := range table {
+		actual := levenshtein(test.a, test.b)
+		if actual != test.expected {
+			t.Errorf("%q, %q\nexpected: %+v\nactual:   %+v", test.a, test.b, test.expected, actual)
+		}
+	}
+}
+
+type usageDBMock struct {
+	dbMock
+	calls int
+}
+
+func (m *usageDBMock) GetAdminAnalytics(context.Context, time.Time, time.Time) (*[]models.AdminAnalytics, error) {
+	m.calls++
+	return &[]models.AdminAnalytics{
+		{UserID: 1, Tags: []models.TagUsage{{Tag: "#tag1", Count: 2}}},
+		{UserID: 2, Tags: []models.TagUsage{{Tag: "#tag1", Count: 3}, {Tag: "#tag2", Count: 1}}},
+	}, nil
+}
+
+func TestUsageCache(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	database := &usageDBMock{}
+	cache := newUsageCache(database, time.Minute, time.Hour, func() time.Time { return now })
+	for range 2 {
+		usage, err := cache.get(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := map[string]int{"#tag1": 5, "#tag2": 1}
+		if !reflect.DeepEqual(expected, usage) {
+			t.Errorf("wrong usage\nexpected: %+v\nactual:   %+v", expected, usage)
+		}
+	}
+	if database.calls != 1 {
+		t.Errorf("cache did not reuse usage, calls: %d", database.calls)
+	}
+	now = now.Add(time.Minute)
+	cache.get(context.Background())
+	if database.calls != 2 {
+		t.Errorf("cache did not expire, calls: %d", database.calls)
+	}
+}
+
+type cooccurrenceDBMock struct {
+	dbMock
+	calls []string
+}
+
+func (m *cooccurrenceDBMock) GetTagCooccurrence(
+	_ context.Context,
+	tags []string,
+	_ time.Time,
+) (*[]models.TagUsage, error) {
+	m.calls = append(m.calls, strings.Join(tags, ","))
+	return &[]models.TagUsage{{Tag: "#tag3", Group: "group2", Count: len(tags)}}, nil
+}
+
+func TestCooccurrenceCache(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	database := &cooccurrenceDBMock{}
+	cache := newCooccurrenceCache(database, time.Minute, time.Hour, 2, func() time.Time { return now })
+
+	type tc struct {
+		name     string
+		tags     []string
+		advance  time.Duration
+		expected []string
+	}
+
+	table := []tc{
+		{
+			name:     "should load new tag set",
+			tags:     []string{"#tag2", "#tag1"},
+			expected: []string{"#tag1,#tag2"},
+		},
+
+		{
+			name:     "should reuse the same set in any order",
+			tags:     []string{"#tag1", "#tag2", "#tag1"},
+			expected: []string{"#tag1,#tag2"},
+		},
+
+		{
+			name:     "should load another set",
+			tags:     []string{"#tag1"},
+			expected: []string{"#tag1,#tag2", "#tag1"},
+		},
+
+		{
+			name:     "should reload expired set",
+			tags:     []string{"#tag1"},
+			advance:  time.Minute,
+			expected: []string{"#tag1,#tag2", "#tag1", "#tag1"},
+		},
+	}
+
+	for _, test := range table {
+		now = now.Add(test.advance)
+		usage, err := cache.get(context.Background(), test.tags)
+		if err != nil {
+			t.Fatalf("%s - unexpected error: %v", test.name, err)
+		}
+		if len(usage) != 1 || usage[0].Tag != "#tag3" {
+			t.Errorf("%s - wrong usage: %+v", test.name, usage)
+		}
+		if !reflect.DeepEqual(test.expected, database.calls) {
+			t.Errorf(
+				"%s - wrong loads\nexpected: %+v\nactual:   %+v",
+				test.name,
+				test.expected,
+				database.calls,
+			)
+		}
+	}
+	if len(cache.entries) > 2 {
+		t.Errorf("cache grew past its size: %d", len(cache.entries))
+	}
+}
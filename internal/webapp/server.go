@@ -1,31 +1,60 @@
 package webapp
 
 import (
+	"bytes"
 	"context"
+	"crypto/subtle"
 	"embed"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
+	"ratatoskr/internal/analytics"
 	"ratatoskr/internal/config"
+	"ratatoskr/internal/correlation"
 	"ratatoskr/internal/db"
+	"ratatoskr/internal/events"
 	"ratatoskr/internal/logger"
+	"ratatoskr/internal/metrics"
 	"ratatoskr/internal/models"
+	"ratatoskr/internal/sessions"
+	"ratatoskr/internal/settings"
+	"ratatoskr/internal/tags_parser"
+	"strconv"
+	"strings"
 	"time"
 )
 
 //go:embed static
 var content embed.FS
 
-func NewServer(c *config.WepAppConfig, db db.DB, logger *logger.Logger) (http.Handler, error) {
+// NewServer builds the webapp handler. runtime, bus and store are shared
+// with the bot when both run in one process, a nil runtime or store makes the
+// webapp keep its own.
+func NewServer(
+	c *config.WepAppConfig,
+	db db.DB,
+	logger *logger.Logger,
+	runtime *settings.Store,
+	bus *events.Bus,
+	store *sessions.Store,
+) (http.Handler, error) {
 	mux := http.NewServeMux()
 	t, err := loadTemplate()
 	if err != nil {
-		return nil, logger.Error(err.Error())
+		return nil, logger.Error("failed to load templates", "error", err)
+	}
+	bot, err := newPublisher(c, logger)
+	if err != nil {
+		return nil, logger.Error("failed to create publisher", "error", err)
 	}
-	addRoutes(mux, c, db, logger, t)
+	addRoutes(mux, c, db, logger, t, bot, runtime, bus, store)
 
 	var handler http.Handler = mux
+	handler = MetricsMiddleware(handler)
 	handler = LoggerMiddleware(logger, handler)
+	handler = CorrelationMiddleware(handler)
 	return handler, nil
 }
 
@@ -35,10 +64,89 @@ func addRoutes(
 	db db.DB,
 	logger *logger.Logger,
 	template *template.Template,
+	bot publisher,
+	runtime *settings.Store,
+	bus *events.Bus,
+	store *sessions.Store,
 ) {
+	if store == nil {
+		store = sessions.NewStore(db, sessions.DefaultTTL, time.Now)
+	}
+	menus := newMenuCache(db, template, menuCheckInterval, time.Now)
+	bus.Subscribe(events.TagsChanged, menus.invalidate)
+	if runtime == nil {
+		runtime = settings.NewStore(
+			db,
+			settings.Defaults(config.ReceiverID),
+			settings.CheckInterval,
+			time.Now,
+		)
+	}
 	mux.Handle("/static/", http.FileServer(http.FS(content)))
-	mux.HandleFunc("/", tokenOnly(config, logger, handleHome(config, db, logger, template)))
+	mux.HandleFunc(
+		"/",
+		tokenOnly(config, logger, handleHome(config, db, logger, template, store, menus)),
+	)
 	mux.Handle("/ping", ping())
+	mux.HandleFunc("GET /healthz", handleHealthz())
+	mux.HandleFunc("GET /readyz", handleReadyz(db, logger, template, menus))
+	if config.MetricsToken != "" {
+		mux.Handle("GET /metrics", metrics.Default.TokenHandler(config.MetricsToken))
+	}
+	mux.HandleFunc(
+		"GET /suggestions",
+		tokenAuth(config, logger, handleSuggestions(
+			logger,
+			menus,
+			newCooccurrenceCache(
+				db,
+				cooccurrenceTTL,
+				suggestionsLookback,
+				cooccurrenceCacheSize,
+				time.Now,
+			),
+		)),
+	)
+	mux.HandleFunc(
+		"GET /search",
+		tokenAuth(config, logger, handleSearch(
+			logger,
+			menus,
+			newUsageCache(db, searchUsageTTL, suggestionsLookback, time.Now),
+		)),
+	)
+	mux.HandleFunc("POST /tags", handleAddTag(config, db, logger, menus, time.Now))
+	mux.HandleFunc("GET /shortcuts", handleShortcuts(config, db, logger, menus, time.Now))
+	mux.HandleFunc("POST /favorites", handleFavorites(config, db, logger, menus, time.Now))
+	mux.HandleFunc("POST /posts", handlePost(config, db, logger, bot, store, runtime, time.Now))
+	mux.HandleFunc("GET /drafts/{session}", handleGetDraft(config, db, logger, store, time.Now))
+	mux.HandleFunc("PUT /drafts/{session}", handleSaveDraft(config, db, logger, store, time.Now))
+	mux.HandleFunc(
+		"GET /export/analytics",
+		tokenAuth(config, logger, handleExportAnalytics(db, logger, time.Now)),
+	)
+	addAPIRoutes(mux, config, db, logger, time.Now)
+	addEditorRoutes(mux, config, db, logger, template, menus, time.Now)
+	mux.HandleFunc(
+		"GET /media/{chat}/{message}",
+		tokenAuth(config, logger, handleMediaPreview(
+			config.AdminIDs,
+			db,
+			logger,
+			newBotFiles(config.BotAPIURL, config.Token),
+			newPreviewCache(previewCacheSize, previewCacheTTL, time.Now),
+		)),
+	)
+	mux.HandleFunc("GET /dashboard", handleDashboard(config, db, logger, template, time.Now))
+	mux.HandleFunc("POST /dashboard", handleDashboardLogin(config, logger, time.Now))
+}
+
+const recentTagsLimit = 8
+
+type shortcuts struct {
+	ID    string
+	Title string
+	Tags  []string
 }
 
 func handleHome(
@@ -46,29 +154,433 @@ func handleHome(
 	db db.DB,
 	logger *logger.Logger,
 	template *template.Template,
+	store *sessions.Store,
+	menus *menuCache,
 ) http.HandlerFunc {
 	type data struct {
-		Version string
-		Groups  []models.Group
+		Version       string
+		Previews      []preview
+		Menu          *cachedMenu
+		SearchResults shortcuts
+		Favorites     shortcuts
+		Recent        shortcuts
+		Suggestions   shortcuts
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := context.Background()
-		ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+		log := logger.WithContext(r.Context())
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
 		defer cancel()
-		group, err := db.GetAllGroupsWithTags(ctx)
+		menu, err := menus.get(ctx)
 		if err != nil {
-			logger.Error(err.Error())
+			log.Error("failed to get tag menu", "error", err)
 			fmt.Fprintf(w, "")
 			return
 		}
-		err = template.ExecuteTemplate(w, "webapp", data{
-			Version: config.Version,
-			Groups:  *group})
+		d := data{
+			Version:       config.Version,
+			Menu:          menu,
+			SearchResults: shortcuts{ID: "search-results", Title: "Results"},
+			Favorites:     shortcuts{ID: "favorites", Title: "Favorites"},
+			Recent:        shortcuts{ID: "recent", Title: "Recent"},
+			Suggestions:   shortcuts{ID: "suggestions", Title: "Suggested"},
+		}
+		session, err := store.Get(ctx, r.URL.Query().Get("session"))
 		if err != nil {
-			logger.Error(err.Error())
+			log.Warning("opened without tagging session", "error", err)
+		}
+		userID := session.ChatID
+		if err == nil && isAdmin(config.AdminIDs, userID) {
+			d.Previews, err = previews(ctx, db, config.Token, userID, session.MessageIDs)
+			if err != nil {
+				log.Error(
+					"failed to get media previews",
+					"session_id", session.ID,
+					"error", err,
+				)
+			}
+		}
+		var page bytes.Buffer
+		err = template.ExecuteTemplate(&page, "webapp", d)
+		if err != nil {
+			log.Error("failed to render webapp", "error", err)
 			fmt.Fprintf(w, "")
 			return
 		}
+		err = writeCached(w, r, "text/html; charset=utf-8", page.Bytes())
+		if err != nil {
+			log.Error("failed to write webapp", "error", err)
+		}
+	}
+}
+
+const (
+	searchUsageTTL     = time.Minute * 5
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+func handleSearch(logger *logger.Logger, menus *menuCache, usage *usageCache) http.HandlerFunc {
+	type response struct {
+		Results []searchResult `json:"results"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.WithContext(r.Context())
+		query := r.URL.Query()
+		limit, err := parseLimit(query.Get("limit"), defaultSearchLimit, maxSearchLimit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+		menu, err := menus.get(ctx)
+		if err != nil {
+			log.Error("failed to get tag menu", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		counts, err := usage.get(ctx)
+		if err != nil {
+			log.Warning("searching without tag usage", "error", err)
+			counts = map[string]int{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		err = json.NewEncoder(w).Encode(response{
+			Results: searchTags(menu.groups, counts, query.Get("q"), limit),
+		})
+		if err != nil {
+			log.Error("failed to write search results", "error", err)
+		}
+	}
+}
+
+func parseLimit(s string, fallback int, max int) (int, error) {
+	if s == "" {
+		return fallback, nil
+	}
+	limit, err := strconv.Atoi(s)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("invalid limit %q", s)
+	}
+	return min(limit, max), nil
+}
+
+// handleShortcuts returns the favorite and recent tags of the admin who opened
+// the webapp. The page is loaded without init data, so they are fetched once
+// the webapp can sign the request.
+func handleShortcuts(
+	config *config.WepAppConfig,
+	db db.DB,
+	logger *logger.Logger,
+	menus *menuCache,
+	now func() time.Time,
+) http.HandlerFunc {
+	type response struct {
+		Favorites []string `json:"favorites"`
+		Recent    []string `json:"recent"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.WithContext(r.Context())
+		userID, err := webAppAdmin(config, r, now())
+		if err != nil {
+			log.Error("rejected shortcuts request", "error", err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+		menu, err := menus.get(ctx)
+		if err != nil {
+			log.Error("failed to get tag menu", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		favorites, err := db.GetFavoriteTags(ctx, userID)
+		if err != nil {
+			log.Error("failed to get favorite tags", "user_id", userID, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		recent, err := db.GetRecentTags(ctx, userID, recentTagsLimit)
+		if err != nil {
+			log.Error("failed to get recent tags", "user_id", userID, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		tags := []string{}
+		for _, u := range *recent {
+			tags = append(tags, u.Tag)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		err = json.NewEncoder(w).Encode(response{
+			Favorites: inMenu(menu.tags, *favorites),
+			Recent:    inMenu(menu.tags, tags),
+		})
+		if err != nil {
+			log.Error("failed to write shortcuts", "error", err)
+		}
+	}
+}
+
+func handleFavorites(
+	config *config.WepAppConfig,
+	db db.DB,
+	logger *logger.Logger,
+	menus *menuCache,
+	now func() time.Time,
+) http.HandlerFunc {
+	type request struct {
+		Tag      string `json:"tag"`
+		Favorite bool   `json:"favorite"`
+	}
+	type response struct {
+		Favorites []string `json:"favorites"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.WithContext(r.Context())
+		userID, err := webAppAdmin(config, r, now())
+		if err != nil {
+			log.Error("rejected favorites request", "error", err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var req request
+		err = json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req)
+		if err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+		menu, err := menus.get(ctx)
+		if err != nil {
+			log.Error("failed to get tag menu", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if _, ok := menu.tags[req.Tag]; !ok && req.Favorite {
+			http.Error(w, fmt.Sprintf("unknown tag %q", req.Tag), http.StatusBadRequest)
+			return
+		}
+		err = db.SetFavoriteTag(ctx, userID, req.Tag, req.Favorite)
+		if err != nil {
+			log.Error("failed to update favorite tags", "user_id", userID, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		favorites, err := db.GetFavoriteTags(ctx, userID)
+		if err != nil {
+			log.Error("failed to get favorite tags", "user_id", userID, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(response{Favorites: inMenu(menu.tags, *favorites)})
+		if err != nil {
+			log.Error("failed to write favorites", "error", err)
+		}
+	}
+}
+
+func handleAddTag(
+	config *config.WepAppConfig,
+	database db.DB,
+	logger *logger.Logger,
+	menus *menuCache,
+	now func() time.Time,
+) http.HandlerFunc {
+	type request struct {
+		Group string `json:"group"`
+		Tag   string `json:"tag"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.WithContext(r.Context())
+		userID, err := webAppAdmin(config, r, now())
+		if err != nil {
+			log.Error("rejected add tag request", "error", err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var req request
+		err = json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req)
+		if err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		req.Tag = strings.TrimSpace(req.Tag)
+		err = tags_parser.ValidateTag(req.Tag)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+		err = database.AddTag(ctx, req.Group, models.Tag{Name: req.Tag})
+		switch {
+		case errors.Is(err, db.ErrGroupNotFound):
+			http.Error(w, fmt.Sprintf("group %q not found", req.Group), http.StatusNotFound)
+			return
+		case errors.Is(err, db.ErrTagExists):
+			http.Error(w, fmt.Sprintf("tag %q already exists", req.Tag), http.StatusConflict)
+			return
+		case err != nil:
+			log.Error("failed to add tag", "group", req.Group, "tag", req.Tag, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		menus.invalidate()
+		log.Info("tag added", "user_id", userID, "group", req.Group, "tag", req.Tag)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		err = json.NewEncoder(w).Encode(req)
+		if err != nil {
+			log.Error("failed to write added tag", "error", err)
+		}
+	}
+}
+
+// webAppAdmin returns the admin who signed the request with webapp init data.
+func webAppAdmin(c *config.WepAppConfig, r *http.Request, now time.Time) (int64, error) {
+	return initDataAdmin(c, r.Header.Get("X-Telegram-Init-Data"), now)
+}
+
+func initDataAdmin(c *config.WepAppConfig, initData string, now time.Time) (int64, error) {
+	userID, err := validateInitData(initData, c.Token, now)
+	if err != nil {
+		return 0, err
+	}
+	if !isAdmin(c.AdminIDs, userID) {
+		return 0, fmt.Errorf("user %d is not an admin", userID)
+	}
+	return userID, nil
+}
+
+// menuTags maps every tag in the menu to its group name.
+func menuTags(groups []models.Group) map[string]string {
+	menu := map[string]string{}
+	for _, g := range groups {
+		for _, t := range g.Tags {
+			menu[t.Name] = g.Name
+		}
+	}
+	return menu
+}
+
+func inMenu(menu map[string]string, tags []string) []string {
+	res := []string{}
+	for _, tag := range tags {
+		if _, ok := menu[tag]; ok {
+			res = append(res, tag)
+		}
+	}
+	return res
+}
+
+const (
+	suggestionsLookback     = time.Hour * 24 * 365
+	cooccurrenceTTL         = time.Minute
+	cooccurrenceCacheSize   = 256
+	defaultSuggestionsLimit = 10
+	maxSuggestionsLimit     = 30
+)
+
+func handleSuggestions(
+	logger *logger.Logger,
+	menus *menuCache,
+	cooccurrence *cooccurrenceCache,
+) http.HandlerFunc {
+	type suggestion struct {
+		Tag   string `json:"tag"`
+		Group string `json:"group"`
+		Score int    `json:"score"`
+	}
+	type response struct {
+		Suggestions []suggestion `json:"suggestions"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.WithContext(r.Context())
+		query := r.URL.Query()
+		limit, err := parseLimit(query.Get("limit"), defaultSuggestionsLimit, maxSuggestionsLimit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		res := response{Suggestions: []suggestion{}}
+		selected := query["tag"]
+		if len(selected) != 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+			defer cancel()
+			menu, err := menus.get(ctx)
+			if err != nil {
+				log.Error("failed to get tag menu", "error", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			usage, err := cooccurrence.get(ctx, selected)
+			if err != nil {
+				log.Error("failed to get tag suggestions", "tags", selected, "error", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			for _, u := range usage {
+				group, ok := menu.tags[u.Tag]
+				if !ok {
+					continue
+				}
+				res.Suggestions = append(res.Suggestions, suggestion{
+					Tag:   u.Tag,
+					Group: group,
+					Score: u.Count,
+				})
+				if len(res.Suggestions) == limit {
+					break
+				}
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		err = json.NewEncoder(w).Encode(res)
+		if err != nil {
+			log.Error("failed to write suggestions", "error", err)
+		}
+	}
+}
+
+func handleExportAnalytics(
+	db db.DB,
+	logger *logger.Logger,
+	now func() time.Time,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.WithContext(r.Context())
+		query := r.URL.Query()
+		format, err := analytics.ParseFormat(query.Get("format"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		from, to, err := analytics.ParseRange(query.Get("from"), query.Get("to"), now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", analytics.ContentType(format))
+		w.Header().Set(
+			"Content-Disposition",
+			fmt.Sprintf("attachment; filename=%q", analytics.FileName(format, from, to)),
+		)
+		err = analytics.Export(r.Context(), db, w, format, from, to)
+		if err != nil {
+			log.Error(
+				"failed to export analytics",
+				"format", format,
+				"from", from,
+				"to", to,
+				"error", err,
+			)
+		}
 	}
 }
 
@@ -81,7 +593,7 @@ func ping() http.HandlerFunc {
 func tokenOnly(c *config.WepAppConfig, l *logger.Logger, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != fmt.Sprintf("/%s", c.Token) {
-			l.Error("requested server, but not bot")
+			l.WithContext(r.Context()).Warning("rejected request without token", "route", r.Pattern)
 			w.WriteHeader(http.StatusForbidden)
 			return
 		}
@@ -89,9 +601,30 @@ func tokenOnly(c *config.WepAppConfig, l *logger.Logger, next http.HandlerFunc)
 	}
 }
 
+func tokenAuth(c *config.WepAppConfig, l *logger.Logger, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !hasValidToken(c, r) {
+			l.WithContext(r.Context()).Warning("rejected request without token", "route", r.Pattern)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// hasValidToken accepts the token as a bearer token or as a token query
+// parameter, so links can be opened directly in a browser.
+func hasValidToken(c *config.WepAppConfig, r *http.Request) bool {
+	token := r.URL.Query().Get("token")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		token = bearer
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(c.Token)) == 1
+}
+
 func loadTemplate() (*template.Template, error) {
 	tmpl := template.New("main")
-	t, err := tmpl.ParseFS(content, "static/view.html")
+	t, err := tmpl.ParseFS(content, "static/view.html", "static/editor.html", "static/dashboard.html")
 	return t, err
 }
 
@@ -100,18 +633,63 @@ type statusRecorder struct {
 	statusCode int
 }
 
-func LoggerMiddleware(l *logger.Logger, next http.Handler) http.Handler {
+func (r *statusRecorder) WriteHeader(statusCode int) {
+	r.statusCode = statusCode
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+// MetricsMiddleware records request durations by route pattern, so tokens
+// in the path never become label values.
+func MetricsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		startTime := time.Now()
-		l.Info(fmt.Sprintf("Started %s %s", r.Method, r.URL.Path))
 		responseRecorder := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
 		next.ServeHTTP(responseRecorder, r)
-		l.Info(fmt.Sprintf(
-			"Completed in %v %s %s %d",
-			time.Since(startTime),
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPRequestDuration.Observe(
+			time.Since(startTime).Seconds(),
+			route,
 			r.Method,
-			r.URL.Path,
-			responseRecorder.statusCode,
-		))
+			strconv.Itoa(responseRecorder.statusCode),
+		)
+	})
+}
+
+const correlationHeader = "X-Correlation-ID"
+
+// CorrelationMiddleware gives every request a correlation ID, reusing a
+// valid one sent by the client, and returns it in a response header.
+func CorrelationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(correlationHeader)
+		if !correlation.Valid(id) {
+			id = correlation.New()
+		}
+		w.Header().Set(correlationHeader, id)
+		next.ServeHTTP(w, r.WithContext(correlation.With(r.Context(), id)))
+	})
+}
+
+// LoggerMiddleware logs every request by route pattern like
+// MetricsMiddleware, the path may contain the bot token.
+func LoggerMiddleware(l *logger.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startTime := time.Now()
+		responseRecorder := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(responseRecorder, r)
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		l.WithContext(r.Context()).Info(
+			"request completed",
+			"method", r.Method,
+			"route", route,
+			"status", responseRecorder.statusCode,
+			"duration", time.Since(startTime),
+		)
 	})
 }
@@ -0,0 +1,865 @@
+package webapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"ratatoskr/internal/config"
+	"ratatoskr/internal/correlation"
+	"ratatoskr/internal/db"
+	"ratatoskr/internal/logger"
+	"ratatoskr/internal/models"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fakeLogger() *logger.Logger {
+	return logger.NewLogger(
+		"test logger",
+		&strings.Builder{},
+		logger.Options{},
+	)
+}
+
+type dbMock struct {
+	db.DB
+	analytics   []models.Analytics
+	cooccurring []models.TagUsage
+	since       *time.Time
+	recent      []models.TagUsage
+	favorites   map[int64][]string
+	admins      []models.AdminAnalytics
+	added       map[string][]string
+	posts       []models.Post
+	menu        *models.TagMenu
+	daily       []models.PostRollup
+	media       []models.PendingMedia
+	inserted    *[]models.Analytics
+	sessions    map[string]models.TaggingSession
+	drafts      map[string]models.Draft
+	pingErr     error
+	settings    *models.Settings
+}
+
+func (m dbMock) Ping(context.Context) error {
+	return m.pingErr
+}
+
+func (m dbMock) GetSettings(context.Context) (*models.Settings, error) {
+	if m.settings == nil {
+		return nil, db.ErrSettingsNotFound
+	}
+	return m.settings, nil
+}
+
+func (m dbMock) GetTaggingSession(_ context.Context, id string) (*models.TaggingSession, error) {
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, db.ErrSessionNotFound
+	}
+	return &s, nil
+}
+
+func (m dbMock) DeleteTaggingSession(_ context.Context, id string) error {
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m dbMock) InsertTaggingSession(_ context.Context, s *models.TaggingSession) error {
+	m.sessions[s.ID] = *s
+	return nil
+}
+
+func (m dbMock) ClaimTaggingSession(_ context.Context, id string) (*models.TaggingSession, error) {
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, db.ErrSessionNotFound
+	}
+	delete(m.sessions, id)
+	return &s, nil
+}
+
+func draftKey(sessionID string, userID int64) string {
+	return fmt.Sprintf("%s/%d", sessionID, userID)
+}
+
+func (m dbMock) GetDraft(_ context.Context, sessionID string, userID int64) (*models.Draft, error) {
+	d, ok := m.drafts[draftKey(sessionID, userID)]
+	if !ok {
+		return nil, db.ErrDraftNotFound
+	}
+	return &d, nil
+}
+
+func (m dbMock) SaveDraft(_ context.Context, d *models.Draft) error {
+	m.drafts[draftKey(d.SessionID, d.UserID)] = *d
+	return nil
+}
+
+func (m dbMock) DeleteDrafts(_ context.Context, sessionID string) error {
+	for k, d := range m.drafts {
+		if d.SessionID == sessionID {
+			delete(m.drafts, k)
+		}
+	}
+	return nil
+}
+
+// testSessions are open tagging sessions of an admin and of another user.
+func testSessions() map[string]models.TaggingSession {
+	return map[string]models.TaggingSession{
+		"admin": {
+			ID:              "admin",
+			ChatID:          1234,
+			MessageIDs:      []int64{1},
+			ButtonMessageID: 1,
+			MediaKind:       models.MediaKindPhoto,
+			ExpiresAt:       time.Now().Add(time.Hour),
+		},
+		"stranger": {
+			ID:              "stranger",
+			ChatID:          5678,
+			MessageIDs:      []int64{1},
+			ButtonMessageID: 1,
+			MediaKind:       models.MediaKindPhoto,
+			ExpiresAt:       time.Now().Add(time.Hour),
+		},
+	}
+}
+
+func (m dbMock) InsertAnalytics(_ context.Context, a *[]models.Analytics) error {
+	*m.inserted = append(*m.inserted, *a...)
+	return nil
+}
+
+func (m dbMock) GetPendingMedia(
+	_ context.Context,
+	chatID int64,
+	messageIDs []int64,
+) (*[]models.PendingMedia, error) {
+	res := []models.PendingMedia{}
+	for _, id := range messageIDs {
+		for _, v := range m.media {
+			if v.ChatID == chatID && v.MessageID == id {
+				res = append(res, v)
+			}
+		}
+	}
+	return &res, nil
+}
+
+func (m dbMock) GetDailyPosts(context.Context, time.Time, time.Time) (*[]models.PostRollup, error) {
+	return &m.daily, nil
+}
+
+func (m dbMock) GetTagMenu(ctx context.Context) (*models.TagMenu, error) {
+	if m.menu == nil {
+		groups, err := m.GetAllGroupsWithTags(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &models.TagMenu{Groups: *groups}, nil
+	}
+	return m.menu, nil
+}
+
+func (m dbMock) GetTagMenuVersion(context.Context) (int64, error) {
+	if m.menu == nil {
+		return 0, nil
+	}
+	return m.menu.Version, nil
+}
+
+func (m dbMock) ReplaceTagMenu(_ context.Context, version int64, groups *[]models.Group) (int64, error) {
+	if version != m.menu.Version {
+		return 0, db.ErrMenuConflict
+	}
+	m.menu.Version++
+	m.menu.Groups = *groups
+	return m.menu.Version, nil
+}
+
+func (m dbMock) GetRecentPosts(_ context.Context, since time.Time, limit int) (*[]models.Post, error) {
+	res := []models.Post{}
+	for _, p := range m.posts {
+		if !p.Date.Before(since) && len(res) < limit {
+			res = append(res, p)
+		}
+	}
+	return &res, nil
+}
+
+func (m dbMock) AddTag(ctx context.Context, group string, tag models.Tag) error {
+	groups, _ := m.GetAllGroupsWithTags(ctx)
+	found := false
+	for _, g := range *groups {
+		found = found || g.Name == group
+		if slices.Contains(m.added[g.Name], tag.Name) {
+			return db.ErrTagExists
+		}
+		for _, t := range g.Tags {
+			if t.Name == tag.Name {
+				return db.ErrTagExists
+			}
+		}
+	}
+	if !found {
+		return db.ErrGroupNotFound
+	}
+	m.added[group] = append(m.added[group], tag.Name)
+	return nil
+}
+
+func (m dbMock) GetAdminAnalytics(context.Context, time.Time, time.Time) (*[]models.AdminAnalytics, error) {
+	return &m.admins, nil
+}
+
+func (_ dbMock) GetAllGroupsWithTags(context.Context) (*[]models.Group, error) {
+	return &[]models.Group{
+		{Name: "group1", Tags: []models.Tag{{Name: "#tag1"}, {Name: "#tag2"}}},
+		{Name: "group2", OriginalIndex: 1, Tags: []models.Tag{{Name: "#tag3"}}},
+	}, nil
+}
+
+func (m dbMock) StreamAnalytics(
+	_ context.Context,
+	from time.Time,
+	to time.Time,
+	fn func(models.Analytics) error,
+) error {
+	for _, a := range m.analytics {
+		if a.Date.Before(from) || !a.Date.Before(to) {
+			continue
+		}
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m dbMock) GetTagCooccurrence(
+	_ context.Context,
+	tags []string,
+	since time.Time,
+) (*[]models.TagUsage, error) {
+	if m.since != nil {
+		*m.since = since
+	}
+	res := []models.TagUsage{}
+	for _, u := range m.cooccurring {
+		if !slices.Contains(tags, u.Tag) {
+			res = append(res, u)
+		}
+	}
+	return &res, nil
+}
+
+func (m dbMock) GetRecentTags(_ context.Context, userID int64, limit int) (*[]models.TagUsage, error) {
+	res := m.recent[:min(limit, len(m.recent))]
+	return &res, nil
+}
+
+func (m dbMock) GetFavoriteTags(_ context.Context, userID int64) (*[]string, error) {
+	res := append([]string{}, m.favorites[userID]...)
+	return &res, nil
+}
+
+func (m dbMock) SetFavoriteTag(_ context.Context, userID int64, tag string, favorite bool) error {
+	tags := slices.DeleteFunc(m.favorites[userID], func(t string) bool { return t == tag })
+	if favorite {
+		tags = append(tags, tag)
+	}
+	m.favorites[userID] = tags
+	return nil
+}
+
+func newTestServer(t *testing.T, database db.DB) http.Handler {
+	t.Helper()
+	handler, err := NewServer(
+		&config.WepAppConfig{Version: "test", Token: "TOKEN", AdminIDs: []int64{1234}},
+		database,
+		fakeLogger(),
+		nil,
+		nil,
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	return handler
+}
+
+func TestExportAnalytics(t *testing.T) {
+	date := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	handler := newTestServer(t, dbMock{analytics: []models.Analytics{
+		{Tag: "#tag1", Group: "group1", Date: date},
+		{Tag: "#tag2", Group: "group1", Date: date.AddDate(0, 1, 0)},
+	}})
+
+	type tc struct {
+		name        string
+		url         string
+		status      int
+		contentType string
+		body        string
+	}
+
+	table := []tc{
+		{
+			name:   "should reject wrong token",
+			url:    "/export/analytics?token=WRONG",
+			status: http.StatusForbidden,
+		},
+
+		{
+			name:        "should stream csv for range",
+			url:         "/export/analytics?token=TOKEN&from=2024-01-01&to=2024-01-31",
+			status:      http.StatusOK,
+			contentType: "text/csv; charset=utf-8",
+			body: "id,date,tag,group,userId,mediaKind,itemCount,destinationChatId,channelMessageIds,postingMode,correlationId\n" +
+				",2024-01-15T10:00:00Z,#tag1,group1,,,,,,,\n",
+		},
+
+		{
+			name:        "should stream json",
+			url:         "/export/analytics?from=2024-02-01&to=2024-02-29&format=json",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        "[\n" + `{"id":"","date":"2024-02-15T10:00:00Z","tag":"#tag2","group":"group1"}` + "\n]\n",
+		},
+
+		{
+			name:   "should reject unknown format",
+			url:    "/export/analytics?token=TOKEN&format=xml",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range table {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, test.url, nil)
+		if !strings.Contains(test.url, "token=") {
+			req.Header.Set("Authorization", "Bearer TOKEN")
+		}
+		handler.ServeHTTP(rec, req)
+		res := rec.Result()
+		body, _ := io.ReadAll(res.Body)
+		if res.StatusCode != test.status {
+			t.Errorf("%s - wrong status, expected %d, actual %d", test.name, test.status, res.StatusCode)
+			continue
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+		if actual := res.Header.Get("Content-Type"); actual != test.contentType {
+			t.Errorf("%s - wrong content type %q", test.name, actual)
+		}
+		if string(body) != test.body {
+			t.Errorf("%s - wrong body\nexpected: %s\nactual:   %s", test.name, test.body, body)
+		}
+	}
+}
+
+func TestSuggestions(t *testing.T) {
+	since := time.Time{}
+	handler := newTestServer(t, dbMock{
+		since: &since,
+		cooccurring: []models.TagUsage{
+			{Tag: "#tag3", Group: "group2", Count: 5},
+			{Tag: "#deleted", Group: "group1", Count: 4},
+			{Tag: "#tag2", Group: "old group", Count: 2},
+			{Tag: "#tag1", Group: "group1", Count: 1},
+		},
+	})
+
+	type tc struct {
+		name     string
+		url      string
+		token    string
+		status   int
+		expected string
+	}
+
+	table := []tc{
+		{
+			name:   "should reject wrong token",
+			url:    "/suggestions?tag=%23tag1",
+			token:  "WRONG",
+			status: http.StatusForbidden,
+		},
+
+		{
+			name:     "should return nothing without selected tags",
+			url:      "/suggestions",
+			token:    "TOKEN",
+			status:   http.StatusOK,
+			expected: `{"suggestions":[]}` + "\n",
+		},
+
+		{
+			name:   "should rank tags from current menu without selected",
+			url:    "/suggestions?tag=%23tag1",
+			token:  "TOKEN",
+			status: http.StatusOK,
+			expected: `{"suggestions":[` +
+				`{"tag":"#tag3","group":"group2","score":5},` +
+				`{"tag":"#tag2","group":"group1","score":2}]}` + "\n",
+		},
+
+		{
+			name:     "should limit suggestions",
+			url:      "/suggestions?tag=%23tag1&tag=%23tag2&limit=1",
+			token:    "TOKEN",
+			status:   http.StatusOK,
+			expected: `{"suggestions":[{"tag":"#tag3","group":"group2","score":5}]}` + "\n",
+		},
+
+		{
+			name:   "should reject invalid limit",
+			url:    "/suggestions?tag=%23tag1&limit=zero",
+			token:  "TOKEN",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range table {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, test.url, nil)
+		req.Header.Set("Authorization", "Bearer "+test.token)
+		handler.ServeHTTP(rec, req)
+		res := rec.Result()
+		body, _ := io.ReadAll(res.Body)
+		if res.StatusCode != test.status {
+			t.Errorf("%s - wrong status, expected %d, actual %d", test.name, test.status, res.StatusCode)
+			continue
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+		if string(body) != test.expected {
+			t.Errorf("%s - wrong body\nexpected: %s\nactual:   %s", test.name, test.expected, body)
+		}
+	}
+	if since.IsZero() || time.Since(since) < suggestionsLookback-time.Minute {
+		t.Errorf("wrong suggestions lookback: %v", since)
+	}
+}
+
+func TestHomeShortcuts(t *testing.T) {
+	handler := newTestServer(t, dbMock{
+		recent:    []models.TagUsage{{Tag: "#tag3", Group: "group2", Count: 1}},
+		favorites: map[int64][]string{1234: {"#tag2"}},
+		sessions:  testSessions(),
+	})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/TOKEN?session=admin", nil))
+	html := rec.Body.String()
+	contains := []string{
+		`<div class="group shortcuts" id="favorites" hidden>`,
+		`<div class="group shortcuts" id="recent" hidden>`,
+	}
+	for _, c := range contains {
+		if !strings.Contains(html, c) {
+			t.Errorf("html does not contain %s\n%s", c, html)
+		}
+	}
+	if strings.Contains(html, `data-type="shortcut"`) {
+		t.Errorf("html renders shortcuts without init data\n%s", html)
+	}
+}
+
+func TestShortcuts(t *testing.T) {
+	handler := newTestServer(t, dbMock{
+		recent: []models.TagUsage{
+			{Tag: "#tag3", Group: "group2", Count: 1},
+			{Tag: "#deleted", Group: "group1", Count: 3},
+			{Tag: "#tag1", Group: "group1", Count: 2},
+		},
+		favorites: map[int64][]string{1234: {"#tag2", "#deleted"}},
+	})
+
+	type tc struct {
+		name     string
+		initData string
+		status   int
+		expected string
+	}
+
+	table := []tc{
+		{
+			name:     "should return recent and favorite tags from the menu",
+			initData: adminInitData(1234),
+			status:   http.StatusOK,
+			expected: `{"favorites":["#tag2"],"recent":["#tag3","#tag1"]}` + "\n",
+		},
+
+		{
+			name:     "should reject unsigned request",
+			initData: "user=%7B%22id%22%3A1234%7D",
+			status:   http.StatusForbidden,
+		},
+
+		{
+			name:     "should reject user who is not admin",
+			initData: adminInitData(5678),
+			status:   http.StatusForbidden,
+		},
+	}
+
+	for _, test := range table {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/shortcuts", nil)
+		req.Header.Set("X-Telegram-Init-Data", test.initData)
+		handler.ServeHTTP(rec, req)
+		if rec.Code != test.status {
+			t.Errorf("%s - wrong status, expected %d, actual %d", test.name, test.status, rec.Code)
+			continue
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+		if rec.Body.String() != test.expected {
+			t.Errorf(
+				"%s - wrong body\nexpected: %s\nactual:   %s",
+				test.name,
+				test.expected,
+				rec.Body.String(),
+			)
+		}
+	}
+}
+
+func adminInitData(userID int64) string {
+	return signedInitData(url.Values{
+		"auth_date": {strconv.FormatInt(time.Now().Unix(), 10)},
+		"user":      {fmt.Sprintf(`{"id":%d}`, userID)},
+	}, "TOKEN")
+}
+
+func TestFavorites(t *testing.T) {
+	database := dbMock{favorites: map[int64][]string{1234: {"#tag1"}}}
+	handler := newTestServer(t, database)
+	initData := adminInitData
+
+	type tc struct {
+		name     string
+		initData string
+		body     string
+		status   int
+		expected string
+	}
+
+	table := []tc{
+		{
+			name:     "should reject unsigned request",
+			initData: "user=%7B%22id%22%3A1234%7D",
+			body:     `{"tag":"#tag2","favorite":true}`,
+			status:   http.StatusForbidden,
+		},
+
+		{
+			name:     "should reject user who is not admin",
+			initData: initData(5678),
+			body:     `{"tag":"#tag2","favorite":true}`,
+			status:   http.StatusForbidden,
+		},
+
+		{
+			name:     "should add favorite",
+			initData: initData(1234),
+			body:     `{"tag":"#tag3","favorite":true}`,
+			status:   http.StatusOK,
+			expected: `{"favorites":["#tag1","#tag3"]}` + "\n",
+		},
+
+		{
+			name:     "should remove favorite",
+			initData: initData(1234),
+			body:     `{"tag":"#tag1","favorite":false}`,
+			status:   http.StatusOK,
+			expected: `{"favorites":["#tag3"]}` + "\n",
+		},
+
+		{
+			name:     "should reject tag missing from menu",
+			initData: initData(1234),
+			body:     `{"tag":"#deleted","favorite":true}`,
+			status:   http.StatusBadRequest,
+		},
+
+		{
+			name:     "should reject malformed body",
+			initData: initData(1234),
+			body:     `{"tag":`,
+			status:   http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range table {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/favorites", strings.NewReader(test.body))
+		req.Header.Set("X-Telegram-Init-Data", test.initData)
+		handler.ServeHTTP(rec, req)
+		res := rec.Result()
+		body, _ := io.ReadAll(res.Body)
+		if res.StatusCode != test.status {
+			t.Errorf("%s - wrong status, expected %d, actual %d", test.name, test.status, res.StatusCode)
+			continue
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+		if string(body) != test.expected {
+			t.Errorf("%s - wrong body\nexpected: %s\nactual:   %s", test.name, test.expected, body)
+		}
+	}
+	if !slices.Equal(database.favorites[1234], []string{"#tag3"}) {
+		t.Errorf("favorites were not stored: %+v", database.favorites)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	handler := newTestServer(t, dbMock{admins: []models.AdminAnalytics{
+		{UserID: 1234, Tags: []models.TagUsage{{Tag: "#tag2", Count: 4}, {Tag: "#tag1", Count: 1}}},
+	}})
+
+	type tc struct {
+		name     string
+		url      string
+		token    string
+		status   int
+		expected string
+	}
+
+	table := []tc{
+		{
+			name:   "should reject wrong token",
+			url:    "/search?q=tag",
+			token:  "WRONG",
+			status: http.StatusForbidden,
+		},
+
+		{
+			name:   "should rank by usage",
+			url:    "/search?q=%23tag",
+			token:  "TOKEN",
+			status: http.StatusOK,
+			expected: `{"results":[` +
+				`{"tag":"#tag2","group":"group1","match":"prefix","usage":4},` +
+				`{"tag":"#tag1","group":"group1","match":"prefix","usage":1},` +
+				`{"tag":"#tag3","group":"group2","match":"prefix","usage":0}]}` + "\n",
+		},
+
+		{
+			name:   "should limit results",
+			url:    "/search?q=group2&limit=1",
+			token:  "TOKEN",
+			status: http.StatusOK,
+			expected: `{"results":[` +
+				`{"tag":"#tag3","group":"group2","match":"group","usage":0}]}` + "\n",
+		},
+
+		{
+			name:   "should reject invalid limit",
+			url:    "/search?q=tag&limit=-1",
+			token:  "TOKEN",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range table {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, test.url, nil)
+		req.Header.Set("Authorization", "Bearer "+test.token)
+		handler.ServeHTTP(rec, req)
+		res := rec.Result()
+		body, _ := io.ReadAll(res.Body)
+		if res.StatusCode != test.status {
+			t.Errorf("%s - wrong status, expected %d, actual %d", test.name, test.status, res.StatusCode)
+			continue
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+		if string(body) != test.expected {
+			t.Errorf("%s - wrong body\nexpected: %s\nactual:   %s", test.name, test.expected, body)
+		}
+	}
+}
+
+func TestAddTag(t *testing.T) {
+	database := dbMock{added: map[string][]string{}}
+	handler := newTestServer(t, database)
+	initData := adminInitData
+
+	type tc struct {
+		name     string
+		initData string
+		body     string
+		status   int
+		expected string
+	}
+
+	table := []tc{
+		{
+			name:     "should reject user who is not admin",
+			initData: initData(5678),
+			body:     `{"group":"group1","tag":"#new"}`,
+			status:   http.StatusForbidden,
+		},
+
+		{
+			name:     "should add tag to group",
+			initData: initData(1234),
+			body:     `{"group":"group1","tag":" #new "}`,
+			status:   http.StatusCreated,
+			expected: `{"group":"group1","tag":"#new"}` + "\n",
+		},
+
+		{
+			name:     "should reject duplicate tag",
+			initData: initData(1234),
+			body:     `{"group":"group2","tag":"#new"}`,
+			status:   http.StatusConflict,
+		},
+
+		{
+			name:     "should reject tag existing in other group",
+			initData: initData(1234),
+			body:     `{"group":"group1","tag":"#tag3"}`,
+			status:   http.StatusConflict,
+		},
+
+		{
+			name:     "should reject tag without hash",
+			initData: initData(1234),
+			body:     `{"group":"group1","tag":"new"}`,
+			status:   http.StatusBadRequest,
+		},
+
+		{
+			name:     "should reject unknown group",
+			initData: initData(1234),
+			body:     `{"group":"missing","tag":"#other"}`,
+			status:   http.StatusNotFound,
+		},
+	}
+
+	for _, test := range table {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/tags", strings.NewReader(test.body))
+		req.Header.Set("X-Telegram-Init-Data", test.initData)
+		handler.ServeHTTP(rec, req)
+		res := rec.Result()
+		body, _ := io.ReadAll(res.Body)
+		if res.StatusCode != test.status {
+			t.Errorf("%s - wrong status, expected %d, actual %d, body %s", test.name, test.status, res.StatusCode, body)
+			continue
+		}
+		if test.status != http.StatusCreated {
+			continue
+		}
+		if string(body) != test.expected {
+			t.Errorf("%s - wrong body\nexpected: %s\nactual:   %s", test.name, test.expected, body)
+		}
+	}
+	if !slices.Equal(database.added["group1"], []string{"#new"}) {
+		t.Errorf("tag was not stored: %+v", database.added)
+	}
+}
+
+func TestLoggerMiddleware(t *testing.T) {
+	var out strings.Builder
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{token}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	handler := CorrelationMiddleware(LoggerMiddleware(
+		logger.NewLogger("test logger", &out, logger.Options{Format: logger.FormatJSON}),
+		mux,
+	))
+
+	req := httptest.NewRequest(http.MethodGet, "/TOKEN", nil)
+	req.Header.Set("X-Correlation-ID", "abc123")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if strings.Contains(out.String(), "TOKEN") {
+		t.Errorf("token leaked into logs: %s", out.String())
+	}
+	record := map[string]any{}
+	err := json.Unmarshal([]byte(out.String()), &record)
+	if err != nil {
+		t.Fatalf("invalid log record %q: %v", out.String(), err)
+	}
+	expected := map[string]any{
+		"msg":            "request completed",
+		"method":         http.MethodGet,
+		"route":          "GET /{token}",
+		"status":         float64(http.StatusTeapot),
+		"correlation_id": "abc123",
+	}
+	for k, v := range expected {
+		if record[k] != v {
+			t.Errorf("wrong %s\nexpected: %+v\nactual:   %+v", k, v, record[k])
+		}
+	}
+}
+
+func TestCorrelationMiddleware(t *testing.T) {
+	var received string
+	handler := CorrelationMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = correlation.ID(r.Context())
+	}))
+
+	type tc struct {
+		name     string
+		header   string
+		generate bool
+	}
+
+	table := []tc{
+		{
+			name:     "should reuse valid id from client",
+			header:   "abc-123_DEF",
+			generate: false,
+		},
+
+		{
+			name:     "should generate id when missing",
+			header:   "",
+			generate: true,
+		},
+
+		{
+			name:     "should replace invalid id from client",
+			header:   "abc\n123",
+			generate: true,
+		},
+	}
+
+	for _, test := range table {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Correlation-ID", test.header)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		actual := rec.Header().Get("X-Correlation-ID")
+		if actual != received {
+			t.Errorf("%s - response and context ids differ\nexpected: %q\nactual:   %q", test.name, received, actual)
+		}
+		if test.generate && (actual == test.header || !correlation.Valid(actual)) {
+			t.Errorf("%s - expected a generated id, actual %q", test.name, actual)
+		}
+		if !test.generate && actual != test.header {
+			t.Errorf("%s\nexpected: %q\nactual:   %q", test.name, test.header, actual)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+document.addEventListener('DOMContentLoaded', () => {
+  const form = assertInstance(
+    document.getElementById('dashboard-login'),
+    HTMLFormElement,
+  )
+  const initData = new URLSearchParams(window.location.hash.slice(1)).get(
+    'tgWebAppData',
+  )
+  if (!initData) {
+    return
+  }
+  login(form, initData)
+})
+
+/**
+ * Telegram passes the init data only in the URL fragment. Posting it once
+ * gets a short-lived cookie, the server renders the dashboard from then on.
+ * @param {HTMLFormElement} form
+ * @param {string} initData
+ */
+function login(form, initData) {
+  const field = assertInstance(
+    form.elements.namedItem('initData'),
+    HTMLInputElement,
+  )
+  field.value = initData
+  form.action = `/dashboard${window.location.search}`
+  form.submit()
+}
+
+/**
+ * @template T
+ * @returns {T}
+ * @param {unknown} obj
+ * @param {new (data: any) => T} type
+ */
+function assertInstance(obj, type) {
+  if (obj instanceof type) {
+    /** @type {any} */
+    const any = obj
+    /** @type {T} */
+    const t = any
+    return t
+  }
+  throw new Error(`Object ${obj} does not have the right type '${type}'!`)
+}
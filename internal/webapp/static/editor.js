@@ -0,0 +1,278 @@
+document.addEventListener('DOMContentLoaded', () => {
+  const editor = new Editor(
+    assertInstance(document.getElementById('editor-groups'), HTMLElement),
+  )
+  assertInstance(
+    document.getElementById('editor-add-group'),
+    HTMLButtonElement,
+  ).addEventListener('click', () => editor.addGroup())
+  assertInstance(
+    document.getElementById('editor-save'),
+    HTMLButtonElement,
+  ).addEventListener('click', () => editor.save())
+  editor.load()
+})
+
+class Editor {
+  /**
+   * @typedef tag
+   * @property {string} name
+   * @property {string[]} [aliases]
+   *
+   * @typedef group
+   * @property {string} name
+   * @property {tag[]} tags
+   *
+   * @typedef menu
+   * @property {number} version
+   * @property {group[]} groups
+   */
+
+  /** @type HTMLElement */
+  #groups
+  #version = 0
+  #dirty = false
+
+  /** @param {HTMLElement} groups */
+  constructor(groups) {
+    this.#groups = groups
+    sortable(groups, '[data-type="group"]', () => this.#changed())
+    groups.addEventListener('input', () => this.#changed())
+  }
+
+  async load() {
+    const res = await this.#request('GET')
+    if (res) {
+      this.#render(res)
+    }
+  }
+
+  async save() {
+    const res = await this.#request('PUT', {
+      version: this.#version,
+      groups: this.#serialize(),
+    })
+    if (res) {
+      this.#render(res)
+      Telegram.WebApp.showAlert('Saved')
+    }
+  }
+
+  /** @param {group} [group] */
+  addGroup(group = { name: '', tags: [] }) {
+    const fragment = cloneTemplate('editor-group')
+    const section = assertInstance(
+      fragment.querySelector('[data-type="group"]'),
+      HTMLElement,
+    )
+    field(section, 'name').value = group.name
+    const tags = assertInstance(section.querySelector('ul'), HTMLUListElement)
+    group.tags.forEach((tag) => this.#addTag(tags, tag))
+    sortable(tags, '[data-type="tag"]', () => this.#changed())
+    section
+      .querySelector('.editor-add-tag')
+      ?.addEventListener('click', () => {
+        this.#addTag(tags, { name: '#' }).focus()
+        this.#changed()
+      })
+    this.#onRemove(section)
+    this.#groups.append(fragment)
+    if (group.name === '') {
+      field(section, 'name').focus()
+      this.#changed()
+    }
+  }
+
+  /**
+   * @param {HTMLUListElement} list
+   * @param {tag} tag
+   * @returns {HTMLInputElement} name field
+   */
+  #addTag(list, tag) {
+    const fragment = cloneTemplate('editor-tag')
+    const li = assertInstance(
+      fragment.querySelector('[data-type="tag"]'),
+      HTMLLIElement,
+    )
+    const name = field(li, 'name')
+    name.value = tag.name
+    field(li, 'aliases').value = (tag.aliases ?? []).join(', ')
+    this.#onRemove(li)
+    list.append(fragment)
+    return name
+  }
+
+  /** @param {HTMLElement} item */
+  #onRemove(item) {
+    const button = [...item.querySelectorAll('.editor-remove')].find(
+      (b) => b.closest('[data-type]') === item,
+    )
+    button?.addEventListener('click', () => {
+      item.remove()
+      this.#changed()
+    })
+  }
+
+  /** @returns {group[]} */
+  #serialize() {
+    return [...this.#groups.querySelectorAll('[data-type="group"]')].map(
+      (g) => {
+        const section = assertInstance(g, HTMLElement)
+        return {
+          name: field(section, 'name').value,
+          tags: [...section.querySelectorAll('[data-type="tag"]')].map((t) => {
+            const li = assertInstance(t, HTMLElement)
+            return {
+              name: field(li, 'name').value,
+              aliases: field(li, 'aliases')
+                .value.split(',')
+                .map((a) => a.trim())
+                .filter(Boolean),
+            }
+          }),
+        }
+      },
+    )
+  }
+
+  /** @param {menu} menu */
+  #render(menu) {
+    this.#version = menu.version
+    this.#groups.replaceChildren()
+    menu.groups.forEach((g) => this.addGroup(g))
+    this.#dirty = false
+    Telegram.WebApp.disableClosingConfirmation()
+  }
+
+  #changed() {
+    if (!this.#dirty) {
+      this.#dirty = true
+      Telegram.WebApp.enableClosingConfirmation()
+    }
+  }
+
+  /**
+   * @param {string} method
+   * @param {menu} [body]
+   * @returns {Promise<menu | null>}
+   */
+  async #request(method, body) {
+    try {
+      const res = await fetch('/editor/menu', {
+        method,
+        headers: {
+          'Content-Type': 'application/json',
+          'X-Telegram-Init-Data': Telegram.WebApp.initData,
+        },
+        body: body && JSON.stringify(body),
+      })
+      const json = await res.json()
+      if (res.ok) {
+        return json
+      }
+      if (res.status === 409) {
+        Telegram.WebApp.showConfirm(
+          'Tags were changed by someone else. Load their version? Your changes will be lost.',
+          (ok) => ok && this.load(),
+        )
+        return null
+      }
+      Telegram.WebApp.showAlert(json.error?.message ?? `Request failed with ${res.status}`)
+    } catch (e) {
+      console.error(e)
+      Telegram.WebApp.showAlert('Request failed, try again')
+    }
+    return null
+  }
+}
+
+/**
+ * Lets items of container be reordered by dragging their .drag-handle with
+ * mouse or touch.
+ * @param {HTMLElement} container
+ * @param {string} itemSelector
+ * @param {() => void} onChange
+ */
+function sortable(container, itemSelector, onChange) {
+  container.addEventListener('pointerdown', (e) => {
+    const handle =
+      e.target instanceof Element ? e.target.closest('.drag-handle') : null
+    const item = handle?.closest('[data-type]')
+    if (
+      !(handle instanceof HTMLElement) ||
+      !(item instanceof HTMLElement) ||
+      !item.matches(itemSelector) ||
+      item.parentElement !== container
+    ) {
+      return
+    }
+    e.preventDefault()
+    handle.setPointerCapture(e.pointerId)
+    item.classList.add('dragging')
+    const next = item.nextElementSibling
+    /** @param {PointerEvent} e */
+    const move = (e) => {
+      const after = [...container.children].find((el) => {
+        if (el === item || !el.matches(itemSelector)) {
+          return false
+        }
+        const box = el.getBoundingClientRect()
+        return e.clientY < box.top + box.height / 2
+      })
+      container.insertBefore(item, after ?? null)
+    }
+    const stop = () => {
+      handle.removeEventListener('pointermove', move)
+      handle.removeEventListener('pointerup', stop)
+      handle.removeEventListener('pointercancel', stop)
+      item.classList.remove('dragging')
+      if (item.nextElementSibling !== next) {
+        onChange()
+      }
+    }
+    handle.addEventListener('pointermove', move)
+    handle.addEventListener('pointerup', stop)
+    handle.addEventListener('pointercancel', stop)
+  })
+}
+
+/**
+ * @param {string} id
+ * @returns {DocumentFragment}
+ */
+function cloneTemplate(id) {
+  const template = assertInstance(
+    document.getElementById(id),
+    HTMLTemplateElement,
+  )
+  return assertInstance(template.content.cloneNode(true), DocumentFragment)
+}
+
+/**
+ * @param {HTMLElement} item
+ * @param {string} name
+ * @returns {HTMLInputElement}
+ */
+function field(item, name) {
+  const input = [...item.querySelectorAll(`[data-field="${name}"]`)].find(
+    (el) => el.closest('[data-type]') === item,
+  )
+  return assertInstance(input, HTMLInputElement)
+}
+
+/**
+ * @template T
+ * @returns {T}
+ * @param {unknown} obj
+ * @param {new (data: any) => T} type
+ */
+function assertInstance(obj, type) {
+  if (obj instanceof type) {
+    /** @type {any} */
+    const any = obj
+    /** @type {T} */
+    const t = any
+    return t
+  }
+  throw new Error(`Object ${obj} does not have the right type '${type}'!`)
+}
@@ -1,18 +1,15 @@
 const TRANSITION_PROPERTY = '--transition-duration'
 
 const params = new URLSearchParams(window.location.search)
-const messageId = params.get('message-id')
-const mediaIds = params.get('media-id')
+const sessionId = params.get('session')
+const token = window.location.pathname.split('/').filter(Boolean)[0] ?? ''
 
-if (!messageId) {
-  throw new Error('messageId is required')
-}
-if (!mediaIds) {
-  throw new Error('messageId is required')
+if (!sessionId) {
+  throw new Error('session is required')
 }
 
-document.addEventListener('DOMContentLoaded', () => {
-  const persistence = new Persistence(messageId)
+document.addEventListener('DOMContentLoaded', async () => {
+  const persistence = await Persistence.load(sessionId)
   const openedGroups = new StringSet(persistence.session.openedGroups)
   const selectedTags = new StringSet(persistence.session.selectedTags)
   const mainElement = assertInstance(
@@ -28,9 +25,15 @@ document.addEventListener('DOMContentLoaded', () => {
     persistence.update('scrollY', window.scrollY)
   }, 100)
   window.addEventListener('scroll', () => throttledScrollUpdate())
-  window.addEventListener('beforeunload', () =>
-    persistence.update('scrollY', window.scrollY),
-  )
+  window.addEventListener('beforeunload', () => {
+    persistence.update('scrollY', window.scrollY)
+    persistence.flush()
+  })
+  document.addEventListener('visibilitychange', () => {
+    if (document.visibilityState === 'hidden') {
+      persistence.flush()
+    }
+  })
 
   document.querySelectorAll('input[data-type="group"]').forEach((g) => {
     const group = assertInstance(g, HTMLInputElement)
@@ -43,17 +46,72 @@ document.addEventListener('DOMContentLoaded', () => {
     })
   })
 
-  document.querySelectorAll('input[data-type="tag"]').forEach((t) => {
-    const tag = assertInstance(t, HTMLInputElement)
+  /** @type Map<string, HTMLInputElement> */
+  const tagInputs = new Map()
+  const shortcuts = new TagShortcuts(tagInputs)
+  const suggestions = new Suggestions(
+    assertInstance(document.getElementById('suggestions'), HTMLElement),
+    shortcuts,
+  )
+  /** @param {HTMLInputElement} tag */
+  const registerTag = (tag) => {
     const ul = assertInstance(tag.closest('ul'), HTMLUListElement)
     const withGroup = `${ul.id}::${tag.name}`
+    tagInputs.set(tag.name, tag)
     if (persistence.session.selectedTags.includes(withGroup)) {
       tag.checked = true
     }
     tag.addEventListener('change', () => {
       selectedTags.toggle(withGroup)
       persistence.update('selectedTags', selectedTags.get())
+      shortcuts.sync()
+      suggestions.update(selectedTags.get())
     })
+  }
+  document.querySelectorAll('input[data-type="tag"]').forEach((t) => {
+    registerTag(assertInstance(t, HTMLInputElement))
+  })
+  document.querySelectorAll('.add-tag button').forEach((b) => {
+    const button = assertInstance(b, HTMLButtonElement)
+    button.addEventListener('click', () =>
+      promptNewTag(button, (input) => {
+        registerTag(input)
+        input.checked = true
+        input.dispatchEvent(new Event('change'))
+      }),
+    )
+  })
+  const favorites = new Favorites(
+    assertInstance(document.getElementById('favorites'), HTMLElement),
+    shortcuts,
+  )
+  document.querySelectorAll('[data-type="shortcut"]').forEach((b) => {
+    shortcuts.bind(assertInstance(b, HTMLButtonElement))
+  })
+  shortcuts.sync()
+  loadShortcuts(
+    favorites,
+    assertInstance(document.getElementById('recent'), HTMLElement),
+    shortcuts,
+  )
+  suggestions.update(selectedTags.get())
+  const search = new Search(
+    mainElement,
+    assertInstance(document.getElementById('search-results'), HTMLElement),
+    shortcuts,
+  )
+  const searchInput = assertInstance(
+    document.getElementById('search'),
+    HTMLInputElement,
+  )
+  searchInput.addEventListener('input', () => search.update(searchInput.value))
+  onLongPress(mainElement, '.tag', (element) => {
+    const input = element.querySelector('input[data-type="tag"]')
+    const tag =
+      input instanceof HTMLInputElement ? input.name : element.dataset.tag
+    if (tag) {
+      favorites.toggle(tag)
+    }
   })
 
   window.scrollTo(0, persistence.session.scrollY)
@@ -62,17 +120,23 @@ document.addEventListener('DOMContentLoaded', () => {
     initialTransitionDuration,
   )
 
-  assertInstance(
+  const callback = assertInstance(
     document.getElementById('callback'),
     HTMLButtonElement,
-  ).addEventListener('click', () => {
-    Telegram.WebApp.sendData(
-      JSON.stringify({
-        messageId,
-        mediaIds,
-        data: selectedTags.get().map((el) => el.split('::')),
-      }),
-    )
+  )
+  callback.addEventListener('click', () => {
+    const post = JSON.stringify({
+      session: sessionId,
+      data: selectedTags.get().map((el) => el.split('::')),
+    })
+    callback.disabled = true
+    publish(post)
+      .then(() => Telegram.WebApp.close())
+      .catch((e) => {
+        console.error(e)
+        callback.disabled = false
+        Telegram.WebApp.showAlert(`Failed to post: ${e.message}`)
+      })
   })
 
   let clicks = 0
@@ -103,6 +167,394 @@ document.addEventListener('DOMContentLoaded', () => {
   mainElement.addEventListener('click', displayVersion)
 })
 
+class TagShortcuts {
+  /** @type Map<string, HTMLInputElement> */
+  tagInputs
+
+  /** @param {Map<string, HTMLInputElement>} tagInputs */
+  constructor(tagInputs) {
+    this.tagInputs = tagInputs
+  }
+
+  /**
+   * @param {string} tag
+   * @returns {HTMLLIElement}
+   */
+  create(tag) {
+    const button = document.createElement('button')
+    button.type = 'button'
+    button.className = 'tag'
+    button.dataset.type = 'shortcut'
+    button.dataset.tag = tag
+    button.textContent = tag
+    this.bind(button)
+    const li = document.createElement('li')
+    li.append(button)
+    return li
+  }
+
+  /** @param {HTMLButtonElement} button */
+  bind(button) {
+    const input = this.tagInputs.get(button.dataset.tag ?? '')
+    if (!input) {
+      button.closest('li')?.remove()
+      return
+    }
+    button.setAttribute('aria-pressed', String(input.checked))
+    button.addEventListener('click', () => {
+      input.checked = !input.checked
+      input.dispatchEvent(new Event('change'))
+    })
+  }
+
+  sync() {
+    document.querySelectorAll('[data-type="shortcut"]').forEach((b) => {
+      const input = this.tagInputs.get(
+        assertInstance(b, HTMLElement).dataset.tag ?? '',
+      )
+      b.setAttribute('aria-pressed', String(input?.checked ?? false))
+    })
+  }
+}
+
+class Suggestions {
+  /**
+   * @typedef suggestion
+   * @property {string} tag
+   * @property {string} group
+   * @property {number} score
+   */
+
+  /** @type HTMLElement */
+  #section
+  /** @type HTMLUListElement */
+  #list
+  /** @type TagShortcuts */
+  #shortcuts
+  /** @type AbortController | null */
+  #pending = null
+  /** @type number | undefined */
+  #timeout
+
+  /**
+   * @param {HTMLElement} section
+   * @param {TagShortcuts} shortcuts
+   */
+  constructor(section, shortcuts) {
+    this.#section = section
+    this.#list = assertInstance(section.querySelector('ul'), HTMLUListElement)
+    this.#shortcuts = shortcuts
+  }
+
+  /** @param {string[]} selectedTags group::tag pairs */
+  update(selectedTags) {
+    clearTimeout(this.#timeout)
+    this.#timeout = setTimeout(() => this.#fetch(selectedTags), 150)
+  }
+
+  /** @param {string[]} selectedTags */
+  async #fetch(selectedTags) {
+    this.#pending?.abort()
+    if (selectedTags.length === 0) {
+      this.#render([])
+      return
+    }
+    const pending = new AbortController()
+    this.#pending = pending
+    const query = new URLSearchParams()
+    selectedTags.forEach((el) => query.append('tag', el.split('::')[1]))
+    try {
+      const res = await fetch(`/suggestions?${query}`, {
+        headers: { Authorization: `Bearer ${token}` },
+        signal: pending.signal,
+      })
+      if (!res.ok) {
+        throw new Error(`suggestions request failed with ${res.status}`)
+      }
+      /** @type {{suggestions: suggestion[]}} */
+      const body = await res.json()
+      this.#render(body.suggestions)
+    } catch (e) {
+      if (!pending.signal.aborted) {
+        console.error(e)
+      }
+    }
+  }
+
+  /** @param {suggestion[]} suggestions */
+  #render(suggestions) {
+    const items = suggestions
+      .filter((s) => this.#shortcuts.tagInputs.get(s.tag)?.checked === false)
+      .map((s) => this.#shortcuts.create(s.tag))
+    this.#list.replaceChildren(...items)
+    this.#section.hidden = items.length === 0
+  }
+}
+
+class Search {
+  /**
+   * @typedef searchResult
+   * @property {string} tag
+   * @property {string} group
+   * @property {string} match
+   * @property {string} [alias]
+   * @property {number} usage
+   */
+
+  /** @type HTMLElement */
+  #main
+  /** @type HTMLElement */
+  #section
+  /** @type HTMLUListElement */
+  #list
+  /** @type TagShortcuts */
+  #shortcuts
+  /** @type AbortController | null */
+  #pending = null
+  /** @type number | undefined */
+  #timeout
+
+  /**
+   * @param {HTMLElement} main
+   * @param {HTMLElement} section
+   * @param {TagShortcuts} shortcuts
+   */
+  constructor(main, section, shortcuts) {
+    this.#main = main
+    this.#section = section
+    this.#list = assertInstance(section.querySelector('ul'), HTMLUListElement)
+    this.#shortcuts = shortcuts
+  }
+
+  /** @param {string} query */
+  update(query) {
+    clearTimeout(this.#timeout)
+    this.#timeout = setTimeout(() => this.#fetch(query.trim()), 150)
+  }
+
+  /** @param {string} query */
+  async #fetch(query) {
+    this.#pending?.abort()
+    if (query === '') {
+      this.#render(null)
+      return
+    }
+    const pending = new AbortController()
+    this.#pending = pending
+    try {
+      const res = await fetch(
+        `/search?${new URLSearchParams({ q: query, limit: '100' })}`,
+        {
+          headers: { Authorization: `Bearer ${token}` },
+          signal: pending.signal,
+        },
+      )
+      if (!res.ok) {
+        throw new Error(`search request failed with ${res.status}`)
+      }
+      /** @type {{results: searchResult[]}} */
+      const body = await res.json()
+      this.#render(body.results)
+    } catch (e) {
+      if (!pending.signal.aborted) {
+        console.error(e)
+      }
+    }
+  }
+
+  /** @param {searchResult[] | null} results null clears the search */
+  #render(results) {
+    const matched = new Set(results?.map((r) => r.tag))
+    this.#main.classList.toggle('searching', results !== null)
+    this.#list.replaceChildren(
+      ...(results ?? []).map((r) => this.#shortcuts.create(r.tag)),
+    )
+    this.#section.hidden = !results?.length
+    this.#main.querySelectorAll('.group:not(.shortcuts)').forEach((group) => {
+      let visible = 0
+      group.querySelectorAll('input[data-type="tag"]').forEach((t) => {
+        const input = assertInstance(t, HTMLInputElement)
+        const li = assertInstance(input.closest('li'), HTMLLIElement)
+        li.hidden = results !== null && !matched.has(input.name)
+        visible += li.hidden ? 0 : 1
+      })
+      assertInstance(group, HTMLElement).hidden = visible === 0
+      group.classList.toggle('search-open', results !== null)
+    })
+  }
+}
+
+class Favorites {
+  /** @type HTMLElement */
+  #section
+  /** @type HTMLUListElement */
+  #list
+  /** @type TagShortcuts */
+  #shortcuts
+  /** @type Set<string> */
+  #tags
+
+  /**
+   * @param {HTMLElement} section
+   * @param {TagShortcuts} shortcuts
+   */
+  constructor(section, shortcuts) {
+    this.#section = section
+    this.#list = assertInstance(section.querySelector('ul'), HTMLUListElement)
+    this.#shortcuts = shortcuts
+    this.#tags = new Set(
+      [...this.#list.querySelectorAll('[data-type="shortcut"]')].map(
+        (b) => assertInstance(b, HTMLElement).dataset.tag ?? '',
+      ),
+    )
+    this.#markTags()
+  }
+
+  /** @param {string[]} tags */
+  set(tags) {
+    this.#tags = new Set(tags)
+    this.#render()
+  }
+
+  /** @param {string} tag */
+  async toggle(tag) {
+    try {
+      const res = await fetch('/favorites', {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+          'X-Telegram-Init-Data': Telegram.WebApp.initData,
+        },
+        body: JSON.stringify({ tag, favorite: !this.#tags.has(tag) }),
+      })
+      if (!res.ok) {
+        throw new Error(`favorites request failed with ${res.status}`)
+      }
+      /** @type {{favorites: string[]}} */
+      const body = await res.json()
+      this.#tags = new Set(body.favorites)
+      this.#render()
+      Telegram.WebApp.HapticFeedback.selectionChanged()
+    } catch (e) {
+      console.error(e)
+    }
+  }
+
+  #render() {
+    const items = [...this.#tags]
+      .filter((tag) => this.#shortcuts.tagInputs.has(tag))
+      .map((tag) => this.#shortcuts.create(tag))
+    this.#list.replaceChildren(...items)
+    this.#section.hidden = items.length === 0
+    this.#markTags()
+  }
+
+  #markTags() {
+    this.#shortcuts.tagInputs.forEach((input, tag) => {
+      input.closest('.tag')?.classList.toggle('favorite', this.#tags.has(tag))
+    })
+  }
+}
+
+/**
+ * Loads favorite and recent tags, which need the signed init data of the
+ * admin and are not rendered with the page.
+ * @param {Favorites} favorites
+ * @param {HTMLElement} recent
+ * @param {TagShortcuts} shortcuts
+ */
+async function loadShortcuts(favorites, recent, shortcuts) {
+  try {
+    const res = await fetch('/shortcuts', {
+      headers: { 'X-Telegram-Init-Data': Telegram.WebApp.initData },
+    })
+    if (!res.ok) {
+      throw new Error(`shortcuts request failed with ${res.status}`)
+    }
+    /** @type {{favorites: string[], recent: string[]}} */
+    const body = await res.json()
+    favorites.set(body.favorites)
+    const items = body.recent
+      .filter((tag) => shortcuts.tagInputs.has(tag))
+      .map((tag) => shortcuts.create(tag))
+    const list = assertInstance(recent.querySelector('ul'), HTMLUListElement)
+    list.replaceChildren(...items)
+    recent.hidden = items.length === 0
+  } catch (e) {
+    console.error(e)
+  }
+}
+
+/**
+ * Swaps the add button for a text field and creates the typed tag in the
+ * button's group once submitted.
+ * @param {HTMLButtonElement} button
+ * @param {(input: HTMLInputElement) => void} onCreated
+ */
+function promptNewTag(button, onCreated) {
+  const group = button.dataset.group ?? ''
+  const li = assertInstance(button.closest('li'), HTMLLIElement)
+  const field = document.createElement('input')
+  field.type = 'text'
+  field.placeholder = '#new_tag'
+  field.enterKeyHint = 'done'
+  let submitting = false
+  const restore = () => field.replaceWith(button)
+  field.addEventListener('blur', () => !submitting && restore())
+  field.addEventListener('keydown', async (e) => {
+    if (e.key === 'Escape') {
+      restore()
+      return
+    }
+    if (e.key !== 'Enter' || submitting) {
+      return
+    }
+    e.preventDefault()
+    let name = field.value.trim()
+    if (name !== '' && !name.startsWith('#')) {
+      name = `#${name}`
+    }
+    submitting = true
+    try {
+      const res = await fetch('/tags', {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+          'X-Telegram-Init-Data': Telegram.WebApp.initData,
+        },
+        body: JSON.stringify({ group, tag: name }),
+      })
+      if (!res.ok) {
+        Telegram.WebApp.showAlert(await res.text(), () => {
+          submitting = false
+          field.focus()
+        })
+        return
+      }
+      /** @type {{group: string, tag: string}} */
+      const created = await res.json()
+      const input = document.createElement('input')
+      input.dataset.type = 'tag'
+      input.name = created.tag
+      input.type = 'checkbox'
+      input.hidden = true
+      const label = document.createElement('label')
+      label.className = 'tag'
+      label.append(created.tag, input)
+      const item = document.createElement('li')
+      item.append(label)
+      li.before(item)
+      restore()
+      onCreated(input)
+    } catch (e) {
+      console.error(e)
+      submitting = false
+    }
+  })
+  button.replaceWith(field)
+  field.focus()
+}
+
 class StringSet {
   /** @type Set<string> */
   #selected
@@ -130,57 +582,73 @@ class StringSet {
   }
 }
 
+/** @param {string} post */
+async function publish(post) {
+  const res = await fetch('/posts', {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+      'X-Telegram-Init-Data': Telegram.WebApp.initData,
+    },
+    body: post,
+  })
+  if (!res.ok) {
+    throw new Error((await res.text()).trim() || `status ${res.status}`)
+  }
+}
+
 class Persistence {
   /**
    * @typedef persisted
-   * @property {string} messageId
    * @property {string[]} openedGroups
    * @property {string[]} selectedTags
    * @property {number} scrollY
    */
 
-  #key = 'ratatosrk-persistant-tags'
+  #url
+  /** @type {number | undefined} */
+  #pending
   /** @type persisted */
   session
 
-  /** @param {string} messageId */
-  constructor(messageId) {
-    this.session = this.#getLastSession(messageId)
-  }
-
   /**
-   * @param {string} messageId
-   * @returns {persisted}
+   * @param {string} sessionId
+   * @param {persisted} session
    */
-  #newSession(messageId) {
-    return {
-      messageId: messageId,
-      openedGroups: [],
-      selectedTags: [],
-      scrollY: 0,
-    }
+  constructor(sessionId, session) {
+    this.#url = `/drafts/${encodeURIComponent(sessionId)}`
+    this.session = session
   }
 
   /**
-   * @param {string} messageId
-   * @returns {persisted}
+   * Loads the draft saved on the server, so a selection started on another
+   * device is picked up.
+   * @param {string} sessionId
    */
-  #getLastSession(messageId) {
-    const stored = localStorage.getItem(this.#key)
-    if (!stored) {
-      return this.#newSession(messageId)
-    }
+  static async load(sessionId) {
     /** @type persisted */
-    const parsed = JSON.parse(stored)
-    if (
-      typeof parsed !== 'object' ||
-      parsed.messageId !== messageId ||
-      !Array.isArray(parsed.selectedTags) ||
-      parsed.selectedTags.some((el) => typeof el !== 'string')
-    ) {
-      return this.#newSession(messageId)
+    const empty = { openedGroups: [], selectedTags: [], scrollY: 0 }
+    try {
+      const res = await fetch(`/drafts/${encodeURIComponent(sessionId)}`, {
+        headers: { 'X-Telegram-Init-Data': Telegram.WebApp.initData },
+      })
+      if (!res.ok) {
+        throw new Error(`status ${res.status}`)
+      }
+      /** @type persisted */
+      const draft = await res.json()
+      if (
+        !Array.isArray(draft.openedGroups) ||
+        !Array.isArray(draft.selectedTags) ||
+        draft.selectedTags.some((el) => typeof el !== 'string')
+      ) {
+        throw new Error('invalid draft')
+      }
+      return new Persistence(sessionId, draft)
+    } catch (e) {
+      console.error('failed to load draft', e)
+      return new Persistence(sessionId, empty)
     }
-    return parsed
   }
 
   /**
@@ -190,12 +658,26 @@ class Persistence {
    */
   update(key, value) {
     this.session[key] = value
-    this.#write(this.session)
+    clearTimeout(this.#pending)
+    this.#pending = setTimeout(() => this.flush(), 500)
   }
 
-  /** @param {persisted} data */
-  #write(data) {
-    localStorage.setItem(this.#key, JSON.stringify(data))
+  /** Saves pending changes right away, e.g. when the webapp is hidden. */
+  flush() {
+    if (this.#pending === undefined) {
+      return
+    }
+    clearTimeout(this.#pending)
+    this.#pending = undefined
+    fetch(this.#url, {
+      method: 'PUT',
+      keepalive: true,
+      headers: {
+        'Content-Type': 'application/json',
+        'X-Telegram-Init-Data': Telegram.WebApp.initData,
+      },
+      body: JSON.stringify(this.session),
+    }).catch((e) => console.error('failed to save draft', e))
   }
 }
 
@@ -216,6 +698,52 @@ function assertInstance(obj, type) {
   throw new Error(`Object ${obj} does not have the right type '${type}'!`)
 }
 
+/**
+ * Calls callback when an element matching selector inside root is held, and
+ * swallows the click that follows releasing it.
+ * @param {HTMLElement} root
+ * @param {string} selector
+ * @param {(element: HTMLElement) => void} callback
+ */
+function onLongPress(root, selector, callback) {
+  const delay = 500
+  /** @type number | undefined */
+  let timeout
+  let pressed = false
+  const cancel = () => clearTimeout(timeout)
+  root.addEventListener('pointerdown', (e) => {
+    const target = e.target instanceof Element ? e.target.closest(selector) : null
+    if (!(target instanceof HTMLElement)) {
+      return
+    }
+    pressed = false
+    timeout = setTimeout(() => {
+      pressed = true
+      callback(target)
+    }, delay)
+  })
+  root.addEventListener('pointerup', cancel)
+  root.addEventListener('pointercancel', cancel)
+  root.addEventListener('pointerleave', cancel)
+  root.addEventListener('contextmenu', (e) => {
+    if (e.target instanceof Element && e.target.closest(selector)) {
+      e.preventDefault()
+    }
+  })
+  root.addEventListener(
+    'click',
+    (e) => {
+      if (!pressed) {
+        return
+      }
+      pressed = false
+      e.preventDefault()
+      e.stopPropagation()
+    },
+    true,
+  )
+}
+
 /**
  * @template T
  * @param {(...args: T[]) => any} func
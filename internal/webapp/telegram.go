@@ -0,0 +1,84 @@
+package webapp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const initDataMaxAge = time.Hour * 24
+
+// validateInitData checks Telegram.WebApp.initData signed with the bot token
+// as described in https://core.telegram.org/bots/webapps and returns the id
+// of the user who opened the webapp.
+func validateInitData(
+	initData string,
+	botToken string,
+	now time.Time,
+) (int64, error) {
+	values, err := url.ParseQuery(initData)
+	if err != nil {
+		return 0, fmt.Errorf("malformed init data: %w", err)
+	}
+	hash := values.Get("hash")
+	if hash == "" {
+		return 0, fmt.Errorf("init data is not signed")
+	}
+	pairs := []string{}
+	for key := range values {
+		if key == "hash" {
+			continue
+		}
+		pairs = append(pairs, key+"="+values.Get(key))
+	}
+	sort.Strings(pairs)
+	if !hmac.Equal([]byte(signInitData(strings.Join(pairs, "\n"), botToken)), []byte(hash)) {
+		return 0, fmt.Errorf("init data signature mismatch")
+	}
+	authDate, err := strconv.ParseInt(values.Get("auth_date"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("init data has invalid auth_date")
+	}
+	if now.Sub(time.Unix(authDate, 0)) > initDataMaxAge {
+		return 0, fmt.Errorf("init data expired")
+	}
+	var user struct {
+		ID int64 `json:"id"`
+	}
+	err = json.Unmarshal([]byte(values.Get("user")), &user)
+	if err != nil || user.ID == 0 {
+		return 0, fmt.Errorf("init data has no user")
+	}
+	return user.ID, nil
+}
+
+// initDataQueryID returns query_id of already validated init data. It is only
+// set when the webapp was opened from an inline keyboard button.
+func initDataQueryID(r *http.Request) string {
+	values, err := url.ParseQuery(r.Header.Get("X-Telegram-Init-Data"))
+	if err != nil {
+		return ""
+	}
+	return values.Get("query_id")
+}
+
+func signInitData(dataCheckString string, botToken string) string {
+	secret := hmac.New(sha256.New, []byte("WebAppData"))
+	secret.Write([]byte(botToken))
+	sign := hmac.New(sha256.New, secret.Sum(nil))
+	sign.Write([]byte(dataCheckString))
+	return hex.EncodeToString(sign.Sum(nil))
+}
+
+func isAdmin(adminIDs []int64, userID int64) bool {
+	return slices.Contains(adminIDs, userID)
+}
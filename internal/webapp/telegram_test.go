@@ -0,0 +1,97 @@
+package webapp
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signedInitData(values url.Values, botToken string) string {
+	signed := url.Values{}
+	pairs := []string{}
+	for key := range values {
+		signed.Set(key, values.Get(key))
+		pairs = append(pairs, key+"="+values.Get(key))
+	}
+	sort.Strings(pairs)
+	signed.Set("hash", signInitData(strings.Join(pairs, "\n"), botToken))
+	return signed.Encode()
+}
+
+func TestValidateInitData(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	valid := url.Values{
+		"auth_date": {"1704164645"},
+		"query_id":  {"AAE"},
+		"user":      {`{"id":1234,"first_name":"Admin"}`},
+	}
+
+	type tc struct {
+		name     string
+		initData string
+		expected int64
+		err      bool
+	}
+
+	table := []tc{
+		{
+			name:     "should accept data signed with bot token",
+			initData: signedInitData(valid, "TOKEN"),
+			expected: 1234,
+		},
+
+		{
+			name:     "should reject data signed with other token",
+			initData: signedInitData(valid, "OTHER"),
+			err:      true,
+		},
+
+		{
+			name:     "should reject tampered data",
+			initData: signedInitData(valid, "TOKEN") + "&extra=1",
+			err:      true,
+		},
+
+		{
+			name: "should reject expired data",
+			initData: signedInitData(url.Values{
+				"auth_date": {"1700000000"},
+				"user":      {`{"id":1234}`},
+			}, "TOKEN"),
+			err: true,
+		},
+
+		{
+			name:     "should reject data without user",
+			initData: signedInitData(url.Values{"auth_date": {"1704164645"}}, "TOKEN"),
+			err:      true,
+		},
+
+		{
+			name:     "should reject unsigned data",
+			initData: "auth_date=1704164645",
+			err:      true,
+		},
+	}
+
+	for _, test := range table {
+		actual, err := validateInitData(test.initData, "TOKEN", now)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s - expected error, got user %d", test.name, actual)
+			}
+			continue
+		}
+		if err != nil || actual != test.expected {
+			t.Errorf(
+				"%s\nexpected: %+v\nactual:   %+v, error: %v",
+				test.name,
+				test.expected,
+				actual,
+				err,
+			)
+		}
+	}
+}